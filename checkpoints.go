@@ -1,7 +1,17 @@
 package focalpoint
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/sha3"
 )
 
 // CheckpointsEnabled can be disabled for testing.
@@ -12,12 +22,18 @@ const LatestCheckpointHeight = 0
 
 // Checkpoints are known height and view ID pairs on the main point.
 var Checkpoints map[int64]string = map[int64]string{
-	
+
 }
 
 // CheckpointCheck returns an error if the passed height is a checkpoint and the
 // passed view ID does not match the given checkpoint view ID.
 func CheckpointCheck(id ViewID, height int64) error {
+	// signed checkpoints are always enforced
+	if cp := getSignedCheckpoint(height); cp != nil && cp.ViewID != id {
+		return fmt.Errorf("View %s at height %d does not match signed checkpoint ID %s",
+			id, height, cp.ViewID)
+	}
+
 	if !CheckpointsEnabled {
 		return nil
 	}
@@ -31,3 +47,182 @@ func CheckpointCheck(id ViewID, height int64) error {
 	}
 	return nil
 }
+
+// SignedCheckpoint is a checkpoint announced over the network by the holder of a trusted key.
+type SignedCheckpoint struct {
+	Height    int64             `json:"height"`
+	ViewID    ViewID            `json:"view_id"`
+	PublicKey ed25519.PublicKey `json:"public_key"`
+	Signature Signature         `json:"signature,omitempty"`
+}
+
+// NewSignedCheckpoint returns a new checkpoint signed with the given private key.
+func NewSignedCheckpoint(id ViewID, height int64, privKey ed25519.PrivateKey) (*SignedCheckpoint, error) {
+	cp := &SignedCheckpoint{
+		Height:    height,
+		ViewID:    id,
+		PublicKey: privKey.Public().(ed25519.PublicKey),
+	}
+	hash, err := cp.hash()
+	if err != nil {
+		return nil, err
+	}
+	cp.Signature = ed25519.Sign(privKey, hash[:])
+	return cp, nil
+}
+
+// Verify returns true if the checkpoint is properly signed by its public key.
+func (cp SignedCheckpoint) Verify() (bool, error) {
+	if len(cp.PublicKey) != ed25519.PublicKeySize {
+		return false, nil
+	}
+	hash, err := cp.hash()
+	if err != nil {
+		return false, err
+	}
+	return ed25519.Verify(cp.PublicKey, hash[:], cp.Signature), nil
+}
+
+// Compute the hash that is signed. Never include the signature
+func (cp SignedCheckpoint) hash() ([32]byte, error) {
+	cp.Signature = nil
+	cpJson, err := json.Marshal(cp)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha3.Sum256(cpJson), nil
+}
+
+var signedCheckpointsLock sync.RWMutex
+
+var signedCheckpoints = make(map[int64]*SignedCheckpoint)
+
+var trustedCheckpointKeys []ed25519.PublicKey
+
+var signedCheckpointsPath string
+
+// LoadSignedCheckpoints sets the keys trusted to sign checkpoints and loads any previously accepted
+// signed checkpoints from the given file. Newly accepted checkpoints are saved to the same file.
+func LoadSignedCheckpoints(path string, trustedKeys []ed25519.PublicKey) error {
+	signedCheckpointsLock.Lock()
+	defer signedCheckpointsLock.Unlock()
+
+	trustedCheckpointKeys = trustedKeys
+	signedCheckpointsPath = path
+
+	cpsJson, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cps []*SignedCheckpoint
+	if err := json.Unmarshal(cpsJson, &cps); err != nil {
+		return err
+	}
+	for _, cp := range cps {
+		// they may have since stopped trusting the signer
+		if err := checkSignedCheckpoint(cp); err != nil {
+			log.Printf("Ignoring saved checkpoint: %s\n", err)
+			continue
+		}
+		signedCheckpoints[cp.Height] = cp
+	}
+	return nil
+}
+
+// AddSignedCheckpoint verifies and persists a signed checkpoint.
+// It returns false if the checkpoint was already known.
+func AddSignedCheckpoint(cp *SignedCheckpoint) (bool, error) {
+	signedCheckpointsLock.Lock()
+	defer signedCheckpointsLock.Unlock()
+
+	if err := checkSignedCheckpoint(cp); err != nil {
+		return false, err
+	}
+
+	if existing, ok := signedCheckpoints[cp.Height]; ok {
+		if existing.ViewID != cp.ViewID {
+			return false, fmt.Errorf("Conflicting signed checkpoint at height %d", cp.Height)
+		}
+		return false, nil
+	}
+	signedCheckpoints[cp.Height] = cp
+
+	if len(signedCheckpointsPath) == 0 {
+		return true, nil
+	}
+	if err := saveSignedCheckpoints(); err != nil {
+		delete(signedCheckpoints, cp.Height)
+		return false, err
+	}
+	return true, nil
+}
+
+// GetLatestSignedCheckpoint returns the highest accepted signed checkpoint, if any.
+func GetLatestSignedCheckpoint() *SignedCheckpoint {
+	signedCheckpointsLock.RLock()
+	defer signedCheckpointsLock.RUnlock()
+	var latest *SignedCheckpoint
+	for _, cp := range signedCheckpoints {
+		if latest == nil || cp.Height > latest.Height {
+			latest = cp
+		}
+	}
+	return latest
+}
+
+// Return the signed checkpoint at the given height, if any
+func getSignedCheckpoint(height int64) *SignedCheckpoint {
+	signedCheckpointsLock.RLock()
+	defer signedCheckpointsLock.RUnlock()
+	return signedCheckpoints[height]
+}
+
+// Check a signed checkpoint is signed by a trusted key. Caller must hold the lock
+func checkSignedCheckpoint(cp *SignedCheckpoint) error {
+	if cp.Height <= 0 || cp.Height > MAX_NUMBER {
+		return fmt.Errorf("Invalid signed checkpoint height %d", cp.Height)
+	}
+	var trusted bool
+	for _, pubKey := range trustedCheckpointKeys {
+		if bytes.Equal(pubKey, cp.PublicKey) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return fmt.Errorf("Checkpoint at height %d is not signed by a trusted key", cp.Height)
+	}
+	ok, err := cp.Verify()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Checkpoint at height %d has an invalid signature", cp.Height)
+	}
+	return nil
+}
+
+// Write all signed checkpoints out to disk. Caller must hold the lock
+func saveSignedCheckpoints() error {
+	var cps []*SignedCheckpoint
+	for _, cp := range signedCheckpoints {
+		cps = append(cps, cp)
+	}
+	sort.Slice(cps, func(i, j int) bool {
+		return cps[i].Height < cps[j].Height
+	})
+	cpsJson, err := json.MarshalIndent(cps, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so we never leave a partial file behind
+	tmpPath := signedCheckpointsPath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, cpsJson, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, signedCheckpointsPath)
+}
@@ -0,0 +1,91 @@
+package focalpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSignedCheckpoint(t *testing.T) {
+	_, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := NewSignedCheckpoint(ViewID{0x01}, 1234, privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := cp.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Verification failed")
+	}
+
+	// changing the checkpoint invalidates the signature
+	cp.Height++
+	ok, err = cp.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Verification succeeded")
+	}
+}
+
+func TestLoadSignedCheckpointsUntrustedKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() {
+		signedCheckpoints = make(map[int64]*SignedCheckpoint)
+		trustedCheckpointKeys = nil
+		signedCheckpointsPath = ""
+	}()
+
+	pubKey1, privKey1, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey2, privKey2, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// accept a checkpoint from each of two trusted keys
+	path := filepath.Join(dir, "checkpoints.json")
+	if err := LoadSignedCheckpoints(path, []ed25519.PublicKey{pubKey1, pubKey2}); err != nil {
+		t.Fatal(err)
+	}
+	cp1, err := NewSignedCheckpoint(ViewID{0x01}, 10, privKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp2, err := NewSignedCheckpoint(ViewID{0x02}, 20, privKey2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cp := range []*SignedCheckpoint{cp1, cp2} {
+		if _, err := AddSignedCheckpoint(cp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// stop trusting the second key. its checkpoint is skipped rather than failing the load
+	signedCheckpoints = make(map[int64]*SignedCheckpoint)
+	if err := LoadSignedCheckpoints(path, []ed25519.PublicKey{pubKey1}); err != nil {
+		t.Fatal(err)
+	}
+	if getSignedCheckpoint(10) == nil {
+		t.Fatal("Expected checkpoint from the trusted key")
+	}
+	if getSignedCheckpoint(20) != nil {
+		t.Fatal("Expected checkpoint from the removed key to be skipped")
+	}
+}
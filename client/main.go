@@ -8,14 +8,15 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
 	. "github.com/inconsiderable/focal-point"
+	"github.com/inconsiderable/focal-point/internal/config"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -25,7 +26,6 @@ func main() {
 
 	// flags
 	pubKeyPtr := flag.String("pubkey", "", "A public key which receives newly rendered view points")
-	dataDirPtr := flag.String("datadir", "", "Path to a directory to save focal point data")
 	memoPtr := flag.String("memo", "", "A memo to include in newly rendered views")
 	portPtr := flag.Int("port", DEFAULT_FOCALPOINT_PORT, "Port to listen for incoming peer connections")
 	peerPtr := flag.String("peer", "", "Address of a peer to connect to")
@@ -37,27 +37,51 @@ func main() {
 	noAcceptPtr := flag.Bool("noaccept", false, "Disable inbound peer connections")
 	prunePtr := flag.Bool("prune", false, "Prune consideration and public key consideration indices")
 	keyFilePtr := flag.String("keyfile", "", "Path to a file containing public keys to use when rendering")
-	tlsCertPtr := flag.String("tlscert", "", "Path to a file containing a PEM-encoded X.509 certificate to use with TLS")
-	tlsKeyPtr := flag.String("tlskey", "", "Path to a file containing a PEM-encoded private key to use with TLS")
 	inLimitPtr := flag.Int("inlimit", MAX_INBOUND_PEER_CONNECTIONS, "Limit for the number of inbound peer connections.")
 	banListPtr := flag.String("banlist", "", "Path to a file containing a list of banned host addresses")
+	eventSocketPtr := flag.String("eventsocket", "", "Path to a unix socket on which to publish node events")
+	exportDirPtr := flag.String("exportdir", "", "Path to a directory to periodically export public key imbalances as CSV")
+	exportIntervalPtr := flag.Int("exportinterval", 1000, "Number of views between imbalance exports")
+	exportRankingsPtr := flag.Bool("exportrankings", false, "Include public key rankings in imbalance exports")
+	backupDirPtr := flag.String("backupdir", "", "Path to a directory to periodically back up the ledger and header databases")
+	backupIntervalPtr := flag.Int("backupinterval", 144, "Number of views between database backups")
+	backupKeepPtr := flag.Int("backupkeep", 3, "Number of database backups to keep")
+	checkpointKeysPtr := flag.String("checkpointkeys", "", "Path to a file containing public keys trusted to sign checkpoints")
+	mindStatePtr := flag.Bool("mindstate", false, "Store encrypted mind state for minds syncing across devices")
+	publicPtr := flag.Bool("public", false, "Serve mind queries to public connections subject to per-host quotas")
+	watchlistPtr := flag.String("watchlist", "", "Path to a file containing public keys whose activity to report")
+	watchHookPtr := flag.String("watchhook", "", "URL to post watchlist events to as JSON")
+	peerRetentionPtr := flag.Int("peerretention", DEFAULT_PEER_RETENTION_DAYS,
+		"Number of days after which stored peer addresses we haven't seen are forgotten, 0 to keep them")
+	maxStoredPeersPtr := flag.Int("maxstoredpeers", DEFAULT_MAX_STORED_PEERS,
+		"Maximum number of peer addresses to store, 0 for no limit")
+	metricsAddrPtr := flag.String("metricsaddr", "",
+		"Address to serve rendering metrics on for Prometheus, e.g. 127.0.0.1:9832")
+	cfg := config.Register(flag.CommandLine, config.DataDir|config.Network|config.TLSServer|config.Logging)
 	flag.Parse()
 
-	if len(*dataDirPtr) == 0 {
-		log.Fatal("-datadir argument required")
+	// switch networks before doing anything else
+	if err := cfg.Apply(); err != nil {
+		log.Fatal(err)
 	}
-	if len(*tlsCertPtr) != 0 && len(*tlsKeyPtr) == 0 {
-		log.Fatal("-tlskey argument missing")
+	if cfg.NetworkParams != nil && !cfg.IsSet("port") {
+		*portPtr = DEFAULT_FOCALPOINT_PORT
 	}
-	if len(*tlsCertPtr) == 0 && len(*tlsKeyPtr) != 0 {
-		log.Fatal("-tlscert argument missing")
+
+	if len(*watchHookPtr) != 0 && len(*watchlistPtr) == 0 {
+		log.Fatal("-watchhook requires -watchlist")
+	}
+
+	if len(*exportDirPtr) != 0 && *exportIntervalPtr <= 0 {
+		log.Fatal("-exportinterval must be positive")
+	}
+
+	if len(*backupDirPtr) != 0 && (*backupIntervalPtr <= 0 || *backupKeepPtr <= 0) {
+		log.Fatal("-backupinterval and -backupkeep must be positive")
 	}
 
 	if len(*peerPtr) != 0 {
-		// add default port, if one was not supplied
-		if i := strings.LastIndex(*peerPtr, ":"); i < 0 {
-			*peerPtr = *peerPtr + ":" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
-		}
+		*peerPtr = config.WithDefaultPort(*peerPtr)
 	}
 
 	// load any ban list
@@ -86,6 +110,23 @@ func main() {
 		}
 	}
 
+	// upgrade the data directory layout or refuse to run against an incompatible one
+	if err := MigrateDatadir(cfg.DataDir); err != nil {
+		log.Fatal(err)
+	}
+
+	// load keys trusted to sign checkpoints and any checkpoints they've signed
+	if len(*checkpointKeysPtr) != 0 {
+		checkpointKeys, err := loadPublicKeys("", *checkpointKeysPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := LoadSignedCheckpoints(filepath.Join(cfg.DataDir, "checkpoints.json"), checkpointKeys); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Trusting %d checkpoint signing key(s)\n", len(checkpointKeys))
+	}
+
 	// load genesis view
 	genesisView := new(View)
 	if err := json.Unmarshal([]byte(GenesisViewJson), genesisView); err != nil {
@@ -105,8 +146,8 @@ func main() {
 
 	// instantiate storage
 	viewStore, err := NewViewStorageDisk(
-		filepath.Join(*dataDirPtr, "views"),
-		filepath.Join(*dataDirPtr, "headers.db"),
+		filepath.Join(cfg.DataDir, "views"),
+		filepath.Join(cfg.DataDir, "headers.db"),
 		false, // not read-only
 		*compressPtr,
 	)
@@ -115,7 +156,7 @@ func main() {
 	}
 
 	// instantiate the ledger
-	ledger, err := NewLedgerDisk(filepath.Join(*dataDirPtr, "ledger.db"),
+	ledger, err := NewLedgerDisk(filepath.Join(cfg.DataDir, "ledger.db"),
 		false, // not read-only
 		*prunePtr,
 		viewStore,
@@ -126,14 +167,55 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// indices pruned by an earlier run stay pruned
+	prunedHeight, err := ledger.GetPrunedHeight()
+	if err != nil {
+		ledger.Close()
+		viewStore.Close()
+		log.Fatal(err)
+	}
+	if prunedHeight != 0 && !*prunePtr {
+		log.Printf("Consideration indices were pruned below height %d, "+
+			"history queries below it will be answered from stored views\n", prunedHeight)
+	}
+
 	// instantiate peer storage
-	peerStore, err := NewPeerStorageDisk(filepath.Join(*dataDirPtr, "peers.db"))
+	peerStore, err := NewPeerStorageDisk(filepath.Join(cfg.DataDir, "peers.db"),
+		false, // read-only
+		time.Duration(*peerRetentionPtr)*24*time.Hour,
+		*maxStoredPeersPtr)
 	if err != nil {
 		ledger.Close()
 		viewStore.Close()
 		log.Fatal(err)
 	}
 
+	// seed it with the network's bootstrap peers if it's empty
+	if cfg.NetworkParams != nil && len(cfg.NetworkParams.BootstrapPeers) != 0 {
+		count, err := peerStore.Seed(cfg.NetworkParams.BootstrapPeers)
+		if err != nil {
+			peerStore.Close()
+			ledger.Close()
+			viewStore.Close()
+			log.Fatal(err)
+		}
+		if count != 0 {
+			log.Printf("Stored %d bootstrap peer address(es)\n", count)
+		}
+	}
+
+	// instantiate mind state storage
+	var mindStateStore MindStateStorage
+	if *mindStatePtr {
+		mindStateStore, err = NewMindStateStorageDisk(filepath.Join(cfg.DataDir, "mindstate.db"))
+		if err != nil {
+			peerStore.Close()
+			ledger.Close()
+			viewStore.Close()
+			log.Fatal(err)
+		}
+	}
+
 	// instantiate the consideration queue
 	cnQueue := NewConsiderationQueueMemory(ledger, conGraph)
 
@@ -150,13 +232,61 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// publish node events
+	var eventFeed *EventFeed
+	if len(*eventSocketPtr) != 0 {
+		eventFeed = NewEventFeed(*eventSocketPtr, processor)
+		if err := eventFeed.Run(); err != nil {
+			processor.Shutdown()
+			peerStore.Close()
+			ledger.Close()
+			viewStore.Close()
+			log.Fatal(err)
+		}
+	}
+
 	indexer := NewIndexer(conGraph, viewStore, ledger, processor, genesisID)
 	indexer.Run()
 
+	// report activity of watched public keys
+	var watchlist *Watchlist
+	if len(*watchlistPtr) != 0 {
+		watchKeys, err := loadPublicKeys("", *watchlistPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		watchlist = NewWatchlist(watchKeys, processor, indexer, eventFeed, *watchHookPtr)
+		watchlist.Run()
+		log.Printf("Watching %d public key(s)\n", len(watchKeys))
+	}
+
+	// periodically export imbalances
+	var imbalanceExporter *ImbalanceExporter
+	if len(*exportDirPtr) != 0 {
+		var rankings *Indexer
+		if *exportRankingsPtr {
+			rankings = indexer
+		}
+		imbalanceExporter = NewImbalanceExporter(*exportDirPtr, int64(*exportIntervalPtr), ledger, processor, rankings)
+		if err := imbalanceExporter.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// periodically back up the databases
+	var databaseBackup *DatabaseBackup
+	if len(*backupDirPtr) != 0 {
+		databaseBackup = NewDatabaseBackup(*backupDirPtr, int64(*backupIntervalPtr), *backupKeepPtr,
+			ledger, viewStore, processor)
+		if err := databaseBackup.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	var renderers []*Renderer
 	var hashrateMonitor *HashrateMonitor
 	if *numRenderersPtr > 0 {
-		hashUpdateChan := make(chan int64, *numRenderersPtr)
+		hashUpdateChan := make(chan HashUpdate, *numRenderersPtr)
 		// create and run renderers
 		for i := 0; i < *numRenderersPtr; i++ {
 			renderer := NewRenderer(pubKeys, *memoPtr, viewStore, cnQueue, ledger, processor, hashUpdateChan, i)
@@ -170,6 +300,29 @@ func main() {
 		log.Println("Rendering is currently disabled")
 	}
 
+	// serve rendering metrics
+	var metricsServer *http.Server
+	if len(*metricsAddrPtr) != 0 {
+		if hashrateMonitor == nil {
+			log.Println("Not serving metrics, rendering is disabled")
+		} else {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+				if err := hashrateMonitor.WriteMetrics(w); err != nil {
+					log.Printf("Error writing metrics: %s\n", err)
+				}
+			})
+			metricsServer = &http.Server{Addr: *metricsAddrPtr, Handler: mux}
+			go func() {
+				log.Printf("Serving metrics on %s\n", *metricsAddrPtr)
+				if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Printf("Error serving metrics: %s\n", err)
+				}
+			}()
+		}
+	}
+
 	// start a dns server
 	var seeder *DNSSeeder
 	if *dnsSeedPtr {
@@ -191,9 +344,9 @@ func main() {
 	}
 
 	// manage peer connections
-	peerManager := NewPeerManager(genesisID, peerStore, viewStore, ledger, processor, indexer, cnQueue,
-		*dataDirPtr, myExternalIP, *peerPtr, *tlsCertPtr, *tlsKeyPtr,
-		*portPtr, *inLimitPtr, !*noAcceptPtr, !*noIrcPtr, *dnsSeedPtr, banMap)
+	peerManager := NewPeerManager(genesisID, peerStore, mindStateStore, viewStore, ledger, processor, indexer, cnQueue,
+		cfg.DataDir, myExternalIP, *peerPtr, cfg.TLSCert, cfg.TLSKey,
+		*portPtr, *inLimitPtr, !*noAcceptPtr, !*noIrcPtr, *dnsSeedPtr, *publicPtr, banMap, eventFeed, hashrateMonitor)
 	peerManager.Run()
 
 	// shutdown on ctrl-c
@@ -225,17 +378,41 @@ func main() {
 		for _, renderer := range renderers {
 			renderer.Shutdown()
 		}
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
 		if hashrateMonitor != nil {
 			hashrateMonitor.Shutdown()
 		}
-		
-		indexer.Shutdown()
+
+		// nothing else can submit work now. the processor finishes any view it's connecting
+		// and aborts anything queued behind it
 		processor.Shutdown()
 
-		// close storage
+		// these are shut down after the processor so they see every view it connected
+		if imbalanceExporter != nil {
+			imbalanceExporter.Shutdown()
+		}
+		if databaseBackup != nil {
+			databaseBackup.Shutdown()
+		}
+		if watchlist != nil {
+			watchlist.Shutdown()
+		}
+		indexer.Shutdown()
+		if eventFeed != nil {
+			eventFeed.Shutdown()
+		}
+
+		// close storage. the ledger reads from view storage so it's closed first
 		if err := peerStore.Close(); err != nil {
 			log.Println(err)
 		}
+		if mindStateStore != nil {
+			if err := mindStateStore.Close(); err != nil {
+				log.Println(err)
+			}
+		}
 		if err := ledger.Close(); err != nil {
 			log.Println(err)
 		}
@@ -0,0 +1,311 @@
+package focalpoint
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/sha3"
+)
+
+// ConsensusVectors is a set of consensus test vectors for a network. They allow alternative
+// implementations to check they accept and reject the same things we do.
+type ConsensusVectors struct {
+	Network string            `json:"network"`
+	Vectors []ConsensusVector `json:"vectors"`
+}
+
+// ConsensusVector is a consideration or a view and the expected outcome of validating it.
+// A consideration is checked for inclusion in the view following a tip at TipHeight.
+// A view is checked on its own with Now as the current time. Checks which need the rest of the
+// point, such as the view's target, median timestamp and imbalances, aren't covered.
+type ConsensusVector struct {
+	Name          string         `json:"name"`
+	Consideration *Consideration `json:"consideration,omitempty"`
+	TipHeight     int64          `json:"tip_height,omitempty"`
+	View          *View          `json:"view,omitempty"`
+	Now           int64          `json:"now,omitempty"`
+	Valid         bool           `json:"valid"`
+	Reason        string         `json:"reason,omitempty"` // why it's invalid
+	Error         string         `json:"error,omitempty"`  // our error message for it
+}
+
+// Validate runs the consensus checks covered by the vector and returns the resulting error, if any.
+func (v ConsensusVector) Validate() error {
+	if v.Consideration != nil {
+		id, err := v.Consideration.ID()
+		if err != nil {
+			return err
+		}
+		if err := checkConsideration(id, v.Consideration); err != nil {
+			return err
+		}
+		if v.Consideration.IsViewpoint() {
+			return fmt.Errorf("Viewpoint consideration %s only allowed in view", id)
+		}
+		return checkConsiderationForNextView(id, v.Consideration, v.TipHeight)
+	}
+	if v.View != nil {
+		id, err := v.View.ID()
+		if err != nil {
+			return err
+		}
+		if err := checkView(id, v.View, v.Now); err != nil {
+			return err
+		}
+		return checkViewConsiderations(v.View, nil)
+	}
+	return fmt.Errorf("Vector has neither a consideration nor a view")
+}
+
+// Check returns an error if validating the vector doesn't have the expected outcome.
+func (v ConsensusVector) Check() error {
+	err := v.Validate()
+	if v.Valid {
+		if err != nil {
+			return fmt.Errorf("Vector %s should be valid but failed with: %s", v.Name, err)
+		}
+		return nil
+	}
+	if err == nil {
+		return fmt.Errorf("Vector %s should be invalid (%s) but passed", v.Name, v.Reason)
+	}
+	if err.Error() != v.Error {
+		return fmt.Errorf("Vector %s failed with: %s, expected: %s", v.Name, err, v.Error)
+	}
+	return nil
+}
+
+// GenerateConsensusVectors returns test vectors for the active network's consensus rules.
+// Keys, times and nonces are fixed so the output is the same every time.
+func GenerateConsensusVectors(network string) (*ConsensusVectors, error) {
+	g := &vectorGenerator{now: 1600000000, tipHeight: 1000}
+	for i := range g.keys {
+		seed := sha3.Sum256([]byte(fmt.Sprintf("focalpoint consensus vector key %d", i)))
+		g.keys[i] = ed25519.NewKeyFromSeed(seed[:])
+	}
+
+	maxMemo := MemoPolicyAt(g.tipHeight + 1).MaxLength
+
+	// loose considerations
+	g.consideration("valid", "", func(cn *Consideration) {})
+	g.consideration("valid_max_memo", "", func(cn *Consideration) {
+		cn.Memo = strings.Repeat("m", maxMemo)
+	})
+	g.consideration("valid_matures_next_view", "", func(cn *Consideration) {
+		cn.Matures = g.tipHeight + 1
+	})
+	g.consideration("valid_expires_next_view", "", func(cn *Consideration) {
+		cn.Expires = g.tipHeight + 1
+	})
+	g.consideration("memo_too_long", "memo_policy", func(cn *Consideration) {
+		cn.Memo = strings.Repeat("m", maxMemo+1)
+	})
+	g.consideration("negative_time", "invalid_time", func(cn *Consideration) {
+		cn.Time = -1
+	})
+	g.consideration("negative_nonce", "invalid_nonce", func(cn *Consideration) {
+		cn.Nonce = -1
+	})
+	g.consideration("to_self", "to_self", func(cn *Consideration) {
+		cn.For = cn.By
+	})
+	g.consideration("missing_recipient", "missing_recipient", func(cn *Consideration) {
+		cn.For = nil
+	})
+	g.consideration("short_recipient", "invalid_recipient", func(cn *Consideration) {
+		cn.For = cn.For[:ed25519.PublicKeySize-1]
+	})
+	g.consideration("negative_maturity", "invalid_maturity", func(cn *Consideration) {
+		cn.Matures = -1
+	})
+	g.consideration("negative_expiration", "invalid_expiration", func(cn *Consideration) {
+		cn.Expires = -1
+	})
+	g.consideration("zero_series", "invalid_series", func(cn *Consideration) {
+		cn.Series = 0
+	})
+	g.consideration("future_series", "invalid_series", func(cn *Consideration) {
+		cn.Series = g.tipHeight/VIEWS_UNTIL_NEW_SERIES + 2
+	})
+	g.consideration("immature", "immature", func(cn *Consideration) {
+		cn.Matures = g.tipHeight
+	})
+	g.consideration("expired", "expired", func(cn *Consideration) {
+		cn.Expires = g.tipHeight
+	})
+	g.unsignedConsideration("missing_signature", "invalid_signature", func(cn *Consideration) {})
+	g.unsignedConsideration("wrong_signer", "signature_verification", func(cn *Consideration) {
+		cn.Sign(g.keys[2])
+	})
+	g.unsignedConsideration("loose_viewpoint", "loose_viewpoint", func(cn *Consideration) {
+		cn.By = nil
+	})
+
+	// views
+	g.view("valid_view", "", func(b *View) {})
+	g.view("valid_viewpoint_only", "", func(b *View) {
+		b.Considerations = b.Considerations[:1]
+		b.Header.ConsiderationCount = 1
+	})
+	g.view("future_timestamp", "future_timestamp", func(b *View) {
+		b.Header.Time = g.now + MAX_FUTURE_SECONDS + 1
+	})
+	g.view("insufficient_work", "insufficient_work", func(b *View) {
+		b.Header.Target = ViewID{}
+	})
+	g.view("negative_nonce", "invalid_nonce", func(b *View) {
+		b.Header.Nonce = -1
+	})
+	g.view("count_mismatch", "consideration_count", func(b *View) {
+		b.Header.ConsiderationCount++
+	})
+	g.view("no_considerations", "no_considerations", func(b *View) {
+		b.Considerations = nil
+		b.Header.ConsiderationCount = 0
+	})
+	g.view("first_not_viewpoint", "first_not_viewpoint", func(b *View) {
+		b.Considerations = b.Considerations[1:]
+		b.Header.ConsiderationCount = 1
+	})
+	g.view("multiple_viewpoints", "multiple_viewpoints", func(b *View) {
+		b.Considerations = append(b.Considerations, g.viewpoint(1))
+		b.Header.ConsiderationCount++
+	})
+	g.view("duplicate_consideration", "duplicate_consideration", func(b *View) {
+		b.Considerations = append(b.Considerations, b.Considerations[1])
+		b.Header.ConsiderationCount++
+	})
+	g.view("hash_list_root_mismatch", "hash_list_root", func(b *View) {
+		b.Header.HashListRoot = ConsiderationID{}
+	})
+	g.view("viewpoint_maturity", "viewpoint_maturity", func(b *View) {
+		b.Considerations[0].Matures = 2
+	})
+	g.view("viewpoint_series", "invalid_series", func(b *View) {
+		b.Considerations[0].Series = 2
+	})
+	g.view("bad_signature", "signature_verification", func(b *View) {
+		cn := g.signed(func(cn *Consideration) {})
+		cn.Sign(g.keys[2])
+		b.Considerations[1] = cn
+	})
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	// record the errors we return and make sure each vector turned out as intended
+	for i := range g.vectors {
+		v := &g.vectors[i]
+		err := v.Validate()
+		if v.Valid != (err == nil) {
+			return nil, fmt.Errorf("Vector %s generated with the wrong outcome, error: %v", v.Name, err)
+		}
+		if err != nil {
+			v.Error = err.Error()
+		}
+	}
+	return &ConsensusVectors{Network: network, Vectors: g.vectors}, nil
+}
+
+// Builds deterministic vectors
+type vectorGenerator struct {
+	keys      [3]ed25519.PrivateKey
+	now       int64
+	tipHeight int64
+	vectors   []ConsensusVector
+	err       error
+}
+
+// Returns a consideration from the first key to the second, signed after applying fn
+func (g *vectorGenerator) signed(fn func(cn *Consideration)) *Consideration {
+	cn := g.unsigned(fn)
+	if err := cn.Sign(g.keys[0]); err != nil && g.err == nil {
+		g.err = err
+	}
+	return cn
+}
+
+func (g *vectorGenerator) unsigned(fn func(cn *Consideration)) *Consideration {
+	cn := &Consideration{
+		Time:   g.now,
+		Nonce:  1,
+		By:     g.keys[0].Public().(ed25519.PublicKey),
+		For:    g.keys[1].Public().(ed25519.PublicKey),
+		Memo:   "for lunch",
+		Series: computeConsiderationSeries(false, g.tipHeight),
+	}
+	fn(cn)
+	return cn
+}
+
+// Returns a viewpoint for a view at height 1
+func (g *vectorGenerator) viewpoint(nonce int32) *Consideration {
+	return &Consideration{
+		Time:   g.now,
+		Nonce:  nonce,
+		For:    g.keys[2].Public().(ed25519.PublicKey),
+		Series: computeConsiderationSeries(true, 1),
+	}
+}
+
+func (g *vectorGenerator) consideration(name, reason string, fn func(cn *Consideration)) {
+	g.vectors = append(g.vectors, ConsensusVector{
+		Name:          "consideration_" + name,
+		Consideration: g.signed(fn),
+		TipHeight:     g.tipHeight,
+		Valid:         len(reason) == 0,
+		Reason:        reason,
+	})
+}
+
+// fn is responsible for any signature
+func (g *vectorGenerator) unsignedConsideration(name, reason string, fn func(cn *Consideration)) {
+	g.vectors = append(g.vectors, ConsensusVector{
+		Name:          "consideration_" + name,
+		Consideration: g.unsigned(fn),
+		TipHeight:     g.tipHeight,
+		Valid:         len(reason) == 0,
+		Reason:        reason,
+	})
+}
+
+// Adds a view at height 1 with a viewpoint and a consideration with easy proof-of-work.
+// The hash list root is recomputed after fn is applied unless fn changes it or empties the view
+func (g *vectorGenerator) view(name, reason string, fn func(b *View)) {
+	easyTarget := ViewID{}
+	for i := range easyTarget {
+		easyTarget[i] = 0xff
+	}
+	considerations := []*Consideration{g.viewpoint(0), g.signed(func(cn *Consideration) {
+		cn.Series = computeConsiderationSeries(false, 1)
+	})}
+	previous := ViewID(sha3.Sum256([]byte("focalpoint consensus vector previous view")))
+	view, err := NewView(previous, 1, easyTarget, ViewID{}, considerations)
+	if err != nil {
+		if g.err == nil {
+			g.err = err
+		}
+		return
+	}
+	view.Header.Time = g.now
+	view.Header.Nonce = 0
+
+	hashListRoot := view.Header.HashListRoot
+	fn(view)
+	if view.Header.HashListRoot == hashListRoot && len(view.Considerations) != 0 {
+		if view.Header.HashListRoot, err = computeHashListRoot(nil, view.Considerations); err != nil {
+			if g.err == nil {
+				g.err = err
+			}
+			return
+		}
+	}
+	g.vectors = append(g.vectors, ConsensusVector{
+		Name:   "view_" + name,
+		View:   view,
+		Now:    g.now,
+		Valid:  len(reason) == 0,
+		Reason: reason,
+	})
+}
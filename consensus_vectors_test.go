@@ -0,0 +1,62 @@
+package focalpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func TestConsensusVectors(t *testing.T) {
+	vectors, err := GenerateConsensusVectors("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectorsJson, err := json.Marshal(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generation should be deterministic
+	vectors2, err := GenerateConsensusVectors("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectorsJson2, err := json.Marshal(vectors2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(vectorsJson) != string(vectorsJson2) {
+		t.Fatal("Consensus vectors differ between runs")
+	}
+
+	// they should survive a round trip
+	var decoded ConsensusVectors
+	if err := json.Unmarshal(vectorsJson, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range decoded.Vectors {
+		if err := v.Check(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestConsensusVectorsFile(t *testing.T) {
+	// vectors generated by an earlier version. if this fails consensus has changed
+	vectorsJson, err := ioutil.ReadFile("testdata/consensus_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors ConsensusVectors
+	if err := json.Unmarshal(vectorsJson, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors.Vectors) == 0 {
+		t.Fatal("No vectors found")
+	}
+	for _, v := range vectors.Vectors {
+		if err := v.Check(); err != nil {
+			t.Error(err)
+		}
+	}
+}
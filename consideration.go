@@ -132,6 +132,25 @@ func (id *ConsiderationID) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// ValidSeries returns the range of series a non-viewpoint consideration may have
+// to be included in a view at the given height.
+func ValidSeries(height int64) (low, high int64) {
+	// user considerations have a grace period (1 full series) to mitigate effects
+	// of any potential queueing delay and/or reorgs near series switchover time
+	high = height/VIEWS_UNTIL_NEW_SERIES + 1
+	low = high - 1
+	if low == 0 {
+		low = 1
+	}
+	return
+}
+
+// LastSeriesHeight returns the height of the last view which can include a non-viewpoint
+// consideration with the given series.
+func LastSeriesHeight(series int64) int64 {
+	return (series+1)*VIEWS_UNTIL_NEW_SERIES - 1
+}
+
 // Compute the series to use for a new consideration.
 func computeConsiderationSeries(isViewpoint bool, height int64) int64 {
 	if isViewpoint {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"sync"
 )
 
 type node struct {
@@ -17,14 +18,31 @@ type Graph struct {
 	index map[string]uint32
 	nodes map[uint32]*node
 	edges map[uint32](map[uint32]float64)
+
+	// memoized IsParentDescendant results. Edges are never removed, only reweighted, so a path
+	// once found stays until Reset. A new edge can only create paths so it just clears unreachable.
+	// IsParentDescendant is called from other goroutines so reachMu also guards its reads of the
+	// index and edges against Link, Assign and Reset
+	reachMu     sync.Mutex
+	reachable   map[reachKey]struct{}
+	unreachable map[reachKey]struct{}
+}
+
+type reachKey struct {
+	parent, descendant uint32
 }
 
+// bound on each memo's size. It's cleared when full
+const maxMemoizedReachability = 1 << 20
+
 // NewGraph initializes and returns a new graph.
 func NewGraph() *Graph {
 	return &Graph{
-		edges: make(map[uint32](map[uint32]float64)),
-		nodes: make(map[uint32]*node),
-		index: make(map[string]uint32),
+		edges:       make(map[uint32](map[uint32]float64)),
+		nodes:       make(map[uint32]*node),
+		index:       make(map[string]uint32),
+		reachable:   make(map[reachKey]struct{}),
+		unreachable: make(map[reachKey]struct{}),
 	}
 }
 
@@ -33,6 +51,11 @@ func NewGraph() *Graph {
 func (graph *Graph) Link(src, trgt string, weight float64) float64 {
 	source := padTo44Characters(src)
 	target := padTo44Characters(trgt)
+
+	// IsParentDescendant reads the index and edges from other goroutines under reachMu
+	graph.reachMu.Lock()
+	defer graph.reachMu.Unlock()
+
 	if _, ok := graph.index[source]; !ok {
 		index := uint32(len(graph.index))
 		graph.index[source] = index
@@ -59,10 +82,17 @@ func (graph *Graph) Link(src, trgt string, weight float64) float64 {
 	if _, ok := graph.edges[sIndex]; !ok {
 		graph.edges[sIndex] = map[uint32]float64{}
 	}
+	_, exists := graph.edges[sIndex][tIndex]
 
 	graph.nodes[sIndex].outbound += weight
 	graph.edges[sIndex][tIndex] += weight
 
+	// a new edge can create paths. clear them only once it's written so a concurrent reader
+	// can't memoize a result computed without it
+	if !exists && len(graph.unreachable) != 0 {
+		graph.unreachable = make(map[reachKey]struct{})
+	}
+
 	return weight
 }
 
@@ -144,6 +174,9 @@ func containsInt(slice []uint32, value uint32) bool {
 
 // Checks for relationship to prevent cycles.
 func (g *Graph) IsParentDescendant(parent, descendant string) bool {
+	g.reachMu.Lock()
+	defer g.reachMu.Unlock()
+
 	parentIndex, pok := g.index[parent]
 	descendantIndex, dok := g.index[descendant]
 
@@ -155,8 +188,27 @@ func (g *Graph) IsParentDescendant(parent, descendant string) bool {
 		return false
 	}
 
+	key := reachKey{parent: parentIndex, descendant: descendantIndex}
+	if _, ok := g.reachable[key]; ok {
+		return true
+	}
+	if _, ok := g.unreachable[key]; ok {
+		return false
+	}
+
 	visited := make(map[uint32]bool)
-	return g.dfs(parentIndex, descendantIndex, visited)
+	if g.dfs(parentIndex, descendantIndex, visited) {
+		if len(g.reachable) >= maxMemoizedReachability {
+			g.reachable = make(map[reachKey]struct{})
+		}
+		g.reachable[key] = struct{}{}
+		return true
+	}
+	if len(g.unreachable) >= maxMemoizedReachability {
+		g.unreachable = make(map[reachKey]struct{})
+	}
+	g.unreachable[key] = struct{}{}
+	return false
 }
 
 func (g *Graph) dfs(current, target uint32, visited map[uint32]bool) bool {
@@ -240,9 +292,42 @@ func (graph *Graph) Rank(alpha, epsilon float64) {
 	}
 }
 
+// Copy returns a deep copy of the graph's nodes and edges.
+func (graph *Graph) Copy() *Graph {
+	c := NewGraph()
+	for pubKey, index := range graph.index {
+		c.index[pubKey] = index
+	}
+	for index, n := range graph.nodes {
+		nodeCopy := *n
+		c.nodes[index] = &nodeCopy
+	}
+	for source, targets := range graph.edges {
+		c.edges[source] = make(map[uint32]float64, len(targets))
+		for target, weight := range targets {
+			c.edges[source][target] = weight
+		}
+	}
+	return c
+}
+
+// Assign replaces the graph's nodes and edges with those of another graph, which mustn't be used
+// afterwards. IsParentDescendant sees either the old graph or the new one.
+func (graph *Graph) Assign(other *Graph) {
+	graph.reachMu.Lock()
+	defer graph.reachMu.Unlock()
+	graph.index, graph.nodes, graph.edges = other.index, other.nodes, other.edges
+	graph.reachable = make(map[reachKey]struct{})
+	graph.unreachable = make(map[reachKey]struct{})
+}
+
 // Reset clears all the current graph data.
 func (graph *Graph) Reset() {
+	graph.reachMu.Lock()
+	defer graph.reachMu.Unlock()
 	graph.edges = make(map[uint32](map[uint32]float64))
 	graph.nodes = make(map[uint32]*node)
 	graph.index = make(map[string]uint32)
+	graph.reachable = make(map[reachKey]struct{})
+	graph.unreachable = make(map[reachKey]struct{})
 }
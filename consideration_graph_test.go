@@ -0,0 +1,226 @@
+package focalpoint
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestIsParentDescendant(t *testing.T) {
+	g := NewGraph()
+	a, b, c, d := padTo44Characters("a"), padTo44Characters("b"), padTo44Characters("c"), padTo44Characters("d")
+	g.Link("0", a, 1) // the root is never considered
+	g.Link(a, b, 1)
+	g.Link(b, c, 1)
+
+	if !g.IsParentDescendant(a, c) {
+		t.Fatal("Expected c to descend from a")
+	}
+	if g.IsParentDescendant(c, a) {
+		t.Fatal("Expected a not to descend from c")
+	}
+
+	// reweighting doesn't change the answers
+	g.Link(b, c, -1)
+	if !g.IsParentDescendant(a, c) {
+		t.Fatal("Expected c to still descend from a")
+	}
+
+	// a new edge invalidates the unreachable memo
+	g.Link(c, d, 1)
+	g.Link(d, a, 1)
+	if !g.IsParentDescendant(c, a) {
+		t.Fatal("Expected a to descend from c after linking")
+	}
+
+	g.Reset()
+	if g.IsParentDescendant(a, c) {
+		t.Fatal("Expected no relationships after reset")
+	}
+}
+
+func TestGraphSnapshot(t *testing.T) {
+	g := NewGraph()
+	g.Link("0", padTo44Characters("a"), 1)
+	idx := NewIndexer(g, nil, nil, nil, ViewID{})
+	snapshot := idx.GraphSnapshot()
+
+	// changes to the indexer's graph aren't seen until the next snapshot
+	g.Link(padTo44Characters("a"), padTo44Characters("b"), 1)
+	g.Rank(1.0, 1e-6)
+	if len(snapshot.graph.nodes) != 2 || len(snapshot.graph.edges) != 1 {
+		t.Fatalf("Snapshot changed, found %d nodes and %d edges",
+			len(snapshot.graph.nodes), len(snapshot.graph.edges))
+	}
+	for _, n := range snapshot.graph.nodes {
+		if n.ranking != 0 {
+			t.Fatal("Snapshot ranking changed")
+		}
+	}
+
+	idx.takeSnapshot()
+	if len(idx.GraphSnapshot().graph.nodes) != 3 {
+		t.Fatal("Expected the new snapshot to include the new node")
+	}
+}
+
+func TestIndexerRebuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "indexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	// a main point of genesis, v1 and v2 each with a viewpoint to the same key
+	pubKey := ed25519.PublicKey(bytes.Repeat([]byte{1}, ed25519.PublicKeySize))
+	var ids []ViewID
+	var views []*View
+	var previous ViewID
+	for height := int64(0); height < 3; height++ {
+		viewpoint := NewConsideration(nil, pubKey, 0, 0, height, "")
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.ConnectView(id, view); err != nil {
+			t.Fatal(err)
+		}
+		ids, views, previous = append(ids, id), append(views, view), id
+	}
+
+	g := NewGraph()
+	idx := NewIndexer(g, viewStore, ledger, nil, ids[0])
+	weight := func() float64 {
+		from, ok := g.index[padTo44Characters(pubKeyToString(nil))]
+		if !ok {
+			return 0
+		}
+		to, ok := g.index[pubKeyToString(pubKey)]
+		if !ok {
+			return 0
+		}
+		return g.edges[from][to]
+	}
+	expect := func(height int64, w float64) {
+		if idx.latestHeight != height || idx.latestViewID != ids[height] {
+			t.Fatalf("Expected the indexer at height %d, found %d", height, idx.latestHeight)
+		}
+		if math.Abs(weight()-w) > 1e-9 {
+			t.Fatalf("Expected a weight of %f, found %f", w, weight())
+		}
+		if idx.cnGraph != g {
+			t.Fatal("Expected the indexer to keep the shared graph")
+		}
+	}
+
+	if err := idx.catchUp(); err != nil {
+		t.Fatal(err)
+	}
+	expect(2, 3)
+
+	// views disconnected since they were indexed are unindexed
+	if _, err := ledger.DisconnectView(ids[2], views[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.catchUp(); err != nil {
+		t.Fatal(err)
+	}
+	expect(1, 2)
+	if _, err := ledger.ConnectView(ids[2], views[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	// rebuilding from a height or from scratch catches up with the tip
+	for _, height := range []int64{2, 1, 0} {
+		if err := idx.rebuild(height); err != nil {
+			t.Fatal(err)
+		}
+		expect(2, 3)
+	}
+	if err := idx.rebuild(4); err == nil {
+		t.Fatal("Expected an error rebuilding from beyond the indexed height")
+	}
+	expect(2, 3)
+}
+
+func TestGraphAssignWhileReading(t *testing.T) {
+	build := func(n int) *Graph {
+		g := NewGraph()
+		g.Link("0", "a", 1)
+		for i := 0; i < n; i++ {
+			g.Link(fmt.Sprintf("n%d", i), fmt.Sprintf("n%d", i+1), 1)
+		}
+		return g
+	}
+	graph := build(10)
+
+	// readers on other goroutines must see either graph whole
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if !graph.IsParentDescendant(padTo44Characters("n0"), padTo44Characters("n5")) {
+				t.Error("Expected n5 to descend from n0")
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		graph.Assign(build(10 + i))
+	}
+	<-done
+}
+
+func TestGraphLinkWhileReading(t *testing.T) {
+	graph := NewGraph()
+	graph.Link("0", "a", 1)
+	graph.Link("n0", "n1", 1)
+
+	// a reader on another goroutine must never memoize a path as missing once its edge is linked
+	started, stop, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		close(started)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				graph.IsParentDescendant(padTo44Characters("n0"), padTo44Characters("n50"))
+			}
+		}
+	}()
+	<-started
+	for i := 1; i < 1000; i++ {
+		graph.Link(fmt.Sprintf("n%d", i), fmt.Sprintf("n%d", i+1), 1)
+	}
+	close(stop)
+	<-done
+	if !graph.IsParentDescendant(padTo44Characters("n0"), padTo44Characters("n50")) {
+		t.Fatal("Expected n50 to descend from n0")
+	}
+}
@@ -44,12 +44,14 @@ func (t *ConsiderationQueueMemory) Add(id ConsiderationID, cn *Consideration) (b
 	}
 	if !ok {
 		// insufficient agent imbalance
-		return false, fmt.Errorf("Consideration %s agent %s has no imbalance",
-			id, base64.StdEncoding.EncodeToString(cn.By[:]))
+		return false, considerationRejection{InsufficientImbalanceCode,
+			fmt.Errorf("Consideration %s agent %s has no imbalance",
+				id, base64.StdEncoding.EncodeToString(cn.By[:]))}
 	}
 
 	if t.conGraph.IsParentDescendant(pubKeyToString(cn.For), pubKeyToString(cn.By)){
-		return false, fmt.Errorf("Agent is a descendant of beneficiary in consideration %s", id)
+		return false, considerationRejection{DescendantRuleCode,
+			fmt.Errorf("Agent is a descendant of beneficiary in consideration %s", id)}
 	}
 
 	// add to the back of the queue
@@ -117,8 +119,9 @@ func (t *ConsiderationQueueMemory) reprocessQueue(height int64) error {
 	tmpQueue.PushBackList(t.cnQueue)
 	for e := tmpQueue.Front(); e != nil; e = e.Next() {
 		cn := e.Value.(*Consideration)
-		// check that the series would still be valid
+		// check that the series and memo would still be valid
 		if !checkConsiderationSeries(cn, height+1) ||
+			MemoPolicyAt(height+1).Check(cn.Memo) != nil ||
 			// check maturity and expiration if included in the next view
 			!cn.IsMature(height+1) || cn.IsExpired(height+1) {
 			// consideration has been invalidated. remove and continue
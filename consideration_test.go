@@ -143,3 +143,17 @@ func TestConsiderationTestVector1(t *testing.T) {
 		t.Errorf("Expected verification failure")
 	}
 }
+
+func TestLastSeriesHeight(t *testing.T) {
+	for _, series := range []int64{1, 2, 100} {
+		last := LastSeriesHeight(series)
+		low, high := ValidSeries(last)
+		if series < low || series > high {
+			t.Fatalf("Series %d not valid at its last height %d", series, last)
+		}
+		low, _ = ValidSeries(last + 1)
+		if series >= low {
+			t.Fatalf("Series %d still valid after its last height %d", series, last)
+		}
+	}
+}
@@ -4,17 +4,20 @@ package focalpoint
 // we could have played with these but we're introducing significant enough changes
 // already IMO, so let's keep the scope of this experiment as small as we can
 
-const VIEWPOINT_MATURITY = 100 // views
+// VIEWPOINT_MATURITY, INITIAL_TARGET, RETARGET_TIME, TARGET_SPACING and MEMO_POLICIES can be
+// overridden by a network parameter bundle. see network.go
 
-const INITIAL_TARGET = "00000000ffff0000000000000000000000000000000000000000000000000000"
+var VIEWPOINT_MATURITY int64 = 100 // views
+
+var INITIAL_TARGET = "00000000ffff0000000000000000000000000000000000000000000000000000"
 
 const MAX_FUTURE_SECONDS = 2 * 60 * 60 // 2 hours
 
 const RETARGET_INTERVAL = 2016 // 2 weeks in views
 
-const RETARGET_TIME = 1209600 // 2 weeks in seconds
+var RETARGET_TIME int64 = 1209600 // 2 weeks in seconds
 
-const TARGET_SPACING = 600 // every 10 minutes
+var TARGET_SPACING int64 = 600 // every 10 minutes
 
 const NUM_VIEWS_FOR_MEDIAN_TMESTAMP = 11
 
@@ -34,7 +37,8 @@ const MAX_CONSIDERATIONS_PER_VIEW_EXCEEDED_AT_HEIGHT = 1852032 // pre-calculated
 
 const VIEWS_UNTIL_NEW_SERIES = 1008 // 1 week in views
 
-const MAX_MEMO_LENGTH = 150 // bytes (ascii/utf8 only)
+// memo limits by activation height. see memo.go
+var MEMO_POLICIES = []MemoPolicy{{Height: 0, MaxLength: 150, Encoding: MemoEncodingUTF8}}
 
 // given our JSON protocol we should respect Javascript's Number.MAX_SAFE_INTEGER value
 const MAX_NUMBER int64 = 1<<53 - 1
@@ -44,7 +48,7 @@ const BITCOIN_CASH_RETARGET_ALGORITHM_HEIGHT = 28861
 
 // the below values only affect peering behavior and do not affect ledger consensus
 
-const DEFAULT_FOCALPOINT_PORT = 8832
+var DEFAULT_FOCALPOINT_PORT = 8832 // can be overridden by a network parameter bundle
 
 const MAX_OUTBOUND_PEER_CONNECTIONS = 8
 
@@ -52,13 +56,25 @@ const MAX_INBOUND_PEER_CONNECTIONS = 128
 
 const MAX_INBOUND_PEER_CONNECTIONS_FROM_SAME_HOST = 4
 
+const DEFAULT_PEER_RETENTION_DAYS = 30 // stored peer addresses not seen for this long are pruned
+
+const DEFAULT_MAX_STORED_PEERS = 10000
+
 const MAX_TIP_AGE = (24 * 3) * 60 * 60 // 3 days
 
 const MAX_PROTOCOL_MESSAGE_LENGTH = 2 * 1024 * 1024 // doesn't apply to views
 
+// advertised to minds. can be overridden by a network parameter bundle. see display_hints.go
+var DISPLAY_HINTS = DisplayHints{NetworkName: "focalpoint", UnitName: "seed", UnitNamePlural: "seeds"}
+
 // the below values are rendering policy and also do not affect ledger consensus
 
 // if you change this it needs to be less than the maximum at the current height
 const MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW = INITIAL_MAX_CONSIDERATIONS_PER_VIEW
 
 const MAX_CONSIDERATION_QUEUE_LENGTH = MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW * 10
+
+// the below values are mind policy and also do not affect ledger consensus
+
+// newly sent considerations expire if not rendered within this many views unless the mind is configured otherwise
+const DEFAULT_CONSIDERATION_EXPIRY = 3
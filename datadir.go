@@ -0,0 +1,186 @@
+package focalpoint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// DATADIR_VERSION is the version of the data directory layout written by this code.
+// Increment it and append a migration to datadirMigrations whenever the layout changes.
+const DATADIR_VERSION = 3
+
+const datadirVersionFile = "VERSION"
+
+// A datadirMigration upgrades a data directory from the given version to the next.
+type datadirMigration struct {
+	from        int
+	description string
+	migrate     func(dataDir string) error
+}
+
+// datadirMigrations are applied in order. A data directory created before the version
+// marker was introduced is treated as version 0.
+var datadirMigrations = []datadirMigration{
+	{
+		from:        0,
+		description: "Per-view undo records in the ledger",
+		migrate: func(dataDir string) error {
+			// views connected before this version have no undo record.
+			// the ledger falls back to replaying them when they're disconnected
+			return nil
+		},
+	},
+	{
+		from:        1,
+		description: "Index of views by the public key which rendered them",
+		migrate: func(dataDir string) error {
+			headersPath := filepath.Join(dataDir, "headers.db")
+			if _, err := os.Stat(headersPath); os.IsNotExist(err) {
+				// no views to index
+				return nil
+			}
+			viewStore, err := NewViewStorageDisk(filepath.Join(dataDir, "views"), headersPath, true, false)
+			if err != nil {
+				return err
+			}
+			defer viewStore.Close()
+			db, err := leveldb.OpenFile(filepath.Join(dataDir, "ledger.db"), nil)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			count, err := indexRenderedViews(db, viewStore)
+			if err != nil {
+				return err
+			}
+			log.Printf("Indexed %d rendered views\n", count)
+			return nil
+		},
+	},
+	{
+		from:        2,
+		description: "Cumulative consideration, viewpoint and active key totals per height",
+		migrate: func(dataDir string) error {
+			headersPath := filepath.Join(dataDir, "headers.db")
+			if _, err := os.Stat(headersPath); os.IsNotExist(err) {
+				// no views to count
+				return nil
+			}
+			viewStore, err := NewViewStorageDisk(filepath.Join(dataDir, "views"), headersPath, true, false)
+			if err != nil {
+				return err
+			}
+			defer viewStore.Close()
+			db, err := leveldb.OpenFile(filepath.Join(dataDir, "ledger.db"), nil)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			count, err := indexSupplyStats(db, viewStore)
+			if err != nil {
+				return err
+			}
+			log.Printf("Recorded totals for %d views\n", count)
+			return nil
+		},
+	},
+}
+
+// MigrateDatadir upgrades the data directory in place to DATADIR_VERSION.
+// It returns an error if the data directory was written by a newer version or can't be upgraded.
+func MigrateDatadir(dataDir string) error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	version, err := ReadDatadirVersion(dataDir)
+	if err != nil {
+		return err
+	}
+	if version > DATADIR_VERSION {
+		return fmt.Errorf("Data directory version %d is newer than supported version %d",
+			version, DATADIR_VERSION)
+	}
+
+	for version < DATADIR_VERSION {
+		var found bool
+		for _, m := range datadirMigrations {
+			if m.from != version {
+				continue
+			}
+			log.Printf("Migrating data directory from version %d to %d: %s\n",
+				version, version+1, m.description)
+			if err := m.migrate(dataDir); err != nil {
+				return fmt.Errorf("Migration from data directory version %d failed: %s", version, err)
+			}
+			// record each step so an interrupted upgrade resumes where it left off
+			version++
+			if err := writeDatadirVersion(dataDir, version); err != nil {
+				return err
+			}
+			found = true
+			break
+		}
+		if !found {
+			return fmt.Errorf("No migration from data directory version %d", version)
+		}
+	}
+
+	// mark a new data directory
+	return writeDatadirVersion(dataDir, version)
+}
+
+// CheckDatadirVersion returns an error if the data directory isn't at DATADIR_VERSION.
+// It's used by tools that shouldn't modify the data directory.
+func CheckDatadirVersion(dataDir string) error {
+	version, err := ReadDatadirVersion(dataDir)
+	if err != nil {
+		return err
+	}
+	if version != DATADIR_VERSION {
+		return fmt.Errorf("Data directory version %d doesn't match supported version %d",
+			version, DATADIR_VERSION)
+	}
+	return nil
+}
+
+// ReadDatadirVersion returns the version of the data directory layout.
+// A new data directory is reported as DATADIR_VERSION and one predating the version marker as 0.
+func ReadDatadirVersion(dataDir string) (int, error) {
+	versionBytes, err := ioutil.ReadFile(filepath.Join(dataDir, datadirVersionFile))
+	if err == nil {
+		version, err := strconv.Atoi(strings.TrimSpace(string(versionBytes)))
+		if err != nil {
+			return 0, fmt.Errorf("Invalid data directory version: %s", err)
+		}
+		return version, nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	// no marker. check for existing data
+	for _, name := range []string{"ledger.db", "headers.db"} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err == nil {
+			return 0, nil
+		} else if !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	return DATADIR_VERSION, nil
+}
+
+// Write the version marker
+func writeDatadirVersion(dataDir string, version int) error {
+	tmpPath := filepath.Join(dataDir, datadirVersionFile+".tmp")
+	if err := ioutil.WriteFile(tmpPath, []byte(strconv.Itoa(version)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(dataDir, datadirVersionFile))
+}
@@ -0,0 +1,43 @@
+package focalpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateDatadir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datadir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// existing data without a version marker
+	if err := os.Mkdir(filepath.Join(dir, "ledger.db"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	version, err := ReadDatadirVersion(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 {
+		t.Fatalf("Expected version 0, found %d", version)
+	}
+
+	if err := MigrateDatadir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckDatadirVersion(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// refuse a layout written by newer code
+	if err := writeDatadirVersion(dir, DATADIR_VERSION+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateDatadir(dir); err == nil {
+		t.Fatal("Expected newer data directory to be refused")
+	}
+}
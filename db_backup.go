@@ -0,0 +1,267 @@
+package focalpoint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// DatabaseBackup periodically copies the ledger and view header databases of a running node to a
+// directory. Each copy is taken from consistent snapshots of the live databases and only the most
+// recent backups are kept. View files aren't copied, they're never rewritten once stored.
+type DatabaseBackup struct {
+	dirPath      string
+	interval     int64
+	keep         int
+	ledger       *LedgerDisk
+	viewStore    *ViewStorageDisk
+	processor    *Processor
+	backupChan   chan int64
+	shutdownChan chan struct{}
+	wg           sync.WaitGroup
+}
+
+// Records copied per write when backing up a database
+const backupBatchSize = 1000
+
+// NewDatabaseBackup returns a new DatabaseBackup instance which writes a backup to the given
+// directory every interval views and keeps the most recent keep backups.
+func NewDatabaseBackup(dirPath string, interval int64, keep int, ledger *LedgerDisk,
+	viewStore *ViewStorageDisk, processor *Processor) *DatabaseBackup {
+	return &DatabaseBackup{
+		dirPath:      dirPath,
+		interval:     interval,
+		keep:         keep,
+		ledger:       ledger,
+		viewStore:    viewStore,
+		processor:    processor,
+		backupChan:   make(chan int64, 1),
+		shutdownChan: make(chan struct{}),
+	}
+}
+
+// Run executes the DatabaseBackup's main loop in its own goroutine.
+func (b *DatabaseBackup) Run() error {
+	if err := os.MkdirAll(b.dirPath, 0700); err != nil {
+		return err
+	}
+	b.wg.Add(2)
+	go b.run()
+	go b.backup()
+	return nil
+}
+
+func (b *DatabaseBackup) run() {
+	defer b.wg.Done()
+
+	// register for tip changes
+	tipChangeChan := make(chan TipChange, 10)
+	b.processor.RegisterForTipChange(tipChangeChan)
+	defer func() { b.processor.UnregisterForTipChange(tipChangeChan) }()
+
+	for {
+		select {
+		case tip, ok := <-tipChangeChan:
+			if !ok {
+				// cut off for falling behind. the next due backup covers what was missed
+				log.Println("Database backup missed tip changes")
+				tipChangeChan = make(chan TipChange, 10)
+				b.processor.RegisterForTipChange(tipChangeChan)
+				break
+			}
+			due, err := b.due(tip)
+			if err != nil {
+				log.Printf("Error: %s\n", err)
+				break
+			}
+			if !due {
+				break
+			}
+			// don't hold up the processor. if a backup is already queued it will cover this one
+			select {
+			case b.backupChan <- tip.View.Header.Height:
+			default:
+			}
+
+		case _, ok := <-b.shutdownChan:
+			if !ok {
+				log.Println("Database backup shutting down...")
+				return
+			}
+		}
+	}
+}
+
+// Returns true if a backup is due for the tip change. None are taken while syncing, they'd be
+// out of date before long and copying the databases would slow the sync down
+func (b *DatabaseBackup) due(tip TipChange) (bool, error) {
+	if !tip.Connect || tip.View.Header.Height%b.interval != 0 {
+		return false, nil
+	}
+	ibd, _, err := IsInitialViewDownload(b.ledger, b.viewStore)
+	if err != nil {
+		return false, err
+	}
+	return !ibd, nil
+}
+
+// Take backups as they're requested
+func (b *DatabaseBackup) backup() {
+	defer b.wg.Done()
+	for {
+		select {
+		case height := <-b.backupChan:
+			log.Printf("Backing up databases at height %d\n", height)
+			path, err := b.Backup()
+			if err != nil {
+				log.Printf("Error backing up databases: %s\n", err)
+				break
+			}
+			log.Printf("Databases backed up to %s\n", path)
+			removed, err := b.rotate()
+			if err != nil {
+				log.Printf("Error removing old backups: %s\n", err)
+				break
+			}
+			if removed != 0 {
+				log.Printf("Removed %d old backup(s)\n", removed)
+			}
+
+		case _, ok := <-b.shutdownChan:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// Backup copies the ledger and view header databases and returns the path of the backup directory.
+// Restore it by replacing ledger.db and headers.db in the node's data directory while it's stopped.
+func (b *DatabaseBackup) Backup() (string, error) {
+	// views are stored before they're connected so a headers snapshot taken after the
+	// ledger's always covers the ledger's point
+	ledgerSnapshot, err := b.ledger.db.GetSnapshot()
+	if err != nil {
+		return "", err
+	}
+	defer ledgerSnapshot.Release()
+	headersSnapshot, err := b.viewStore.db.GetSnapshot()
+	if err != nil {
+		return "", err
+	}
+	defer headersSnapshot.Release()
+
+	_, height, err := getPointTip(ledgerSnapshot)
+	if err != nil {
+		return "", err
+	}
+
+	// write to a temporary directory first so a partial backup is never mistaken for a complete one
+	tmpPath, err := ioutil.TempDir(b.dirPath, ".backup-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpPath)
+	if err := copyLevelDBSnapshot(ledgerSnapshot, filepath.Join(tmpPath, "ledger.db")); err != nil {
+		return "", err
+	}
+	if err := copyLevelDBSnapshot(headersSnapshot, filepath.Join(tmpPath, "headers.db")); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("backup-%d-%s", height, time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(b.dirPath, name)
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Remove all but the most recent backups. Returns the number removed
+func (b *DatabaseBackup) rotate() (int, error) {
+	return rotateBackups(b.dirPath, "backup-", b.keep)
+}
+
+// Shutdown stops the database backup synchronously.
+func (b *DatabaseBackup) Shutdown() {
+	close(b.shutdownChan)
+	b.wg.Wait()
+	log.Println("Database backup shutdown")
+}
+
+// Write the contents of a snapshot to a new database at the given path
+func copyLevelDBSnapshot(snapshot *leveldb.Snapshot, dbPath string) error {
+	db, err := leveldb.OpenFile(dbPath, &opt.Options{ErrorIfExist: true})
+	if err != nil {
+		return err
+	}
+	iter := snapshot.NewIterator(nil, nil)
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Put(iter.Key(), iter.Value())
+		if batch.Len() < backupBatchSize {
+			continue
+		}
+		if err := db.Write(batch, nil); err != nil {
+			iter.Release()
+			db.Close()
+			return err
+		}
+		batch.Reset()
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// Remove all but the keep most recent backups in a directory with names starting with prefix and
+// ending with their UTC creation time. Returns the number removed
+func rotateBackups(dirPath, prefix string, keep int) (int, error) {
+	infos, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), prefix) {
+			names = append(names, info.Name())
+		}
+	}
+	if len(names) <= keep {
+		return 0, nil
+	}
+
+	// newest first
+	timeOf := func(name string) string {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		return name[strings.LastIndex(name, "-")+1:]
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return timeOf(names[i]) > timeOf(names[j])
+	})
+
+	var removed int
+	for _, name := range names[keep:] {
+		if err := os.RemoveAll(filepath.Join(dirPath, name)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,103 @@
+package focalpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestDatabaseBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewpoint := NewConsideration(nil, pubKey, 0, 0, 0, "")
+	view, err := NewView(ViewID{}, 0, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := view.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := viewStore.Store(id, view, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// no backups while syncing
+	backupDir := filepath.Join(dir, "backups")
+	backup := NewDatabaseBackup(backupDir, 1, 1, ledger, viewStore, nil)
+	tip := TipChange{ViewID: id, View: view, Connect: true}
+	if due, err := backup.due(tip); err != nil || due {
+		t.Fatal("Expected no backup due without a point tip")
+	}
+
+	if _, err := ledger.ConnectView(id, view); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := backup.due(tip); err != nil || !due {
+		t.Fatal("Expected a backup due with a recent tip")
+	}
+	if err := os.Mkdir(backupDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	oldPath := filepath.Join(backupDir, "backup-0-20000101T000000Z")
+	if err := os.Mkdir(oldPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path, err := backup.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := backup.rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("Expected 1 backup removed, found %d", removed)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Fatal("Expected the older backup to be removed")
+	}
+
+	// the copies open with the same tip
+	viewStoreCopy, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(path, "headers.db"), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStoreCopy.Close()
+	ledgerCopy, err := NewLedgerDisk(filepath.Join(path, "ledger.db"), true, false, viewStoreCopy, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledgerCopy.Close()
+	tipID, _, err := ledgerCopy.GetPointTip()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tipID == nil || *tipID != id {
+		t.Fatal("Expected the backed up ledger to have the same tip")
+	}
+	if header, _, err := viewStoreCopy.GetViewHeader(id); err != nil || header == nil {
+		t.Fatal("Expected the backed up headers to include the tip")
+	}
+}
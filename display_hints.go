@@ -0,0 +1,49 @@
+package focalpoint
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// DisplayHints are presentation suggestions a node advertises for the network it's on so minds
+// and explorers can tell deployments of this package apart and label what they show. They have
+// no effect on consensus and can be set by a network parameter bundle.
+type DisplayHints struct {
+	NetworkName     string   `json:"network_name"`
+	UnitName        string   `json:"unit_name,omitempty"`        // what one unit of imbalance is called
+	UnitNamePlural  string   `json:"unit_name_plural,omitempty"` // defaults to the unit name
+	MemoConventions []string `json:"memo_conventions,omitempty"` // suggestions to show people writing memos
+}
+
+// limits on display hints so a bundle can't make them unwieldy to advertise
+const (
+	maxDisplayHintLength    = 64
+	maxMemoConventions      = 8
+	maxMemoConventionLength = 256
+)
+
+// Units returns the unit name to use for the given amount, or "" if the network doesn't name it.
+func (h DisplayHints) Units(amount int64) string {
+	if amount == 1 || amount == -1 || len(h.UnitNamePlural) == 0 {
+		return h.UnitName
+	}
+	return h.UnitNamePlural
+}
+
+// Validate checks the hints are within limits.
+func (h DisplayHints) Validate() error {
+	for _, s := range []string{h.NetworkName, h.UnitName, h.UnitNamePlural} {
+		if len(s) > maxDisplayHintLength || !utf8.ValidString(s) {
+			return fmt.Errorf("Display hints must be utf8 of at most %d bytes", maxDisplayHintLength)
+		}
+	}
+	if len(h.MemoConventions) > maxMemoConventions {
+		return fmt.Errorf("At most %d memo conventions are allowed", maxMemoConventions)
+	}
+	for _, s := range h.MemoConventions {
+		if len(s) > maxMemoConventionLength || !utf8.ValidString(s) {
+			return fmt.Errorf("Memo conventions must be utf8 of at most %d bytes", maxMemoConventionLength)
+		}
+	}
+	return nil
+}
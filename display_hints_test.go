@@ -0,0 +1,30 @@
+package focalpoint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisplayHints(t *testing.T) {
+	hints := DisplayHints{NetworkName: "test", UnitName: "point", UnitNamePlural: "points"}
+	if err := hints.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if hints.Units(1) != "point" || hints.Units(-1) != "point" || hints.Units(0) != "points" {
+		t.Fatal("Expected the plural for amounts other than one")
+	}
+	hints.UnitNamePlural = ""
+	if hints.Units(5) != "point" {
+		t.Fatal("Expected the unit name without a plural")
+	}
+
+	hints.UnitName = strings.Repeat("x", maxDisplayHintLength+1)
+	if err := hints.Validate(); err == nil {
+		t.Fatal("Expected a long unit name to be rejected")
+	}
+	hints.UnitName = "point"
+	hints.MemoConventions = make([]string, maxMemoConventions+1)
+	if err := hints.Validate(); err == nil {
+		t.Fatal("Expected too many memo conventions to be rejected")
+	}
+}
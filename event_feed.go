@@ -0,0 +1,286 @@
+package focalpoint
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// EventFeed publishes structured node events to subscribers connected to a unix socket.
+// Each event is a single line of JSON using the same envelope as the peer protocol.
+// It allows external automation to follow the node without speaking the peer protocol.
+type EventFeed struct {
+	socketPath      string
+	processor       *Processor
+	listener        net.Listener
+	subscribers     map[chan []byte]struct{}
+	subscribersLock sync.Mutex
+	publishChan     chan Message
+	disconnected    int // views disconnected since the last connection
+	shutdownChan    chan struct{}
+	wg              sync.WaitGroup
+}
+
+// TipEvent is published when a view is connected to or disconnected from the main point tip.
+// Type: "tip_connected" or "tip_disconnected".
+type TipEvent struct {
+	ViewID ViewID `json:"view_id"`
+	Height int64  `json:"height"`
+	Source string `json:"source,omitempty"`
+}
+
+// ConsiderationEvent is published when a consideration is confirmed in a main point view.
+// Type: "consideration_confirmed".
+type ConsiderationEvent struct {
+	ConsiderationID ConsiderationID `json:"consideration_id"`
+	ViewID          ViewID          `json:"view_id"`
+	Height          int64           `json:"height"`
+}
+
+// ReorgEvent is published once the main point tip is connected following one or more disconnections.
+// Type: "reorg".
+type ReorgEvent struct {
+	Depth  int    `json:"depth"`
+	ViewID ViewID `json:"view_id"`
+	Height int64  `json:"height"`
+}
+
+// StaleTipEvent is published when the main point tip becomes older than MAX_TIP_AGE and again
+// once a newer tip is connected.
+// Type: "tip_stale" or "tip_fresh".
+type StaleTipEvent struct {
+	ViewID ViewID `json:"view_id"`
+	Height int64  `json:"height"`
+	Time   int64  `json:"time"` // the tip's header time
+}
+
+// WatchConsiderationEvent is published when a consideration involving a watched public key is queued,
+// confirmed in a main point view or disconnected with the view. See Watchlist.
+// Type: "watch_consideration_queued", "watch_consideration_confirmed" or "watch_consideration_unconfirmed".
+type WatchConsiderationEvent struct {
+	PublicKey       ed25519.PublicKey `json:"public_key"`
+	ConsiderationID ConsiderationID   `json:"consideration_id"`
+	Consideration   *Consideration    `json:"consideration"`
+	ViewID          *ViewID           `json:"view_id,omitempty"`
+	Height          int64             `json:"height,omitempty"`
+}
+
+// WatchRankingEvent is published when the ranking of a watched public key changes.
+// Type: "watch_ranking_changed".
+type WatchRankingEvent struct {
+	PublicKey ed25519.PublicKey `json:"public_key"`
+	Ranking   float64           `json:"ranking"`
+	Previous  float64           `json:"previous"`
+	ViewID    ViewID            `json:"view_id"`
+	Height    int64             `json:"height"`
+}
+
+// PeerEvent is published when a peer is rejected because it is banned.
+// Type: "peer_banned".
+type PeerEvent struct {
+	Address string `json:"address"`
+}
+
+// NewEventFeed returns a new EventFeed instance which will listen on the given unix socket path.
+func NewEventFeed(socketPath string, processor *Processor) *EventFeed {
+	return &EventFeed{
+		socketPath:   socketPath,
+		processor:    processor,
+		subscribers:  make(map[chan []byte]struct{}),
+		publishChan:  make(chan Message, 100),
+		shutdownChan: make(chan struct{}),
+	}
+}
+
+// Run executes the EventFeed's main loop in its own goroutine.
+func (e *EventFeed) Run() error {
+	// remove a stale socket left behind by a previous run
+	if err := os.Remove(e.socketPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	listener, err := net.Listen("unix", e.socketPath)
+	if err != nil {
+		return err
+	}
+	e.listener = listener
+
+	e.wg.Add(2)
+	go e.accept()
+	go e.run()
+	return nil
+}
+
+// Publish queues an event for delivery to all subscribers. It never blocks the caller.
+func (e *EventFeed) Publish(eventType string, body interface{}) {
+	select {
+	case e.publishChan <- Message{Type: eventType, Body: body}:
+	default:
+		log.Printf("Event feed is full, dropping %s event\n", eventType)
+	}
+}
+
+func (e *EventFeed) run() {
+	defer e.wg.Done()
+
+	// register for tip changes
+	tipChangeChan := make(chan TipChange, 10)
+	e.processor.RegisterForTipChange(tipChangeChan)
+	defer func() { e.processor.UnregisterForTipChange(tipChangeChan) }()
+
+	for {
+		select {
+		case tip, ok := <-tipChangeChan:
+			if !ok {
+				// cut off for falling behind. subscribers miss the events in between
+				log.Println("Event feed missed tip changes")
+				tipChangeChan = make(chan TipChange, 10)
+				e.processor.RegisterForTipChange(tipChangeChan)
+				break
+			}
+			e.onTipChange(tip)
+
+		case m := <-e.publishChan:
+			e.broadcast(m)
+
+		case _, ok := <-e.shutdownChan:
+			if !ok {
+				log.Println("Event feed shutting down...")
+				return
+			}
+		}
+	}
+}
+
+// Translate a tip change into events
+func (e *EventFeed) onTipChange(tip TipChange) {
+	event := TipEvent{ViewID: tip.ViewID, Height: tip.View.Header.Height, Source: tip.Source}
+	if !tip.Connect {
+		e.disconnected++
+		e.broadcast(Message{Type: "tip_disconnected", Body: event})
+		return
+	}
+
+	e.broadcast(Message{Type: "tip_connected", Body: event})
+	if e.disconnected != 0 && !tip.More {
+		e.broadcast(Message{
+			Type: "reorg",
+			Body: ReorgEvent{Depth: e.disconnected, ViewID: tip.ViewID, Height: tip.View.Header.Height},
+		})
+		e.disconnected = 0
+	}
+
+	for _, cn := range tip.View.Considerations[1:] {
+		cnID, err := cn.ID()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		e.broadcast(Message{
+			Type: "consideration_confirmed",
+			Body: ConsiderationEvent{
+				ConsiderationID: cnID,
+				ViewID:          tip.ViewID,
+				Height:          tip.View.Header.Height,
+			},
+		})
+	}
+}
+
+// Send an event to every subscriber. Subscribers who can't keep up are dropped
+func (e *EventFeed) broadcast(m Message) {
+	line, err := json.Marshal(m)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	line = append(line, '\n')
+
+	e.subscribersLock.Lock()
+	defer e.subscribersLock.Unlock()
+	for ch := range e.subscribers {
+		select {
+		case ch <- line:
+		default:
+			log.Println("Dropping slow event feed subscriber")
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Accept new subscribers
+func (e *EventFeed) accept() {
+	defer e.wg.Done()
+	for {
+		conn, err := e.listener.Accept()
+		if err != nil {
+			select {
+			case <-e.shutdownChan:
+				return
+			default:
+				log.Printf("Event feed accept error: %s\n", err)
+				continue
+			}
+		}
+
+		ch := make(chan []byte, 1000)
+		e.subscribersLock.Lock()
+		select {
+		case <-e.shutdownChan:
+			// raced with shutdown
+			e.subscribersLock.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		e.subscribers[ch] = struct{}{}
+		e.subscribersLock.Unlock()
+
+		e.wg.Add(1)
+		go e.write(conn, ch)
+	}
+}
+
+// Write events to a subscriber until it goes away or we shut down
+func (e *EventFeed) write(conn net.Conn, ch chan []byte) {
+	defer e.wg.Done()
+	defer conn.Close()
+	for line := range ch {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if _, err := conn.Write(line); err != nil {
+			e.subscribersLock.Lock()
+			if _, ok := e.subscribers[ch]; ok {
+				delete(e.subscribers, ch)
+				close(ch)
+			}
+			e.subscribersLock.Unlock()
+			// drain anything left
+			for range ch {
+			}
+			return
+		}
+	}
+}
+
+// Shutdown stops the event feed synchronously.
+func (e *EventFeed) Shutdown() {
+	close(e.shutdownChan)
+	e.listener.Close()
+
+	// disconnect all subscribers
+	e.subscribersLock.Lock()
+	for ch := range e.subscribers {
+		delete(e.subscribers, ch)
+		close(ch)
+	}
+	e.subscribersLock.Unlock()
+
+	e.wg.Wait()
+	os.Remove(e.socketPath)
+	log.Println("Event feed shutdown")
+}
@@ -0,0 +1,147 @@
+package focalpoint
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestEventFeedSubscribe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "event_feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	processor := NewProcessor(ViewID{}, nil, nil, nil)
+	processor.Run()
+	defer processor.Shutdown()
+	feed := NewEventFeed(filepath.Join(dir, "events.sock"), processor)
+	if err := feed.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer feed.Shutdown()
+
+	subscribers := func() int {
+		feed.subscribersLock.Lock()
+		defer feed.subscribersLock.Unlock()
+		return len(feed.subscribers)
+	}
+	waitFor := func(count int) {
+		for start := time.Now(); subscribers() != count; time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("Expected %d subscriber(s), found %d", count, subscribers())
+			}
+		}
+	}
+
+	conn, err := net.Dial("unix", filepath.Join(dir, "events.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(1)
+
+	// events arrive in the order they're published
+	types := []string{"peer_banned", "tip_stale", "tip_fresh"}
+	for _, eventType := range types {
+		feed.Publish(eventType, PeerEvent{Address: "127.0.0.1:8831"})
+	}
+	reader := bufio.NewReader(conn)
+	for _, eventType := range types {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m Message
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != eventType {
+			t.Fatalf("Expected %s event, found %s", eventType, m.Type)
+		}
+	}
+
+	// a subscriber which goes away is removed
+	conn.Close()
+	for start := time.Now(); subscribers() != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("Expected the closed subscriber to be removed")
+		}
+		feed.Publish("peer_banned", PeerEvent{Address: "127.0.0.1:8831"})
+	}
+}
+
+func TestEventFeedTipChanges(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := NewEventFeed("", nil)
+	ch := make(chan []byte, 100)
+	feed.subscribers[ch] = struct{}{}
+
+	viewpoint := NewConsideration(nil, pubKey, 0, 0, 0, "")
+	cn := NewConsideration(pubKey, pubKey, 0, 0, 0, "")
+	view := &View{Header: &ViewHeader{Height: 5}, Considerations: []*Consideration{viewpoint, cn}}
+
+	// a reorg two views deep
+	feed.onTipChange(TipChange{ViewID: ViewID{0x1}, View: view})
+	feed.onTipChange(TipChange{ViewID: ViewID{0x2}, View: view})
+	feed.onTipChange(TipChange{ViewID: ViewID{0x3}, View: view, Connect: true, More: true})
+	feed.onTipChange(TipChange{ViewID: ViewID{0x4}, View: view, Connect: true})
+
+	expected := []string{
+		"tip_disconnected", "tip_disconnected",
+		"tip_connected", "consideration_confirmed",
+		"tip_connected", "reorg", "consideration_confirmed",
+	}
+	if len(ch) != len(expected) {
+		t.Fatalf("Expected %d events, found %d", len(expected), len(ch))
+	}
+	for _, eventType := range expected {
+		var m Message
+		var reorg ReorgEvent
+		if eventType == "reorg" {
+			m.Body = &reorg
+		}
+		if err := json.Unmarshal(<-ch, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != eventType {
+			t.Fatalf("Expected %s event, found %s", eventType, m.Type)
+		}
+		if eventType == "reorg" && (reorg.Depth != 2 || reorg.ViewID != (ViewID{0x4})) {
+			t.Fatalf("Unexpected reorg event: %+v", reorg)
+		}
+	}
+}
+
+func TestEventFeedSlowSubscriber(t *testing.T) {
+	feed := NewEventFeed("", nil)
+	slow, fast := make(chan []byte, 1), make(chan []byte, 10)
+	feed.subscribers[slow] = struct{}{}
+	feed.subscribers[fast] = struct{}{}
+
+	feed.broadcast(Message{Type: "tip_stale"})
+	feed.broadcast(Message{Type: "tip_fresh"})
+
+	// the slow subscriber is dropped rather than holding up the others
+	if _, ok := feed.subscribers[slow]; ok {
+		t.Fatal("Expected the slow subscriber to be dropped")
+	}
+	<-slow
+	if _, ok := <-slow; ok {
+		t.Fatal("Expected the slow subscriber's channel to be closed")
+	}
+	if len(fast) != 2 {
+		t.Fatalf("Expected 2 events for the other subscriber, found %d", len(fast))
+	}
+}
@@ -0,0 +1,144 @@
+package focalpoint
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	cuckoo "github.com/seiflotfy/cuckoofilter"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Filter types a peer can load to select the considerations relayed to it.
+const (
+	// A cuckoo filter. It's compact but has false positives
+	FilterTypeCuckoo = "cuckoo"
+
+	// A plain list of public keys. It's larger but exact and trivial to build
+	FilterTypeKeySet = "keys"
+)
+
+// FilterTypes are the filter types we support.
+var FilterTypes = []string{FilterTypeCuckoo, FilterTypeKeySet}
+
+const (
+	// Maximum encoded size of a cuckoo filter we'll load
+	maxCuckooFilterLength = 1 << 16
+
+	// Capacity of a cuckoo filter created on behalf of a peer
+	cuckooFilterCapacity = 1 << 16
+
+	// Maximum number of public keys in a key set filter
+	maxKeySetFilterKeys = 2048
+)
+
+// ConsiderationFilter is a set of public keys a peer is interested in.
+type ConsiderationFilter interface {
+	// Type returns the filter's type
+	Type() string
+
+	// Insert adds the data to the filter. It returns false if the filter is full or the data is invalid
+	Insert(data []byte) bool
+
+	// Lookup returns true if the data may be in the filter
+	Lookup(data []byte) bool
+
+	// Encode returns the filter's wire encoding
+	Encode() []byte
+}
+
+// NewConsiderationFilter returns a new empty filter of the given type.
+// Capacity is the number of keys to size a cuckoo filter for.
+func NewConsiderationFilter(filterType string, capacity int) (ConsiderationFilter, error) {
+	switch filterType {
+	case FilterTypeCuckoo:
+		return cuckooConsiderationFilter{cuckoo.NewFilter(uint(capacity))}, nil
+	case FilterTypeKeySet:
+		// it grows as needed up to maxKeySetFilterKeys
+		return keySetConsiderationFilter{make(map[[ed25519.PublicKeySize]byte]bool)}, nil
+	}
+	return nil, fmt.Errorf("Unsupported filter type: %s", filterType)
+}
+
+// DecodeConsiderationFilter decodes a filter of the given type, enforcing the type's size limit.
+func DecodeConsiderationFilter(filterType string, filterBytes []byte) (ConsiderationFilter, error) {
+	switch filterType {
+	case FilterTypeCuckoo:
+		if len(filterBytes) > maxCuckooFilterLength {
+			return nil, fmt.Errorf("Filter too large, max: %d", maxCuckooFilterLength)
+		}
+		filter, err := cuckoo.Decode(filterBytes)
+		if err != nil {
+			return nil, err
+		}
+		return cuckooConsiderationFilter{filter}, nil
+
+	case FilterTypeKeySet:
+		if len(filterBytes)%ed25519.PublicKeySize != 0 {
+			return nil, fmt.Errorf("Invalid %s filter length: %d", FilterTypeKeySet, len(filterBytes))
+		}
+		if len(filterBytes)/ed25519.PublicKeySize > maxKeySetFilterKeys {
+			return nil, fmt.Errorf("Too many public keys, max: %d", maxKeySetFilterKeys)
+		}
+		filter := keySetConsiderationFilter{make(map[[ed25519.PublicKeySize]byte]bool)}
+		for i := 0; i < len(filterBytes); i += ed25519.PublicKeySize {
+			filter.Insert(filterBytes[i : i+ed25519.PublicKeySize])
+		}
+		return filter, nil
+	}
+	return nil, fmt.Errorf("Unsupported filter type: %s", filterType)
+}
+
+type cuckooConsiderationFilter struct {
+	*cuckoo.Filter
+}
+
+func (f cuckooConsiderationFilter) Type() string {
+	return FilterTypeCuckoo
+}
+
+// Exact set of public keys. It's encoded as their concatenation in sorted order
+type keySetConsiderationFilter struct {
+	keys map[[ed25519.PublicKeySize]byte]bool
+}
+
+func (f keySetConsiderationFilter) Type() string {
+	return FilterTypeKeySet
+}
+
+func (f keySetConsiderationFilter) Insert(data []byte) bool {
+	if len(data) != ed25519.PublicKeySize {
+		return false
+	}
+	var key [ed25519.PublicKeySize]byte
+	copy(key[:], data)
+	if f.keys[key] {
+		return true
+	}
+	if len(f.keys) >= maxKeySetFilterKeys {
+		return false
+	}
+	f.keys[key] = true
+	return true
+}
+
+func (f keySetConsiderationFilter) Lookup(data []byte) bool {
+	if len(data) != ed25519.PublicKeySize {
+		return false
+	}
+	var key [ed25519.PublicKeySize]byte
+	copy(key[:], data)
+	return f.keys[key]
+}
+
+func (f keySetConsiderationFilter) Encode() []byte {
+	keys := make([][]byte, 0, len(f.keys))
+	for key := range f.keys {
+		k := key
+		keys = append(keys, k[:])
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	return bytes.Join(keys, nil)
+}
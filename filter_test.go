@@ -0,0 +1,66 @@
+package focalpoint
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestConsiderationFilterEncoding(t *testing.T) {
+	var pubKeys []ed25519.PublicKey
+	for i := 0; i < 10; i++ {
+		pubKey, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filterType := range FilterTypes {
+		filter, err := NewConsiderationFilter(filterType, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pubKey := range pubKeys {
+			if !filter.Insert(pubKey) {
+				t.Fatalf("Unable to insert into %s filter", filterType)
+			}
+		}
+
+		// round trip it
+		filter2, err := DecodeConsiderationFilter(filterType, filter.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filter2.Type() != filterType {
+			t.Fatalf("Expected type %s, found %s", filterType, filter2.Type())
+		}
+		if !bytes.Equal(filter.Encode(), filter2.Encode()) {
+			t.Fatalf("Encoding of %s filter doesn't match", filterType)
+		}
+		for _, pubKey := range pubKeys {
+			if !filter2.Lookup(pubKey) {
+				t.Fatalf("Public key missing from %s filter", filterType)
+			}
+		}
+		if filterType == FilterTypeKeySet && filter2.Lookup(other) {
+			t.Fatal("Key set filter should be exact")
+		}
+	}
+
+	if _, err := DecodeConsiderationFilter("bloom", nil); err == nil {
+		t.Fatal("Expected unsupported filter type to fail")
+	}
+	if _, err := DecodeConsiderationFilter(FilterTypeKeySet, make([]byte, ed25519.PublicKeySize+1)); err == nil {
+		t.Fatal("Expected invalid key set length to fail")
+	}
+	if _, err := DecodeConsiderationFilter(FilterTypeKeySet,
+		make([]byte, (maxKeySetFilterKeys+1)*ed25519.PublicKeySize)); err == nil {
+		t.Fatal("Expected oversized key set to fail")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	. "github.com/inconsiderable/focal-point"
+	"github.com/inconsiderable/focal-point/internal/config"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -20,8 +21,13 @@ func main() {
 
 	memoPtr := flag.String("memo", "", "A memo to include in the genesis view's viewpoint memo field")
 	pubKeyPtr := flag.String("pubkey", "", "A public key to include in the genesis view's viewpoint output")
+	cfg := config.Register(flag.CommandLine, config.Logging)
 	flag.Parse()
 
+	if err := cfg.Apply(); err != nil {
+		log.Fatal(err)
+	}
+
 	if len(*memoPtr) == 0 {
 		log.Fatal("Memo required for genesis view")
 	}
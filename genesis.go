@@ -1,6 +1,8 @@
 package focalpoint
 
-const GenesisViewJson = `
+// GenesisViewJson is the genesis view of the active network. It can be overridden by a
+// network parameter bundle.
+var GenesisViewJson = `
 {
     "header": {
         "previous": "0000000000000000000000000000000000000000000000000000000000000000",
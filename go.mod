@@ -7,7 +7,7 @@ require (
 	github.com/c-bata/go-prompt v0.2.3
 	github.com/dgryski/go-metro v0.0.0-20180109044635-280f6062b5bc // indirect
 	github.com/glendc/go-external-ip v0.0.0-20170425150139-139229dcdddd
-	github.com/google/open-location-code/go v0.0.0-20240712113549-dfcebc905b81 // indirect
+	github.com/google/open-location-code/go v0.0.0-20240712113549-dfcebc905b81
 	github.com/gorilla/websocket v1.4.0
 	github.com/logrusorgru/aurora v0.0.0-20190428105938-cea283e61946
 	github.com/mattn/go-colorable v0.1.2 // indirect
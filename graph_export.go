@@ -0,0 +1,165 @@
+package focalpoint
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CombinedGraph is the union of several DOT graphs returned by peers for individual public keys.
+// Node IDs are the peer's graph indices so graphs must come from the same peer.
+type CombinedGraph struct {
+	nodes     map[string]*combinedGraphNode
+	edges     map[[2]string]string // weight
+	highlight map[string]bool      // public keys
+}
+
+type combinedGraphNode struct {
+	id    string
+	attrs [][2]string // in the order the peer wrote them
+}
+
+var (
+	dotEdgeRegexp = regexp.MustCompile(`^"([^"]*)" -> "([^"]*)" \[weight="([^"]*)"\];$`)
+	dotNodeRegexp = regexp.MustCompile(`^"([^"]*)" \[(.*)\];$`)
+	dotAttrRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// graphML attribute keys and types for node attributes written by Graph.ToDOT
+var graphMLNodeKeys = [][2]string{
+	{"label", "string"},
+	{"pubkey", "string"},
+	{"locale", "string"},
+	{"localeIndex", "int"},
+	{"ranking", "double"},
+	{"mind", "boolean"},
+}
+
+// MergeGraphs parses and combines DOT graphs. Nodes for the highlighted public keys are marked.
+func MergeGraphs(graphs []string, highlight []string) (*CombinedGraph, error) {
+	g := &CombinedGraph{
+		nodes:     make(map[string]*combinedGraphNode),
+		edges:     make(map[[2]string]string),
+		highlight: make(map[string]bool),
+	}
+	for _, pubKey := range highlight {
+		g.highlight[pubKey] = true
+	}
+	for _, graph := range graphs {
+		for _, line := range strings.Split(graph, "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) == 0 || line == "digraph G {" || line == "}" {
+				continue
+			}
+			if m := dotEdgeRegexp.FindStringSubmatch(line); m != nil {
+				g.edges[[2]string{m[1], m[2]}] = m[3]
+				continue
+			}
+			m := dotNodeRegexp.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("Unable to parse graph line: %s", line)
+			}
+			node := &combinedGraphNode{id: m[1]}
+			for _, attr := range dotAttrRegexp.FindAllStringSubmatch(m[2], -1) {
+				node.attrs = append(node.attrs, [2]string{attr[1], attr[2]})
+			}
+			g.nodes[node.id] = node
+		}
+	}
+	return g, nil
+}
+
+// DOT returns the combined graph in DOT format. Highlighted nodes are filled.
+func (g *CombinedGraph) DOT() string {
+	var builder strings.Builder
+	builder.WriteString("digraph G {\n")
+	for _, edge := range g.sortedEdges() {
+		builder.WriteString(fmt.Sprintf("  \"%s\" -> \"%s\" [weight=\"%s\"];\n",
+			edge[0], edge[1], g.edges[edge]))
+	}
+	for _, node := range g.sortedNodes() {
+		attrs := make([]string, 0, len(node.attrs)+2)
+		for _, attr := range node.attrs {
+			attrs = append(attrs, fmt.Sprintf("%s=\"%s\"", attr[0], attr[1]))
+		}
+		if g.isHighlighted(node) {
+			attrs = append(attrs, `mind="true"`, `style="filled"`, `fillcolor="lightblue"`)
+		}
+		builder.WriteString(fmt.Sprintf("  \"%s\" [%s];\n", node.id, strings.Join(attrs, ", ")))
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+// GraphML returns the combined graph in GraphML format. Highlighted nodes have mind set to true.
+func (g *CombinedGraph) GraphML() string {
+	var builder strings.Builder
+	escape := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	builder.WriteString(xml.Header)
+	builder.WriteString("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	for _, key := range graphMLNodeKeys {
+		builder.WriteString(fmt.Sprintf("  <key id=\"%s\" for=\"node\" attr.name=\"%s\" attr.type=\"%s\"/>\n",
+			key[0], key[0], key[1]))
+	}
+	builder.WriteString("  <key id=\"weight\" for=\"edge\" attr.name=\"weight\" attr.type=\"double\"/>\n")
+	builder.WriteString("  <graph id=\"G\" edgedefault=\"directed\">\n")
+	for _, node := range g.sortedNodes() {
+		builder.WriteString(fmt.Sprintf("    <node id=\"%s\">\n", escape(node.id)))
+		for _, attr := range node.attrs {
+			builder.WriteString(fmt.Sprintf("      <data key=\"%s\">%s</data>\n", escape(attr[0]), escape(attr[1])))
+		}
+		builder.WriteString(fmt.Sprintf("      <data key=\"mind\">%t</data>\n", g.isHighlighted(node)))
+		builder.WriteString("    </node>\n")
+	}
+	for _, edge := range g.sortedEdges() {
+		builder.WriteString(fmt.Sprintf("    <edge source=\"%s\" target=\"%s\">\n", escape(edge[0]), escape(edge[1])))
+		builder.WriteString(fmt.Sprintf("      <data key=\"weight\">%s</data>\n", escape(g.edges[edge])))
+		builder.WriteString("    </edge>\n")
+	}
+	builder.WriteString("  </graph>\n")
+	builder.WriteString("</graphml>\n")
+	return builder.String()
+}
+
+// Returns true if the node is one of the highlighted public keys
+func (g *CombinedGraph) isHighlighted(node *combinedGraphNode) bool {
+	for _, attr := range node.attrs {
+		if attr[0] == "pubkey" {
+			return g.highlight[attr[1]]
+		}
+	}
+	return false
+}
+
+// Nodes and edges are written in a stable order so exports can be diffed
+func (g *CombinedGraph) sortedNodes() []*combinedGraphNode {
+	nodes := make([]*combinedGraphNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].id < nodes[j].id
+	})
+	return nodes
+}
+
+func (g *CombinedGraph) sortedEdges() [][2]string {
+	edges := make([][2]string, 0, len(g.edges))
+	for edge := range g.edges {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
@@ -0,0 +1,48 @@
+package focalpoint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeGraphs(t *testing.T) {
+	graph1 := "digraph G {\n" +
+		"  \"1\" -> \"2\" [weight=\"1.000000\"];\n" +
+		"  \"1\" [label=\"a\", pubkey=\"keyA\", locale=\"\", localeIndex=\"-1\", ranking=\"0.500000\"];\n" +
+		"  \"2\" [label=\"b\", pubkey=\"keyB\", locale=\"\", localeIndex=\"-1\", ranking=\"0.250000\"];\n" +
+		"}\n"
+	graph2 := "digraph G {\n" +
+		"  \"1\" -> \"2\" [weight=\"1.000000\"];\n" +
+		"  \"3\" -> \"2\" [weight=\"2.000000\"];\n" +
+		"  \"2\" [label=\"b\", pubkey=\"keyB\", locale=\"\", localeIndex=\"-1\", ranking=\"0.250000\"];\n" +
+		"  \"3\" [label=\"c & d\", pubkey=\"keyC\", locale=\"\", localeIndex=\"-1\", ranking=\"0.125000\"];\n" +
+		"}\n"
+
+	g, err := MergeGraphs([]string{graph1, graph2}, []string{"keyA", "keyB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.nodes) != 3 || len(g.edges) != 2 {
+		t.Fatalf("Expected 3 nodes and 2 edges, found %d and %d", len(g.nodes), len(g.edges))
+	}
+
+	dot := g.DOT()
+	if strings.Count(dot, `mind="true"`) != 2 {
+		t.Fatalf("Expected 2 highlighted nodes:\n%s", dot)
+	}
+	if _, err := MergeGraphs([]string{dot}, nil); err != nil {
+		t.Fatalf("Unable to parse our own output: %s", err)
+	}
+
+	graphML := g.GraphML()
+	if strings.Count(graphML, "<data key=\"mind\">true</data>") != 2 {
+		t.Fatalf("Expected 2 highlighted nodes:\n%s", graphML)
+	}
+	if !strings.Contains(graphML, "c &amp; d") {
+		t.Fatalf("Expected escaped label:\n%s", graphML)
+	}
+
+	if _, err := MergeGraphs([]string{"digraph G {\n  nonsense\n}\n"}, nil); err == nil {
+		t.Fatal("Expected an error parsing an invalid graph")
+	}
+}
@@ -0,0 +1,100 @@
+package focalpoint
+
+import (
+	"sync"
+)
+
+// headerIndexDepth is how far below the main point tip the HeaderIndex keeps headers.
+// It covers the retarget walks and median timestamps with room left over for reorgs.
+const headerIndexDepth = RETARGET_INTERVAL + 100
+
+// viewHeaderSource is anything which can look up view headers by view ID.
+// It's satisfied by both ViewStorage and HeaderIndex.
+type viewHeaderSource interface {
+	GetViewHeader(id ViewID) (*ViewHeader, int64, error)
+}
+
+// viewIDForHeightSource is anything which can look up main point view IDs by height.
+// It's satisfied by both Ledger and HeaderIndex.
+type viewIDForHeightSource interface {
+	GetViewIDForHeight(height int64) (*ViewID, error)
+}
+
+// HeaderIndex keeps the headers of recent main point views in memory so that retarget
+// calculations, median timestamps and common ancestor walks don't repeatedly hit
+// leveldb and gob decoding. It's maintained as views are connected and disconnected.
+// Lookups it can't satisfy fall through to view storage.
+type HeaderIndex struct {
+	viewStore ViewStorage
+	ledger    Ledger
+	headers   map[ViewID]headerIndexEntry
+	byHeight  map[int64]ViewID
+	lock      sync.RWMutex
+}
+
+type headerIndexEntry struct {
+	header ViewHeader
+	when   int64
+}
+
+// NewHeaderIndex returns a new, empty HeaderIndex instance.
+func NewHeaderIndex(viewStore ViewStorage, ledger Ledger) *HeaderIndex {
+	return &HeaderIndex{
+		viewStore: viewStore,
+		ledger:    ledger,
+		headers:   make(map[ViewID]headerIndexEntry),
+		byHeight:  make(map[int64]ViewID),
+	}
+}
+
+// GetViewHeader returns the referenced view's header and the timestamp of when it was stored.
+func (h *HeaderIndex) GetViewHeader(id ViewID) (*ViewHeader, int64, error) {
+	h.lock.RLock()
+	entry, ok := h.headers[id]
+	h.lock.RUnlock()
+	if ok {
+		// hand out a copy so callers can't modify the index
+		header := entry.header
+		return &header, entry.when, nil
+	}
+	return h.viewStore.GetViewHeader(id)
+}
+
+// GetViewIDForHeight returns the ID of the main point view at the given height.
+func (h *HeaderIndex) GetViewIDForHeight(height int64) (*ViewID, error) {
+	h.lock.RLock()
+	id, ok := h.byHeight[height]
+	h.lock.RUnlock()
+	if ok {
+		return &id, nil
+	}
+	return h.ledger.GetViewIDForHeight(height)
+}
+
+// Connect is called when a view is connected to the main point tip.
+func (h *HeaderIndex) Connect(id ViewID, header *ViewHeader, when int64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	entry := headerIndexEntry{header: *header, when: when}
+	entry.header.hasher = nil
+	h.headers[id] = entry
+	h.byHeight[header.Height] = id
+
+	// forget views which have fallen too far behind the tip
+	oldHeight := header.Height - headerIndexDepth
+	if oldID, ok := h.byHeight[oldHeight]; ok {
+		delete(h.headers, oldID)
+		delete(h.byHeight, oldHeight)
+	}
+}
+
+// Disconnect is called when a view is disconnected from the main point tip.
+func (h *HeaderIndex) Disconnect(id ViewID, header *ViewHeader) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.headers, id)
+	if h.byHeight[header.Height] == id {
+		delete(h.byHeight, header.Height)
+	}
+}
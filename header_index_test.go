@@ -0,0 +1,55 @@
+package focalpoint
+
+import (
+	"testing"
+)
+
+func TestHeaderIndex(t *testing.T) {
+	h := NewHeaderIndex(nil, nil)
+
+	// connect a point longer than the index depth
+	var prevID ViewID
+	var ids []ViewID
+	for height := int64(0); height <= headerIndexDepth+1; height++ {
+		var id ViewID
+		id[0], id[1] = byte(height>>8), byte(height)
+		h.Connect(id, &ViewHeader{Previous: prevID, Height: height, Time: height}, height)
+		ids = append(ids, id)
+		prevID = id
+	}
+	if len(h.headers) != headerIndexDepth || len(h.byHeight) != headerIndexDepth {
+		t.Fatalf("Expected %d headers, found %d", headerIndexDepth, len(h.headers))
+	}
+	if _, ok := h.headers[ids[0]]; ok {
+		t.Fatal("Expected oldest header to be evicted")
+	}
+
+	header, when, err := h.GetViewHeader(prevID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Height != headerIndexDepth+1 || when != headerIndexDepth+1 {
+		t.Fatalf("Unexpected header at height %d", header.Height)
+	}
+
+	// callers get a copy
+	header.Height = 0
+	if h.headers[prevID].header.Height != headerIndexDepth+1 {
+		t.Fatal("Index modified through returned header")
+	}
+
+	h.Disconnect(prevID, &ViewHeader{Height: headerIndexDepth + 1})
+	if _, ok := h.headers[prevID]; ok {
+		t.Fatal("Expected disconnected header to be removed")
+	}
+	if _, ok := h.byHeight[headerIndexDepth+1]; ok {
+		t.Fatal("Expected disconnected height to be removed")
+	}
+	id, err := h.GetViewIDForHeight(headerIndexDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *id != ids[headerIndexDepth] {
+		t.Fatal("View ID mismatch for height")
+	}
+}
@@ -0,0 +1,72 @@
+package focalpoint
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// ScanPublicKeyConsiderations returns consideration indices involving a given public key over a range
+// of main point heights by reading stored views. It's used to answer history queries over heights a
+// pruned ledger no longer indexes. If startHeight > endHeight this iterates in reverse.
+// At most maxViews views are read. If it runs out before reaching endHeight or the limit, truncated is
+// true and scannedHeight is the last height read.
+func ScanPublicKeyConsiderations(ledger Ledger, viewStore ViewStorage, pubKey ed25519.PublicKey,
+	startHeight, endHeight int64, startIndex, limit, maxViews int) (
+	ids []ViewID, indices []int, lastHeight int64, lastIndex int, scannedHeight int64, truncated bool, err error) {
+
+	step := int64(1)
+	if startHeight > endHeight {
+		step = -1
+	}
+
+	var numViews int
+	for height := startHeight; height*step <= endHeight*step; height += step {
+		if numViews == maxViews {
+			return ids, indices, lastHeight, lastIndex, height - step, true, nil
+		}
+		numViews++
+
+		id, err := ledger.GetViewIDForHeight(height)
+		if err != nil {
+			return nil, nil, 0, 0, 0, false, err
+		}
+		if id == nil {
+			// past the tip
+			break
+		}
+		view, err := viewStore.GetView(*id)
+		if err != nil {
+			return nil, nil, 0, 0, 0, false, err
+		}
+		if view == nil {
+			return nil, nil, 0, 0, 0, false, fmt.Errorf("Missing view %s at height %d", *id, height)
+		}
+
+		// the range of indices to check in this view
+		first, last := 0, len(view.Considerations)-1
+		if height == startHeight {
+			if step == 1 {
+				first = startIndex
+			} else if startIndex < last {
+				last = startIndex
+			}
+		}
+		for j := first; j <= last; j++ {
+			i := j
+			if step == -1 {
+				i = first + last - j
+			}
+			if !view.Considerations[i].Contains(pubKey) {
+				continue
+			}
+			ids = append(ids, *id)
+			indices = append(indices, i)
+			lastHeight, lastIndex = height, i
+			if limit != 0 && len(indices) == limit {
+				return ids, indices, lastHeight, lastIndex, height, false, nil
+			}
+		}
+	}
+	return ids, indices, lastHeight, lastIndex, endHeight, false, nil
+}
@@ -0,0 +1,104 @@
+package focalpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestScanPublicKeyConsiderations(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey2, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	// every other view is rendered to the second key
+	var previous ViewID
+	for height := int64(0); height < 6; height++ {
+		forKey := pubKey
+		if height%2 == 1 {
+			forKey = pubKey2
+		}
+		viewpoint := NewConsideration(nil, forKey, 0, 0, height, "")
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.ConnectView(id, view); err != nil {
+			t.Fatal(err)
+		}
+		previous = id
+	}
+
+	// the scan should agree with the index
+	for _, r := range [][2]int64{{0, 5}, {5, 0}, {2, 4}} {
+		ids, indices, lastHeight, _, _, truncated, err := ScanPublicKeyConsiderations(
+			ledger, viewStore, pubKey2, r[0], r[1], 0, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if truncated {
+			t.Fatalf("Scan of %v unexpectedly truncated", r)
+		}
+		indexIDs, indexIndices, indexLastHeight, _, err := ledger.GetPublicKeyConsiderationIndicesRange(
+			pubKey2, r[0], r[1], 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, indexIDs) || !reflect.DeepEqual(indices, indexIndices) ||
+			lastHeight != indexLastHeight {
+			t.Fatalf("Scan of %v differs from the index", r)
+		}
+	}
+
+	// stop after reading 2 views
+	ids, _, lastHeight, _, scannedHeight, truncated, err := ScanPublicKeyConsiderations(
+		ledger, viewStore, pubKey2, 0, 5, 0, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated || scannedHeight != 1 || len(ids) != 1 || lastHeight != 1 {
+		t.Fatalf("Unexpected truncated scan, found: %d, scanned to: %d", len(ids), scannedHeight)
+	}
+
+	// the limit stops it first
+	ids, _, lastHeight, _, _, truncated, err = ScanPublicKeyConsiderations(
+		ledger, viewStore, pubKey2, 5, 0, 0, 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated || len(ids) != 2 || lastHeight != 3 {
+		t.Fatalf("Unexpected limited scan, found: %d, last height: %d", len(ids), lastHeight)
+	}
+}
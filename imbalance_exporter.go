@@ -0,0 +1,171 @@
+package focalpoint
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// ImbalanceExporter periodically dumps the complete public key imbalance table to a CSV file
+// for use by downstream analytics. Each dump is taken from a consistent snapshot of the ledger.
+type ImbalanceExporter struct {
+	dirPath      string
+	interval     int64
+	ledger       Ledger
+	processor    *Processor
+	indexer      *Indexer // rankings are included if set
+	exportChan   chan int64
+	shutdownChan chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewImbalanceExporter returns a new ImbalanceExporter instance which writes a dump to the given
+// directory every interval views. Pass an indexer to include each public key's ranking.
+func NewImbalanceExporter(dirPath string, interval int64, ledger Ledger, processor *Processor,
+	indexer *Indexer) *ImbalanceExporter {
+	return &ImbalanceExporter{
+		dirPath:      dirPath,
+		interval:     interval,
+		ledger:       ledger,
+		processor:    processor,
+		indexer:      indexer,
+		exportChan:   make(chan int64, 1),
+		shutdownChan: make(chan struct{}),
+	}
+}
+
+// Run executes the ImbalanceExporter's main loop in its own goroutine.
+func (e *ImbalanceExporter) Run() error {
+	if err := os.MkdirAll(e.dirPath, 0755); err != nil {
+		return err
+	}
+	e.wg.Add(2)
+	go e.run()
+	go e.export()
+	return nil
+}
+
+func (e *ImbalanceExporter) run() {
+	defer e.wg.Done()
+
+	// register for tip changes
+	tipChangeChan := make(chan TipChange, 10)
+	e.processor.RegisterForTipChange(tipChangeChan)
+	defer func() { e.processor.UnregisterForTipChange(tipChangeChan) }()
+
+	for {
+		select {
+		case tip, ok := <-tipChangeChan:
+			if !ok {
+				// cut off for falling behind. the next export height is still picked up
+				log.Println("Imbalance exporter missed tip changes")
+				tipChangeChan = make(chan TipChange, 10)
+				e.processor.RegisterForTipChange(tipChangeChan)
+				break
+			}
+			if !tip.Connect || tip.View.Header.Height%e.interval != 0 {
+				break
+			}
+			// don't hold up the processor. if a dump is already queued it will cover this one
+			select {
+			case e.exportChan <- tip.View.Header.Height:
+			default:
+			}
+
+		case _, ok := <-e.shutdownChan:
+			if !ok {
+				log.Println("Imbalance exporter shutting down...")
+				return
+			}
+		}
+	}
+}
+
+// Write dumps as they're requested
+func (e *ImbalanceExporter) export() {
+	defer e.wg.Done()
+	for {
+		select {
+		case height := <-e.exportChan:
+			log.Printf("Exporting imbalances at height %d\n", height)
+			path, err := e.Export()
+			if err != nil {
+				log.Printf("Error exporting imbalances: %s\n", err)
+				break
+			}
+			log.Printf("Imbalances exported to %s\n", path)
+
+		case _, ok := <-e.shutdownChan:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// Export writes a dump of the current imbalance table and returns the path of the file.
+func (e *ImbalanceExporter) Export() (string, error) {
+	// write to a temporary file first so consumers never see a partial dump
+	tmpFile, err := os.Create(filepath.Join(e.dirPath, ".imbalances.csv.tmp"))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	w := csv.NewWriter(tmpFile)
+	header := []string{"public_key", "imbalance"}
+	var snapshot *GraphSnapshot
+	if e.indexer != nil {
+		snapshot = e.indexer.GraphSnapshot()
+		header = append(header, "ranking")
+	}
+	if err := w.Write(header); err != nil {
+		return "", err
+	}
+
+	_, height, err := e.ledger.ForEachPublicKeyImbalance(func(pubKey ed25519.PublicKey, imbalance int64) error {
+		record := []string{
+			base64.StdEncoding.EncodeToString(pubKey),
+			strconv.FormatInt(imbalance, 10),
+		}
+		if snapshot != nil {
+			ranking, _ := snapshot.Ranking(pubKey)
+			record = append(record, strconv.FormatFloat(ranking, 'f', -1, 64))
+		}
+		return w.Write(record)
+	})
+	if err != nil {
+		return "", err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", err
+	}
+
+	// the snapshot may be ahead of the view which triggered the dump so name it after the snapshot
+	name := fmt.Sprintf("imbalances-%d-%s.csv", height, time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(e.dirPath, name)
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Shutdown stops the imbalance exporter synchronously.
+func (e *ImbalanceExporter) Shutdown() {
+	close(e.shutdownChan)
+	e.wg.Wait()
+	log.Println("Imbalance exporter shutdown")
+}
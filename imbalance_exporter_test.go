@@ -0,0 +1,132 @@
+package focalpoint
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestImbalanceExporter(t *testing.T) {
+	var pubKeys []ed25519.PublicKey
+	for i := 0; i < 3; i++ {
+		pubKey, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	dir, err := ioutil.TempDir("", "exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	// mature viewpoints in the next view so there's something to spend
+	defer func(maturity int64) { VIEWPOINT_MATURITY = maturity }(VIEWPOINT_MATURITY)
+	VIEWPOINT_MATURITY = 1
+
+	var previous ViewID
+	for height, cns := range [][]*Consideration{
+		{NewConsideration(nil, pubKeys[0], 0, 0, 0, "")},
+		{NewConsideration(nil, pubKeys[0], 0, 0, 1, "")},
+		{
+			NewConsideration(nil, pubKeys[1], 0, 0, 2, ""),
+			NewConsideration(pubKeys[0], pubKeys[2], 0, 0, 2, ""),
+		},
+	} {
+		view, err := NewView(previous, int64(height), ViewID{}, ViewID{}, cns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.ConnectView(id, view); err != nil {
+			t.Fatal(err)
+		}
+		previous = id
+	}
+
+	exportDir := filepath.Join(dir, "exports")
+	if err := os.Mkdir(exportDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	exporter := NewImbalanceExporter(exportDir, 1, ledger, nil, nil)
+	path, err := exporter.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(filepath.Base(path), "imbalances-2-") {
+		t.Fatalf("Expected the dump to be named after height 2, found %s", filepath.Base(path))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != "public_key,imbalance" {
+		t.Fatal("Expected a header row")
+	}
+
+	// one row per public key with an imbalance, matching the ledger
+	rows := make(map[string]string)
+	for _, record := range records[1:] {
+		rows[record[0]] = record[1]
+	}
+	var expected int
+	for _, pubKey := range pubKeys {
+		imbalance, err := ledger.GetPublicKeyImbalance(pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imbalance == 0 {
+			continue
+		}
+		expected++
+		row, ok := rows[base64.StdEncoding.EncodeToString(pubKey)]
+		if !ok {
+			t.Fatalf("Missing row for public key %d", expected)
+		}
+		if row != strconv.FormatInt(imbalance, 10) {
+			t.Fatalf("Expected imbalance %d, found %s", imbalance, row)
+		}
+	}
+	if expected == 0 || len(rows) != expected {
+		t.Fatalf("Expected %d rows, found %d", expected, len(rows))
+	}
+
+	// no temporary file is left behind
+	infos, err := ioutil.ReadDir(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("Expected 1 file in the export directory, found %d", len(infos))
+	}
+}
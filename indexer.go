@@ -10,21 +10,81 @@ import (
 	"time"
 
 	olc "github.com/google/open-location-code/go"
+	"golang.org/x/crypto/ed25519"
 )
 
 type Indexer struct {
 	viewStore    ViewStorage
 	ledger       Ledger
 	processor    *Processor
+	latestLock   sync.RWMutex // guards latestViewID and latestHeight for other goroutines
 	latestViewID ViewID
 	latestHeight int64
 	cnGraph      *Graph
 	Indices  	 *OrderedHashSet
 	synonyms     map[string]string
+	snapshotLock sync.RWMutex
+	snapshot     *GraphSnapshot
+	genesisID    ViewID
+	indexed      bool // whether the view at latestViewID has been indexed
+	changed      bool // views have been indexed or unindexed since the last snapshot
+	stateLock    sync.RWMutex
+	state        string
+	controlChan  chan indexerControl
 	shutdownChan chan struct{}
 	wg           sync.WaitGroup
 }
 
+// Indexer ranking states. Considerations are linked into the graph in every state since the
+// descendant rule is checked against it. The states only govern the expensive rank passes.
+const (
+	IndexerRunning  = "running"  // the graph is ranked after each new tip
+	IndexerPaused   = "paused"   // no rank passes. queries are answered as of the last snapshot
+	IndexerDisabled = "disabled" // no rank passes. ranking queries are refused
+)
+
+// a request from another goroutine to change the indexer's state
+type indexerControl struct {
+	action     string
+	height     int64
+	resultChan chan error
+}
+
+// GraphSnapshot is an unchanging copy of the indexer's graph taken after a rank pass. Graph queries
+// are answered from it so they're consistent while the indexer links and ranks the next views.
+type GraphSnapshot struct {
+	ViewID   ViewID
+	Height   int64
+	graph    *Graph
+	indices  []string
+	synonyms map[string]string
+}
+
+// Ranking returns a public key's ranking and whether it's in the graph.
+func (s *GraphSnapshot) Ranking(pubKey ed25519.PublicKey) (float64, bool) {
+	index, ok := s.graph.index[pubKeyToString(pubKey)]
+	if !ok {
+		return 0, false
+	}
+	return s.graph.nodes[index].ranking, true
+}
+
+// Locale returns the locale a public key belongs to, if any.
+func (s *GraphSnapshot) Locale(pubKey ed25519.PublicKey) string {
+	_, locale, _ := localeFromPubKey(pubKeyToString(pubKey), s.indices)
+	return locale
+}
+
+// IsParentDescendant returns true if descendant descends from parent in the graph.
+func (s *GraphSnapshot) IsParentDescendant(parent, descendant ed25519.PublicKey) bool {
+	return s.graph.IsParentDescendant(pubKeyToString(parent), pubKeyToString(descendant))
+}
+
+// ToDOT returns a public key's part of the graph in DOT format.
+func (s *GraphSnapshot) ToDOT(pubKey ed25519.PublicKey) string {
+	return s.graph.ToDOT(pubKeyToString(pubKey), s.indices, s.synonyms)
+}
+
 func NewIndexer(
 	conGraph *Graph,
 	viewStore ViewStorage,
@@ -32,19 +92,117 @@ func NewIndexer(
 	processor *Processor,
 	genesisViewID ViewID,
 ) *Indexer {
-	fpHashset := NewOrderedHashSet()
-	fpHashset.Add(padTo44Characters("0"))
-	return &Indexer{
+	idx := &Indexer{
 		cnGraph:      conGraph,
 		viewStore:    viewStore,
 		ledger:       ledger,
 		processor:    processor,
 		latestViewID: genesisViewID,
 		latestHeight: 0,
-		Indices:  	  fpHashset,
+		Indices:  	  newIndices(),
 		synonyms:     make(map[string]string),
+		genesisID:    genesisViewID,
+		state:        IndexerRunning,
+		controlChan:  make(chan indexerControl),
 		shutdownChan: make(chan struct{}),
 	}
+	idx.takeSnapshot()
+	return idx
+}
+
+func newIndices() *OrderedHashSet {
+	fpHashset := NewOrderedHashSet()
+	fpHashset.Add(padTo44Characters("0"))
+	return fpHashset
+}
+
+// State returns the indexer's ranking state.
+func (idx *Indexer) State() string {
+	idx.stateLock.RLock()
+	defer idx.stateLock.RUnlock()
+	return idx.state
+}
+
+func (idx *Indexer) setState(state string) {
+	idx.stateLock.Lock()
+	idx.state = state
+	idx.stateLock.Unlock()
+}
+
+// PauseRanking stops rank passes until ResumeRanking is called. Queries are answered from the
+// graph as of the last rank pass in the meantime.
+func (idx *Indexer) PauseRanking() error {
+	return idx.control("pause", 0)
+}
+
+// DisableRanking stops rank passes and ranking queries until ResumeRanking is called, for
+// consensus-only operation. Descendant queries are still answered from the current graph.
+func (idx *Indexer) DisableRanking() error {
+	return idx.control("disable", 0)
+}
+
+// ResumeRanking ranks the graph now and after each new tip again.
+func (idx *Indexer) ResumeRanking() error {
+	return idx.control("resume", 0)
+}
+
+// Rebuild drops everything indexed from the given height on and re-derives it from the ledger.
+// With a height of 0 the graph is rebuilt from scratch. The new graph replaces the old one only
+// once it's complete, so the descendant rule is never checked against a partial graph.
+func (idx *Indexer) Rebuild(height int64) error {
+	return idx.control("rebuild", height)
+}
+
+func (idx *Indexer) control(action string, height int64) error {
+	resultChan := make(chan error)
+	select {
+	case idx.controlChan <- indexerControl{action: action, height: height, resultChan: resultChan}:
+	case <-idx.shutdownChan:
+		return fmt.Errorf("Indexer is shutting down")
+	}
+	return <-resultChan
+}
+
+// Latest returns the ID and height of the latest view the indexer has indexed.
+// It's safe to call from any goroutine.
+func (idx *Indexer) Latest() (ViewID, int64) {
+	idx.latestLock.RLock()
+	defer idx.latestLock.RUnlock()
+	return idx.latestViewID, idx.latestHeight
+}
+
+// Record the latest indexed view. Only the indexer's goroutine changes them so it reads them
+// without the lock
+func (idx *Indexer) setLatest(id ViewID, height int64) {
+	idx.latestLock.Lock()
+	idx.latestViewID, idx.latestHeight = id, height
+	idx.latestLock.Unlock()
+}
+
+// GraphSnapshot returns the graph as of the last rank pass.
+func (idx *Indexer) GraphSnapshot() *GraphSnapshot {
+	idx.snapshotLock.RLock()
+	defer idx.snapshotLock.RUnlock()
+	return idx.snapshot
+}
+
+// Publish a copy of the graph for readers. Only called from the indexer's goroutine
+// or before it's started
+func (idx *Indexer) takeSnapshot() {
+	indices := idx.Indices.Values()
+	snapshot := &GraphSnapshot{
+		ViewID:   idx.latestViewID,
+		Height:   idx.latestHeight,
+		graph:    idx.cnGraph.Copy(),
+		indices:  append(make([]string, 0, len(indices)), indices...),
+		synonyms: make(map[string]string, len(idx.synonyms)),
+	}
+	for k, v := range idx.synonyms {
+		snapshot.synonyms[k] = v
+	}
+	idx.snapshotLock.Lock()
+	idx.snapshot = snapshot
+	idx.snapshotLock.Unlock()
 }
 
 // Run executes the indexer's main loop in its own goroutine.
@@ -56,116 +214,241 @@ func (idx *Indexer) Run() {
 func (idx *Indexer) run() {
 	defer idx.wg.Done()
 
-	ticker := time.NewTicker(30 * time.Second)
-
 	// don't start indexing until we think we're synced.
 	// we're just wasting time and slowing down the sync otherwise
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	syncChan := ticker.C
+
+	var tipChangeChan chan TipChange
+	defer func() {
+		if tipChangeChan != nil {
+			idx.processor.UnregisterForTipChange(tipChangeChan)
+		}
+	}()
+
+	start := func() {
+		ticker.Stop()
+		syncChan = nil
+
+		// register first so no tip change is missed while catching up
+		tipChangeChan = make(chan TipChange, 1)
+		idx.processor.RegisterForTipChange(tipChangeChan)
+
+		if err := idx.catchUp(); err != nil {
+			log.Printf("Error: %s\n", err)
+		}
+		log.Printf("Finished indexing at height %v", idx.latestHeight)
+		log.Printf("Latest indexed viewID: %v", idx.latestViewID)
+		idx.rankGraph()
+	}
+
 	ibd, _, err := IsInitialViewDownload(idx.ledger, idx.viewStore)
 	if err != nil {
 		panic(err)
 	}
 	if ibd {
 		log.Printf("Indexer waiting for focalpoint sync\n")
-	ready:
-		for {
-			select {
-			case _, ok := <-idx.shutdownChan:
-				if !ok {
-					log.Printf("Indexer shutting down...\n")
-					return
-				}
-			case <-ticker.C:
-				var err error
-				ibd, _, err = IsInitialViewDownload(idx.ledger, idx.viewStore)
-				if err != nil {
-					panic(err)
-				}
-				if !ibd {
-					// time to start indexing
-					break ready
-				}
+	} else {
+		start()
+	}
+
+	for {
+		select {
+		case <-syncChan:
+			ibd, _, err := IsInitialViewDownload(idx.ledger, idx.viewStore)
+			if err != nil {
+				panic(err)
+			}
+			if !ibd {
+				// time to start indexing
+				start()
+			}
+
+		case tip, ok := <-tipChangeChan:
+			if !ok {
+				// cut off for falling behind. register again and catch up with the point
+				log.Println("Indexer missed tip changes")
+				start()
+				break
+			}
+			log.Printf("Indexer received notice of new tip view: %s at height: %d\n", tip.ViewID, tip.View.Header.Height)
+			// follow the main point rather than the notification so nothing is skipped
+			// if notifications were dropped
+			if err := idx.catchUp(); err != nil {
+				log.Printf("Error: %s\n", err)
+			}
+			if !tip.More {
+				idx.rankGraph()
+			}
+
+		case req := <-idx.controlChan:
+			req.resultChan <- idx.onControl(req, tipChangeChan != nil)
+
+		case _, ok := <-idx.shutdownChan:
+			if !ok {
+				log.Printf("Indexer shutting down...\n")
+				return
 			}
 		}
 	}
+}
 
-	ticker.Stop()
+// Handle a control request. started is false while waiting for the sync to finish
+func (idx *Indexer) onControl(req indexerControl, started bool) error {
+	log.Printf("Indexer received %s request\n", req.action)
+	switch req.action {
+	case "pause":
+		idx.setState(IndexerPaused)
+		return nil
+
+	case "disable":
+		idx.setState(IndexerDisabled)
+		if started {
+			// keep descent queries current from here on
+			idx.changed = true
+			idx.rankGraph()
+		}
+		return nil
+
+	case "resume":
+		idx.setState(IndexerRunning)
+		if started {
+			// rankings may be stale even if nothing was indexed since the last snapshot
+			idx.changed = true
+			idx.rankGraph()
+		}
+		return nil
 
-	header, _, err := idx.viewStore.GetViewHeader(idx.latestViewID)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	if header == nil {
-		// don't have it
-		log.Println(err)
-		return
-	}
-	branchType, err := idx.ledger.GetBranchType(idx.latestViewID)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	if branchType != MAIN {
-		// not on the main branch
-		log.Println(err)
-		return
+	case "rebuild":
+		if !started {
+			return fmt.Errorf("The indexer hasn't started, it's waiting for the sync to finish")
+		}
+		if err := idx.rebuild(req.height); err != nil {
+			return err
+		}
+		idx.rankGraph()
+		return nil
 	}
+	return fmt.Errorf("Unknown indexer action: %s", req.action)
+}
 
-	var height int64 = header.Height
-	for {
-		nextID, err := idx.ledger.GetViewIDForHeight(height)
+// Bring the graph in line with the main point. Views disconnected since they were indexed are
+// unindexed, then each view connected after the latest indexed one is indexed
+func (idx *Indexer) catchUp() error {
+	for idx.indexed {
+		id, err := idx.ledger.GetViewIDForHeight(idx.latestHeight)
 		if err != nil {
-			log.Println(err)
-			return
+			return err
 		}
-		if nextID == nil {
-			height -= 1
+		if id != nil && *id == idx.latestViewID {
 			break
 		}
+		if err := idx.unindexLatest(); err != nil {
+			return err
+		}
+	}
 
-		view, err := idx.viewStore.GetView(*nextID)
+	height := idx.latestHeight + 1
+	if !idx.indexed {
+		height = 0
+	}
+	for {
+		id, err := idx.ledger.GetViewIDForHeight(height)
 		if err != nil {
-			// not found
-			log.Println(err)
-			return
+			return err
+		}
+		if id == nil {
+			return nil
+		}
+		view, err := idx.viewStore.GetView(*id)
+		if err != nil {
+			return err
 		}
-
 		if view == nil {
 			// not found
-			log.Printf("No view found with ID %v", nextID)
-			return
+			return fmt.Errorf("No view found with ID %s", *id)
 		}
-
-		idx.indexConsiderations(view, *nextID, true)
-
-		height += 1
+		idx.indexConsiderations(view, *id, true)
+		idx.indexed = true
+		idx.changed = true
+		height++
 	}
+}
 
-	log.Printf("Finished indexing at height %v", idx.latestHeight)
-	log.Printf("Latest indexed viewID: %v", idx.latestViewID)
-
-	idx.rankGraph()
+// Reverse the latest indexed view's considerations and step back to its parent
+func (idx *Indexer) unindexLatest() error {
+	id := idx.latestViewID
+	view, err := idx.viewStore.GetView(id)
+	if err != nil {
+		return err
+	}
+	if view == nil {
+		// not found
+		return fmt.Errorf("No view found with ID %s", id)
+	}
+	idx.indexConsiderations(view, id, false)
+	idx.changed = true
+	if view.Header.Height == 0 {
+		idx.setLatest(idx.genesisID, 0)
+		idx.indexed = false
+		return nil
+	}
+	idx.setLatest(view.Header.Previous, view.Header.Height-1)
+	return nil
+}
 
-	// register for tip changes
-	tipChangeChan := make(chan TipChange, 1)
-	idx.processor.RegisterForTipChange(tipChangeChan)
-	defer idx.processor.UnregisterForTipChange(tipChangeChan)
+// Re-derive the graph from the given height on a copy and swap it into the shared graph once it's
+// caught up with the main point. On error the indexer's state is left as it was
+func (idx *Indexer) rebuild(height int64) error {
+	if height < 0 {
+		return fmt.Errorf("Invalid height %d", height)
+	}
+	if idx.indexed && height > idx.latestHeight+1 {
+		return fmt.Errorf("The graph is only indexed to height %d", idx.latestHeight)
+	}
+	log.Printf("Indexer rebuilding the graph from height %d\n", height)
+
+	shared, indices, synonyms := idx.cnGraph, idx.Indices, idx.synonyms
+	latestViewID, latestHeight, indexed := idx.latestViewID, idx.latestHeight, idx.indexed
+	restore := func() {
+		idx.cnGraph, idx.Indices, idx.synonyms = shared, indices, synonyms
+		idx.setLatest(latestViewID, latestHeight)
+		idx.indexed = indexed
+	}
 
-	for {
-		select {
-		case tip := <-tipChangeChan:
-			log.Printf("Indexer received notice of new tip view: %s at height: %d\n", tip.ViewID, tip.View.Header.Height)
-			idx.indexConsiderations(tip.View, tip.ViewID, tip.Connect) //Todo: Make sure no consideration is skipped.
-			if !tip.More {
-				idx.rankGraph()
-			}
-		case _, ok := <-idx.shutdownChan:
-			if !ok {
-				log.Printf("Indexer shutting down...\n")
-				return
+	idx.synonyms = make(map[string]string, len(synonyms))
+	if height == 0 {
+		idx.cnGraph = NewGraph()
+		idx.Indices = newIndices()
+		idx.setLatest(idx.genesisID, 0)
+		idx.indexed = false
+	} else {
+		idx.cnGraph = shared.Copy()
+		idx.Indices = NewOrderedHashSet()
+		for _, index := range indices.Values() {
+			idx.Indices.Add(index)
+		}
+		for k, v := range synonyms {
+			idx.synonyms[k] = v
+		}
+		for idx.indexed && idx.latestHeight >= height {
+			if err := idx.unindexLatest(); err != nil {
+				restore()
+				return err
 			}
 		}
 	}
+	if err := idx.catchUp(); err != nil {
+		restore()
+		return err
+	}
+
+	shared.Assign(idx.cnGraph)
+	idx.cnGraph = shared
+	idx.changed = true
+	log.Printf("Indexer finished rebuilding at height %d\n", idx.latestHeight)
+	return nil
 }
 
 // localeIndex returns the index of a locale in the localePoints slice.
@@ -238,15 +521,28 @@ func inflateNodes(pubKey string) (bool, string, []string, string) {
 	return nodesOk, locale, nodes, notes
 }
 
+// Rank the graph and publish a snapshot if anything was indexed since the last one.
+// While paused nothing is published. While disabled the snapshot is published unranked
 func (idx *Indexer) rankGraph() {
-	log.Printf("Indexer ranking at height: %d\n", idx.latestHeight)
-	idx.cnGraph.Rank(1.0, 1e-6)
-	log.Printf("Ranking finished")
+	if !idx.changed {
+		return
+	}
+	switch idx.State() {
+	case IndexerPaused:
+		return
+	case IndexerDisabled:
+		idx.takeSnapshot()
+	default:
+		log.Printf("Indexer ranking at height: %d\n", idx.latestHeight)
+		idx.cnGraph.Rank(1.0, 1e-6)
+		idx.takeSnapshot()
+		log.Printf("Ranking finished")
+	}
+	idx.changed = false
 }
 
 func (idx *Indexer) indexConsiderations(view *View, id ViewID, increment bool) {
-	idx.latestViewID = id
-	idx.latestHeight = view.Header.Height
+	idx.setLatest(id, view.Header.Height)
 	incrementBy := 0.00
 
 	if increment {
@@ -7,22 +7,22 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 
 	. "github.com/inconsiderable/focal-point"
+	"github.com/inconsiderable/focal-point/internal/config"
 	"github.com/logrusorgru/aurora"
 	"golang.org/x/crypto/ed25519"
 )
 
-// A small tool to inspect the focal point and ledger offline
+// A small tool to inspect the focal point and ledger, from a replica if the client is running
 func main() {
 	var commands = []string{
 		"height", "imbalance", "imbalance_at", "view", "view_at", "cn", "history", "verify",
+		"recompress", "sidetips", "branch", "peers",
 	}
 
-	dataDirPtr := flag.String("datadir", "", "Path to a directory containing focal point data")
 	pubKeyPtr := flag.String("pubkey", "", "Base64 encoded public key")
 	cmdPtr := flag.String("command", "height", "Commands: "+strings.Join(commands, ", "))
 	heightPtr := flag.Int("height", 0, "View point height")
@@ -32,11 +32,15 @@ func main() {
 	startIndexPtr := flag.Int("start_index", 0, "Start consideration index (for use with \"history\")")
 	endHeightPtr := flag.Int("end_height", 0, "End view height (for use with \"history\")")
 	limitPtr := flag.Int("limit", 3, "Limit (for use with \"history\")")
+	compressPtr := flag.Bool("compress", false, "Convert views to lz4 instead of JSON (for use with \"recompress\")")
+	cfg := config.Register(flag.CommandLine, config.DataDir|config.Network|config.Logging)
 	flag.Parse()
 
-	if len(*dataDirPtr) == 0 {
-		log.Printf("You must specify a -datadir\n")
-		os.Exit(-1)
+	if err := cfg.Apply(); err != nil {
+		fatal(err)
+	}
+	if err := CheckDatadirVersion(cfg.DataDir); err != nil {
+		fatal(err)
 	}
 
 	var pubKey ed25519.PublicKey
@@ -44,7 +48,7 @@ func main() {
 		// decode the key
 		pubKeyBytes, err := base64.StdEncoding.DecodeString(*pubKeyPtr)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		pubKey = ed25519.PublicKey(pubKeyBytes)
 	}
@@ -53,7 +57,7 @@ func main() {
 	if len(*viewIDPtr) != 0 {
 		viewIDBytes, err := hex.DecodeString(*viewIDPtr)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		viewID = new(ViewID)
 		copy(viewID[:], viewIDBytes)
@@ -63,38 +67,73 @@ func main() {
 	if len(*cnIDPtr) != 0 {
 		cnIDBytes, err := hex.DecodeString(*cnIDPtr)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		cnID = new(ConsiderationID)
 		copy(cnID[:], cnIDBytes)
 	}
 
+	if *cmdPtr == "recompress" {
+		// this needs write access. opening the headers database fails if the client is running
+		viewStore, err := NewViewStorageDisk(
+			filepath.Join(cfg.DataDir, "views"),
+			filepath.Join(cfg.DataDir, "headers.db"),
+			false, // read-only
+			*compressPtr,
+		)
+		if err != nil {
+			fatal(err)
+		}
+		defer viewStore.Close()
+
+		result, err := viewStore.Recompress()
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("Converted %d view(s), %d failed verification\n", result.Converted, result.Failed)
+		fmt.Printf("Size before: %d bytes, after: %d bytes, saved: %d bytes\n",
+			result.BytesBefore, result.BytesAfter, result.BytesBefore-result.BytesAfter)
+		return
+	}
+
 	// instatiate view storage (read-only)
+	dbPath := func(name string) string { return filepath.Join(cfg.DataDir, name) }
 	viewStore, err := NewViewStorageDisk(
-		filepath.Join(*dataDirPtr, "views"),
-		filepath.Join(*dataDirPtr, "headers.db"),
+		filepath.Join(cfg.DataDir, "views"),
+		dbPath("headers.db"),
 		true,  // read-only
 		false, // compress (if a view is compressed storage will figure it out)
 	)
 	if err != nil {
-		log.Fatal(err)
+		// the client keeps its databases locked while it's running. read from a snapshot instead
+		log.Printf("Unable to open the databases directly (%s), reading from a replica\n", err)
+		replica, err := NewReplica(cfg.DataDir)
+		if err != nil {
+			fatal(err)
+		}
+		defer replica.Close()
+		cleanup = func() { replica.Close() }
+		dbPath = replica.DatabasePath
+		if viewStore, err = replica.OpenViewStorage(); err != nil {
+			fatal(err)
+		}
 	}
 
 	// instantiate the ledger (read-only)
-	ledger, err := NewLedgerDisk(filepath.Join(*dataDirPtr, "ledger.db"),
+	ledger, err := NewLedgerDisk(dbPath("ledger.db"),
 		true,  // read-only
 		false, // prune (no effect with read-only set)
 		viewStore,
 	    NewGraph())
 		
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	// get the current height
 	_, currentHeight, err := ledger.GetPointTip()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	switch *cmdPtr {
@@ -103,87 +142,142 @@ func main() {
 
 	case "imbalance":
 		if pubKey == nil {
-			log.Fatal("-pubkey required for \"imbalance\" command")
+			fatal("-pubkey required for \"imbalance\" command")
 		}
 		imbalance, err := ledger.GetPublicKeyImbalance(pubKey)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		log.Printf("Current imbalance: %+d\n", aurora.Bold(imbalance))
 
 	case "imbalance_at":
 		if pubKey == nil {
-			log.Fatal("-pubkey required for \"imbalance_at\" command")
+			fatal("-pubkey required for \"imbalance_at\" command")
 		}
 		imbalance, err := ledger.GetPublicKeyImbalanceAt(pubKey, int64(*heightPtr))
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		log.Printf("Imbalance at height %d: %+d\n", *heightPtr, aurora.Bold(imbalance))
 
 	case "view_at":
 		id, err := ledger.GetViewIDForHeight(int64(*heightPtr))
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		if id == nil {
-			log.Fatalf("No view found at height %d\n", *heightPtr)
+			fatalf("No view found at height %d\n", *heightPtr)
 		}
 		view, err := viewStore.GetView(*id)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		if view == nil {
-			log.Fatalf("No view with ID %s\n", *id)
+			fatalf("No view with ID %s\n", *id)
 		}
 		displayView(*id, view)
 
 	case "view":
 		if viewID == nil {
-			log.Fatalf("-view_id required for \"view\" command")
+			fatalf("-view_id required for \"view\" command")
 		}
 		view, err := viewStore.GetView(*viewID)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		if view == nil {
-			log.Fatalf("No view with id %s\n", *viewID)
+			fatalf("No view with id %s\n", *viewID)
 		}
 		displayView(*viewID, view)
 
 	case "cn":
 		if cnID == nil {
-			log.Fatalf("-cn_id required for \"cn\" command")
+			fatalf("-cn_id required for \"cn\" command")
 		}
 		id, index, err := ledger.GetConsiderationIndex(*cnID)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		if id == nil {
-			log.Fatalf("Consideration %s not found", *cnID)
+			prunedHeight, err := ledger.GetPrunedHeight()
+			if err != nil {
+				fatal(err)
+			}
+			if prunedHeight != 0 {
+				fatalf("Consideration %s not found at or above height %d, the index is pruned below it",
+					*cnID, prunedHeight)
+			}
+			fatalf("Consideration %s not found", *cnID)
 		}
 		cn, header, err := viewStore.GetConsideration(*id, index)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		if cn == nil {
-			log.Fatalf("No consideration found with ID %s\n", *cnID)
+			fatalf("No consideration found with ID %s\n", *cnID)
 		}
 		displayConsideration(*cnID, header, index, cn)
 
 	case "history":
 		if pubKey == nil {
-			log.Fatal("-pubkey required for \"history\" command")
+			fatal("-pubkey required for \"history\" command")
+		}
+		prunedHeight, err := ledger.GetPrunedHeight()
+		if err != nil {
+			fatal(err)
+		}
+		lowestHeight := *startHeightPtr
+		if *endHeightPtr < lowestHeight {
+			lowestHeight = *endHeightPtr
+		}
+		if int64(lowestHeight) < prunedHeight {
+			fatalf("Public key consideration indices are pruned below height %d, "+
+				"history can only be shown from there\n", prunedHeight)
 		}
 		bIDs, indices, stopHeight, stopIndex, err := ledger.GetPublicKeyConsiderationIndicesRange(
 			pubKey, int64(*startHeightPtr), int64(*endHeightPtr), int(*startIndexPtr), int(*limitPtr))
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		displayHistory(bIDs, indices, stopHeight, stopIndex, viewStore)
 
 	case "verify":
 		verify(ledger, viewStore, pubKey, currentHeight)
+
+	case "sidetips":
+		branches, err := GetSideBranches(ledger, viewStore)
+		if err != nil {
+			fatal(err)
+		}
+		displaySideBranches(branches)
+
+	case "peers":
+		peerStore, err := NewPeerStorageDisk(dbPath("peers.db"),
+			true, // read-only
+			0,    // retention (no effect with read-only set)
+			0)    // max peers (no effect with read-only set)
+		if err != nil {
+			fatal(err)
+		}
+		peers, err := peerStore.GetAll()
+		if err != nil {
+			fatal(err)
+		}
+		peerStore.Close()
+		displayPeers(peers)
+
+	case "branch":
+		if viewID == nil {
+			fatalf("-view_id required for \"branch\" command")
+		}
+		ids, headers, err := GetBranchHeaders(*viewID, ledger, viewStore)
+		if err != nil {
+			fatal(err)
+		}
+		if len(ids) == 0 {
+			fatalf("View %s is on the main point or not found\n", *viewID)
+		}
+		displayBranch(ids, headers)
 	}
 
 	// close storage
@@ -195,6 +289,25 @@ func main() {
 	}
 }
 
+// Run before exiting on a fatal error, which skips deferred calls
+var cleanup func()
+
+// Exit with log.Fatal after cleaning up
+func fatal(v ...interface{}) {
+	if cleanup != nil {
+		cleanup()
+	}
+	log.Fatal(v...)
+}
+
+// Exit with log.Fatalf after cleaning up
+func fatalf(format string, v ...interface{}) {
+	if cleanup != nil {
+		cleanup()
+	}
+	log.Fatalf(format, v...)
+}
+
 type conciseView struct {
 	ID           ViewID         `json:"id"`
 	Header       ViewHeader     `json:"header"`
@@ -309,22 +422,22 @@ func verify(ledger Ledger, viewStore ViewStorage, pubKey ed25519.PublicKey, heig
 		// get expected imbalance
 		expect, err = ledger.GetPublicKeyImbalance(pubKey)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 
 		// compute the imbalance based on history
 		found, err = ledger.GetPublicKeyImbalanceAt(pubKey, height)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 	}
 
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	if expect != found {
-		log.Fatalf("%s: At height %d, we expected %+d crux but we found %+d\n",
+		fatalf("%s: At height %d, we expected %+d crux but we found %+d\n",
 			aurora.Bold(aurora.Red("FAILURE")),
 			aurora.Bold(height),
 			aurora.Bold(expect),
@@ -337,3 +450,52 @@ func verify(ledger Ledger, viewStore ViewStorage, pubKey ed25519.PublicKey, heig
 		aurora.Bold(expect),
 		aurora.Bold(found))
 }
+
+type storedPeers struct {
+	Peers []PeerRecord `json:"peers"`
+}
+
+func displayPeers(peers []PeerRecord) {
+	pJson, err := json.MarshalIndent(&storedPeers{Peers: peers}, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(string(pJson))
+}
+
+type sideBranches struct {
+	SideBranches []SideBranch `json:"side_branches"`
+}
+
+func displaySideBranches(branches []SideBranch) {
+	sbJson, err := json.MarshalIndent(&sideBranches{SideBranches: branches}, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(string(sbJson))
+}
+
+type branchView struct {
+	ID     ViewID     `json:"id"`
+	Header ViewHeader `json:"header"`
+}
+
+type branch struct {
+	Views []branchView `json:"views"`
+}
+
+func displayBranch(ids []ViewID, headers []*ViewHeader) {
+	b := branch{Views: make([]branchView, len(ids))}
+	for i, id := range ids {
+		b.Views[i] = branchView{ID: id, Header: *headers[i]}
+	}
+
+	bJson, err := json.MarshalIndent(&b, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(string(bJson))
+}
@@ -0,0 +1,143 @@
+// Package config handles the command line flags shared by the focal point binaries so they're
+// named, validated and applied the same way everywhere.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	. "github.com/inconsiderable/focal-point"
+)
+
+// Options select which of the shared flags a binary registers.
+type Options int
+
+const (
+	DataDir   Options = 1 << iota // -datadir
+	Network                       // -network
+	TLSServer                     // -tlscert and -tlskey
+	TLSClient                     // -tlsverify
+	Logging                       // -logfile
+)
+
+// Config holds the values of the shared flags.
+type Config struct {
+	DataDir   string
+	Network   string
+	TLSCert   string
+	TLSKey    string
+	TLSVerify bool
+	LogFile   string
+
+	// NetworkParams is set by Apply if a network parameter bundle was loaded.
+	NetworkParams *NetworkParams
+
+	options Options
+	flags   *flag.FlagSet
+}
+
+// Register adds the shared flags selected by options to the flag set.
+func Register(flags *flag.FlagSet, options Options) *Config {
+	c := &Config{options: options, flags: flags}
+	if options&DataDir != 0 {
+		flags.StringVar(&c.DataDir, "datadir", "", "Path to a directory containing focal point data")
+	}
+	if options&Network != 0 {
+		flags.StringVar(&c.Network, "network", "",
+			"Path to a network parameter bundle to use instead of the main network")
+	}
+	if options&TLSServer != 0 {
+		flags.StringVar(&c.TLSCert, "tlscert", "",
+			"Path to a file containing a PEM-encoded X.509 certificate to use with TLS")
+		flags.StringVar(&c.TLSKey, "tlskey", "",
+			"Path to a file containing a PEM-encoded private key to use with TLS")
+	}
+	if options&TLSClient != 0 {
+		flags.BoolVar(&c.TLSVerify, "tlsverify", false,
+			"Verify the TLS certificate of the peer is signed by a recognized CA and the host matches the CN")
+	}
+	if options&Logging != 0 {
+		flags.StringVar(&c.LogFile, "logfile", "", "Path to a file to append log output to instead of stderr")
+	}
+	return c
+}
+
+// Apply validates the shared flags once they've been parsed, redirects logging, resolves the
+// data directory and switches networks. Binaries should call it before doing anything else.
+func (c *Config) Apply() error {
+	if len(c.LogFile) != 0 {
+		// left open for the life of the process
+		logFile, err := os.OpenFile(c.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		log.SetOutput(logFile)
+	}
+
+	if c.options&DataDir != 0 {
+		if len(c.DataDir) == 0 {
+			return fmt.Errorf("-datadir argument required")
+		}
+		dataDir, err := resolvePath(c.DataDir)
+		if err != nil {
+			return err
+		}
+		c.DataDir = dataDir
+	}
+
+	if len(c.TLSCert) != 0 && len(c.TLSKey) == 0 {
+		return fmt.Errorf("-tlskey argument missing")
+	}
+	if len(c.TLSCert) == 0 && len(c.TLSKey) != 0 {
+		return fmt.Errorf("-tlscert argument missing")
+	}
+
+	if len(c.Network) != 0 {
+		network, err := LoadNetworkParams(c.Network)
+		if err != nil {
+			return err
+		}
+		if err := SetNetwork(network); err != nil {
+			return err
+		}
+		c.NetworkParams = network
+		log.Printf("Using network: %s\n", network.Name)
+	}
+	return nil
+}
+
+// IsSet returns true if the named flag was set on the command line.
+func (c *Config) IsSet(name string) bool {
+	set := false
+	c.flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// WithDefaultPort adds the network's default port to a peer address if it doesn't have one.
+func WithDefaultPort(addr string) string {
+	if strings.LastIndex(addr, ":") < 0 {
+		return addr + ":" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
+	}
+	return addr
+}
+
+// Expand a leading ~ to the user's home directory and make the path absolute
+func resolvePath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
@@ -7,7 +7,8 @@ import (
 // BranchType indicates the type of branch a particular view resides on.
 // Only views currently on the main branch are considered confirmed and only
 // considerations in those views affect public key imbalances.
-// Values are: MAIN, SIDE, ORPHAN or UNKNOWN.
+// Views the operator has invalidated, and any built on them, are INVALID until reconsidered.
+// Values are: MAIN, SIDE, ORPHAN, UNKNOWN or INVALID.
 type BranchType int
 
 const (
@@ -15,8 +16,16 @@ const (
 	SIDE
 	ORPHAN
 	UNKNOWN
+	INVALID
 )
 
+// SupplyStats are cumulative network-wide totals as of a height on the main point.
+type SupplyStats struct {
+	Considerations    int64 // considerations confirmed, not counting viewpoints
+	ViewpointsMatured int64 // viewpoints applied to imbalances. Each adds one to the total supply
+	ActiveKeys        int64 // public keys with a non-zero imbalance
+}
+
 // Ledger is an interface to a ledger built from the most-work point of views.
 // It manages and computes public key imbalances as well as consideration and public key consideration indices.
 // It also maintains an index of the focal point by height as well as branch information.
@@ -33,6 +42,9 @@ type Ledger interface {
 	// GetBranchType returns the branch type for the given view.
 	GetBranchType(id ViewID) (BranchType, error)
 
+	// GetBranchViews returns the IDs of all views recorded with the given branch type.
+	GetBranchViews(branchType BranchType) ([]ViewID, error)
+
 	// ConnectView connects a view to the tip of the focal point and applies the considerations
 	// to the ledger.
 	ConnectView(id ViewID, view *View) ([]ConsiderationID, error)
@@ -49,15 +61,36 @@ type Ledger interface {
 	GetPublicKeyImbalances(pubKeys []ed25519.PublicKey) (
 		map[[ed25519.PublicKeySize]byte]int64, *ViewID, int64, error)
 
+	// ForEachPublicKeyImbalance calls fn with every public key imbalance from a consistent
+	// snapshot of the ledger. It returns the view ID and height of the snapshot's main point tip.
+	ForEachPublicKeyImbalance(fn func(pubKey ed25519.PublicKey, imbalance int64) error) (*ViewID, int64, error)
+
 	// GetConsiderationIndex returns the index of a processed consideration.
 	GetConsiderationIndex(id ConsiderationID) (*ViewID, int, error)
 
+	// GetPublicKeyConsiderationID returns the ID of the consideration at the given height and index
+	// involving the public key, or nil if the index doesn't record it.
+	GetPublicKeyConsiderationID(pubKey ed25519.PublicKey, height int64, index int) (*ConsiderationID, error)
+
 	// GetPublicKeyConsiderationIndicesRange returns consideration indices involving a given public key
 	// over a range of heights. If startHeight > endHeight this iterates in reverse.
 	GetPublicKeyConsiderationIndicesRange(
 		pubKey ed25519.PublicKey, startHeight, endHeight int64, startIndex, limit int) (
 		[]ViewID, []int, int64, int, error)
 
+	// GetPrunedHeight returns the height from which consideration and public key consideration
+	// indices are complete. It's zero if the indices have never been pruned.
+	GetPrunedHeight() (int64, error)
+
+	// GetRenderedViews returns the main point views whose viewpoint went to the given public key
+	// with heights in the range [startHeight, endHeight], at most limit in ascending height order.
+	GetRenderedViews(pubKey ed25519.PublicKey, startHeight, endHeight int64, limit int) (
+		[]ViewID, []int64, error)
+
+	// GetSupplyStats returns the cumulative totals as of the given main point height.
+	// It returns nil if the height is beyond the tip.
+	GetSupplyStats(height int64) (*SupplyStats, error)
+
 	// Imbalance returns the total current ledger imbalance by summing the imbalance of all public keys.
 	// It's only used offline for verification purposes.
 	Imbalance() (int64, error)
@@ -3,6 +3,7 @@ package focalpoint
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/gob"
 	"fmt"
 
 	"github.com/syndtr/goleveldb/leveldb"
@@ -19,6 +20,9 @@ type LedgerDisk struct {
 	prune      	bool // prune historic consideration and public key consideration indices
 }
 
+// How many views deep a view's undo record is kept, whether or not the ledger is pruned
+var viewUndoDepth int64 = 2 * VIEWS_UNTIL_NEW_SERIES
+
 // NewLedgerDisk returns a new instance of LedgerDisk.
 func NewLedgerDisk(dbPath string, readOnly, prune bool, viewStore ViewStorage, conGraph *Graph) (*LedgerDisk, error) {
 	opts := opt.Options{ReadOnly: readOnly}
@@ -26,7 +30,16 @@ func NewLedgerDisk(dbPath string, readOnly, prune bool, viewStore ViewStorage, c
 	if err != nil {
 		return nil, err
 	}
-	return &LedgerDisk{db: db, viewStore: viewStore, conGraph: *&conGraph, prune: prune}, nil
+	l := &LedgerDisk{db: db, viewStore: viewStore, conGraph: *&conGraph, prune: prune}
+	if !readOnly {
+		// record how far an existing ledger was pruned so history isn't silently incomplete
+		// when it's later opened without -prune
+		if err := l.recordPrunedHeight(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return l, nil
 }
 
 // GetPointTip returns the ID and the height of the view at the current tip of the main point.
@@ -120,6 +133,25 @@ func (l LedgerDisk) GetBranchType(id ViewID) (BranchType, error) {
 	return BranchType(branchType[0]), nil
 }
 
+// GetBranchViews returns the IDs of all views recorded with the given branch type.
+func (l LedgerDisk) GetBranchViews(branchType BranchType) ([]ViewID, error) {
+	var ids []ViewID
+	iter := l.db.NewIterator(util.BytesPrefix([]byte{branchTypePrefix}), nil)
+	for iter.Next() {
+		if len(iter.Value()) == 0 || BranchType(iter.Value()[0]) != branchType {
+			continue
+		}
+		var id ViewID
+		copy(id[:], iter.Key()[1:])
+		ids = append(ids, id)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 // ConnectView connects a view to the tip of the focal point and applies the considerations to the ledger.
 func (l LedgerDisk) ConnectView(id ViewID, view *View) ([]ConsiderationID, error) {
 	// sanity check
@@ -135,9 +167,21 @@ func (l LedgerDisk) ConnectView(id ViewID, view *View) ([]ConsiderationID, error
 	// apply all resulting writes atomically
 	batch := new(leveldb.Batch)
 
+	// record what we need to cheaply disconnect this view later
+	undo := new(viewUndo)
+
 	imbalanceCache := NewImbalanceCache(l)
 	cnIDs := make([]ConsiderationID, len(view.Considerations))
 
+	// cumulative totals carry on from the previous view's
+	stats, err := l.GetSupplyStats(view.Header.Height - 1)
+	if err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		stats = new(SupplyStats)
+	}
+
 	for i, cn := range view.Considerations {
 		cnID, err := cn.ID()
 		if err != nil {
@@ -165,6 +209,7 @@ func (l LedgerDisk) ConnectView(id ViewID, view *View) ([]ConsiderationID, error
 			return nil, err
 		}
 		batch.Put(key, indexBytes)
+		undo.IndexKeys = append(undo.IndexKeys, key)
 
 		cnToApply := cn
 
@@ -197,7 +242,10 @@ func (l LedgerDisk) ConnectView(id ViewID, view *View) ([]ConsiderationID, error
 
 				// apply it to the recipient's imbalance
 				cnToApply = oldTx
+				stats.ViewpointsMatured++
 			}
+		} else {
+			stats.Considerations++
 		}
 
 		if cnToApply != nil {
@@ -217,19 +265,22 @@ func (l LedgerDisk) ConnectView(id ViewID, view *View) ([]ConsiderationID, error
 			}
 		}
 
-		// associate this consideration with both parties
+		// associate this consideration with both parties. record its ID so history can be
+		// listed by ID without reading views
 		if !cn.IsViewpoint() {
 			key, err = computePubKeyConsiderationIndexKey(cn.By, &view.Header.Height, &i)
 			if err != nil {
 				return nil, err
 			}
-			batch.Put(key, []byte{0x1})
+			batch.Put(key, cnIDs[i][:])
+			undo.IndexKeys = append(undo.IndexKeys, key)
 		}
 		key, err = computePubKeyConsiderationIndexKey(cn.For, &view.Header.Height, &i)
 		if err != nil {
 			return nil, err
 		}
-		batch.Put(key, []byte{0x1})
+		batch.Put(key, cnIDs[i][:])
+		undo.IndexKeys = append(undo.IndexKeys, key)
 	}
 
 	// update recorded imbalances
@@ -239,6 +290,25 @@ func (l LedgerDisk) ConnectView(id ViewID, view *View) ([]ConsiderationID, error
 		if err != nil {
 			return nil, err
 		}
+
+		// remember the change for undo
+		prevImbalance, err := l.GetPublicKeyImbalance(ed25519.PublicKey(pubKeyBytes[:]))
+		if err != nil {
+			return nil, err
+		}
+		if prevImbalance == 0 && imbalance != 0 {
+			stats.ActiveKeys++
+		} else if prevImbalance != 0 && imbalance == 0 {
+			stats.ActiveKeys--
+		}
+		if delta := imbalance - prevImbalance; delta != 0 {
+			undo.Imbalances = append(undo.Imbalances, imbalanceDelta{
+				// copied since pubKeyBytes is reused by the loop
+				PublicKey: append([]byte(nil), pubKeyBytes[:]...),
+				Delta:     delta,
+			})
+		}
+
 		if imbalance == 0 {
 			batch.Delete(key)
 		} else {
@@ -257,6 +327,26 @@ func (l LedgerDisk) ConnectView(id ViewID, view *View) ([]ConsiderationID, error
 	}
 	batch.Put(key, id[:])
 
+	// record the totals as of this height
+	key, err = computeSupplyStatsKey(view.Header.Height)
+	if err != nil {
+		return nil, err
+	}
+	statsBytes, err := encodeSupplyStats(stats)
+	if err != nil {
+		return nil, err
+	}
+	batch.Put(key, statsBytes)
+
+	// index the view by the public key which rendered it
+	if len(view.Considerations) != 0 && view.Considerations[0].IsViewpoint() {
+		key, err = computeRenderedViewIndexKey(view.Considerations[0].For, &view.Header.Height)
+		if err != nil {
+			return nil, err
+		}
+		batch.Put(key, id[:])
+	}
+
 	// set this view on the main point
 	key, err = computeBranchTypeKey(id)
 	if err != nil {
@@ -277,11 +367,41 @@ func (l LedgerDisk) ConnectView(id ViewID, view *View) ([]ConsiderationID, error
 
 	// prune historic consideration and public key consideration indices now
 	if l.prune && view.Header.Height >= 2*VIEWS_UNTIL_NEW_SERIES {
-		if err := l.pruneIndices(view.Header.Height-2*VIEWS_UNTIL_NEW_SERIES, batch); err != nil {
+		if err := l.pruneIndices(view.Header.Height-2*VIEWS_UNTIL_NEW_SERIES, batch, undo); err != nil {
+			return nil, err
+		}
+		if err := putPrunedHeight(view.Header.Height-2*VIEWS_UNTIL_NEW_SERIES+1, batch); err != nil {
 			return nil, err
 		}
 	}
 
+	// drop the undo record of the view which is now too deep to need one.
+	// a reorg this deep falls back to re-reading view bodies
+	if view.Header.Height >= viewUndoDepth {
+		deepID, err := l.GetViewIDForHeight(view.Header.Height - viewUndoDepth)
+		if err != nil {
+			return nil, err
+		}
+		if deepID != nil {
+			key, err := computeViewUndoKey(*deepID)
+			if err != nil {
+				return nil, err
+			}
+			batch.Delete(key)
+		}
+	}
+
+	// store the undo record
+	key, err = computeViewUndoKey(id)
+	if err != nil {
+		return nil, err
+	}
+	undoBytes, err := encodeViewUndo(undo)
+	if err != nil {
+		return nil, err
+	}
+	batch.Put(key, undoBytes)
+
 	// perform the writes
 	wo := opt.WriteOptions{Sync: true}
 	if err := l.db.Write(batch, &wo); err != nil {
@@ -307,6 +427,15 @@ func (l LedgerDisk) DisconnectView(id ViewID, view *View) ([]ConsiderationID, er
 			id, *tipID)
 	}
 
+	// views connected by older versions won't have an undo record
+	undo, err := l.getViewUndo(id)
+	if err != nil {
+		return nil, err
+	}
+	if undo != nil {
+		return l.disconnectViewWithUndo(id, view, undo)
+	}
+
 	// apply all resulting writes atomically
 	batch := new(leveldb.Batch)
 
@@ -406,6 +535,22 @@ func (l LedgerDisk) DisconnectView(id ViewID, view *View) ([]ConsiderationID, er
 	}
 	batch.Delete(key)
 
+	// and its totals
+	key, err = computeSupplyStatsKey(view.Header.Height)
+	if err != nil {
+		return nil, err
+	}
+	batch.Delete(key)
+
+	// and by the public key which rendered it
+	if len(view.Considerations) != 0 && view.Considerations[0].IsViewpoint() {
+		key, err = computeRenderedViewIndexKey(view.Considerations[0].For, &view.Header.Height)
+		if err != nil {
+			return nil, err
+		}
+		batch.Delete(key)
+	}
+
 	// set this view on a side point
 	key, err = computeBranchTypeKey(id)
 	if err != nil {
@@ -429,8 +574,122 @@ func (l LedgerDisk) DisconnectView(id ViewID, view *View) ([]ConsiderationID, er
 		if err := l.restoreIndices(view.Header.Height-2*VIEWS_UNTIL_NEW_SERIES, batch); err != nil {
 			return nil, err
 		}
+		if err := putPrunedHeight(view.Header.Height-2*VIEWS_UNTIL_NEW_SERIES, batch); err != nil {
+			return nil, err
+		}
+	}
+
+	// perform the writes
+	wo := opt.WriteOptions{Sync: true}
+	if err := l.db.Write(batch, &wo); err != nil {
+		return nil, err
+	}
+
+	return cnIDs, nil
+}
+
+// Disconnect the view at the tip using its undo record instead of re-reading historic views
+func (l LedgerDisk) disconnectViewWithUndo(id ViewID, view *View, undo *viewUndo) ([]ConsiderationID, error) {
+	// apply all resulting writes atomically
+	batch := new(leveldb.Batch)
+
+	cnIDs := make([]ConsiderationID, len(view.Considerations))
+	for i, cn := range view.Considerations {
+		cnID, err := cn.ID()
+		if err != nil {
+			return nil, err
+		}
+		cnIDs[i] = cnID
+	}
+
+	// remove consideration and public key consideration indices
+	for _, key := range undo.IndexKeys {
+		batch.Delete(key)
+	}
+
+	// revert imbalances
+	for _, delta := range undo.Imbalances {
+		imbalance, err := l.GetPublicKeyImbalance(ed25519.PublicKey(delta.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		imbalance -= delta.Delta
+		if imbalance < 0 {
+			return nil, fmt.Errorf("Undo record for view %s would make imbalance negative", id)
+		}
+		key, err := computePubKeyImbalanceKey(ed25519.PublicKey(delta.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		if imbalance == 0 {
+			batch.Delete(key)
+		} else {
+			imbalanceBytes, err := encodeNumber(imbalance)
+			if err != nil {
+				return nil, err
+			}
+			batch.Put(key, imbalanceBytes)
+		}
+	}
+
+	// restore historic indices
+	for i, key := range undo.PrunedKeys {
+		batch.Put(key, undo.PrunedValues[i])
+	}
+	if len(undo.PrunedKeys) != 0 {
+		if err := putPrunedHeight(view.Header.Height-2*VIEWS_UNTIL_NEW_SERIES, batch); err != nil {
+			return nil, err
+		}
+	}
+
+	// remove this view's index by height
+	key, err := computeViewHeightIndexKey(view.Header.Height)
+	if err != nil {
+		return nil, err
+	}
+	batch.Delete(key)
+
+	// and its totals
+	key, err = computeSupplyStatsKey(view.Header.Height)
+	if err != nil {
+		return nil, err
+	}
+	batch.Delete(key)
+
+	// and by the public key which rendered it. it may have been indexed after the undo record was written
+	if len(view.Considerations) != 0 && view.Considerations[0].IsViewpoint() {
+		key, err = computeRenderedViewIndexKey(view.Considerations[0].For, &view.Header.Height)
+		if err != nil {
+			return nil, err
+		}
+		batch.Delete(key)
 	}
 
+	// set this view on a side point
+	key, err = computeBranchTypeKey(id)
+	if err != nil {
+		return nil, err
+	}
+	batch.Put(key, []byte{byte(SIDE)})
+
+	// set the previous view as the point tip
+	key, err = computePointTipKey()
+	if err != nil {
+		return nil, err
+	}
+	ctBytes, err := encodePointTip(view.Header.Previous, view.Header.Height-1)
+	if err != nil {
+		return nil, err
+	}
+	batch.Put(key, ctBytes)
+
+	// the undo record is no longer needed
+	key, err = computeViewUndoKey(id)
+	if err != nil {
+		return nil, err
+	}
+	batch.Delete(key)
+
 	// perform the writes
 	wo := opt.WriteOptions{Sync: true}
 	if err := l.db.Write(batch, &wo); err != nil {
@@ -440,8 +699,28 @@ func (l LedgerDisk) DisconnectView(id ViewID, view *View) ([]ConsiderationID, er
 	return cnIDs, nil
 }
 
-// Prune consideration and public key consideration indices created by the view at the given height
-func (l LedgerDisk) pruneIndices(height int64, batch *leveldb.Batch) error {
+// Fetch the undo record for the given view, if any
+func (l LedgerDisk) getViewUndo(id ViewID) (*viewUndo, error) {
+	// compute db key
+	key, err := computeViewUndoKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// fetch the record
+	undoBytes, err := l.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeViewUndo(undoBytes)
+}
+
+// Prune consideration and public key consideration indices created by the view at the given height.
+// Pruned entries are recorded in the undo record of the view being connected.
+func (l LedgerDisk) pruneIndices(height int64, batch *leveldb.Batch, undo *viewUndo) error {
 	// get the ID
 	id, err := l.GetViewIDForHeight(height)
 	if err != nil {
@@ -471,7 +750,13 @@ func (l LedgerDisk) pruneIndices(height int64, batch *leveldb.Batch) error {
 		if err != nil {
 			return err
 		}
+		indexBytes, err := encodeConsiderationIndex(view.Header.Height, i)
+		if err != nil {
+			return err
+		}
 		batch.Delete(key)
+		undo.PrunedKeys = append(undo.PrunedKeys, key)
+		undo.PrunedValues = append(undo.PrunedValues, indexBytes)
 
 		// prune public key consideration indices
 		if !cn.IsViewpoint() {
@@ -480,14 +765,17 @@ func (l LedgerDisk) pruneIndices(height int64, batch *leveldb.Batch) error {
 				return err
 			}
 			batch.Delete(key)
+			undo.PrunedKeys = append(undo.PrunedKeys, key)
+			undo.PrunedValues = append(undo.PrunedValues, cnID[:])
 		}
 		key, err = computePubKeyConsiderationIndexKey(cn.For, &view.Header.Height, &i)
 		if err != nil {
 			return err
 		}
 		batch.Delete(key)
+		undo.PrunedKeys = append(undo.PrunedKeys, key)
+		undo.PrunedValues = append(undo.PrunedValues, cnID[:])
 	}
-
 	return nil
 }
 
@@ -534,13 +822,13 @@ func (l LedgerDisk) restoreIndices(height int64, batch *leveldb.Batch) error {
 			if err != nil {
 				return err
 			}
-			batch.Put(key, []byte{0x1})
+			batch.Put(key, cnID[:])
 		}
 		key, err = computePubKeyConsiderationIndexKey(cn.For, &view.Header.Height, &i)
 		if err != nil {
 			return err
 		}
-		batch.Put(key, []byte{0x1})
+		batch.Put(key, cnID[:])
 	}
 
 	return nil
@@ -622,6 +910,48 @@ func (l LedgerDisk) GetPublicKeyImbalances(pubKeys []ed25519.PublicKey) (
 	return imbalances, tipID, tipHeight, nil
 }
 
+// ForEachPublicKeyImbalance calls fn with every public key imbalance from a consistent
+// snapshot of the ledger. It returns the view ID and height of the snapshot's main point tip.
+func (l LedgerDisk) ForEachPublicKeyImbalance(fn func(pubKey ed25519.PublicKey, imbalance int64) error) (
+	*ViewID, int64, error) {
+
+	// get a consistent view across the whole table
+	snapshot, err := l.db.GetSnapshot()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer snapshot.Release()
+
+	// get the point tip
+	tipID, tipHeight, err := getPointTip(snapshot)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	prefix, err := computePubKeyImbalanceKey(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	iter := snapshot.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		pubKey := make([]byte, ed25519.PublicKeySize)
+		copy(pubKey, iter.Key()[len(prefix):])
+
+		var imbalance int64
+		buf := bytes.NewReader(iter.Value())
+		binary.Read(buf, binary.BigEndian, &imbalance)
+
+		if err := fn(ed25519.PublicKey(pubKey), imbalance); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, 0, err
+	}
+	return tipID, tipHeight, nil
+}
+
 // GetConsiderationIndex returns the index of a processed consideration.
 func (l LedgerDisk) GetConsiderationIndex(id ConsiderationID) (*ViewID, int, error) {
 	// compute the db key
@@ -660,6 +990,31 @@ func (l LedgerDisk) GetConsiderationIndex(id ConsiderationID) (*ViewID, int, err
 	return viewID, index, nil
 }
 
+// GetPublicKeyConsiderationID returns the ID of the consideration at the given height and index
+// involving the public key as recorded in the public key consideration index. It returns nil if
+// the consideration isn't indexed or was indexed before IDs were recorded.
+func (l LedgerDisk) GetPublicKeyConsiderationID(pubKey ed25519.PublicKey, height int64, index int) (
+	*ConsiderationID, error) {
+	key, err := computePubKeyConsiderationIndexKey(pubKey, &height, &index)
+	if err != nil {
+		return nil, err
+	}
+	value, err := l.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(value) != len(ConsiderationID{}) {
+		// indexed by an older version
+		return nil, nil
+	}
+	cnID := new(ConsiderationID)
+	copy(cnID[:], value)
+	return cnID, nil
+}
+
 // GetPublicKeyConsiderationIndicesRange returns consideration indices involving a given public key
 // over a range of heights. If startHeight > endHeight this iterates in reverse.
 func (l LedgerDisk) GetPublicKeyConsiderationIndicesRange(
@@ -802,6 +1157,288 @@ func (l LedgerDisk) getPublicKeyConsiderationIndicesRangeReverse(
 	return
 }
 
+// GetPrunedHeight returns the height from which consideration and public key consideration
+// indices are complete. It's zero if the indices have never been pruned. Indices stay pruned
+// after pruning is disabled so this doesn't depend on the current setting.
+func (l LedgerDisk) GetPrunedHeight() (int64, error) {
+	key, err := computePrunedHeightKey()
+	if err != nil {
+		return 0, err
+	}
+	heightBytes, err := l.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		// opened read-only and not yet recorded
+		return l.detectPrunedHeight()
+	}
+	if err != nil {
+		return 0, err
+	}
+	var height int64
+	if err := binary.Read(bytes.NewReader(heightBytes), binary.BigEndian, &height); err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// Record the pruned height if the ledger was written before it was tracked
+func (l LedgerDisk) recordPrunedHeight() error {
+	key, err := computePrunedHeightKey()
+	if err != nil {
+		return err
+	}
+	if ok, err := l.db.Has(key, nil); err != nil || ok {
+		return err
+	}
+	height, err := l.detectPrunedHeight()
+	if err != nil {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	if err := putPrunedHeight(height, batch); err != nil {
+		return err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return l.db.Write(batch, &wo)
+}
+
+// Pruning removes the genesis view's indices first. If they're missing assume everything up to
+// the last height pruning would have reached is missing too
+func (l LedgerDisk) detectPrunedHeight() (int64, error) {
+	_, height, err := l.GetPointTip()
+	if err != nil {
+		return 0, err
+	}
+	// see ConnectView
+	if height < 2*VIEWS_UNTIL_NEW_SERIES {
+		return 0, nil
+	}
+	id, err := l.GetViewIDForHeight(0)
+	if err != nil {
+		return 0, err
+	}
+	if id == nil {
+		return 0, fmt.Errorf("No view found at height 0")
+	}
+	view, err := l.viewStore.GetView(*id)
+	if err != nil {
+		return 0, err
+	}
+	if view == nil {
+		return 0, fmt.Errorf("Missing view %s", *id)
+	}
+	cnID, err := view.Considerations[0].ID()
+	if err != nil {
+		return 0, err
+	}
+	key, err := computeConsiderationIndexKey(cnID)
+	if err != nil {
+		return 0, err
+	}
+	indexed, err := l.db.Has(key, nil)
+	if err != nil {
+		return 0, err
+	}
+	if indexed {
+		return 0, nil
+	}
+	return height - 2*VIEWS_UNTIL_NEW_SERIES + 1, nil
+}
+
+// Set the height from which indices are complete
+func putPrunedHeight(height int64, batch *leveldb.Batch) error {
+	key, err := computePrunedHeightKey()
+	if err != nil {
+		return err
+	}
+	heightBytes, err := encodeNumber(height)
+	if err != nil {
+		return err
+	}
+	batch.Put(key, heightBytes)
+	return nil
+}
+
+// GetRenderedViews returns the main point views whose viewpoint went to the given public key
+// with heights in the range [startHeight, endHeight]. At most limit views are returned in
+// ascending height order.
+func (l LedgerDisk) GetRenderedViews(pubKey ed25519.PublicKey, startHeight, endHeight int64, limit int) (
+	[]ViewID, []int64, error) {
+	startKey, err := computeRenderedViewIndexKey(pubKey, &startHeight)
+	if err != nil {
+		return nil, nil, err
+	}
+	endHeight += 1 // make it inclusive
+	endKey, err := computeRenderedViewIndexKey(pubKey, &endHeight)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ids []ViewID
+	var heights []int64
+	iter := l.db.NewIterator(&util.Range{Start: startKey, Limit: endKey}, nil)
+	for iter.Next() && len(ids) < limit {
+		_, height, err := decodeRenderedViewIndexKey(iter.Key())
+		if err != nil {
+			iter.Release()
+			return nil, nil, err
+		}
+		var id ViewID
+		copy(id[:], iter.Value())
+		ids = append(ids, id)
+		heights = append(heights, height)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, nil, err
+	}
+	return ids, heights, nil
+}
+
+// GetSupplyStats returns the cumulative totals as of the given main point height.
+// It returns nil if the height is beyond the tip.
+func (l LedgerDisk) GetSupplyStats(height int64) (*SupplyStats, error) {
+	if height < 0 {
+		return nil, nil
+	}
+	key, err := computeSupplyStatsKey(height)
+	if err != nil {
+		return nil, err
+	}
+	statsBytes, err := l.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeSupplyStats(statsBytes)
+}
+
+// Build the rendered view index for views connected before it existed
+func indexRenderedViews(db *leveldb.DB, viewStore ViewStorage) (int, error) {
+	var count int
+	batch := new(leveldb.Batch)
+	iter := db.NewIterator(util.BytesPrefix([]byte{viewHeightIndexPrefix}), nil)
+	for iter.Next() {
+		var id ViewID
+		copy(id[:], iter.Value())
+		cn, header, err := viewStore.GetConsideration(id, 0)
+		if err != nil {
+			iter.Release()
+			return 0, err
+		}
+		if cn == nil || header == nil || !cn.IsViewpoint() {
+			continue
+		}
+		key, err := computeRenderedViewIndexKey(cn.For, &header.Height)
+		if err != nil {
+			iter.Release()
+			return 0, err
+		}
+		batch.Put(key, id[:])
+		count++
+
+		// write in chunks
+		if batch.Len() == 1000 {
+			if err := db.Write(batch, nil); err != nil {
+				iter.Release()
+				return 0, err
+			}
+			batch.Reset()
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return count, db.Write(batch, &wo)
+}
+
+// Record cumulative totals for views connected before they were tracked by replaying the main point
+func indexSupplyStats(db *leveldb.DB, viewStore ViewStorage) (int, error) {
+	var count int
+	stats := new(SupplyStats)
+	imbalances := make(map[[ed25519.PublicKeySize]byte]int64)
+	viewpoints := make(map[int64]ed25519.PublicKey) // recipients of viewpoints yet to mature
+
+	credit := func(pubKey ed25519.PublicKey, amount int64) {
+		var pk [ed25519.PublicKeySize]byte
+		copy(pk[:], pubKey)
+		before := imbalances[pk]
+		after := before + amount
+		if after == 0 {
+			delete(imbalances, pk)
+		} else {
+			imbalances[pk] = after
+		}
+		if before == 0 && after != 0 {
+			stats.ActiveKeys++
+		} else if before != 0 && after == 0 {
+			stats.ActiveKeys--
+		}
+	}
+
+	batch := new(leveldb.Batch)
+	iter := db.NewIterator(util.BytesPrefix([]byte{viewHeightIndexPrefix}), nil)
+	for iter.Next() {
+		var id ViewID
+		copy(id[:], iter.Value())
+		view, err := viewStore.GetView(id)
+		if err != nil {
+			iter.Release()
+			return 0, err
+		}
+		if view == nil {
+			iter.Release()
+			return 0, fmt.Errorf("Missing view %s", id)
+		}
+		height := view.Header.Height
+		for _, cn := range view.Considerations {
+			if !cn.IsViewpoint() {
+				stats.Considerations++
+				credit(cn.By, -1)
+				credit(cn.For, 1)
+				continue
+			}
+			viewpoints[height] = cn.For
+			if pubKey, ok := viewpoints[height-VIEWPOINT_MATURITY]; ok {
+				stats.ViewpointsMatured++
+				credit(pubKey, 1)
+				delete(viewpoints, height-VIEWPOINT_MATURITY)
+			}
+		}
+
+		key, err := computeSupplyStatsKey(height)
+		if err != nil {
+			iter.Release()
+			return 0, err
+		}
+		statsBytes, err := encodeSupplyStats(stats)
+		if err != nil {
+			iter.Release()
+			return 0, err
+		}
+		batch.Put(key, statsBytes)
+		count++
+
+		// write in chunks
+		if batch.Len() == 1000 {
+			if err := db.Write(batch, nil); err != nil {
+				iter.Release()
+				return 0, err
+			}
+			batch.Reset()
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return count, db.Write(batch, &wo)
+}
+
 // Imbalance returns the total current ledger imbalance by summing the imbalance of all public keys.
 // It's only used offline for verification purposes.
 func (l LedgerDisk) Imbalance() (int64, error) {
@@ -829,13 +1466,23 @@ func (l LedgerDisk) Imbalance() (int64, error) {
 
 // GetPublicKeyImbalanceAt returns the public key imbalance at the given height.
 // It's only used offline for historical and verification purposes.
-// This is only accurate when the full focal point is indexed (pruning disabled.)
+// It requires the full focal point to be indexed and fails if the indices were ever pruned.
 func (l LedgerDisk) GetPublicKeyImbalanceAt(pubKey ed25519.PublicKey, height int64) (int64, error) {
 	_, currentHeight, err := l.GetPointTip()
 	if err != nil {
 		return 0, err
 	}
 
+	// the imbalance is summed from genesis
+	prunedHeight, err := l.GetPrunedHeight()
+	if err != nil {
+		return 0, err
+	}
+	if prunedHeight != 0 {
+		return 0, fmt.Errorf("Public key consideration indices are pruned below height %d, "+
+			"historic imbalances require an unpruned ledger", prunedHeight)
+	}
+
 	startKey, err := computePubKeyConsiderationIndexKey(pubKey, nil, nil)
 	if err != nil {
 		return 0, err
@@ -912,6 +1559,10 @@ func (l LedgerDisk) Close() error {
 // t{cnid}              -> {height}{index} (prunable up to the previous series)
 // k{pk}{height}{index} -> 1 (not strictly necessary. probably should make it optional by flag)
 // b{pk}                -> {imbalance} (we always need all of this table)
+// u{bid}               -> {gob encoded undo record} (kept for views in the last two series)
+// r{pk}{height}        -> {bid} (main point views rendered to the public key)
+// s{height}            -> {considerations}{viewpoints matured}{active keys} (cumulative totals)
+// P                    -> {height} (indices are complete from this height)
 
 const pointTipPrefix = 'T'
 
@@ -925,6 +1576,29 @@ const pubKeyConsiderationIndexPrefix = 'k'
 
 const pubKeyImbalancePrefix = 'b'
 
+const viewUndoPrefix = 'u'
+
+const renderedViewIndexPrefix = 'r'
+
+const supplyStatsPrefix = 's'
+
+const prunedHeightPrefix = 'P'
+
+// viewUndo records the effects of connecting a view so it can be disconnected without
+// re-reading its body or the viewpoint it matured.
+type viewUndo struct {
+	Imbalances   []imbalanceDelta // imbalance changes applied
+	IndexKeys    [][]byte         // consideration and public key consideration index keys added
+	PrunedKeys   [][]byte         // historic index keys pruned
+	PrunedValues [][]byte         // and their values
+}
+
+// imbalanceDelta is the change in a public key's imbalance from connecting a view.
+type imbalanceDelta struct {
+	PublicKey []byte
+	Delta     int64
+}
+
 func computeBranchTypeKey(id ViewID) ([]byte, error) {
 	key := new(bytes.Buffer)
 	if err := key.WriteByte(branchTypePrefix); err != nil {
@@ -955,6 +1629,14 @@ func computePointTipKey() ([]byte, error) {
 	return key.Bytes(), nil
 }
 
+func computePrunedHeightKey() ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(prunedHeightPrefix); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
 func computeConsiderationIndexKey(id ConsiderationID) ([]byte, error) {
 	key := new(bytes.Buffer)
 	if err := key.WriteByte(considerationIndexPrefix); err != nil {
@@ -1011,6 +1693,61 @@ func decodePubKeyConsiderationIndexKey(key []byte) (ed25519.PublicKey, int64, in
 	return ed25519.PublicKey(pubKey[:]), height, int(index), nil
 }
 
+func computeRenderedViewIndexKey(pubKey ed25519.PublicKey, height *int64) ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(renderedViewIndexPrefix); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(key, binary.BigEndian, pubKey); err != nil {
+		return nil, err
+	}
+	if height == nil {
+		return key.Bytes(), nil
+	}
+	if err := binary.Write(key, binary.BigEndian, *height); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
+func decodeRenderedViewIndexKey(key []byte) (ed25519.PublicKey, int64, error) {
+	buf := bytes.NewBuffer(key)
+	if _, err := buf.ReadByte(); err != nil {
+		return nil, 0, err
+	}
+	var pubKey [ed25519.PublicKeySize]byte
+	if err := binary.Read(buf, binary.BigEndian, pubKey[:32]); err != nil {
+		return nil, 0, err
+	}
+	var height int64
+	if err := binary.Read(buf, binary.BigEndian, &height); err != nil {
+		return nil, 0, err
+	}
+	return ed25519.PublicKey(pubKey[:]), height, nil
+}
+
+func computeSupplyStatsKey(height int64) ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(supplyStatsPrefix); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(key, binary.BigEndian, height); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
+func computeViewUndoKey(id ViewID) ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(viewUndoPrefix); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(key, binary.BigEndian, id[:]); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
 func computePubKeyImbalanceKey(pubKey ed25519.PublicKey) ([]byte, error) {
 	key := new(bytes.Buffer)
 	if err := key.WriteByte(pubKeyImbalancePrefix); err != nil {
@@ -1078,3 +1815,42 @@ func decodeConsiderationIndex(indexBytes []byte) (int64, int, error) {
 	}
 	return height, int(index), nil
 }
+
+func encodeSupplyStats(stats *SupplyStats) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, num := range []int64{stats.Considerations, stats.ViewpointsMatured, stats.ActiveKeys} {
+		if err := binary.Write(buf, binary.BigEndian, num); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeSupplyStats(statsBytes []byte) (*SupplyStats, error) {
+	buf := bytes.NewBuffer(statsBytes)
+	stats := new(SupplyStats)
+	for _, num := range []*int64{&stats.Considerations, &stats.ViewpointsMatured, &stats.ActiveKeys} {
+		if err := binary.Read(buf, binary.BigEndian, num); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+func encodeViewUndo(undo *viewUndo) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(undo); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeViewUndo(undoBytes []byte) (*viewUndo, error) {
+	dec := gob.NewDecoder(bytes.NewBuffer(undoBytes))
+	undo := new(viewUndo)
+	if err := dec.Decode(undo); err != nil {
+		return nil, err
+	}
+	return undo, nil
+}
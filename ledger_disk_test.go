@@ -0,0 +1,434 @@
+package focalpoint
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestEncodeViewUndo(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	height, index := int64(12345), 3
+	key, err := computePubKeyConsiderationIndexKey(pubKey, &height, &index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	undo := &viewUndo{
+		Imbalances:   []imbalanceDelta{{PublicKey: pubKey, Delta: -1}},
+		IndexKeys:    [][]byte{key},
+		PrunedKeys:   [][]byte{key},
+		PrunedValues: [][]byte{{0x1}},
+	}
+
+	// encode the record
+	undoBytes, err := encodeViewUndo(undo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decode the record
+	undo2, err := decodeViewUndo(undoBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// compare
+	if len(undo2.Imbalances) != 1 ||
+		!bytes.Equal(undo2.Imbalances[0].PublicKey, pubKey) ||
+		undo2.Imbalances[0].Delta != -1 {
+		t.Fatal("Decoded imbalances don't match original")
+	}
+	if len(undo2.IndexKeys) != 1 || !bytes.Equal(undo2.IndexKeys[0], key) {
+		t.Fatal("Decoded index keys don't match original")
+	}
+	if len(undo2.PrunedKeys) != 1 || !bytes.Equal(undo2.PrunedKeys[0], key) ||
+		len(undo2.PrunedValues) != 1 || !bytes.Equal(undo2.PrunedValues[0], []byte{0x1}) {
+		t.Fatal("Decoded pruned entries don't match original")
+	}
+}
+
+func TestLedgerDiskRenderedViews(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	// the viewpoint won't mature so the view store isn't needed
+	viewpoint := NewConsideration(nil, pubKey, 0, 0, 0, "")
+	view, err := NewView(ViewID{}, 0, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := view.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.ConnectView(id, view); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, heights, err := ledger.GetRenderedViews(pubKey, 0, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != id || heights[0] != 0 {
+		t.Fatal("Rendered view missing from index")
+	}
+
+	if _, err := ledger.DisconnectView(id, view); err != nil {
+		t.Fatal(err)
+	}
+	ids, _, err = ledger.GetRenderedViews(pubKey, 0, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Fatal("Rendered view still indexed after disconnect")
+	}
+}
+
+func TestLedgerDiskSupplyStats(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	viewpoint := NewConsideration(nil, pubKey, 0, 0, 0, "")
+	view, err := NewView(ViewID{}, 0, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := view.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := viewStore.Store(id, view, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.ConnectView(id, view); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := ledger.GetSupplyStats(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats == nil || *stats != (SupplyStats{}) {
+		t.Fatalf("Expected empty totals for the genesis view, found %+v", stats)
+	}
+	if stats, err := ledger.GetSupplyStats(1); err != nil || stats != nil {
+		t.Fatalf("Expected no totals past the tip, error: %v", err)
+	}
+
+	// rebuilding them as the migration does gives the same result
+	key, err := computeSupplyStatsKey(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.db.Delete(key, nil); err != nil {
+		t.Fatal(err)
+	}
+	count, err := indexSupplyStats(ledger.db, viewStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats2, err := ledger.GetSupplyStats(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || stats2 == nil || *stats2 != *stats {
+		t.Fatalf("Rebuilt totals %+v don't match %+v", stats2, stats)
+	}
+
+	if _, err := ledger.DisconnectView(id, view); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := ledger.GetSupplyStats(0); err != nil || stats != nil {
+		t.Fatalf("Expected totals to be removed on disconnect, error: %v", err)
+	}
+}
+
+func TestLedgerDiskUndoImbalances(t *testing.T) {
+	var pubKeys []ed25519.PublicKey
+	for i := 0; i < 4; i++ {
+		pubKey, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	// mature viewpoints in the next view so there's something to spend
+	defer func(maturity int64) { VIEWPOINT_MATURITY = maturity }(VIEWPOINT_MATURITY)
+	VIEWPOINT_MATURITY = 1
+
+	var previous ViewID
+	connect := func(height int64, considerations []*Consideration) (ViewID, *View) {
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, considerations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.ConnectView(id, view); err != nil {
+			t.Fatal(err)
+		}
+		previous = id
+		return id, view
+	}
+	imbalances := func() []int64 {
+		var result []int64
+		for _, pubKey := range pubKeys {
+			imbalance, err := ledger.GetPublicKeyImbalance(pubKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, imbalance)
+		}
+		return result
+	}
+
+	connect(0, []*Consideration{NewConsideration(nil, pubKeys[0], 0, 0, 0, "")})
+	connect(1, []*Consideration{NewConsideration(nil, pubKeys[1], 0, 0, 1, "")})
+	before := imbalances()
+
+	// this view changes the imbalances of several keys
+	id, view := connect(2, []*Consideration{
+		NewConsideration(nil, pubKeys[2], 0, 0, 2, ""),
+		NewConsideration(pubKeys[0], pubKeys[3], 0, 0, 2, ""),
+	})
+	after := imbalances()
+	if after[0] != before[0]-1 || after[1] != before[1]+1 || after[3] != before[3]+1 {
+		t.Fatalf("Unexpected imbalances %v after connecting, before %v", after, before)
+	}
+
+	// disconnecting it with its undo record should put them all back
+	if _, err := ledger.DisconnectView(id, view); err != nil {
+		t.Fatal(err)
+	}
+	restored := imbalances()
+	for i := range before {
+		if restored[i] != before[i] {
+			t.Fatalf("Expected imbalances %v after disconnecting, found %v", before, restored)
+		}
+	}
+}
+
+func TestLedgerDiskUndoDepth(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	// not pruned
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	defer func(depth int64) { viewUndoDepth = depth }(viewUndoDepth)
+	viewUndoDepth = 3
+
+	var ids []ViewID
+	var previous ViewID
+	for height := int64(0); height < 6; height++ {
+		viewpoint := NewConsideration(nil, pubKey, 0, 0, height, "")
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.ConnectView(id, view); err != nil {
+			t.Fatal(err)
+		}
+		ids, previous = append(ids, id), id
+	}
+
+	// only the most recent views keep their undo records
+	for height, id := range ids {
+		undo, err := ledger.getViewUndo(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := int64(height) > 5-viewUndoDepth; (undo != nil) != expected {
+			t.Fatalf("Expected undo record at height %d: %v", height, expected)
+		}
+	}
+}
+
+func TestLedgerDiskPrunedHeight(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, true, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viewpoint := NewConsideration(nil, pubKey, 0, 0, 0, "")
+	view, err := NewView(ViewID{}, 0, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := view.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := viewStore.Store(id, view, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.ConnectView(id, view); err != nil {
+		t.Fatal(err)
+	}
+	if height, err := ledger.GetPrunedHeight(); err != nil || height != 0 {
+		t.Fatalf("Expected nothing pruned, found height %d, error: %v", height, err)
+	}
+
+	// make it look like a ledger pruned before the pruned height was recorded
+	tipHeight := int64(2 * VIEWS_UNTIL_NEW_SERIES)
+	batch := new(leveldb.Batch)
+	key, err := computePointTipKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctBytes, err := encodePointTip(id, tipHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch.Put(key, ctBytes)
+	cnID, err := viewpoint.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err = computeConsiderationIndexKey(cnID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch.Delete(key)
+	key, err = computePrunedHeightKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch.Delete(key)
+	if err := ledger.db.Write(batch, nil); err != nil {
+		t.Fatal(err)
+	}
+	ledger.Close()
+
+	// it's detected when reopened without pruning
+	ledger, err = NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+	if ok, err := ledger.db.Has(key, nil); err != nil || !ok {
+		t.Fatalf("Expected the pruned height to be recorded, error: %v", err)
+	}
+	height, err := ledger.GetPrunedHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != tipHeight-2*VIEWS_UNTIL_NEW_SERIES+1 {
+		t.Fatalf("Expected pruned height %d, found %d", tipHeight-2*VIEWS_UNTIL_NEW_SERIES+1, height)
+	}
+	if _, err := ledger.GetPublicKeyImbalanceAt(pubKey, 0); err == nil {
+		t.Fatal("Expected historic imbalance to fail on a pruned ledger")
+	}
+}
+
+func TestEncodeSupplyStats(t *testing.T) {
+	stats := &SupplyStats{Considerations: 12345, ViewpointsMatured: 678, ActiveKeys: 90}
+	statsBytes, err := encodeSupplyStats(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats2, err := decodeSupplyStats(statsBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats2 != *stats {
+		t.Fatalf("Decoded totals %+v don't match original %+v", stats2, stats)
+	}
+}
@@ -0,0 +1,77 @@
+package focalpoint
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// MemoPolicy limits consideration memos in views from Height on, until the next policy activates.
+// It's consensus and can be overridden by a network parameter bundle.
+type MemoPolicy struct {
+	Height    int64  `json:"height"`     // activation height
+	MaxLength int    `json:"max_length"` // bytes
+	Encoding  string `json:"encoding"`
+}
+
+// Memo encodings a policy can require.
+const (
+	MemoEncodingUTF8  = "utf8"
+	MemoEncodingASCII = "ascii"
+)
+
+// MemoPolicyAt returns the memo policy in effect for a view at the given height.
+func MemoPolicyAt(height int64) MemoPolicy {
+	policy := MEMO_POLICIES[0]
+	for _, p := range MEMO_POLICIES[1:] {
+		if p.Height > height {
+			break
+		}
+		policy = p
+	}
+	return policy
+}
+
+// Check returns an error if the memo violates the policy.
+func (m MemoPolicy) Check(memo string) error {
+	if len(memo) > m.MaxLength {
+		return fmt.Errorf("Max memo length (%d) exceeded: %d", m.MaxLength, len(memo))
+	}
+	switch m.Encoding {
+	case MemoEncodingUTF8:
+		if !utf8.ValidString(memo) {
+			return fmt.Errorf("Memo contains invalid utf8 characters")
+		}
+	case MemoEncodingASCII:
+		for i := 0; i < len(memo); i++ {
+			if memo[i] >= utf8.RuneSelf {
+				return fmt.Errorf("Memo contains non-ascii characters")
+			}
+		}
+	default:
+		return fmt.Errorf("Unknown memo encoding: %s", m.Encoding)
+	}
+	return nil
+}
+
+// Check memo policies are well formed. The first must be active from genesis and
+// activation heights must increase
+func validateMemoPolicies(policies []MemoPolicy) error {
+	if len(policies) == 0 {
+		return fmt.Errorf("At least one memo policy is required")
+	}
+	if policies[0].Height != 0 {
+		return fmt.Errorf("First memo policy must activate at height 0")
+	}
+	for i, p := range policies {
+		if i > 0 && p.Height <= policies[i-1].Height {
+			return fmt.Errorf("Memo policy activation heights must increase")
+		}
+		if p.MaxLength < 0 || p.MaxLength > MAX_PROTOCOL_MESSAGE_LENGTH/2 {
+			return fmt.Errorf("Invalid max memo length %d", p.MaxLength)
+		}
+		if p.Encoding != MemoEncodingUTF8 && p.Encoding != MemoEncodingASCII {
+			return fmt.Errorf("Unknown memo encoding: %s", p.Encoding)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package focalpoint
+
+import "testing"
+
+func TestMemoPolicy(t *testing.T) {
+	defer func(policies []MemoPolicy) { MEMO_POLICIES = policies }(MEMO_POLICIES)
+	MEMO_POLICIES = []MemoPolicy{
+		{Height: 0, MaxLength: 10, Encoding: MemoEncodingUTF8},
+		{Height: 100, MaxLength: 4, Encoding: MemoEncodingASCII},
+	}
+	if err := validateMemoPolicies(MEMO_POLICIES); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MemoPolicyAt(99).Check("こんにちは"[:9]); err != nil {
+		t.Fatalf("Expected utf8 memo to be allowed before activation: %s", err)
+	}
+	if err := MemoPolicyAt(100).Check("こ"); err == nil {
+		t.Fatal("Expected non-ascii memo to be rejected after activation")
+	}
+	if err := MemoPolicyAt(1000).Check("hello"); err == nil {
+		t.Fatal("Expected long memo to be rejected after activation")
+	}
+	if err := MemoPolicyAt(1000).Check("hey"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateMemoPolicies([]MemoPolicy{{Height: 5, MaxLength: 10, Encoding: MemoEncodingUTF8}}); err == nil {
+		t.Fatal("Expected policies not starting at genesis to be invalid")
+	}
+	if err := validateMemoPolicies([]MemoPolicy{
+		{Height: 0, MaxLength: 10, Encoding: MemoEncodingUTF8},
+		{Height: 0, MaxLength: 5, Encoding: "latin1"},
+	}); err == nil {
+		t.Fatal("Expected invalid policies to be rejected")
+	}
+}
@@ -8,11 +8,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
-	cuckoo "github.com/seiflotfy/cuckoofilter"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/util"
@@ -23,15 +26,22 @@ import (
 
 // Mind manages keys and considerations on behalf of a user.
 type Mind struct {
-	db                    *leveldb.DB
-	passphrase            string
-	conn                  *websocket.Conn
-	outChan               chan Message    // outgoing messages for synchronous requests
-	resultChan            chan mindResult // incoming results for synchronous requests
-	considerationCallback func(*Consideration)
-	filterViewCallback    func(*FilterViewMessage)
-	filter                *cuckoo.Filter
-	wg                    sync.WaitGroup
+	db                        *leveldb.DB
+	passphrase                string
+	conn                      *websocket.Conn
+	outChan                   chan Message    // outgoing messages for synchronous requests
+	resultChan                chan mindResult // incoming results for synchronous requests
+	considerationCallback     func(*Consideration)
+	filterViewCallback        func(*FilterViewMessage)
+	confirmationBatchCallback func(*ConfirmationBatch)
+	batch                     *ConfirmationBatch // confirmations held until the batch is delivered
+	batchLock                 sync.Mutex
+	filter                    ConsiderationFilter
+	filterType                string
+	awaitingDescendant        int32 // set while a get_descendant reply is wanted. late replies are dropped
+	syncedHeightLock          sync.Mutex
+	signLogLock               sync.Mutex
+	wg                        sync.WaitGroup
 }
 
 // NewMind returns a new Mind instance.
@@ -46,11 +56,15 @@ func NewMind(mindDbPath string, recover bool) (*Mind, error) {
 	if err != nil {
 		return nil, err
 	}
-	w := &Mind{db: db}
+	w := &Mind{db: db, filterType: FilterTypeCuckoo}
 	if err := w.initializeFilter(); err != nil {
 		w.db.Close()
 		return nil, err
 	}
+	if err := w.loadHeldBatch(); err != nil {
+		w.db.Close()
+		return nil, err
+	}
 	return w, nil
 }
 
@@ -153,6 +167,36 @@ func (w *Mind) AddKey(pubKey ed25519.PublicKey, privKey ed25519.PrivateKey) erro
 	return nil
 }
 
+// SetDefaultExpiry sets the number of views from now after which newly sent considerations expire.
+// Zero means they never expire.
+func (w *Mind) SetDefaultExpiry(expires int64) error {
+	if expires < 0 || expires > MAX_NUMBER {
+		return fmt.Errorf("Invalid expiry %d", expires)
+	}
+	expiresBytes, err := encodeNumber(expires)
+	if err != nil {
+		return err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return w.db.Put([]byte{defaultExpiryPrefix}, expiresBytes, &wo)
+}
+
+// GetDefaultExpiry returns the number of views from now after which newly sent considerations expire.
+func (w *Mind) GetDefaultExpiry() (int64, error) {
+	expiresBytes, err := w.db.Get([]byte{defaultExpiryPrefix}, nil)
+	if err == leveldb.ErrNotFound {
+		return DEFAULT_CONSIDERATION_EXPIRY, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var expires int64
+	if err := binary.Read(bytes.NewReader(expiresBytes), binary.BigEndian, &expires); err != nil {
+		return 0, err
+	}
+	return expires, nil
+}
+
 // GetKeys returns all of the public keys from the database.
 func (w *Mind) GetKeys() ([]ed25519.PublicKey, error) {
 	privKeyDbKey, err := encodePrivateKeyDbKey(nil)
@@ -200,7 +244,10 @@ func (w *Mind) Connect(addr string, genesisID ViewID, tlsVerify bool) error {
 	u := url.URL{Scheme: "wss", Host: addr, Path: "/" + genesisID.String()}
 	// by default clients skip verification as most peers are using ephemeral certificates and keys.
 	peerDialer.TLSClientConfig.InsecureSkipVerify = !tlsVerify
-	conn, _, err := peerDialer.Dial(u.String(), nil)
+	// let the peer know we're a mind and not a relaying peer
+	header := http.Header{}
+	header.Add("Viewpoint-Mind", "1")
+	conn, _, err := peerDialer.Dial(u.String(), header)
 	if err != nil {
 		return err
 	}
@@ -236,6 +283,9 @@ func (w *Mind) GetGraph(pubKey ed25519.PublicKey) (string, int64, error) {
 	if err := json.Unmarshal(result.message, b); err != nil {
 		return "", 0, err
 	}
+	if len(b.Error) != 0 {
+		return "", 0, fmt.Errorf("%s", b.Error)
+	}
 	return b.Graph, b.Height, nil
 }
 
@@ -250,9 +300,56 @@ func (w *Mind) GetRanking(pubKey ed25519.PublicKey) (float64, int64, error) {
 	if err := json.Unmarshal(result.message, b); err != nil {
 		return 0.00, 0, err
 	}
+	if len(b.Error) != 0 {
+		return 0.00, 0, fmt.Errorf("%s", b.Error)
+	}
 	return b.Ranking, b.Height, nil
 }
 
+// How long to wait for a get_descendant reply. Peers which predate it never answer
+var descendantCheckTimeout = 10 * time.Second
+
+// IsDescendant returns true if descendant descends from parent in the peer's consideration graph
+// as well as the corresponding view height.
+func (w *Mind) IsDescendant(parent, descendant ed25519.PublicKey) (bool, int64, error) {
+	isDescendant, height, answered, err := w.isDescendant(parent, descendant)
+	if err == nil && !answered {
+		err = fmt.Errorf("Peer didn't answer, it may not support get_descendant")
+	}
+	return isDescendant, height, err
+}
+
+// Ask the peer whether descendant descends from parent. answered is false if the peer didn't
+// reply in time
+func (w *Mind) isDescendant(parent, descendant ed25519.PublicKey) (
+	isDescendant bool, height int64, answered bool, err error) {
+	atomic.StoreInt32(&w.awaitingDescendant, 1)
+	w.outChan <- Message{Type: "get_descendant",
+		Body: GetDescendantMessage{Parent: parent, Descendant: descendant}}
+	var result mindResult
+	select {
+	case result = <-w.resultChan:
+	case <-time.After(descendantCheckTimeout):
+		if atomic.CompareAndSwapInt32(&w.awaitingDescendant, 1, 0) {
+			return false, 0, false, nil
+		}
+		// the reply arrived as we gave up on it
+		result = <-w.resultChan
+	}
+	atomic.StoreInt32(&w.awaitingDescendant, 0)
+	if len(result.err) != 0 {
+		return false, 0, false, fmt.Errorf("%s", result.err)
+	}
+	b := new(DescendantMessage)
+	if err := json.Unmarshal(result.message, b); err != nil {
+		return false, 0, false, err
+	}
+	if len(b.Error) != 0 {
+		return false, 0, false, fmt.Errorf("%s", b.Error)
+	}
+	return b.IsDescendant, b.Height, true, nil
+}
+
 // GetRankings returns a set of public key rankings as well as the current view height.
 func (w *Mind) GetRankings(pubKeys []ed25519.PublicKey) ([]PublicKeyRanking, int64, error) {
 	w.outChan <- Message{Type: "get_rankings", Body: GetRankingsMessage{PublicKeys: pubKeys}}
@@ -309,24 +406,106 @@ func (w *Mind) GetTipHeader() (ViewID, ViewHeader, error) {
 	return *th.ViewID, *th.ViewHeader, nil
 }
 
+// GetViewHeaderByHeight returns the header of the main point's view at the given height.
+func (w *Mind) GetViewHeaderByHeight(height int64) (ViewID, ViewHeader, error) {
+	w.outChan <- Message{Type: "get_view_header_by_height", Body: GetViewHeaderByHeightMessage{Height: height}}
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return ViewID{}, ViewHeader{}, fmt.Errorf("%s", result.err)
+	}
+	if len(result.message) == 0 {
+		return ViewID{}, ViewHeader{}, fmt.Errorf("No view at height %d", height)
+	}
+	vh := new(ViewHeaderMessage)
+	if err := json.Unmarshal(result.message, vh); err != nil {
+		return ViewID{}, ViewHeader{}, err
+	}
+	if vh.ViewID == nil || vh.ViewHeader == nil {
+		return ViewID{}, ViewHeader{}, fmt.Errorf("No view at height %d", height)
+	}
+	return *vh.ViewID, *vh.ViewHeader, nil
+}
+
+// GetDisplayHints returns the display hints the peer advertises for its network.
+func (w *Mind) GetDisplayHints() (*DisplayHintsMessage, error) {
+	w.outChan <- Message{Type: "get_display_hints"}
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return nil, fmt.Errorf("%s", result.err)
+	}
+	dh := new(DisplayHintsMessage)
+	if err := json.Unmarshal(result.message, dh); err != nil {
+		return nil, err
+	}
+	return dh, nil
+}
+
+// GetStatus returns a summary of the peer's health.
+func (w *Mind) GetStatus() (*StatusMessage, error) {
+	w.outChan <- Message{Type: "get_status"}
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return nil, fmt.Errorf("%s", result.err)
+	}
+	status := new(StatusMessage)
+	if err := json.Unmarshal(result.message, status); err != nil {
+		return nil, err
+	}
+	if len(status.Error) != 0 {
+		return nil, fmt.Errorf("%s", status.Error)
+	}
+	return status, nil
+}
+
+// SetFilterType sets the type of filter the mind asks the peer to load and rebuilds it.
+func (w *Mind) SetFilterType(filterType string) error {
+	previousType := w.filterType
+	w.filterType = filterType
+	if err := w.initializeFilter(); err != nil {
+		w.filterType = previousType
+		return err
+	}
+	return nil
+}
+
 // SetFilter sets the filter for the connection.
+// If the peer doesn't support the mind's filter type it falls back to a cuckoo filter.
 func (w *Mind) SetFilter() error {
 	m := Message{
 		Type: "filter_load",
 		Body: FilterLoadMessage{
-			Type:   "cuckoo",
+			Type:   w.filter.Type(),
 			Filter: w.filter.Encode(),
 		},
 	}
 	w.outChan <- m
 	result := <-w.resultChan
-	if len(result.err) != 0 {
-		return fmt.Errorf("%s", result.err)
+	if len(result.err) == 0 {
+		return nil
 	}
-	return nil
+	if w.filterType != FilterTypeCuckoo && len(result.message) != 0 {
+		fr := new(FilterResultMessage)
+		if err := json.Unmarshal(result.message, fr); err != nil {
+			return err
+		}
+		var supported, cuckooSupported bool
+		for _, filterType := range fr.Types {
+			supported = supported || filterType == w.filterType
+			cuckooSupported = cuckooSupported || filterType == FilterTypeCuckoo
+		}
+		if len(fr.Types) != 0 && !supported && cuckooSupported {
+			log.Printf("Peer doesn't support %s filters, using %s\n", w.filterType, FilterTypeCuckoo)
+			if err := w.SetFilterType(FilterTypeCuckoo); err != nil {
+				return err
+			}
+			return w.SetFilter()
+		}
+	}
+	return fmt.Errorf("%s", result.err)
 }
 
 // AddFilter sends a message to add a public key to the filter.
+// If the peer has lost the filter it's reloaded first.
 func (w *Mind) AddFilter(pubKey ed25519.PublicKey) error {
 	m := Message{
 		Type: "filter_add",
@@ -334,17 +513,213 @@ func (w *Mind) AddFilter(pubKey ed25519.PublicKey) error {
 			PublicKeys: []ed25519.PublicKey{pubKey},
 		},
 	}
-	w.outChan <- m
-	result := <-w.resultChan
-	if len(result.err) != 0 {
-		return fmt.Errorf("%s", result.err)
+	for reloaded := false; ; reloaded = true {
+		w.outChan <- m
+		result := <-w.resultChan
+		if len(result.err) == 0 {
+			return nil
+		}
+		fr := new(FilterResultMessage)
+		if len(result.message) != 0 {
+			if err := json.Unmarshal(result.message, fr); err != nil {
+				return err
+			}
+		}
+		if fr.Code != FilterNotLoadedCode || reloaded {
+			return fmt.Errorf("%s", result.err)
+		}
+		if err := w.reloadFilter(); err != nil {
+			return err
+		}
 	}
+}
+
+// GetFilterConsiderationQueue returns the unconfirmed considerations in the peer's queue
+// which match the mind's filter. If the peer has lost the filter it's reloaded first.
+func (w *Mind) GetFilterConsiderationQueue() ([]*Consideration, error) {
+	for reloaded := false; ; reloaded = true {
+		w.outChan <- Message{Type: "get_filter_consideration_queue"}
+		result := <-w.resultChan
+		if len(result.err) != 0 {
+			return nil, fmt.Errorf("%s", result.err)
+		}
+		ftq := new(FilterConsiderationQueueMessage)
+		if err := json.Unmarshal(result.message, ftq); err != nil {
+			return nil, err
+		}
+		if len(ftq.Error) == 0 {
+			return ftq.Considerations, nil
+		}
+		if ftq.Code != FilterNotLoadedCode || reloaded {
+			return nil, fmt.Errorf("%s", ftq.Error)
+		}
+		if err := w.reloadFilter(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Reload the filter after the peer lost it and replay the filter views we missed in the meantime
+func (w *Mind) reloadFilter() error {
+	log.Printf("Peer lost our filter, reloading it\n")
+	if err := w.SetFilter(); err != nil {
+		return err
+	}
+	height, err := w.GetSyncedHeight()
+	if err != nil {
+		return err
+	}
+	if height == 0 {
+		// we haven't seen a filter view so we don't know what we missed
+		return nil
+	}
+	return w.Rescan(height + 1)
+}
+
+// GetSyncedHeight returns the height of the most recent filter view the mind has handled
+// or 0 if it hasn't handled any.
+func (w *Mind) GetSyncedHeight() (int64, error) {
+	heightBytes, err := w.db.Get([]byte{syncedHeightPrefix}, nil)
+	if err == leveldb.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var height int64
+	if err := binary.Read(bytes.NewReader(heightBytes), binary.BigEndian, &height); err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// Rescan rebuilds the filter views from sinceHeight to the tip from each key's consideration
+// history and replays them through the filter view callback as if they had been received from
+// the peer. It's used to learn what the mind missed while it was offline.
+func (w *Mind) Rescan(sinceHeight int64) error {
+	_, tipHeader, err := w.GetTipHeader()
+	if err != nil {
+		return err
+	}
+	if sinceHeight > tipHeader.Height {
+		return nil
+	}
+	pubKeys, err := w.GetKeys()
+	if err != nil {
+		return err
+	}
+	watchKeys, err := w.GetWatchKeys()
+	if err != nil {
+		return err
+	}
+	pubKeys = append(pubKeys, watchKeys...)
+
+	// merge the keys' histories so each view is handled once
+	merged := newFilterViewMerger()
+	for _, pubKey := range pubKeys {
+		height, index := sinceHeight, 0
+		for {
+			_, stopHeight, stopIndex, fbs, err := w.GetPublicKeyConsiderations(
+				pubKey, height, tipHeader.Height+1, index, 32)
+			if err != nil {
+				return err
+			}
+			var numCn int
+			height, index = stopHeight, stopIndex+1
+			for _, fb := range fbs {
+				for _, cn := range fb.Considerations {
+					numCn++
+					if err := merged.add(fb.ViewID, fb.Header, cn); err != nil {
+						return err
+					}
+				}
+			}
+			if numCn < 32 {
+				break
+			}
+		}
+	}
+
+	fbs := merged.filterViews()
+	log.Printf("Replaying %d missed filter view(s) from height %d\n", len(fbs), sinceHeight)
+	for _, fb := range fbs {
+		w.onFilterView(fb)
+	}
+
+	// views without any of our considerations weren't returned but we've seen them too
+	return w.updateSyncedHeight(tipHeader.Height)
+}
+
+// filterViewMerger combines the histories of several keys into one filter view per view
+type filterViewMerger struct {
+	fbsByID map[ViewID]*FilterViewMessage
+	seen    map[ConsiderationID]bool
+}
+
+func newFilterViewMerger() *filterViewMerger {
+	return &filterViewMerger{
+		fbsByID: make(map[ViewID]*FilterViewMessage),
+		seen:    make(map[ConsiderationID]bool),
+	}
+}
+
+// Add a consideration found in the given view
+func (m *filterViewMerger) add(id ViewID, header *ViewHeader, cn *Consideration) error {
+	cnID, err := cn.ID()
+	if err != nil {
+		return err
+	}
+	if m.seen[cnID] {
+		// it involves more than one of our keys
+		return nil
+	}
+	m.seen[cnID] = true
+	fb, ok := m.fbsByID[id]
+	if !ok {
+		fb = &FilterViewMessage{ViewID: id, Header: header}
+		m.fbsByID[id] = fb
+	}
+	fb.Considerations = append(fb.Considerations, cn)
 	return nil
 }
 
-// Send creates, signs and pushes an consideration out to the network.
+// Returns the merged filter views in height order
+func (m *filterViewMerger) filterViews() []*FilterViewMessage {
+	fbs := make([]*FilterViewMessage, 0, len(m.fbsByID))
+	for _, fb := range m.fbsByID {
+		fbs = append(fbs, fb)
+	}
+	sort.Slice(fbs, func(i, j int) bool {
+		return fbs[i].Header.Height < fbs[j].Header.Height
+	})
+	return fbs
+}
+
+// What to tell the user when the peer rejects a consideration with one of these reason codes
+var pushRejectionHints = map[string]string{
+	QueueFullCode: "The peer's consideration queue is full, try sending again later",
+	ExpiredCode: "The consideration would expire before it could be rendered, " +
+		"use expiry to allow more views or 0 for no expiry",
+	ImmatureCode: "The consideration's maturity height has passed and peers only queue considerations " +
+		"which are mature in the next view",
+	DescendantRuleCode: "The sender descends from the recipient in the consideration graph " +
+		"and cannot consider one of its own ancestors",
+	InsufficientImbalanceCode: "The sending key has no imbalance, it must be considered by others before it can consider",
+}
+
+// Send creates, signs and pushes an consideration out to the network. Matures and expires are
+// numbers of views from the current height, zero for neither. The network has no time lock so
+// a consideration which matures later is held by the mind and pushed by PushUnlocked once it
+// could be rendered. Its expiry counts from then.
 func (w *Mind) Send(from, to ed25519.PublicKey, matures, expires int64, memo string) (
 	ConsiderationID, error) {
+	if matures < 0 {
+		return ConsiderationID{}, fmt.Errorf("Invalid maturity %d", matures)
+	}
+	if expires < 0 {
+		return ConsiderationID{}, fmt.Errorf("Invalid expiry %d", expires)
+	}
+
 	// fetch the private key
 	privKeyDbKey, err := encodePrivateKeyDbKey(from)
 	if err != nil {
@@ -367,37 +742,207 @@ func (w *Mind) Send(from, to ed25519.PublicKey, matures, expires int64, memo str
 		return ConsiderationID{}, err
 	}
 	// set these relative to the current height
+	var unlocks int64
 	if matures != 0 {
-		matures = header.Height + matures
+		unlocks = header.Height + matures
 	}
 	if expires != 0 {
-		expires = header.Height + expires
+		expires = header.Height + matures + expires
 	}
 
-	// create the consideration
-	cn := NewConsideration(from, to, matures, expires, header.Height, memo)
+	// create the consideration. it's signed now so its series is the current one
+	cn := NewConsideration(from, to, 0, expires, header.Height, memo)
+	if last := LastSeriesHeight(cn.Series); unlocks > last {
+		return ConsiderationID{}, fmt.Errorf("Consideration can't be locked past height %d, "+
+			"when its series is retired", last)
+	}
 
 	// sign it
 	if err := cn.Sign(privKey); err != nil {
 		return ConsiderationID{}, err
 	}
 
+	// check what we can locally so obviously invalid considerations fail without a round trip
+	id, err := cn.ID()
+	if err != nil {
+		return ConsiderationID{}, err
+	}
+	err = w.appendSignLog(SignLogEntry{
+		Operation:       SignLogConsideration,
+		From:            from,
+		To:              to,
+		MemoHash:        hashSignLogMemo(memo),
+		ConsiderationID: &id,
+		Height:          header.Height,
+	})
+	if err != nil {
+		return ConsiderationID{}, err
+	}
+	if err := checkConsideration(id, cn); err != nil {
+		return ConsiderationID{}, err
+	}
+	if err := checkConsiderationForNextView(id, cn, header.Height); err != nil {
+		if r, ok := err.(considerationRejection); ok {
+			return ConsiderationID{}, fmt.Errorf("%s", pushRejectionHints[r.code])
+		}
+		return ConsiderationID{}, err
+	}
+	// peers which predate get_descendant don't answer. they still apply the rule to the push
+	descendant, _, _, err := w.isDescendant(to, from)
+	if err != nil {
+		return ConsiderationID{}, err
+	}
+	if descendant {
+		return ConsiderationID{}, fmt.Errorf("%s", pushRejectionHints[DescendantRuleCode])
+	}
+
+	if unlocks > header.Height+1 {
+		// hold it until it can be rendered
+		if err := w.recordLocked(id, cn, unlocks); err != nil {
+			return ConsiderationID{}, err
+		}
+		return id, nil
+	}
+
 	// push it
+	if err := w.pushConsideration(cn); err != nil {
+		return ConsiderationID{}, err
+	}
+
+	// remember it until it's confirmed
+	if err := w.recordSent(id, cn); err != nil {
+		return ConsiderationID{}, err
+	}
+	return id, nil
+}
+
+// Push a signed consideration to the peer
+func (w *Mind) pushConsideration(cn *Consideration) error {
 	w.outChan <- Message{Type: "push_consideration", Body: PushConsiderationMessage{Consideration: cn}}
 	result := <-w.resultChan
 
 	// handle result
 	if len(result.err) != 0 {
-		return ConsiderationID{}, fmt.Errorf("%s", result.err)
+		return fmt.Errorf("%s", result.err)
 	}
 	ptr := new(PushConsiderationResultMessage)
 	if err := json.Unmarshal(result.message, ptr); err != nil {
-		return ConsiderationID{}, err
+		return err
 	}
 	if len(ptr.Error) != 0 {
-		return ConsiderationID{}, fmt.Errorf("%s", ptr.Error)
+		if hint, ok := pushRejectionHints[ptr.Code]; ok {
+			return fmt.Errorf("%s", hint)
+		}
+		return fmt.Errorf("%s", ptr.Error)
+	}
+	return nil
+}
+
+// SignCheckpoint signs a checkpoint with the given key and announces it to the peer.
+// The peer will only accept it if it's been configured to trust the key.
+func (w *Mind) SignCheckpoint(pubKey ed25519.PublicKey, id ViewID, height int64) error {
+	// fetch the private key
+	privKeyDbKey, err := encodePrivateKeyDbKey(pubKey)
+	if err != nil {
+		return err
+	}
+	encryptedPrivKey, err := w.db.Get(privKeyDbKey, nil)
+	if err != nil {
+		return err
+	}
+
+	// decrypt it
+	privKey, ok := decryptPrivateKey(encryptedPrivKey, w.passphrase)
+	if !ok {
+		return fmt.Errorf("Unable to decrypt private key")
+	}
+
+	cp, err := NewSignedCheckpoint(id, height, privKey)
+	if err != nil {
+		return err
+	}
+	err = w.appendSignLog(SignLogEntry{
+		Operation: SignLogCheckpoint,
+		From:      pubKey,
+		ViewID:    &id,
+		Height:    height,
+	})
+	if err != nil {
+		return err
+	}
+
+	// push it
+	w.outChan <- Message{Type: "checkpoint", Body: CheckpointMessage{Checkpoint: cp}}
+	result := <-w.resultChan
+
+	// handle result
+	if len(result.err) != 0 {
+		return fmt.Errorf("%s", result.err)
+	}
+	cr := new(CheckpointResultMessage)
+	if err := json.Unmarshal(result.message, cr); err != nil {
+		return err
+	}
+	if len(cr.Error) != 0 {
+		return fmt.Errorf("%s", cr.Error)
+	}
+	return nil
+}
+
+// InvalidateView asks the peer to treat a view and every view built on it as invalid.
+// The peer must be running on the same host and token must be its operator token.
+func (w *Mind) InvalidateView(id ViewID, token string) error {
+	w.outChan <- Message{Type: "invalidate_view", Body: InvalidateViewMessage{ViewID: id, Token: token}}
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return fmt.Errorf("%s", result.err)
+	}
+	ir := new(InvalidateViewResultMessage)
+	if err := json.Unmarshal(result.message, ir); err != nil {
+		return err
 	}
-	return ptr.ConsiderationID, nil
+	if len(ir.Error) != 0 {
+		return fmt.Errorf("%s", ir.Error)
+	}
+	return nil
+}
+
+// ReconsiderView asks the peer to clear the invalid mark from a view it was told to invalidate.
+// The peer must be running on the same host and token must be its operator token.
+func (w *Mind) ReconsiderView(id ViewID, token string) error {
+	w.outChan <- Message{Type: "reconsider_view", Body: ReconsiderViewMessage{ViewID: id, Token: token}}
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return fmt.Errorf("%s", result.err)
+	}
+	rr := new(ReconsiderViewResultMessage)
+	if err := json.Unmarshal(result.message, rr); err != nil {
+		return err
+	}
+	if len(rr.Error) != 0 {
+		return fmt.Errorf("%s", rr.Error)
+	}
+	return nil
+}
+
+// ControlIndexer asks the peer to "pause", "disable", "resume" or "rebuild" its indexer and
+// returns the indexer's resulting state. Rebuilding starts from the given height.
+// The peer must be running on the same host and token must be its operator token.
+func (w *Mind) ControlIndexer(action string, height int64, token string) (string, error) {
+	w.outChan <- Message{Type: "indexer_control",
+		Body: IndexerControlMessage{Action: action, Height: height, Token: token}}
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return "", fmt.Errorf("%s", result.err)
+	}
+	ir := new(IndexerControlResultMessage)
+	if err := json.Unmarshal(result.message, ir); err != nil {
+		return "", err
+	}
+	if len(ir.Error) != 0 {
+		return ir.State, fmt.Errorf("%s", ir.Error)
+	}
+	return ir.State, nil
 }
 
 // GetConsideration retrieves information about a historic consideration.
@@ -415,29 +960,122 @@ func (w *Mind) GetConsideration(id ConsiderationID) (*Consideration, *ViewID, in
 }
 
 // GetPublicKeyConsiderations retrieves information about historic considerations involving the given public key.
+// If a pruned peer stops reading its stored views early the request is continued until the limit is reached.
 func (w *Mind) GetPublicKeyConsiderations(
 	pubKey ed25519.PublicKey, startHeight, endHeight int64, startIndex, limit int) (
 	startH, stopH int64, stopIndex int, fb []*FilterViewMessage, err error) {
-	gpkt := GetPublicKeyConsiderationsMessage{
+	startH, stopH, stopIndex, fb, _, err = w.getPublicKeyConsiderations(
+		pubKey, startHeight, endHeight, startIndex, limit, false)
+	return
+}
+
+// GetPublicKeyConsiderationIDs is like GetPublicKeyConsiderations but only retrieves the IDs of the
+// considerations and where they were confirmed. Use GetConsideration to fetch any which are needed.
+func (w *Mind) GetPublicKeyConsiderationIDs(
+	pubKey ed25519.PublicKey, startHeight, endHeight int64, startIndex, limit int) (
+	startH, stopH int64, stopIndex int, ids []PublicKeyConsiderationID, err error) {
+	startH, stopH, stopIndex, _, ids, err = w.getPublicKeyConsiderations(
+		pubKey, startHeight, endHeight, startIndex, limit, true)
+	return
+}
+
+func (w *Mind) getPublicKeyConsiderations(
+	pubKey ed25519.PublicKey, startHeight, endHeight int64, startIndex, limit int, idsOnly bool) (
+	startH, stopH int64, stopIndex int, fb []*FilterViewMessage, ids []PublicKeyConsiderationID, err error) {
+	if limit <= 0 || limit > 32 {
+		// the peer's limit
+		limit = 32
+	}
+	startH = startHeight
+	var numCn int
+	for {
+		gpkt := GetPublicKeyConsiderationsMessage{
+			PublicKey:   pubKey,
+			StartHeight: startHeight,
+			StartIndex:  startIndex,
+			EndHeight:   endHeight,
+			Limit:       limit - numCn,
+			IDsOnly:     idsOnly,
+		}
+		w.outChan <- Message{Type: "get_public_key_considerations", Body: gpkt}
+		result := <-w.resultChan
+		if len(result.err) != 0 {
+			return 0, 0, 0, nil, nil, fmt.Errorf("%s", result.err)
+		}
+		pkt := new(PublicKeyConsiderationsMessage)
+		if err := json.Unmarshal(result.message, pkt); err != nil {
+			return 0, 0, 0, nil, nil, err
+		}
+		if len(pkt.Error) != 0 {
+			return 0, 0, 0, nil, nil, fmt.Errorf("%s", pkt.Error)
+		}
+		for _, fb := range pkt.FilterViewes {
+			numCn += len(fb.Considerations)
+		}
+		numCn += len(pkt.ConsiderationIDs)
+		fb = append(fb, pkt.FilterViewes...)
+		ids = append(ids, pkt.ConsiderationIDs...)
+		if len(pkt.FilterViewes) != 0 || len(pkt.ConsiderationIDs) != 0 || !pkt.Truncated {
+			stopH, stopIndex = pkt.StopHeight, pkt.StopIndex
+		}
+		if !pkt.Truncated || numCn >= limit {
+			return startH, stopH, stopIndex, fb, ids, nil
+		}
+
+		// a pruned peer stopped reading its stored views early. continue from where it left off
+		if endHeight >= startHeight {
+			startHeight, startIndex = pkt.StopHeight+1, 0
+			if startHeight > endHeight {
+				return startH, stopH, stopIndex, fb, ids, nil
+			}
+		} else {
+			startHeight, startIndex = pkt.StopHeight-1, MAX_CONSIDERATIONS_PER_VIEW-1
+			if startHeight < endHeight {
+				return startH, stopH, stopIndex, fb, ids, nil
+			}
+		}
+	}
+}
+
+// GetRenderedViews retrieves the main point views whose viewpoint went to the given public key.
+func (w *Mind) GetRenderedViews(pubKey ed25519.PublicKey, startHeight, endHeight int64, limit int) (
+	[]RenderedView, error) {
+	grv := GetRenderedViewsMessage{
 		PublicKey:   pubKey,
 		StartHeight: startHeight,
-		StartIndex:  startIndex,
 		EndHeight:   endHeight,
 		Limit:       limit,
 	}
-	w.outChan <- Message{Type: "get_public_key_considerations", Body: gpkt}
+	w.outChan <- Message{Type: "get_rendered_views", Body: grv}
 	result := <-w.resultChan
 	if len(result.err) != 0 {
-		return 0, 0, 0, nil, fmt.Errorf("%s", result.err)
+		return nil, fmt.Errorf("%s", result.err)
 	}
-	pkt := new(PublicKeyConsiderationsMessage)
-	if err := json.Unmarshal(result.message, pkt); err != nil {
-		return 0, 0, 0, nil, err
+	rv := new(RenderedViewsMessage)
+	if err := json.Unmarshal(result.message, rv); err != nil {
+		return nil, err
 	}
-	if len(pkt.Error) != 0 {
-		return 0, 0, 0, nil, fmt.Errorf("%s", pkt.Error)
+	if len(rv.Error) != 0 {
+		return nil, fmt.Errorf("%s", rv.Error)
 	}
-	return pkt.StartHeight, pkt.StopHeight, pkt.StopIndex, pkt.FilterViewes, nil
+	return rv.Views, nil
+}
+
+// GetSupply returns network-wide totals as of the given main point height, or the tip if it's 0.
+func (w *Mind) GetSupply(height int64) (*SupplyMessage, error) {
+	w.outChan <- Message{Type: "get_supply", Body: GetSupplyMessage{Height: height}}
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return nil, fmt.Errorf("%s", result.err)
+	}
+	sm := new(SupplyMessage)
+	if err := json.Unmarshal(result.message, sm); err != nil {
+		return nil, err
+	}
+	if len(sm.Error) != 0 {
+		return nil, fmt.Errorf("%s", sm.Error)
+	}
+	return sm, nil
 }
 
 // VerifyKey verifies that the private key associated with the given public key is intact in the database.
@@ -528,12 +1166,27 @@ func (w *Mind) run() {
 			case "ranking":
 				w.resultChan <- mindResult{message: body}
 
+			case "descendant":
+				// drop replies which arrive after the request gave up on them
+				if atomic.CompareAndSwapInt32(&w.awaitingDescendant, 1, 0) {
+					w.resultChan <- mindResult{message: body}
+				}
+
 			case "graph":
 				w.resultChan <- mindResult{message: body}
 
 			case "tip_header":
 				w.resultChan <- mindResult{message: body}
 
+			case "view_header":
+				w.resultChan <- mindResult{message: body}
+
+			case "display_hints":
+				w.resultChan <- mindResult{message: body}
+
+			case "status":
+				w.resultChan <- mindResult{message: body}
+
 			case "consideration_relay_policy":
 				w.resultChan <- mindResult{message: body}
 
@@ -546,6 +1199,39 @@ func (w *Mind) run() {
 			case "public_key_considerations":
 				w.resultChan <- mindResult{message: body}
 
+			case "rendered_views":
+				w.resultChan <- mindResult{message: body}
+
+			case "supply":
+				w.resultChan <- mindResult{message: body}
+
+			case "mind_state":
+				w.resultChan <- mindResult{message: body}
+
+			case "put_mind_state_result":
+				w.resultChan <- mindResult{message: body}
+
+			case "checkpoint_result":
+				w.resultChan <- mindResult{message: body}
+
+			case "invalidate_view_result":
+				w.resultChan <- mindResult{message: body}
+
+			case "reconsider_view_result":
+				w.resultChan <- mindResult{message: body}
+
+			case "indexer_control_result":
+				w.resultChan <- mindResult{message: body}
+
+			case "query_rejected":
+				qr := new(QueryRejectedMessage)
+				if err := json.Unmarshal(body, qr); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, w.conn.RemoteAddr())
+					w.resultChan <- mindResult{err: err.Error()}
+					break
+				}
+				w.resultChan <- mindResult{err: qr.Error}
+
 			case "filter_result":
 				if len(body) != 0 {
 					fr := new(FilterResultMessage)
@@ -554,7 +1240,7 @@ func (w *Mind) run() {
 						w.resultChan <- mindResult{err: err.Error()}
 						break
 					}
-					w.resultChan <- mindResult{err: fr.Error}
+					w.resultChan <- mindResult{err: fr.Error, message: body}
 				} else {
 					w.resultChan <- mindResult{}
 				}
@@ -565,19 +1251,28 @@ func (w *Mind) run() {
 					log.Printf("Error: %s, from: %s\n", err, w.conn.RemoteAddr())
 					break
 				}
-				if w.considerationCallback != nil {
+				if w.considerationCallback == nil {
+					break
+				}
+				muted, err := w.isMuted(pt.Consideration)
+				if err != nil {
+					log.Printf("Error: %s\n", err)
+					break
+				}
+				if !muted {
 					w.considerationCallback(pt.Consideration)
 				}
 
+			case "filter_consideration_queue":
+				w.resultChan <- mindResult{message: body}
+
 			case "filter_view":
 				fb := new(FilterViewMessage)
 				if err := json.Unmarshal(body, fb); err != nil {
 					log.Printf("Error: %s, from: %s\n", err, w.conn.RemoteAddr())
 					break
 				}
-				if w.filterViewCallback != nil {
-					w.filterViewCallback(fb)
-				}
+				w.onFilterView(fb)
 			}
 
 		case websocket.CloseMessage:
@@ -587,6 +1282,51 @@ func (w *Mind) run() {
 	}
 }
 
+// Handle a new filter view
+func (w *Mind) onFilterView(fb *FilterViewMessage) {
+	// confirmed considerations are no longer pending
+	for _, cn := range fb.Considerations {
+		cnID, err := cn.ID()
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			continue
+		}
+		if err := w.confirmSent(cnID, fb.ViewID, fb.Header.Height); err != nil {
+			log.Printf("Error: %s\n", err)
+		}
+	}
+	if err := w.updateSyncedHeight(fb.Header.Height); err != nil {
+		log.Printf("Error: %s\n", err)
+	}
+	if w.filterViewCallback == nil {
+		return
+	}
+	filtered, err := w.filterNotifications(fb)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+	w.filterViewCallback(filtered)
+}
+
+// Record the height of a handled filter view if it's the most recent
+func (w *Mind) updateSyncedHeight(height int64) error {
+	w.syncedHeightLock.Lock()
+	defer w.syncedHeightLock.Unlock()
+	syncedHeight, err := w.GetSyncedHeight()
+	if err != nil {
+		return err
+	}
+	if height <= syncedHeight {
+		return nil
+	}
+	heightBytes, err := encodeNumber(height)
+	if err != nil {
+		return err
+	}
+	return w.db.Put([]byte{syncedHeightPrefix}, heightBytes, nil)
+}
+
 // Shutdown is called to shutdown the mind synchronously.
 func (w *Mind) Shutdown() error {
 	var addr string
@@ -608,10 +1348,19 @@ func (w *Mind) initializeFilter() error {
 	if err != nil {
 		return err
 	}
+	watchKeys, err := w.GetWatchKeys()
+	if err != nil {
+		return err
+	}
+	pubKeys = append(pubKeys, watchKeys...)
 	if len(pubKeys) > capacity/2 {
 		capacity = len(pubKeys) * 2
 	}
-	w.filter = cuckoo.NewFilter(uint(capacity))
+	filter, err := NewConsiderationFilter(w.filterType, capacity)
+	if err != nil {
+		return err
+	}
+	w.filter = filter
 	for _, pubKey := range pubKeys {
 		if !w.filter.Insert(pubKey[:]) {
 			return fmt.Errorf("Error building filter")
@@ -624,11 +1373,41 @@ func (w *Mind) initializeFilter() error {
 
 // n         -> newest public key
 // k{pubkey} -> encrypted private key
+// l{pubkey} -> label (for our own keys and contacts)
+// d{pubkey} -> 1 (label removed, kept so the removal syncs to other devices)
+// w{pubkey} -> 1 (watch-only public key)
+// p{cnid}   -> sent consideration record (json). it's pending until seen confirmed
+// x         -> default expiry (views)
+// h         -> height of the most recent filter view handled
+// m{pubkey} -> 1 (public key muted for notifications)
+// b         -> confirmation notification batch size
+// c         -> confirmations held for the current batch (json)
+// g{seq}    -> encrypted signing log entry (json)
 
 const newestPublicKeyPrefix = 'n'
 
 const privateKeyPrefix = 'k'
 
+const labelPrefix = 'l'
+
+const removedLabelPrefix = 'd'
+
+const watchKeyPrefix = 'w'
+
+const sentConsiderationPrefix = 'p'
+
+const defaultExpiryPrefix = 'x'
+
+const syncedHeightPrefix = 'h'
+
+const mutedKeyPrefix = 'm'
+
+const confirmationBatchSizePrefix = 'b'
+
+const heldBatchPrefix = 'c'
+
+const signLogPrefix = 'g'
+
 func encodePrivateKeyDbKey(pubKey ed25519.PublicKey) ([]byte, error) {
 	key := new(bytes.Buffer)
 	if err := key.WriteByte(privateKeyPrefix); err != nil {
@@ -656,6 +1435,20 @@ func decodePrivateKeyDbKey(key []byte) (ed25519.PublicKey, error) {
 
 // NaCl secretbox encrypt a private key with an Argon2id key derived from passphrase
 func encryptPrivateKey(privKey ed25519.PrivateKey, passphrase string) []byte {
+	return encryptWithPassphrase(privKey[:], passphrase)
+}
+
+// NaCl secretbox decrypt a private key with an Argon2id key derived from passphrase
+func decryptPrivateKey(encryptedPrivKey []byte, passphrase string) (ed25519.PrivateKey, bool) {
+	decryptedPrivKey, ok := decryptWithPassphrase(encryptedPrivKey, passphrase)
+	if !ok {
+		return ed25519.PrivateKey{}, false
+	}
+	return ed25519.PrivateKey(decryptedPrivKey[:]), true
+}
+
+// NaCl secretbox encrypt data with an Argon2id key derived from passphrase
+func encryptWithPassphrase(data []byte, passphrase string) []byte {
 	salt := generateSalt()
 	key := stretchPassphrase(passphrase, salt)
 
@@ -667,32 +1460,31 @@ func encryptPrivateKey(privKey ed25519.PrivateKey, passphrase string) []byte {
 		panic(err)
 	}
 
-	encrypted := secretbox.Seal(nonce[:], privKey[:], &nonce, &secretKey)
+	encrypted := secretbox.Seal(nonce[:], data, &nonce, &secretKey)
 
 	// prepend the salt
-	encryptedPrivKey := make([]byte, len(encrypted)+ArgonSaltLength)
-	copy(encryptedPrivKey[:], salt)
-	copy(encryptedPrivKey[ArgonSaltLength:], encrypted)
+	encryptedData := make([]byte, len(encrypted)+ArgonSaltLength)
+	copy(encryptedData[:], salt)
+	copy(encryptedData[ArgonSaltLength:], encrypted)
 
-	return encryptedPrivKey
+	return encryptedData
 }
 
-// NaCl secretbox decrypt a private key with an Argon2id key derived from passphrase
-func decryptPrivateKey(encryptedPrivKey []byte, passphrase string) (ed25519.PrivateKey, bool) {
-	salt := encryptedPrivKey[:ArgonSaltLength]
+// NaCl secretbox decrypt data with an Argon2id key derived from passphrase
+func decryptWithPassphrase(encryptedData []byte, passphrase string) ([]byte, bool) {
+	if len(encryptedData) < ArgonSaltLength+24 {
+		return nil, false
+	}
+	salt := encryptedData[:ArgonSaltLength]
 	key := []byte(stretchPassphrase(passphrase, salt))
 
 	var secretKey [32]byte
 	copy(secretKey[:], key)
 
 	var nonce [24]byte
-	copy(nonce[:], encryptedPrivKey[ArgonSaltLength:ArgonSaltLength+24])
+	copy(nonce[:], encryptedData[ArgonSaltLength:ArgonSaltLength+24])
 
-	decryptedPrivKey, ok := secretbox.Open(nil, encryptedPrivKey[ArgonSaltLength+24:], &nonce, &secretKey)
-	if !ok {
-		return ed25519.PrivateKey{}, false
-	}
-	return ed25519.PrivateKey(decryptedPrivKey[:]), true
+	return secretbox.Open(nil, encryptedData[ArgonSaltLength+24:], &nonce, &secretKey)
 }
 
 const ArgonSaltLength = 16
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"math"
 	"math/rand"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/c-bata/go-prompt"
 	. "github.com/inconsiderable/focal-point"
+	"github.com/inconsiderable/focal-point/internal/config"
 	"github.com/logrusorgru/aurora"
 	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh/terminal"
@@ -31,21 +33,28 @@ func main() {
 	DefaultPeer := "127.0.0.1:" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
 	peerPtr := flag.String("peer", DefaultPeer, "Address of a peer to connect to")
 	dbPathPtr := flag.String("minddb", "", "Path to a mind database (created if it doesn't exist)")
-	tlsVerifyPtr := flag.Bool("tlsverify", false, "Verify the TLS certificate of the peer is signed by a recognized CA and the host matches the CN")
 	recoverPtr := flag.Bool("recover", false, "Attempt to recover a corrupt minddb")
+	restorePtr := flag.String("restore", "", "Path to a backup to restore into a new minddb")
+	filterTypePtr := flag.String("filtertype", FilterTypeCuckoo, "Type of filter to ask the peer to use (cuckoo or keys)")
+	operatorTokenPtr := flag.String("operatortoken", "", "Path to the peer's operator.token file, required by operator commands")
+	cfg := config.Register(flag.CommandLine, config.Network|config.TLSClient|config.Logging)
 	flag.Parse()
 
+	// switch networks before doing anything else
+	if err := cfg.Apply(); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.NetworkParams != nil && *peerPtr == DefaultPeer {
+		*peerPtr = "127.0.0.1:" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
+	}
+
 	if len(*dbPathPtr) == 0 {
 		log.Fatal("Path to the mind database required")
 	}
 	if len(*peerPtr) == 0 {
 		log.Fatal("Peer address required")
 	}
-	// add default port, if one was not supplied
-	i := strings.LastIndex(*peerPtr, ":")
-	if i < 0 {
-		*peerPtr = *peerPtr + ":" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
-	}
+	*peerPtr = config.WithDefaultPort(*peerPtr)
 
 	// load genesis view
 	var genesisView View
@@ -64,15 +73,32 @@ func main() {
 		fmt.Println("Attempting to recover mind...")
 	}
 
+	// restore from a backup before opening the restored database
+	var passphrase string
+	if len(*restorePtr) != 0 {
+		fmt.Printf("Restoring mind from '%s'...\n", *restorePtr)
+		passphrase = promptForPassphrase()
+		count, err := RestoreMindBackup(*restorePtr, *dbPathPtr, passphrase)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Restored %d record(s) to '%s'\n", count, *dbPathPtr)
+	}
+
 	// instantiate mind
 	mind, err := NewMind(*dbPathPtr, *recoverPtr)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := mind.SetFilterType(*filterTypePtr); err != nil {
+		log.Fatal(err)
+	}
 
 	for {
 		// load mind passphrase
-		passphrase := promptForPassphrase()
+		if len(passphrase) == 0 {
+			passphrase = promptForPassphrase()
+		}
 		ok, err := mind.SetPassphrase(passphrase)
 		if err != nil {
 			log.Fatal(err)
@@ -81,6 +107,7 @@ func main() {
 			break
 		}
 		fmt.Println(aurora.Bold(aurora.Red("Passphrase is not the one used to encrypt your most recent key.")))
+		passphrase = ""
 	}
 
 	// connect the mind ondemand
@@ -88,11 +115,31 @@ func main() {
 		if mind.IsConnected() {
 			return nil
 		}
-		if err := mind.Connect(*peerPtr, genesisID, *tlsVerifyPtr); err != nil {
+		if err := mind.Connect(*peerPtr, genesisID, cfg.TLSVerify); err != nil {
 			return err
 		}
 		go mind.Run()
-		return mind.SetFilter()
+		if err := mind.SetFilter(); err != nil {
+			return err
+		}
+		// catch up on anything we missed while offline
+		syncedHeight, err := mind.GetSyncedHeight()
+		if err != nil {
+			return err
+		}
+		if syncedHeight == 0 {
+			return nil
+		}
+		return mind.Rescan(syncedHeight + 1)
+	}
+
+	// operator commands present the token the peer wrote to its data directory
+	readOperatorToken := func() (string, error) {
+		if len(*operatorTokenPtr) == 0 {
+			return "", fmt.Errorf("This command requires -operatortoken set to the peer's %s file",
+				OPERATOR_TOKEN_FILE)
+		}
+		return ReadOperatorToken(*operatorTokenPtr)
 	}
 
 	var newTxs []*Consideration
@@ -153,6 +200,37 @@ func main() {
 				}()
 			}
 		}
+
+		// send any locked considerations which can now be rendered
+		go func() {
+			cmdLock.Lock()
+			defer cmdLock.Unlock()
+			pushed, err := mind.PushUnlocked()
+			for _, id := range pushed {
+				fmt.Printf("\n\nLocked consideration %s sent\n\n", id)
+			}
+			if err != nil {
+				fmt.Printf("\n\nError sending a locked consideration: %s\n\n", err)
+			}
+		}()
+	})
+
+	// handle batches of confirmations when batching is enabled
+	mind.SetConfirmationBatchCallback(func(batch *ConfirmationBatch) {
+		newConfsLock.Lock()
+		for _, conf := range batch.Confirmations {
+			newConfs = append(newConfs, &considerationWithHeight{cn: conf.Consideration, height: conf.Height})
+		}
+		newConfsLock.Unlock()
+		go func() {
+			// don't interrupt a user during a command
+			cmdLock.Lock()
+			defer cmdLock.Unlock()
+			fmt.Printf("\n\n%d consideration(s) confirmed between heights %d and %d! ",
+				len(batch.Confirmations), batch.StartHeight, batch.EndHeight)
+			fmt.Printf("Type %s to view them.\n\n",
+				aurora.Bold(aurora.Green("conf")))
+		}()
 	})
 
 	// setup prompt
@@ -165,16 +243,42 @@ func main() {
 			{Text: "imbalance", Description: "Retrieve the current imbalance of all public keys"},
 			{Text: "ranking", Description: "Retrieve the current considerability ranking of all public keys"},
 			{Text: "graph", Description: "Retrieve the DOT graph consideration of all public keys"},
+			{Text: "graphall", Description: "Save the graphs of all public keys combined into one DOT or GraphML file"},
 			{Text: "send", Description: "Send seeds to someone"},
+			{Text: "eta", Description: "Estimate how long a consideration sent now would take to confirm"},
 			{Text: "show", Description: "Show new incoming considerations"},
-			{Text: "cnstatus", Description: "Show confirmed consideration information given a consideration ID"},
+			{Text: "cnstatus", Description: "Show confirmed consideration information given a consideration ID or list the status of all sent considerations"},
 			{Text: "clearnew", Description: "Clear all pending incoming consideration notifications"},
 			{Text: "conf", Description: "Show new consideration confirmations"},
 			{Text: "clearconf", Description: "Clear all pending consideration confirmation notifications"},
+			{Text: "mute", Description: "Stop notifications of considerations involving a public key"},
+			{Text: "unmute", Description: "Resume notifications of considerations involving a public key"},
+			{Text: "batchconf", Description: "Set how many confirmations to collect before notifying you of them together"},
 			{Text: "points", Description: "Show immature view points for all public keys"},
+			{Text: "rendered", Description: "Show the number of views rendered to each public key and the most recent"},
 			{Text: "verify", Description: "Verify the private key is decryptable and intact for all public keys displayed with 'listkeys'"},
+			{Text: "audit", Description: "Save a signed report of the imbalance, last activity and private key status of all keys"},
+			{Text: "supply", Description: "Show the total supply, considerations and active keys as of a height"},
+			{Text: "rescan", Description: "Replay confirmations for all keys from a given height"},
+			{Text: "signlog", Description: "Review the log of everything your keys have signed"},
+			{Text: "scanledger", Description: "Replay confirmations for all keys from a full node's data directory on this machine"},
+			{Text: "status", Description: "Show the peer's sync state, connections, queue, rendering and indexer status"},
+			{Text: "network", Description: "Show the name, units and memo conventions the peer advertises for its network"},
+			{Text: "backup", Description: "Save an encrypted archive of the whole mind, keeping the most recent archives"},
 			{Text: "export", Description: "Save all of the mind's public-private key pairs to a text file"},
 			{Text: "import", Description: "Import public-private key pairs from a text file"},
+			{Text: "label", Description: "Label one of your public keys or a contact's public key"},
+			{Text: "watch", Description: "Watch a public key without holding its private key"},
+			{Text: "exportstate", Description: "Save labels, watch-only keys and pending considerations to an encrypted file"},
+			{Text: "importstate", Description: "Merge labels, watch-only keys and pending considerations from an encrypted file"},
+			{Text: "pushstate", Description: "Store encrypted mind state with the peer for your other devices"},
+			{Text: "pullstate", Description: "Merge encrypted mind state stored with the peer by your other devices"},
+			{Text: "series", Description: "Show the currently valid consideration series and warn about pending considerations"},
+			{Text: "expiry", Description: "Set the number of views after which newly sent considerations expire"},
+			{Text: "signcheckpoint", Description: "Sign a checkpoint with one of your keys and announce it to the peer"},
+			{Text: "invalidateview", Description: "Make the local peer treat a view and its descendants as invalid and reorganize away from them"},
+			{Text: "reconsiderview", Description: "Clear the invalid mark from a view on the local peer"},
+			{Text: "indexer", Description: "Pause, disable, resume or rebuild the local peer's indexer"},
 			{Text: "quit", Description: "Quit this mind session"},
 		}
 		return prompt.FilterHasPrefix(s, d.GetWordBeforeCursor(), true)
@@ -209,9 +313,24 @@ func main() {
 				fmt.Printf("Error: %s\n", err)
 				break
 			}
+			labels, err := getLabelMap(mind)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
 			for i, pubKey := range pubKeys {
-				fmt.Printf("%4d: %s\n",
-					i+1, base64.StdEncoding.EncodeToString(pubKey[:]))
+				pubKeyEncoded := base64.StdEncoding.EncodeToString(pubKey[:])
+				fmt.Printf("%4d: %s %s\n", i+1, pubKeyEncoded, labels[pubKeyEncoded])
+			}
+			watchKeys, err := mind.GetWatchKeys()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			for i, pubKey := range watchKeys {
+				pubKeyEncoded := base64.StdEncoding.EncodeToString(pubKey[:])
+				fmt.Printf("%4d: %s %s %s\n", len(pubKeys)+i+1, pubKeyEncoded,
+					aurora.Bold("(watch-only)"), labels[pubKeyEncoded])
 			}
 
 		case "genkeys":
@@ -283,6 +402,74 @@ func main() {
 					graph)
 			}
 
+		case "graphall":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			pubKeys, err := mind.GetKeys()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			watchKeys, err := mind.GetWatchKeys()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			pubKeys = append(pubKeys, watchKeys...)
+
+			var graphs, highlight []string
+			var height int64
+			for _, pubKey := range pubKeys {
+				graph, h, err := mind.GetGraph(pubKey)
+				if err != nil {
+					fmt.Printf("Error: %s\n", err)
+					break
+				}
+				graphs = append(graphs, graph)
+				highlight = append(highlight, base64.StdEncoding.EncodeToString(pubKey[:]))
+				height = h
+			}
+			if len(graphs) != len(pubKeys) {
+				break
+			}
+			combined, err := MergeGraphs(graphs, highlight)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+
+			reader := bufio.NewReader(os.Stdin)
+			format, err := promptForString("Format (dot or graphml)", "dot", reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			var output string
+			switch format {
+			case "dot":
+				output = combined.DOT()
+			case "graphml":
+				output = combined.GraphML()
+			default:
+				fmt.Printf("Error: Unknown format: %s\n", format)
+			}
+			if len(output) == 0 {
+				break
+			}
+			filename, err := promptForString("Filename", fmt.Sprintf("graph-%d.%s", height, format), reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := ioutil.WriteFile(filename, []byte(output), 0600); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("Combined graph of %d public key(s) saved to: %s\n",
+				len(pubKeys), aurora.Bold(filename))
+
 		case "ranking":
 			if err := connectMind(); err != nil {
 				fmt.Printf("Error: %s\n", err)
@@ -340,24 +527,55 @@ func main() {
 				fmt.Printf("Error: %s\n", err)
 				break
 			}
-			id, err := sendConsideration(mind)
+			id, unlocks, err := sendConsideration(mind)
 			if err != nil {
 				fmt.Printf("Error: %s\n", err)
 				break
 			}
+			if unlocks != 0 {
+				fmt.Printf("Consideration %s %v, it will be sent then\n", id, aurora.Bold(aurora.Yellow(
+					fmt.Sprintf("locked until height %d", unlocks))))
+				break
+			}
 			fmt.Printf("Consideration %s sent\n", id)
+			if estimate, err := mind.EstimateConfirmation(); err == nil {
+				showEstimate(estimate)
+			}
+
+		case "eta":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			estimate, err := mind.EstimateConfirmation()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("%d consideration(s) queued, views hold up to %d\n",
+				estimate.QueueLength, estimate.PerView)
+			showEstimate(estimate)
 
 		case "cnstatus":
 			if err := connectMind(); err != nil {
 				fmt.Printf("Error: %s\n", err)
 				break
 			}
-			cnID, err := promptForConsiderationID("ID", 2, bufio.NewReader(os.Stdin))
+			text, err := promptForString("ID (blank to list sent considerations)", "", bufio.NewReader(os.Stdin))
 			if err != nil {
 				fmt.Printf("Error: %s\n", err)
 				break
 			}
 			fmt.Println("")
+			if len(text) == 0 {
+				showSentConsiderations(mind)
+				break
+			}
+			cnID, err := parseConsiderationID(text)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
 			cn, _, height, err := mind.GetConsideration(cnID)
 			if err != nil {
 				fmt.Printf("Error: %s\n", err)
@@ -366,6 +584,26 @@ func main() {
 			if cn == nil {
 				fmt.Printf("Consideration %s not found in the focalpoint at this time.\n",
 					cnID)
+				sent, err := mind.GetSentConsideration(cnID)
+				if err != nil {
+					fmt.Printf("Error: %s\n", err)
+					break
+				}
+				if sent != nil {
+					if err := mind.RefreshSentStatus(sent); err != nil {
+						fmt.Printf("Error: %s\n", err)
+						break
+					}
+					if sent.Status == SentStatusExpired {
+						fmt.Println("It has expired and can no longer be confirmed.")
+						break
+					}
+					if sent.Status == SentStatusLocked {
+						fmt.Println("It will be sent once it unlocks.")
+						showConsideration(mind, sent.Consideration, 0)
+						break
+					}
+				}
 				fmt.Println("It may be waiting for confirmation.")
 				break
 			}
@@ -408,6 +646,8 @@ func main() {
 				fmt.Printf("Error: %s\n", err)
 				break
 			}
+			// include any held for the current batch
+			mind.FlushConfirmations()
 			cn, left := func() (*considerationWithHeight, int) {
 				newConfsLock.Lock()
 				defer newConfsLock.Unlock()
@@ -435,6 +675,66 @@ func main() {
 				newConfs = nil
 			}()
 
+		case "mute":
+			mutedKeys, err := mind.GetMutedKeys()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			for i, pubKey := range mutedKeys {
+				fmt.Printf("%4d: %s (muted)\n", i+1, base64.StdEncoding.EncodeToString(pubKey[:]))
+			}
+			pubKey, err := promptForPublicKey("Public key", 10, bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.MuteKey(pubKey); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Println("Considerations involving the public key will no longer be shown")
+
+		case "unmute":
+			pubKey, err := promptForPublicKey("Public key", 10, bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.UnmuteKey(pubKey); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Println("Considerations involving the public key will be shown again")
+
+		case "batchconf":
+			size, err := mind.GetConfirmationBatchSize()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			text, err := promptForString("Confirmations per notification (0 to notify as they arrive)",
+				strconv.Itoa(size), bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			size, err = strconv.Atoi(text)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.SetConfirmationBatchSize(size); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if size <= 1 {
+				fmt.Println("You will be notified of confirmations as they arrive")
+			} else {
+				fmt.Printf("You will be notified once %d confirmations have arrived or after %d views\n",
+					size, MaxConfirmationBatchViews)
+			}
+
 		case "points":
 			if err := connectMind(); err != nil {
 				fmt.Printf("Error: %s\n", err)
@@ -487,6 +787,52 @@ func main() {
 			amount := total
 			fmt.Printf("%s: %+d\n", aurora.Bold("Total"), amount)
 
+		case "rendered":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			pubKeys, err := mind.GetKeys()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			_, tipHeader, err := mind.GetTipHeader()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			var total int
+		grv:
+			for i, pubKey := range pubKeys {
+				var count int
+				var last *RenderedView
+				var startHeight int64
+				for {
+					views, err := mind.GetRenderedViews(pubKey, startHeight, tipHeader.Height, 0)
+					if err != nil {
+						fmt.Printf("Error: %s\n", err)
+						break grv
+					}
+					if len(views) == 0 {
+						break
+					}
+					count += len(views)
+					last = &views[len(views)-1]
+					startHeight = last.Height + 1
+				}
+				if last == nil {
+					fmt.Printf("%4d: %s %d\n",
+						i+1, base64.StdEncoding.EncodeToString(pubKey[:]), count)
+				} else {
+					fmt.Printf("%4d: %s %d, most recent: %s at height %d\n",
+						i+1, base64.StdEncoding.EncodeToString(pubKey[:]), count,
+						last.ViewID, last.Height)
+				}
+				total += count
+			}
+			fmt.Printf("%s: %d\n", aurora.Bold("Total"), total)
+
 		case "verify":
 			pubKeys, err := mind.GetKeys()
 			if err != nil {
@@ -510,6 +856,56 @@ func main() {
 			fmt.Printf("%d key(s) verified and %d key(s) potentially corrupt\n",
 				verified, corrupt)
 
+		case "audit":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			reader := bufio.NewReader(os.Stdin)
+			fmt.Println("The report is signed with one of your keys")
+			signer, err := promptForPublicKey("Public key", 10, reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			audit, err := mind.Audit(signer)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			for i, key := range audit.Keys {
+				status := aurora.Bold(aurora.Green("Verified")).String()
+				if key.WatchOnly {
+					status = "Watch-only"
+				} else if len(key.PrivateKeyError) != 0 {
+					status = aurora.Bold(aurora.Red(key.PrivateKeyError)).String()
+				}
+				lastActivity := "never"
+				if key.LastActivityHeight != 0 {
+					lastActivity = fmt.Sprintf("height %d", key.LastActivityHeight)
+				}
+				fmt.Printf("%4d: %s %+d, last active: %s, %s\n",
+					i+1, base64.StdEncoding.EncodeToString(key.PublicKey[:]),
+					key.Imbalance, lastActivity, status)
+			}
+			fmt.Printf("%s: %+d at height %d\n", aurora.Bold("Total"), audit.Total, audit.Height)
+			filename, err := promptForString("Filename",
+				fmt.Sprintf("audit-%d.json", audit.Height), reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			auditJson, err := json.MarshalIndent(audit, "", "  ")
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := ioutil.WriteFile(filename, auditJson, 0600); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("Signed audit of %d key(s) saved to '%s'\n", len(audit.Keys), aurora.Bold(filename))
+
 		case "export":
 			fmt.Println(aurora.BrightRed("WARNING"), aurora.Bold(": Anyone with access to a mind's "+
 				"private key(s) has full control of the funds in the mind."))
@@ -610,51 +1006,578 @@ func main() {
 			}
 			fmt.Printf("Successfully added %d key(s); %d line(s) skipped.\n", len(pubKeys), skipped)
 
-		case "quit":
-			mind.Shutdown()
-			return
-		}
-
-		fmt.Println("")
-		cmdLock.Unlock()
-	}
-}
-
-// Prompt for consideration details and request the mind to send it
-func sendConsideration(mind *Mind) (ConsiderationID, error) {
+		case "label":
+			reader := bufio.NewReader(os.Stdin)
+			pubKey, err := promptForPublicKey("Public key", 10, reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			label, err := promptForString("Label (empty to remove)", "", reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.SetLabel(pubKey, label); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Println("Label saved")
 
-	reader := bufio.NewReader(os.Stdin)
+		case "watch":
+			pubKey, err := promptForPublicKey("Public key", 10, bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.AddWatchKey(pubKey); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if mind.IsConnected() {
+				// update our filter if online
+				if err := mind.SetFilter(); err != nil {
+					fmt.Printf("Error: %s\n", err)
+				}
+			}
+			fmt.Println("Now watching public key")
 
-	// prompt for from
-	from, err := promptForPublicKey("By", 6, reader)
-	if err != nil {
-		return ConsiderationID{}, err
-	}
+		case "exportstate":
+			filename, err := promptForString("Filename", "state.bin", bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			state, _, err := mind.ExportState()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := ioutil.WriteFile(filename, state, 0600); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("Mind state saved to '%s'. It can only be imported by a mind using the same passphrase.\n",
+				aurora.Bold(filename))
 
-	// prompt for to
+		case "importstate":
+			filename, err := promptForString("Filename", "state.bin", bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			state, err := ioutil.ReadFile(filename)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.ImportState(state); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if mind.IsConnected() {
+				// update our filter if online
+				if err := mind.SetFilter(); err != nil {
+					fmt.Printf("Error: %s\n", err)
+				}
+			}
+			fmt.Println("Mind state imported")
+
+		case "pushstate":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.PushState(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Println("Mind state stored with peer")
+
+		case "pullstate":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			ok, err := mind.PullState()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if !ok {
+				fmt.Println("No mind state stored with peer")
+				break
+			}
+			if err := mind.SetFilter(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+			}
+			fmt.Println("Mind state merged from peer")
+
+		case "series":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			showSeries(mind)
+
+		case "expiry":
+			expires, err := mind.GetDefaultExpiry()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			text, err := promptForString("Expire after views (0 for never)", strconv.FormatInt(expires, 10),
+				bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			expires, err = strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.SetDefaultExpiry(expires); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if expires == 0 {
+				fmt.Println("Newly sent considerations will not expire")
+			} else {
+				fmt.Printf("Newly sent considerations will expire if not rendered within %d views\n", expires)
+			}
+
+		case "signcheckpoint":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			reader := bufio.NewReader(os.Stdin)
+			pubKey, err := promptForPublicKey("Public key", 10, reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			height, err := promptForNumber("Height", 10, reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			// the operator must supply the ID; it shouldn't come from the peer we're announcing to
+			id, err := promptForViewID("View ID", 10, reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.SignCheckpoint(pubKey, id, int64(height)); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Println("Checkpoint accepted by peer")
+
+		case "invalidateview":
+			token, err := readOperatorToken()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			reader := bufio.NewReader(os.Stdin)
+			id, err := promptForViewID("View ID", 10, reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			confirmed, err := promptForConfirmation(
+				"Invalidate this view and every view built on it", false, reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if !confirmed {
+				break
+			}
+			if err := mind.InvalidateView(id, token); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("View %s is now invalid. Use %s to undo this.\n",
+				id, aurora.Bold(aurora.Green("reconsiderview")))
+
+		case "reconsiderview":
+			token, err := readOperatorToken()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			id, err := promptForViewID("View ID", 10, bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.ReconsiderView(id, token); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("View %s is no longer marked invalid\n", id)
+
+		case "indexer":
+			token, err := readOperatorToken()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			reader := bufio.NewReader(os.Stdin)
+			action, err := promptForString("Action (pause, disable, resume or rebuild)", "resume", reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			var height int
+			if action == "rebuild" {
+				height, err = promptForNumber("Rebuild from height", 19, reader)
+				if err != nil {
+					fmt.Printf("Error: %s\n", err)
+					break
+				}
+			}
+			state, err := mind.ControlIndexer(action, int64(height), token)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("Indexer ranking is %s\n", state)
+
+		case "status":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			status, err := mind.GetStatus()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			syncing := aurora.Bold(aurora.Green("synced")).String()
+			if status.InitialViewDownload {
+				syncing = aurora.Bold(aurora.Yellow("initial view download")).String()
+			}
+			fmt.Printf("%-16s %d (%s), %s\n", "Tip:", status.Height, status.ViewID, syncing)
+			fmt.Printf("%-16s %s\n", "Tip seen:", time.Unix(status.TimeSeen, 0))
+			fmt.Printf("%-16s %d inbound, %d outbound\n", "Peers:", status.InboundPeers, status.OutboundPeers)
+			fmt.Printf("%-16s %d\n", "Queue length:", status.QueueLength)
+			if status.Hashrate != nil {
+				fmt.Printf("%-16s %.2f MH/s\n", "Hashrate:", *status.Hashrate/1000/1000)
+				for _, key := range status.RendererKeys {
+					fmt.Printf("%-16s %s %.2f MH/s, %d view(s) rendered\n", "",
+						base64.StdEncoding.EncodeToString(key.PublicKey), key.Hashrate/1000/1000, key.ViewsRendered)
+				}
+			} else {
+				fmt.Printf("%-16s not rendering\n", "Hashrate:")
+			}
+			fmt.Printf("%-16s %d (%s)", "Indexer:", status.IndexerHeight, status.IndexerViewID)
+			if len(status.IndexerState) != 0 && status.IndexerState != IndexerRunning {
+				fmt.Printf(", ranking %s", status.IndexerState)
+			}
+			fmt.Println()
+			if status.NotificationBacklog != 0 || status.DroppedNotifications != 0 {
+				fmt.Printf("%-16s %d queued, %d ms behind, %d dropped\n", "Notifications:",
+					status.NotificationBacklog, status.NotificationLag, status.DroppedNotifications)
+			}
+			if status.StaleTip {
+				fmt.Println(aurora.Bold(aurora.Red("The peer's tip is stale. It won't answer queries until it catches up.")))
+			}
+
+		case "network":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			dh, err := mind.GetDisplayHints()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("%-16s %s\n", "Network:", dh.Hints.NetworkName)
+			fmt.Printf("%-16s %s\n", "Genesis view:", dh.GenesisID)
+			if len(dh.Hints.UnitName) != 0 {
+				fmt.Printf("%-16s %s (%s)\n", "Units:", dh.Hints.UnitName, dh.Hints.Units(2))
+			}
+			for i, convention := range dh.Hints.MemoConventions {
+				label := ""
+				if i == 0 {
+					label = "Memos:"
+				}
+				fmt.Printf("%-16s %s\n", label, convention)
+			}
+
+		case "supply":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			text, err := promptForString("Height (0 for the tip)", "0", bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			height, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			supply, err := mind.GetSupply(height)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("%-20s %d (%s)\n", "Height:", supply.Height, supply.ViewID)
+			fmt.Printf("%-20s %d\n", "Supply:", aurora.Bold(supply.Supply))
+			fmt.Printf("%-20s %d\n", "Considerations:", supply.Considerations)
+			fmt.Printf("%-20s %d\n", "Viewpoints matured:", supply.ViewpointsMatured)
+			fmt.Printf("%-20s %d\n", "Active keys:", supply.ActiveKeys)
+
+		case "rescan":
+			if err := connectMind(); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			syncedHeight, err := mind.GetSyncedHeight()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("Synced through height: %d\n", syncedHeight)
+			height, err := promptForNumber("Rescan from height", 18, bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if err := mind.Rescan(int64(height)); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("Rescan complete. Type %s to view any confirmations found.\n",
+				aurora.Bold(aurora.Green("conf")))
+
+		case "signlog":
+			count, err := promptForString("Most recent entries to show, 0 for all", "20", bufio.NewReader(os.Stdin))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				fmt.Println("Error: Invalid number of entries")
+				break
+			}
+			entries, err := mind.GetSignLog(n)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if len(entries) == 0 {
+				fmt.Println("Nothing has been signed")
+				break
+			}
+			for _, entry := range entries {
+				showSignLogEntry(entry)
+			}
+
+		case "scanledger":
+			reader := bufio.NewReader(os.Stdin)
+			dataDir, err := promptForString("Node data directory", "", reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if len(dataDir) == 0 {
+				fmt.Println("Error: A data directory is required")
+				break
+			}
+			syncedHeight, err := mind.GetSyncedHeight()
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			height, err := promptForNumber("Scan from height", 16, reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			if int64(height) <= syncedHeight && syncedHeight != 0 {
+				fmt.Printf("Already synced through height %d, confirmations will be replayed again\n", syncedHeight)
+			}
+			result, err := scanLedger(mind, genesisID, dataDir, int64(height))
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			var total int64
+			for i, imbalance := range result.Imbalances {
+				fmt.Printf("%4d: %s %+d\n",
+					i+1,
+					base64.StdEncoding.EncodeToString(imbalance.PublicKey[:]),
+					imbalance.Imbalance)
+				total += imbalance.Imbalance
+			}
+			fmt.Printf("%s: %+d\n", aurora.Bold("Total"), total)
+			fmt.Printf("Found %d view(s) with your considerations through height %d. Type %s to view them.\n",
+				result.Views, result.Height, aurora.Bold(aurora.Green("conf")))
+
+		case "backup":
+			reader := bufio.NewReader(os.Stdin)
+			dirPath, err := promptForString("Backup directory", *dbPathPtr+"-backups", reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			keep, err := promptForString("Most recent backups to keep", "5", reader)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			n, err := strconv.Atoi(keep)
+			if err != nil || n <= 0 {
+				fmt.Println("Error: Invalid number of backups")
+				break
+			}
+			path, err := mind.Backup(dirPath, n)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				break
+			}
+			fmt.Printf("Mind backed up to '%s'. Restore it with the same passphrase using %s.\n",
+				aurora.Bold(path), aurora.Bold("-restore"))
+
+		case "quit":
+			mind.Shutdown()
+			return
+		}
+
+		fmt.Println("")
+		cmdLock.Unlock()
+	}
+}
+
+// Prompt for consideration details and request the mind to send it. Returns the consideration ID
+// and the height it's locked until, if it's locked
+func sendConsideration(mind *Mind) (ConsiderationID, int64, error) {
+
+	reader := bufio.NewReader(os.Stdin)
+
+	// prompt for from
+	from, err := promptForPublicKey("By", 6, reader)
+	if err != nil {
+		return ConsiderationID{}, 0, err
+	}
+
+	// prompt for to
 	to, err := promptForPublicKey("For", 6, reader)
 	if err != nil {
-		return ConsiderationID{}, err
+		return ConsiderationID{}, 0, err
 	}
 
 	// prompt for memo
 	fmt.Printf("%6v: ", aurora.Bold("Memo"))
 	text, err := reader.ReadString('\n')
 	if err != nil {
-		return ConsiderationID{}, err
+		return ConsiderationID{}, 0, err
 	}
 	memo := strings.TrimSpace(text)
-	if len(memo) > MAX_MEMO_LENGTH {
-		return ConsiderationID{}, fmt.Errorf("Maximum memo length (%d) exceeded (%d)",
-			MAX_MEMO_LENGTH, len(memo))
+	_, tipHeader, err := mind.GetTipHeader()
+	if err != nil {
+		return ConsiderationID{}, 0, err
+	}
+	if err := MemoPolicyAt(tipHeader.Height + 1).Check(memo); err != nil {
+		return ConsiderationID{}, 0, err
 	}
 
-	// create and send send it. by default the consideration expires if not rendered within 3 views from now
-	id, err := mind.Send(from, to, 0, 3, memo)
+	// prompt for maturity
+	text, err = promptForString("Lock for views (0 for none)", "0", reader)
 	if err != nil {
-		return ConsiderationID{}, err
+		return ConsiderationID{}, 0, err
 	}
-	return id, nil
+	matures, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return ConsiderationID{}, 0, err
+	}
+	if matures < 0 {
+		return ConsiderationID{}, 0, fmt.Errorf("Lock can't be negative")
+	}
+
+	// create and send send it. the consideration expires if not rendered within the mind's default expiry
+	// of it unlocking
+	expires, err := mind.GetDefaultExpiry()
+	if err != nil {
+		return ConsiderationID{}, 0, err
+	}
+	id, err := mind.Send(from, to, matures, expires, memo)
+	if err != nil {
+		return ConsiderationID{}, 0, err
+	}
+	if matures > 1 {
+		// it's held by the mind rather than pushed
+		return id, tipHeader.Height + matures, nil
+	}
+	return id, 0, nil
+}
+
+// Print when a consideration is likely to confirm
+func showEstimate(estimate *ConfirmationEstimate) {
+	fmt.Printf("Likely to confirm in about %d view(s), %v\n", estimate.Views,
+		aurora.Bold(fmt.Sprintf("~%d minutes", int64(estimate.Duration/time.Minute))))
+}
+
+// Show a signing log entry on one or two lines
+func showSignLogEntry(entry SignLogEntry) {
+	fmt.Printf("%6d: %s %-13s by %s",
+		entry.Sequence,
+		time.Unix(entry.Time, 0).Format("2006-01-02 15:04:05"),
+		entry.Operation,
+		base64.StdEncoding.EncodeToString(entry.From[:]))
+	switch entry.Operation {
+	case SignLogConsideration:
+		fmt.Printf(" for %s\n", base64.StdEncoding.EncodeToString(entry.To[:]))
+		fmt.Printf("        %s at height %d", entry.ConsiderationID, entry.Height)
+		if len(entry.MemoHash) != 0 {
+			fmt.Printf(", memo hash %s", entry.MemoHash)
+		}
+	case SignLogCheckpoint, SignLogAudit:
+		fmt.Printf("\n        view %s at height %d", entry.ViewID, entry.Height)
+	}
+	fmt.Println()
+}
+
+// Scan a replica of a node's databases so the node can keep running
+func scanLedger(mind *Mind, genesisID ViewID, dataDir string, sinceHeight int64) (*LedgerScanResult, error) {
+	replica, err := NewReplica(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer replica.Close()
+	viewStore, err := replica.OpenViewStorage()
+	if err != nil {
+		return nil, err
+	}
+	defer viewStore.Close()
+	ledger, err := replica.OpenLedger(viewStore, NewGraph())
+	if err != nil {
+		return nil, err
+	}
+	defer ledger.Close()
+	return mind.ScanLedger(ledger, viewStore, genesisID, sinceHeight)
 }
 
 func promptForPublicKey(prompt string, rightJustify int, reader *bufio.Reader) (ed25519.PublicKey, error) {
@@ -725,7 +1648,10 @@ func promptForConsiderationID(prompt string, rightJustify int, reader *bufio.Rea
 	if err != nil {
 		return ConsiderationID{}, err
 	}
-	text = strings.TrimSpace(text)
+	return parseConsiderationID(strings.TrimSpace(text))
+}
+
+func parseConsiderationID(text string) (ConsiderationID, error) {
 	if len(text) != 2*(len(ConsiderationID{})) {
 		return ConsiderationID{}, fmt.Errorf("Invalid consideration ID")
 	}
@@ -741,6 +1667,105 @@ func promptForConsiderationID(prompt string, rightJustify int, reader *bufio.Rea
 	return id, nil
 }
 
+func promptForViewID(prompt string, rightJustify int, reader *bufio.Reader) (ViewID, error) {
+	fmt.Printf("%"+strconv.Itoa(rightJustify)+"v: ", aurora.Bold(prompt))
+	text, err := reader.ReadString('\n')
+	if err != nil {
+		return ViewID{}, err
+	}
+	text = strings.TrimSpace(text)
+	if len(text) != 2*(len(ViewID{})) {
+		return ViewID{}, fmt.Errorf("Invalid view ID")
+	}
+	idBytes, err := hex.DecodeString(text)
+	if err != nil {
+		return ViewID{}, err
+	}
+	if len(idBytes) != len(ViewID{}) {
+		return ViewID{}, fmt.Errorf("Invalid view ID")
+	}
+	var id ViewID
+	copy(id[:], idBytes)
+	return id, nil
+}
+
+func showSeries(w *Mind) {
+	_, header, err := w.GetTipHeader()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+	// considerations are checked against the next view
+	height := header.Height + 1
+	low, high := ValidSeries(height)
+	fmt.Printf("%s: %d\n", aurora.Bold("Current height"), header.Height)
+	fmt.Printf("%s: %d through %d\n", aurora.Bold("Valid series"), low, high)
+	fmt.Printf("Series %d is accepted until height %d (%d views from now)\n",
+		low, LastSeriesHeight(low), LastSeriesHeight(low)-header.Height)
+
+	// warn about anything we're waiting on which can no longer be confirmed
+	pending, err := w.GetPending()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+	for _, cn := range pending {
+		id, err := cn.ID()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return
+		}
+		last := LastSeriesHeight(cn.Series)
+		if last < height {
+			fmt.Printf("%s consideration %s has series %d which is no longer accepted\n",
+				aurora.Bold(aurora.Red("Warning:")), id, cn.Series)
+		} else if last-header.Height <= VIEWS_UNTIL_NEW_SERIES/7 {
+			fmt.Printf("%s consideration %s has series %d which will no longer be accepted after height %d\n",
+				aurora.Bold(aurora.Yellow("Warning:")), id, cn.Series, last)
+		}
+	}
+}
+
+func showSentConsiderations(w *Mind) {
+	sent, err := w.GetSent()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+	if len(sent) == 0 {
+		fmt.Println("No sent considerations")
+		return
+	}
+	clock, _ := w.GetViewClock()
+	for _, s := range sent {
+		// confirmed considerations can still be undone by a reorg so refresh them all
+		if err := w.RefreshSentStatus(s); err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return
+		}
+		var status interface{}
+		switch s.Status {
+		case SentStatusConfirmed:
+			status = aurora.Bold(aurora.Green(fmt.Sprintf("confirmed at height %d", s.Height)))
+		case SentStatusExpired:
+			status = aurora.Bold(aurora.Red("expired"))
+		case SentStatusLocked:
+			status = aurora.Bold(aurora.Yellow(fmt.Sprintf("locked until height %d%s",
+				s.Unlocks, estimatedTimeAt(clock, s.Unlocks, "unlocks"))))
+		default:
+			status = aurora.Bold(s.Status)
+			// it can't be confirmed after it expires or its series is retired, whichever is first
+			last := LastSeriesHeight(s.Consideration.Series)
+			if s.Consideration.Expires != 0 && s.Consideration.Expires < last {
+				last = s.Consideration.Expires
+			}
+			status = fmt.Sprintf("%s (can be confirmed until height %d%s)",
+				status, last, estimatedTimeAt(clock, last, "expires"))
+		}
+		fmt.Printf("%s %s %s\n", time.Unix(s.Sent, 0).Format("2006-01-02 15:04:05"), s.ConsiderationID, status)
+	}
+}
+
 func showConsideration(w *Mind, cn *Consideration, height int64) {
 	when := time.Unix(cn.Time, 0)
 	id, _ := cn.ID()
@@ -756,14 +1781,20 @@ func showConsideration(w *Mind, cn *Consideration, height int64) {
 	}
 
 	_, header, _ := w.GetTipHeader()
+	clock, _ := w.GetViewClock()
 	if height <= 0 {
+		if sent, _ := w.GetSentConsideration(id); sent != nil && sent.Status == SentStatusLocked {
+			fmt.Printf("%7v: %s\n", aurora.Bold("Locked"), aurora.Bold(aurora.Yellow(
+				fmt.Sprintf("until height %d, %d view(s) from now%s",
+					sent.Unlocks, sent.Unlocks-header.Height, estimatedTimeAt(clock, sent.Unlocks, "unlocks")))))
+		}
 		if cn.Matures > 0 {
-			fmt.Printf("%7v: cannot be rendered until height: %d, current height: %d\n",
-				aurora.Bold("Matures"), cn.Matures, header.Height)
+			fmt.Printf("%7v: cannot be rendered until height: %d, current height: %d%s\n",
+				aurora.Bold("Matures"), cn.Matures, header.Height, estimatedTimeAt(clock, cn.Matures, "matures"))
 		}
 		if cn.Expires > 0 {
-			fmt.Printf("%7v: cannot be rendered after height: %d, current height: %d\n",
-				aurora.Bold("Expires"), cn.Expires, header.Height)
+			fmt.Printf("%7v: cannot be rendered after height: %d, current height: %d%s\n",
+				aurora.Bold("Expires"), cn.Expires, header.Height, estimatedTimeAt(clock, cn.Expires, "expires"))
 		}
 		return
 	}
@@ -772,12 +1803,50 @@ func showConsideration(w *Mind, cn *Consideration, height int64) {
 		aurora.Bold("Status"), height, (header.Height-height)+1)
 }
 
+// Returns the estimated wall-clock time of the view at the given height, e.g. ", expires ~3:40pm today".
+// Returns an empty string if there's no clock
+func estimatedTimeAt(clock *ViewClock, height int64, verb string) string {
+	if clock == nil {
+		return ""
+	}
+	if len(verb) != 0 {
+		verb += " "
+	}
+	return fmt.Sprintf(", %s~%s", verb, formatEstimatedTime(clock.TimeAt(height), time.Now()))
+}
+
+// Formats a time relative to now, e.g. "3:40pm today", "9:05am tomorrow" or "Jan 2 3:04pm"
+func formatEstimatedTime(when, now time.Time) string {
+	when, now = when.Local(), now.Local()
+	sameDay := func(a, b time.Time) bool {
+		return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+	}
+	clock := when.Format("3:04pm")
+	switch {
+	case sameDay(when, now):
+		return clock + " today"
+	case sameDay(when, now.AddDate(0, 0, 1)):
+		return clock + " tomorrow"
+	case sameDay(when, now.AddDate(0, 0, -1)):
+		return clock + " yesterday"
+	}
+	if when.Year() != now.Year() {
+		return when.Format("Jan 2 2006 3:04pm")
+	}
+	return when.Format("Jan 2 3:04pm")
+}
+
 // Catch filter false-positives
 func considerationIsRelevant(mind *Mind, cn *Consideration) (bool, error) {
 	pubKeys, err := mind.GetKeys()
 	if err != nil {
 		return false, err
 	}
+	watchKeys, err := mind.GetWatchKeys()
+	if err != nil {
+		return false, err
+	}
+	pubKeys = append(pubKeys, watchKeys...)
 	for _, pubKey := range pubKeys {
 		if cn.Contains(pubKey) {
 			return true, nil
@@ -813,6 +1882,19 @@ func promptForPassphrase() string {
 	return passphrase
 }
 
+// Map base64-encoded public keys to their labels
+func getLabelMap(mind *Mind) (map[string]string, error) {
+	labels, err := mind.GetLabels()
+	if err != nil {
+		return nil, err
+	}
+	labelMap := make(map[string]string)
+	for _, label := range labels {
+		labelMap[base64.StdEncoding.EncodeToString(label.PublicKey[:])] = label.Label
+	}
+	return labelMap, nil
+}
+
 type considerationWithHeight struct {
 	cn     *Consideration
 	height int64
@@ -0,0 +1,147 @@
+package focalpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/sha3"
+)
+
+// MindAudit is a report on every key held or watched by a mind. It's signed by one of the
+// mind's keys so an archived copy can later be shown to be unaltered.
+type MindAudit struct {
+	Created   int64             `json:"created"`
+	ViewID    ViewID            `json:"view_id"`
+	Height    int64             `json:"height"`
+	Keys      []MindAuditKey    `json:"keys"`
+	Total     int64             `json:"total"`
+	PublicKey ed25519.PublicKey `json:"public_key"`
+	Signature Signature         `json:"signature,omitempty"`
+}
+
+// MindAuditKey is the audit of a single key.
+type MindAuditKey struct {
+	PublicKey          ed25519.PublicKey `json:"public_key"`
+	Label              string            `json:"label,omitempty"`
+	WatchOnly          bool              `json:"watch_only,omitempty"`
+	Imbalance          int64             `json:"imbalance"`
+	Height             int64             `json:"height"`                         // height the imbalance was read at
+	LastActivityHeight int64             `json:"last_activity_height,omitempty"` // omitted if never active
+	PrivateKeyError    string            `json:"private_key_error,omitempty"`
+}
+
+// Audit queries the peer for the imbalance and last activity of every key held or watched by
+// the mind and confirms each stored private key still decrypts. The report is signed by signer,
+// which must be one of the mind's keys.
+func (w *Mind) Audit(signer ed25519.PublicKey) (*MindAudit, error) {
+	privKey, err := w.GetPrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.VerifyKey(signer); err != nil {
+		return nil, fmt.Errorf("Unable to sign the audit: %s", err)
+	}
+
+	pubKeys, err := w.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+	watchKeys, err := w.GetWatchKeys()
+	if err != nil {
+		return nil, err
+	}
+	labels, err := w.GetLabels()
+	if err != nil {
+		return nil, err
+	}
+	labelsByKey := make(map[string]string)
+	for _, label := range labels {
+		labelsByKey[pubKeyToString(label.PublicKey)] = label.Label
+	}
+
+	tipID, tipHeader, err := w.GetTipHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	audit := &MindAudit{
+		Created:   time.Now().Unix(),
+		ViewID:    tipID,
+		Height:    tipHeader.Height,
+		PublicKey: signer,
+	}
+
+	for _, pubKey := range pubKeys {
+		key := MindAuditKey{PublicKey: pubKey, Label: labelsByKey[pubKeyToString(pubKey)]}
+		if err := w.VerifyKey(pubKey); err != nil {
+			key.PrivateKeyError = err.Error()
+		}
+		audit.Keys = append(audit.Keys, key)
+	}
+	for _, pubKey := range watchKeys {
+		audit.Keys = append(audit.Keys, MindAuditKey{
+			PublicKey: pubKey,
+			Label:     labelsByKey[pubKeyToString(pubKey)],
+			WatchOnly: true,
+		})
+	}
+
+	for i := range audit.Keys {
+		key := &audit.Keys[i]
+		key.Imbalance, key.Height, err = w.GetImbalance(key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		audit.Total += key.Imbalance
+
+		// find the most recent consideration involving the key
+		_, _, _, fbs, err := w.GetPublicKeyConsiderations(
+			key.PublicKey, tipHeader.Height, 0, MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(fbs) != 0 {
+			key.LastActivityHeight = fbs[0].Header.Height
+		}
+	}
+
+	hash, err := audit.hash()
+	if err != nil {
+		return nil, err
+	}
+	audit.Signature = ed25519.Sign(privKey, hash[:])
+	err = w.appendSignLog(SignLogEntry{
+		Operation: SignLogAudit,
+		From:      signer,
+		ViewID:    &audit.ViewID,
+		Height:    audit.Height,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return audit, nil
+}
+
+// Verify returns true if the audit is properly signed by its public key.
+func (a MindAudit) Verify() (bool, error) {
+	if len(a.PublicKey) != ed25519.PublicKeySize {
+		return false, nil
+	}
+	hash, err := a.hash()
+	if err != nil {
+		return false, err
+	}
+	return ed25519.Verify(a.PublicKey, hash[:], a.Signature), nil
+}
+
+// Compute the hash that is signed. Never include the signature
+func (a MindAudit) hash() ([32]byte, error) {
+	a.Signature = nil
+	auditJson, err := json.Marshal(a)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha3.Sum256(auditJson), nil
+}
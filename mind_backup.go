@@ -0,0 +1,171 @@
+package focalpoint
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// A mind backup is this header followed by the gzipped records of the mind's database, encrypted
+// with the mind's passphrase. Each record is a varint-prefixed key followed by a varint-prefixed value.
+const mindBackupHeader = "focalpoint-mind-backup-1\n"
+
+const mindBackupPrefix = "mind-"
+
+const mindBackupExt = ".backup"
+
+// Backup writes a single encrypted archive of the mind's database to the given directory and
+// removes all but the keep most recent archives there. It returns the path of the archive.
+// The archive can only be restored with the mind's passphrase.
+func (w *Mind) Backup(dirPath string, keep int) (string, error) {
+	if len(w.passphrase) == 0 {
+		return "", fmt.Errorf("Mind passphrase not set")
+	}
+	archive, err := w.encodeBackup()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dirPath, 0700); err != nil {
+		return "", err
+	}
+
+	// write to a temporary file first so a partial archive is never mistaken for a complete one
+	tmpFile, err := ioutil.TempFile(dirPath, ".mind-backup-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+	if _, err := tmpFile.Write(archive); err != nil {
+		return "", err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", err
+	}
+
+	name := mindBackupPrefix + time.Now().UTC().Format("20060102T150405Z") + mindBackupExt
+	path := filepath.Join(dirPath, name)
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return "", err
+	}
+	if _, err := rotateBackups(dirPath, mindBackupPrefix, keep); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// Encode and encrypt a consistent snapshot of the database
+func (w *Mind) encodeBackup() ([]byte, error) {
+	snapshot, err := w.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	var records bytes.Buffer
+	zw := gzip.NewWriter(&records)
+	lengthBytes := make([]byte, binary.MaxVarintLen64)
+	writeField := func(field []byte) error {
+		n := binary.PutUvarint(lengthBytes, uint64(len(field)))
+		if _, err := zw.Write(lengthBytes[:n]); err != nil {
+			return err
+		}
+		_, err := zw.Write(field)
+		return err
+	}
+	iter := snapshot.NewIterator(nil, nil)
+	for iter.Next() {
+		if err := writeField(iter.Key()); err != nil {
+			iter.Release()
+			return nil, err
+		}
+		if err := writeField(iter.Value()); err != nil {
+			iter.Release()
+			return nil, err
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	encrypted := encryptWithPassphrase(records.Bytes(), w.passphrase)
+	return append([]byte(mindBackupHeader), encrypted...), nil
+}
+
+// RestoreMindBackup creates a new mind database at the given path from an archive written by Backup.
+// It returns the number of records restored.
+func RestoreMindBackup(archivePath, mindDbPath, passphrase string) (int, error) {
+	archive, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.HasPrefix(archive, []byte(mindBackupHeader)) {
+		return 0, fmt.Errorf("%s is not a mind backup", archivePath)
+	}
+	decrypted, ok := decryptWithPassphrase(archive[len(mindBackupHeader):], passphrase)
+	if !ok {
+		return 0, fmt.Errorf("Unable to decrypt %s, the passphrase is wrong or the backup is corrupt", archivePath)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(decrypted))
+	if err != nil {
+		return 0, err
+	}
+	records, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return 0, err
+	}
+
+	// decode everything before touching the disk
+	batch := new(leveldb.Batch)
+	r := bytes.NewReader(records)
+	readField := func() ([]byte, error) {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if length > uint64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		field := make([]byte, length)
+		_, err = io.ReadFull(r, field)
+		return field, err
+	}
+	for r.Len() != 0 {
+		key, err := readField()
+		if err != nil {
+			return 0, fmt.Errorf("Malformed mind backup: %s", err)
+		}
+		value, err := readField()
+		if err != nil {
+			return 0, fmt.Errorf("Malformed mind backup: %s", err)
+		}
+		batch.Put(key, value)
+	}
+
+	// never restore over an existing mind
+	db, err := leveldb.OpenFile(mindDbPath, &opt.Options{ErrorIfExist: true})
+	if err != nil {
+		return 0, err
+	}
+	if err := db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		db.Close()
+		return 0, err
+	}
+	return batch.Len(), db.Close()
+}
@@ -0,0 +1,86 @@
+package focalpoint
+
+import "time"
+
+// ConfirmationEstimate is a rough forecast of when a consideration pushed now will confirm.
+type ConfirmationEstimate struct {
+	Height      int64         // tip height the estimate was made at
+	QueueLength int           // considerations queued ahead of it
+	PerView     int           // considerations a view is expected to include besides its viewpoint
+	Views       int64         // views until it's likely confirmed
+	Duration    time.Duration // Views at the target spacing
+}
+
+// EstimateConfirmation estimates how many views a consideration pushed now will take to confirm
+// given the tip height and the number of considerations queued ahead of it. It assumes renderers
+// fill views from the queue in order up to their limit.
+func EstimateConfirmation(height int64, queueLength int) ConfirmationEstimate {
+	perView := computeMaxConsiderationsPerView(height + 1)
+	if MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW != 0 && perView > MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW {
+		perView = MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW
+	}
+	// one is taken by the viewpoint
+	perView--
+
+	views := int64(queueLength/perView) + 1
+	return ConfirmationEstimate{
+		Height:      height,
+		QueueLength: queueLength,
+		PerView:     perView,
+		Views:       views,
+		Duration:    time.Duration(views*TARGET_SPACING) * time.Second,
+	}
+}
+
+// EstimateConfirmation asks the peer for its tip and queue length and estimates when a
+// consideration pushed now would confirm.
+func (w *Mind) EstimateConfirmation() (*ConfirmationEstimate, error) {
+	status, err := w.GetStatus()
+	if err != nil {
+		return nil, err
+	}
+	estimate := EstimateConfirmation(status.Height, status.QueueLength)
+	return &estimate, nil
+}
+
+// ViewClock converts heights into estimated wall-clock times using the recent average
+// spacing between views.
+type ViewClock struct {
+	Height  int64         // tip height
+	Time    time.Time     // tip time
+	Spacing time.Duration // recent average time between views
+}
+
+// NewViewClock returns a clock for the tip given the height and time of an earlier view.
+// It falls back to the target spacing if there's no earlier view or the times aren't increasing.
+func NewViewClock(tipHeight, tipTime, pastHeight, pastTime int64) ViewClock {
+	spacing := time.Duration(TARGET_SPACING) * time.Second
+	if tipHeight > pastHeight && tipTime > pastTime {
+		spacing = time.Duration(tipTime-pastTime) * time.Second / time.Duration(tipHeight-pastHeight)
+	}
+	return ViewClock{Height: tipHeight, Time: time.Unix(tipTime, 0), Spacing: spacing}
+}
+
+// TimeAt returns the estimated time the view at the given height was or will be rendered.
+func (c ViewClock) TimeAt(height int64) time.Time {
+	return c.Time.Add(time.Duration(height-c.Height) * c.Spacing)
+}
+
+// GetViewClock asks the peer for its tip and the view RETARGET_SMA_WINDOW views before it and
+// returns a clock based on the average spacing between them.
+func (w *Mind) GetViewClock() (*ViewClock, error) {
+	_, tipHeader, err := w.GetTipHeader()
+	if err != nil {
+		return nil, err
+	}
+	pastHeight := tipHeader.Height - RETARGET_SMA_WINDOW
+	if pastHeight < 0 {
+		pastHeight = 0
+	}
+	_, pastHeader, err := w.GetViewHeaderByHeight(pastHeight)
+	if err != nil {
+		return nil, err
+	}
+	clock := NewViewClock(tipHeader.Height, tipHeader.Time, pastHeader.Height, pastHeader.Time)
+	return &clock, nil
+}
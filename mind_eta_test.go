@@ -0,0 +1,26 @@
+package focalpoint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestViewClock(t *testing.T) {
+	// 144 views in 72000 seconds, 500 seconds apart
+	clock := NewViewClock(1144, 1072000, 1000, 1000000)
+	if clock.Spacing != 500*time.Second {
+		t.Fatalf("Expected spacing of 500s, found: %s", clock.Spacing)
+	}
+	if when := clock.TimeAt(1150).Unix(); when != 1075000 {
+		t.Fatalf("Expected time 1075000, found: %d", when)
+	}
+	if when := clock.TimeAt(1140).Unix(); when != 1070000 {
+		t.Fatalf("Expected time 1070000, found: %d", when)
+	}
+
+	// fall back to the target spacing at genesis
+	clock = NewViewClock(0, 1000000, 0, 1000000)
+	if clock.Spacing != time.Duration(TARGET_SPACING)*time.Second {
+		t.Fatalf("Expected the target spacing, found: %s", clock.Spacing)
+	}
+}
@@ -0,0 +1,93 @@
+package focalpoint
+
+import (
+	"fmt"
+	"log"
+)
+
+// LedgerScanResult summarizes a scan of a node's ledger for the mind's keys.
+type LedgerScanResult struct {
+	Height     int64                // main point height the ledger was scanned through
+	Views      int                  // views with considerations involving the mind's keys
+	Imbalances []PublicKeyImbalance // imbalance of each key as of Height
+}
+
+// ScanLedger replays the history of the mind's keys from a full node's ledger instead of asking
+// a peer for it. It's meant to be used offline with a replica of the databases of a node on the
+// same machine, to quickly restore a mind from a backup of its keys. Considerations from
+// sinceHeight through the ledger's tip are handled as if their filter views had been received
+// from the peer and the mind is marked synced through the tip, so once it connects it only
+// catches up on views the ledger didn't have. The ledger's indices must not be pruned below
+// sinceHeight.
+func (w *Mind) ScanLedger(ledger Ledger, viewStore ViewStorage, genesisID ViewID, sinceHeight int64) (
+	*LedgerScanResult, error) {
+	id, err := ledger.GetViewIDForHeight(0)
+	if err != nil {
+		return nil, err
+	}
+	if id == nil || *id != genesisID {
+		return nil, fmt.Errorf("Ledger belongs to a different network")
+	}
+	_, tipHeight, err := ledger.GetPointTip()
+	if err != nil {
+		return nil, err
+	}
+	prunedHeight, err := ledger.GetPrunedHeight()
+	if err != nil {
+		return nil, err
+	}
+	if sinceHeight < prunedHeight {
+		return nil, fmt.Errorf("Ledger indices are pruned below height %d, "+
+			"use rescan to read that history from the peer", prunedHeight)
+	}
+
+	pubKeys, err := w.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+	watchKeys, err := w.GetWatchKeys()
+	if err != nil {
+		return nil, err
+	}
+	pubKeys = append(pubKeys, watchKeys...)
+
+	result := &LedgerScanResult{Height: tipHeight}
+	merged := newFilterViewMerger()
+	for _, pubKey := range pubKeys {
+		ids, indices, _, _, err := ledger.GetPublicKeyConsiderationIndicesRange(
+			pubKey, sinceHeight, tipHeight, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for i, id := range ids {
+			cn, header, err := viewStore.GetConsideration(id, indices[i])
+			if err != nil {
+				return nil, err
+			}
+			if cn == nil {
+				return nil, fmt.Errorf("No consideration found in view %s at index %d", id, indices[i])
+			}
+			if err := merged.add(id, header, cn); err != nil {
+				return nil, err
+			}
+		}
+
+		imbalance, err := ledger.GetPublicKeyImbalance(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		result.Imbalances = append(result.Imbalances,
+			PublicKeyImbalance{PublicKey: pubKey, Imbalance: imbalance})
+	}
+
+	fbs := merged.filterViews()
+	result.Views = len(fbs)
+	log.Printf("Replaying %d filter view(s) from the ledger from height %d\n", len(fbs), sinceHeight)
+	for _, fb := range fbs {
+		w.onFilterView(fb)
+	}
+	if err := w.updateSyncedHeight(tipHeight); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
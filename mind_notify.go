@@ -0,0 +1,240 @@
+package focalpoint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"golang.org/x/crypto/ed25519"
+)
+
+// MaxConfirmationBatchViews is how many views may pass after the first confirmation in a batch
+// before the batch is delivered, even if it hasn't reached the batch size.
+const MaxConfirmationBatchViews = 6
+
+// ConfirmationBatch summarizes confirmed considerations delivered together when the mind is set
+// to batch confirmation notifications.
+type ConfirmationBatch struct {
+	Confirmations []MindConfirmation `json:"confirmations"`
+	StartHeight   int64              `json:"start_height"`
+	EndHeight     int64              `json:"end_height"`
+}
+
+// MindConfirmation is a relevant consideration and the view which confirmed it.
+type MindConfirmation struct {
+	Consideration *Consideration `json:"consideration"`
+	ViewID        ViewID         `json:"view_id"`
+	Height        int64          `json:"height"`
+}
+
+// MuteKey stops notifications of considerations involving the public key. It may be one of the
+// mind's own keys or a counterparty's. The considerations are still tracked as usual.
+func (w *Mind) MuteKey(pubKey ed25519.PublicKey) error {
+	key, err := encodeMindPubKeyDbKey(mutedKeyPrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return w.db.Put(key, []byte{0x1}, &wo)
+}
+
+// UnmuteKey resumes notifications of considerations involving the public key.
+func (w *Mind) UnmuteKey(pubKey ed25519.PublicKey) error {
+	key, err := encodeMindPubKeyDbKey(mutedKeyPrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return w.db.Delete(key, &wo)
+}
+
+// GetMutedKeys returns all of the muted public keys from the database.
+func (w *Mind) GetMutedKeys() ([]ed25519.PublicKey, error) {
+	var pubKeys []ed25519.PublicKey
+	iter := w.db.NewIterator(util.BytesPrefix([]byte{mutedKeyPrefix}), nil)
+	for iter.Next() {
+		pubKey, err := decodeMindPubKeyDbKey(iter.Key())
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return pubKeys, nil
+}
+
+// SetConfirmationBatchSize sets how many confirmations are collected before they're delivered
+// together to the confirmation batch callback. 0 or 1 delivers each filter view as it arrives.
+func (w *Mind) SetConfirmationBatchSize(size int) error {
+	if size < 0 || int64(size) > MAX_NUMBER {
+		return fmt.Errorf("Invalid batch size %d", size)
+	}
+	sizeBytes, err := encodeNumber(int64(size))
+	if err != nil {
+		return err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	if err := w.db.Put([]byte{confirmationBatchSizePrefix}, sizeBytes, &wo); err != nil {
+		return err
+	}
+	if size <= 1 {
+		// deliver anything held under the old setting
+		w.FlushConfirmations()
+	}
+	return nil
+}
+
+// GetConfirmationBatchSize returns how many confirmations are collected before they're delivered together.
+func (w *Mind) GetConfirmationBatchSize() (int, error) {
+	sizeBytes, err := w.db.Get([]byte{confirmationBatchSizePrefix}, nil)
+	if err == leveldb.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	if err := binary.Read(bytes.NewReader(sizeBytes), binary.BigEndian, &size); err != nil {
+		return 0, err
+	}
+	return int(size), nil
+}
+
+// SetConfirmationBatchCallback sets a callback to receive batches of confirmed considerations
+// when a batch size is set. Without one, confirmations are never batched.
+func (w *Mind) SetConfirmationBatchCallback(callback func(*ConfirmationBatch)) {
+	w.confirmationBatchCallback = callback
+}
+
+// FlushConfirmations delivers any confirmations held for the current batch now.
+func (w *Mind) FlushConfirmations() {
+	w.batchLock.Lock()
+	batch := w.batch
+	w.batch = nil
+	if err := w.putHeldBatch(nil); err != nil {
+		log.Printf("Error: %s\n", err)
+	}
+	w.batchLock.Unlock()
+	if batch != nil && w.confirmationBatchCallback != nil {
+		w.confirmationBatchCallback(batch)
+	}
+}
+
+// Store the batch being held so it outlives the process. The synced height has already moved
+// past its views so they won't be seen again. Caller must hold the batch lock
+func (w *Mind) putHeldBatch(batch *ConfirmationBatch) error {
+	wo := opt.WriteOptions{Sync: true}
+	if batch == nil {
+		return w.db.Delete([]byte{heldBatchPrefix}, &wo)
+	}
+	batchJson, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return w.db.Put([]byte{heldBatchPrefix}, batchJson, &wo)
+}
+
+// Load the batch held when the mind was last open, if any
+func (w *Mind) loadHeldBatch() error {
+	batchJson, err := w.db.Get([]byte{heldBatchPrefix}, nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	batch := new(ConfirmationBatch)
+	if err := json.Unmarshal(batchJson, batch); err != nil {
+		return err
+	}
+	w.batchLock.Lock()
+	defer w.batchLock.Unlock()
+	w.batch = batch
+	return nil
+}
+
+// Returns true if the consideration involves a muted public key
+func (w *Mind) isMuted(cn *Consideration) (bool, error) {
+	for _, pubKey := range []ed25519.PublicKey{cn.By, cn.For} {
+		if len(pubKey) == 0 {
+			// viewpoint
+			continue
+		}
+		key, err := encodeMindPubKeyDbKey(mutedKeyPrefix, pubKey)
+		if err != nil {
+			return false, err
+		}
+		muted, err := w.db.Has(key, nil)
+		if err != nil {
+			return false, err
+		}
+		if muted {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Drops considerations involving muted keys from a filter view and, if batching is enabled, holds
+// the rest for the current batch. Returns the filter view to pass to the filter view callback
+func (w *Mind) filterNotifications(fb *FilterViewMessage) (*FilterViewMessage, error) {
+	var cns []*Consideration
+	for _, cn := range fb.Considerations {
+		muted, err := w.isMuted(cn)
+		if err != nil {
+			return nil, err
+		}
+		if !muted {
+			cns = append(cns, cn)
+		}
+	}
+	filtered := &FilterViewMessage{ViewID: fb.ViewID, Header: fb.Header, Considerations: cns}
+
+	batchSize, err := w.GetConfirmationBatchSize()
+	if err != nil {
+		return nil, err
+	}
+	if batchSize <= 1 || w.confirmationBatchCallback == nil {
+		return filtered, nil
+	}
+
+	w.batchLock.Lock()
+	if w.batch == nil && len(cns) != 0 {
+		w.batch = &ConfirmationBatch{StartHeight: fb.Header.Height}
+	}
+	var full *ConfirmationBatch
+	if w.batch != nil {
+		for _, cn := range cns {
+			w.batch.Confirmations = append(w.batch.Confirmations,
+				MindConfirmation{Consideration: cn, ViewID: fb.ViewID, Height: fb.Header.Height})
+		}
+		if fb.Header.Height > w.batch.EndHeight {
+			w.batch.EndHeight = fb.Header.Height
+		}
+		if len(w.batch.Confirmations) >= batchSize ||
+			fb.Header.Height-w.batch.StartHeight >= MaxConfirmationBatchViews {
+			full, w.batch = w.batch, nil
+		}
+		if err := w.putHeldBatch(w.batch); err != nil {
+			w.batchLock.Unlock()
+			return nil, err
+		}
+	}
+	w.batchLock.Unlock()
+	if full != nil {
+		w.confirmationBatchCallback(full)
+	}
+
+	// the considerations are reported with the batch instead
+	filtered.Considerations = nil
+	return filtered, nil
+}
@@ -0,0 +1,206 @@
+package focalpoint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// SentConsideration is the mind's record of a consideration it pushed to the network.
+type SentConsideration struct {
+	ConsiderationID ConsiderationID `json:"consideration_id"`
+	Consideration   *Consideration  `json:"consideration"`
+	Sent            int64           `json:"sent"`
+	Status          string          `json:"status"`
+	ViewID          *ViewID         `json:"view_id,omitempty"`
+	Height          int64           `json:"height,omitempty"`
+	Unlocks         int64           `json:"unlocks,omitempty"` // the height a locked consideration can first be rendered at
+	Updated         int64           `json:"updated"`
+}
+
+// Statuses of a sent consideration.
+const (
+	SentStatusLocked    = "locked" // held by the mind until it can be rendered
+	SentStatusPending   = "pending"
+	SentStatusConfirmed = "confirmed"
+	SentStatusExpired   = "expired"
+)
+
+// GetSent returns every consideration the mind has sent, oldest first.
+func (w *Mind) GetSent() ([]*SentConsideration, error) {
+	var sent []*SentConsideration
+	iter := w.db.NewIterator(util.BytesPrefix([]byte{sentConsiderationPrefix}), nil)
+	for iter.Next() {
+		s := new(SentConsideration)
+		if err := json.Unmarshal(iter.Value(), s); err != nil {
+			iter.Release()
+			return nil, err
+		}
+		sent = append(sent, s)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	sort.Slice(sent, func(i, j int) bool {
+		return sent[i].Sent < sent[j].Sent
+	})
+	return sent, nil
+}
+
+// GetSentConsideration returns the record of a sent consideration or nil if we didn't send it.
+func (w *Mind) GetSentConsideration(id ConsiderationID) (*SentConsideration, error) {
+	key, err := encodeSentConsiderationDbKey(id)
+	if err != nil {
+		return nil, err
+	}
+	sentJson, err := w.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := new(SentConsideration)
+	if err := json.Unmarshal(sentJson, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RefreshSentStatus queries the peer for the live status of a sent consideration and saves it.
+// Locked considerations haven't been pushed yet so they're left as they are.
+func (w *Mind) RefreshSentStatus(s *SentConsideration) error {
+	if s.Status == SentStatusLocked {
+		return nil
+	}
+	cn, viewID, height, err := w.GetConsideration(s.ConsiderationID)
+	if err != nil {
+		return err
+	}
+	if cn != nil {
+		s.Status, s.ViewID, s.Height = SentStatusConfirmed, viewID, height
+	} else {
+		_, header, err := w.GetTipHeader()
+		if err != nil {
+			return err
+		}
+		// it can no longer make it into the next view
+		s.ViewID, s.Height = nil, 0
+		if s.Consideration.IsExpired(header.Height+1) ||
+			LastSeriesHeight(s.Consideration.Series) < header.Height+1 {
+			s.Status = SentStatusExpired
+		} else {
+			s.Status = SentStatusPending
+		}
+	}
+	s.Updated = time.Now().Unix()
+	return w.putSent(s)
+}
+
+// Record a newly sent consideration. It may have been recorded already, e.g. by another device
+func (w *Mind) recordSent(id ConsiderationID, cn *Consideration) error {
+	s, err := w.GetSentConsideration(id)
+	if err != nil || s != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	return w.putSent(&SentConsideration{
+		ConsiderationID: id,
+		Consideration:   cn,
+		Sent:            now,
+		Status:          SentStatusPending,
+		Updated:         now,
+	})
+}
+
+// Record a signed consideration to be pushed once it can be rendered at the given height
+func (w *Mind) recordLocked(id ConsiderationID, cn *Consideration, unlocks int64) error {
+	now := time.Now().Unix()
+	return w.putSent(&SentConsideration{
+		ConsiderationID: id,
+		Consideration:   cn,
+		Sent:            now,
+		Status:          SentStatusLocked,
+		Unlocks:         unlocks,
+		Updated:         now,
+	})
+}
+
+// PushUnlocked pushes the locked considerations which can be rendered in the next view and
+// marks them pending. It returns the IDs of those it pushed. A consideration the peer rejects
+// stays locked and is tried again next time unless it can no longer be rendered.
+func (w *Mind) PushUnlocked() ([]ConsiderationID, error) {
+	sent, err := w.GetSent()
+	if err != nil {
+		return nil, err
+	}
+	_, header, err := w.GetTipHeader()
+	if err != nil {
+		return nil, err
+	}
+	var pushed []ConsiderationID
+	for _, s := range sent {
+		if s.Status != SentStatusLocked || s.Unlocks > header.Height+1 {
+			continue
+		}
+		if s.Consideration.IsExpired(header.Height+1) ||
+			LastSeriesHeight(s.Consideration.Series) < header.Height+1 {
+			// the mind wasn't running while it could have been sent
+			s.Status = SentStatusExpired
+		} else {
+			if err := w.pushConsideration(s.Consideration); err != nil {
+				return pushed, err
+			}
+			s.Status = SentStatusPending
+			pushed = append(pushed, s.ConsiderationID)
+		}
+		s.Updated = time.Now().Unix()
+		if err := w.putSent(s); err != nil {
+			return pushed, err
+		}
+	}
+	return pushed, nil
+}
+
+// Mark a sent consideration confirmed. It's a no-op for considerations we didn't send
+func (w *Mind) confirmSent(id ConsiderationID, viewID ViewID, height int64) error {
+	s, err := w.GetSentConsideration(id)
+	if err != nil || s == nil {
+		return err
+	}
+	s.Status, s.ViewID, s.Height = SentStatusConfirmed, &viewID, height
+	s.Updated = time.Now().Unix()
+	return w.putSent(s)
+}
+
+// Store a sent consideration record
+func (w *Mind) putSent(s *SentConsideration) error {
+	key, err := encodeSentConsiderationDbKey(s.ConsiderationID)
+	if err != nil {
+		return err
+	}
+	sentJson, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return w.db.Put(key, sentJson, &wo)
+}
+
+func encodeSentConsiderationDbKey(id ConsiderationID) ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(sentConsiderationPrefix); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(key, binary.BigEndian, id[:]); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
@@ -0,0 +1,156 @@
+package focalpoint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/sha3"
+)
+
+// Operations recorded in the signing log
+const (
+	SignLogConsideration = "consideration"
+	SignLogCheckpoint    = "checkpoint"
+	SignLogAudit         = "audit"
+)
+
+// SignLogEntry records one use of a private key held by the mind. Entries are appended to a log
+// in the mind's database, each encrypted with the mind's passphrase, so what a mind has signed
+// can be reviewed if its keys are ever suspected to be compromised. Each entry includes the hash
+// of the one before it so entries removed from within the log or reordered are detected.
+type SignLogEntry struct {
+	Sequence        int64             `json:"sequence"`
+	Time            int64             `json:"time"`
+	Operation       string            `json:"operation"`
+	From            ed25519.PublicKey `json:"from"`
+	To              ed25519.PublicKey `json:"to,omitempty"`
+	MemoHash        string            `json:"memo_hash,omitempty"` // hex SHA3-256 of the memo
+	ConsiderationID *ConsiderationID  `json:"consideration_id,omitempty"`
+	ViewID          *ViewID           `json:"view_id,omitempty"` // the view a checkpoint or audit is for
+	Height          int64             `json:"height,omitempty"`
+	Previous        string            `json:"previous,omitempty"` // hex SHA3-256 of the previous encrypted entry
+}
+
+// GetSignLog returns the most recent count entries of the signing log, or all of them if count
+// is 0, oldest first. It fails if the returned entries don't follow on from each other.
+func (w *Mind) GetSignLog(count int) ([]SignLogEntry, error) {
+	// walk back from the newest. keep one more to check the oldest returned entry's link
+	var records [][]byte
+	iter := w.db.NewIterator(util.BytesPrefix([]byte{signLogPrefix}), nil)
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		records = append(records, append([]byte{}, iter.Value()...))
+		if count != 0 && len(records) > count {
+			break
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	var entries []SignLogEntry
+	for i := len(records) - 1; i >= 0; i-- {
+		if i == len(records)-1 && count != 0 && len(records) > count {
+			// only used to check the link
+			continue
+		}
+		entry, err := decryptSignLogEntry(records[i], w.passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(records)-1 {
+			prevHash := sha3.Sum256(records[i+1])
+			if entry.Previous != hex.EncodeToString(prevHash[:]) {
+				return nil, fmt.Errorf("Signing log entry %d doesn't follow the entry before it, "+
+					"the log has been altered", entry.Sequence)
+			}
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+// Append an entry to the signing log. It must be recorded before the signature is used
+func (w *Mind) appendSignLog(entry SignLogEntry) error {
+	w.signLogLock.Lock()
+	defer w.signLogLock.Unlock()
+
+	iter := w.db.NewIterator(util.BytesPrefix([]byte{signLogPrefix}), nil)
+	if iter.Last() {
+		seq, err := decodeSignLogDbKey(iter.Key())
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		prevHash := sha3.Sum256(iter.Value())
+		entry.Sequence = seq + 1
+		entry.Previous = hex.EncodeToString(prevHash[:])
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	entry.Time = time.Now().Unix()
+
+	entryJson, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	key, err := encodeSignLogDbKey(entry.Sequence)
+	if err != nil {
+		return err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return w.db.Put(key, encryptWithPassphrase(entryJson, w.passphrase), &wo)
+}
+
+// Returns the hex SHA3-256 of a memo or an empty string if there isn't one
+func hashSignLogMemo(memo string) string {
+	if len(memo) == 0 {
+		return ""
+	}
+	hash := sha3.Sum256([]byte(memo))
+	return hex.EncodeToString(hash[:])
+}
+
+func decryptSignLogEntry(record []byte, passphrase string) (*SignLogEntry, error) {
+	entryJson, ok := decryptWithPassphrase(record, passphrase)
+	if !ok {
+		return nil, fmt.Errorf("Unable to decrypt signing log entry")
+	}
+	entry := new(SignLogEntry)
+	if err := json.Unmarshal(entryJson, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func encodeSignLogDbKey(seq int64) ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(signLogPrefix); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(key, binary.BigEndian, seq); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
+func decodeSignLogDbKey(key []byte) (int64, error) {
+	buf := bytes.NewBuffer(key)
+	if _, err := buf.ReadByte(); err != nil {
+		return 0, err
+	}
+	var seq int64
+	if err := binary.Read(buf, binary.BigEndian, &seq); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
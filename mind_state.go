@@ -0,0 +1,311 @@
+package focalpoint
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/sha3"
+)
+
+// MindState is the portable metadata of a Mind used to keep multiple devices consistent.
+// It never contains private keys. Labels on public keys we don't own serve as contacts.
+type MindState struct {
+	Updated   int64               `json:"updated"`
+	Labels    []MindLabel         `json:"labels,omitempty"`
+	WatchKeys []ed25519.PublicKey `json:"watch_keys,omitempty"`
+	Pending   []*Consideration    `json:"pending,omitempty"`
+}
+
+// MindLabel associates a human-readable label with a public key.
+// An empty label records that the label was removed.
+type MindLabel struct {
+	PublicKey ed25519.PublicKey `json:"public_key"`
+	Label     string            `json:"label"`
+}
+
+// SetLabel sets the label for a public key. An empty label removes it.
+func (w *Mind) SetLabel(pubKey ed25519.PublicKey, label string) error {
+	key, err := encodeMindPubKeyDbKey(labelPrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	removedKey, err := encodeMindPubKeyDbKey(removedLabelPrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	if len(label) == 0 {
+		// remember the removal so it syncs to our other devices
+		batch.Delete(key)
+		batch.Put(removedKey, []byte{0x1})
+	} else {
+		batch.Put(key, []byte(label))
+		batch.Delete(removedKey)
+	}
+	wo := opt.WriteOptions{Sync: true}
+	return w.db.Write(batch, &wo)
+}
+
+// GetLabels returns all of the public key labels from the database.
+func (w *Mind) GetLabels() ([]MindLabel, error) {
+	var labels []MindLabel
+	iter := w.db.NewIterator(util.BytesPrefix([]byte{labelPrefix}), nil)
+	for iter.Next() {
+		pubKey, err := decodeMindPubKeyDbKey(iter.Key())
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		labels = append(labels, MindLabel{PublicKey: pubKey, Label: string(iter.Value())})
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// Returns an empty label for each public key whose label was removed
+func (w *Mind) getRemovedLabels() ([]MindLabel, error) {
+	var labels []MindLabel
+	iter := w.db.NewIterator(util.BytesPrefix([]byte{removedLabelPrefix}), nil)
+	for iter.Next() {
+		pubKey, err := decodeMindPubKeyDbKey(iter.Key())
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		labels = append(labels, MindLabel{PublicKey: pubKey})
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// AddWatchKey adds a public key to watch without holding its private key.
+func (w *Mind) AddWatchKey(pubKey ed25519.PublicKey) error {
+	key, err := encodeMindPubKeyDbKey(watchKeyPrefix, pubKey)
+	if err != nil {
+		return err
+	}
+	wo := opt.WriteOptions{Sync: true}
+	if err := w.db.Put(key, []byte{0x1}, &wo); err != nil {
+		return err
+	}
+
+	// update the filter
+	if !w.filter.Lookup(pubKey[:]) && !w.filter.Insert(pubKey[:]) {
+		return fmt.Errorf("Error updating filter")
+	}
+	return nil
+}
+
+// GetWatchKeys returns all of the watch-only public keys from the database.
+func (w *Mind) GetWatchKeys() ([]ed25519.PublicKey, error) {
+	var pubKeys []ed25519.PublicKey
+	iter := w.db.NewIterator(util.BytesPrefix([]byte{watchKeyPrefix}), nil)
+	for iter.Next() {
+		pubKey, err := decodeMindPubKeyDbKey(iter.Key())
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return pubKeys, nil
+}
+
+// GetPending returns the considerations we've sent which haven't yet been seen confirmed.
+func (w *Mind) GetPending() ([]*Consideration, error) {
+	sent, err := w.GetSent()
+	if err != nil {
+		return nil, err
+	}
+	var cns []*Consideration
+	for _, s := range sent {
+		if s.Status == SentStatusPending {
+			cns = append(cns, s.Consideration)
+		}
+	}
+	return cns, nil
+}
+
+// GetState returns the mind's current metadata.
+func (w *Mind) GetState() (*MindState, error) {
+	labels, err := w.GetLabels()
+	if err != nil {
+		return nil, err
+	}
+	removedLabels, err := w.getRemovedLabels()
+	if err != nil {
+		return nil, err
+	}
+	labels = append(labels, removedLabels...)
+	watchKeys, err := w.GetWatchKeys()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := w.GetPending()
+	if err != nil {
+		return nil, err
+	}
+	return &MindState{
+		Updated:   time.Now().Unix(),
+		Labels:    labels,
+		WatchKeys: watchKeys,
+		Pending:   pending,
+	}, nil
+}
+
+// MergeState merges metadata from another device into the mind.
+// Labels from the other device take precedence over our own, including removals.
+func (w *Mind) MergeState(state *MindState) error {
+	for _, label := range state.Labels {
+		if len(label.PublicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("Invalid public key in label")
+		}
+		if err := w.SetLabel(label.PublicKey, label.Label); err != nil {
+			return err
+		}
+	}
+	for _, pubKey := range state.WatchKeys {
+		if len(pubKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("Invalid watch-only public key")
+		}
+		if err := w.AddWatchKey(pubKey); err != nil {
+			return err
+		}
+	}
+	for _, cn := range state.Pending {
+		id, err := cn.ID()
+		if err != nil {
+			return err
+		}
+		if err := w.recordSent(id, cn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportState returns the mind's metadata encrypted with the mind's passphrase.
+func (w *Mind) ExportState() ([]byte, int64, error) {
+	state, err := w.GetState()
+	if err != nil {
+		return nil, 0, err
+	}
+	stateJson, err := json.Marshal(state)
+	if err != nil {
+		return nil, 0, err
+	}
+	return encryptWithPassphrase(stateJson, w.passphrase), state.Updated, nil
+}
+
+// ImportState decrypts metadata exported by another device and merges it into the mind.
+// The other device must be using the same passphrase.
+func (w *Mind) ImportState(encryptedState []byte) error {
+	stateJson, ok := decryptWithPassphrase(encryptedState, w.passphrase)
+	if !ok {
+		return fmt.Errorf("Unable to decrypt mind state")
+	}
+	state := new(MindState)
+	if err := json.Unmarshal(stateJson, state); err != nil {
+		return err
+	}
+	return w.MergeState(state)
+}
+
+// PushState stores the mind's encrypted metadata with the connected peer for other devices to retrieve.
+func (w *Mind) PushState() error {
+	state, updated, err := w.ExportState()
+	if err != nil {
+		return err
+	}
+	m := Message{
+		Type: "put_mind_state",
+		Body: MindStateMessage{
+			SyncID:  w.syncID(),
+			Updated: updated,
+			State:   state,
+		},
+	}
+	w.outChan <- m
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return fmt.Errorf("%s", result.err)
+	}
+	pr := new(PutMindStateResultMessage)
+	if err := json.Unmarshal(result.message, pr); err != nil {
+		return err
+	}
+	if len(pr.Error) != 0 {
+		return fmt.Errorf("%s", pr.Error)
+	}
+	return nil
+}
+
+// PullState retrieves encrypted metadata stored with the connected peer by another device and merges it.
+// It returns false if the peer has no state stored for this mind.
+func (w *Mind) PullState() (bool, error) {
+	w.outChan <- Message{Type: "get_mind_state", Body: GetMindStateMessage{SyncID: w.syncID()}}
+	result := <-w.resultChan
+	if len(result.err) != 0 {
+		return false, fmt.Errorf("%s", result.err)
+	}
+	ms := new(MindStateMessage)
+	if err := json.Unmarshal(result.message, ms); err != nil {
+		return false, err
+	}
+	if len(ms.Error) != 0 {
+		return false, fmt.Errorf("%s", ms.Error)
+	}
+	if len(ms.State) == 0 {
+		return false, nil
+	}
+	return true, w.ImportState(ms.State)
+}
+
+// Derive an identifier for the stored state from the passphrase so all devices agree on it
+// without revealing the passphrase to the peer
+func (w *Mind) syncID() string {
+	key := stretchPassphrase(w.passphrase, []byte("focalpoint state"))
+	id := sha3.Sum256(key)
+	return base64.StdEncoding.EncodeToString(id[:])
+}
+
+func encodeMindPubKeyDbKey(prefix byte, pubKey ed25519.PublicKey) ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(prefix); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(key, binary.BigEndian, pubKey); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
+func decodeMindPubKeyDbKey(key []byte) (ed25519.PublicKey, error) {
+	buf := bytes.NewBuffer(key)
+	if _, err := buf.ReadByte(); err != nil {
+		return nil, err
+	}
+	var pubKey [ed25519.PublicKeySize]byte
+	if err := binary.Read(buf, binary.BigEndian, pubKey[:32]); err != nil {
+		return nil, err
+	}
+	return ed25519.PublicKey(pubKey[:]), nil
+}
@@ -0,0 +1,15 @@
+package focalpoint
+
+// MindStateStorage is an interface for storing encrypted mind state on behalf of minds syncing across devices.
+// Peers can't decrypt the state. They only keep the most recently updated copy per sync ID.
+type MindStateStorage interface {
+	// Put stores the state for the given sync ID. It returns false if a newer state is already stored.
+	// A new sync ID is charged to the host storing it and each host may only store so many.
+	Put(syncID, host string, updated int64, state []byte) (bool, error)
+
+	// Get returns the state and its update time for the given sync ID.
+	Get(syncID string) ([]byte, int64, error)
+
+	// Close is called to close any underlying storage.
+	Close() error
+}
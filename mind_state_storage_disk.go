@@ -0,0 +1,179 @@
+package focalpoint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// MindStateStorageDisk is an on-disk implementation of the MindStateStorage interface using LevelDB.
+type MindStateStorageDisk struct {
+	db   *leveldb.DB
+	lock sync.Mutex
+}
+
+// Maximum number of sync IDs a single host may store state for
+const maxMindStatesPerHost = 16
+
+// NewMindStateStorageDisk returns a new MindStateStorageDisk instance.
+func NewMindStateStorageDisk(dbPath string) (*MindStateStorageDisk, error) {
+	db, err := leveldb.OpenFile(dbPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &MindStateStorageDisk{db: db}, nil
+}
+
+// Put stores the state for the given sync ID. It returns false if a newer state is already stored.
+// A new sync ID is charged to the host storing it and each host may only store so many.
+func (m *MindStateStorageDisk) Put(syncID, host string, updated int64, state []byte) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// don't let an older copy clobber a newer one
+	currentState, current, err := m.Get(syncID)
+	if err != nil {
+		return false, err
+	}
+	if current > updated {
+		return false, nil
+	}
+
+	// compute db key
+	key, err := computeMindStateKey(syncID)
+	if err != nil {
+		return false, err
+	}
+
+	// write it
+	stateBytes, err := encodeMindState(updated, state)
+	if err != nil {
+		return false, err
+	}
+	batch := new(leveldb.Batch)
+	batch.Put(key, stateBytes)
+
+	if currentState == nil {
+		// a new sync ID. charge it to the host
+		count, err := m.countForHost(host)
+		if err != nil {
+			return false, err
+		}
+		if count >= maxMindStatesPerHost {
+			return false, fmt.Errorf("Host %s already stores the maximum of %d mind states",
+				host, maxMindStatesPerHost)
+		}
+		hostKey, err := computeMindStateHostKey(host, syncID)
+		if err != nil {
+			return false, err
+		}
+		batch.Put(hostKey, []byte{0x1})
+	}
+
+	wo := opt.WriteOptions{Sync: true}
+	if err := m.db.Write(batch, &wo); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Returns the number of sync IDs charged to the host
+func (m *MindStateStorageDisk) countForHost(host string) (int, error) {
+	prefix, err := computeMindStateHostKey(host, "")
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	iter := m.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		count++
+	}
+	iter.Release()
+	return count, iter.Error()
+}
+
+// Get returns the state and its update time for the given sync ID.
+func (m *MindStateStorageDisk) Get(syncID string) ([]byte, int64, error) {
+	// compute db key
+	key, err := computeMindStateKey(syncID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// fetch it
+	stateBytes, err := m.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return decodeMindState(stateBytes)
+}
+
+// Close is called to close any underlying storage.
+func (m *MindStateStorageDisk) Close() error {
+	return m.db.Close()
+}
+
+// leveldb schema
+
+// s{sync id}              -> {updated}{encrypted state}
+// o{host}{0x0}{sync id}    -> 1 (sync ID charged to the host which first stored it)
+
+const mindStatePrefix = 's'
+
+const mindStateHostPrefix = 'o'
+
+func computeMindStateKey(syncID string) ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(mindStatePrefix); err != nil {
+		return nil, err
+	}
+	if _, err := key.WriteString(syncID); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
+func computeMindStateHostKey(host, syncID string) ([]byte, error) {
+	key := new(bytes.Buffer)
+	if err := key.WriteByte(mindStateHostPrefix); err != nil {
+		return nil, err
+	}
+	if _, err := key.WriteString(host); err != nil {
+		return nil, err
+	}
+	if err := key.WriteByte(0x0); err != nil {
+		return nil, err
+	}
+	if _, err := key.WriteString(syncID); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
+func encodeMindState(updated int64, state []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, updated); err != nil {
+		return nil, err
+	}
+	if _, err := buf.Write(state); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeMindState(stateBytes []byte) ([]byte, int64, error) {
+	buf := bytes.NewBuffer(stateBytes)
+	var updated int64
+	if err := binary.Read(buf, binary.BigEndian, &updated); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), updated, nil
+}
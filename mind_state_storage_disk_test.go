@@ -0,0 +1,53 @@
+package focalpoint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMindStateStorageHostLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mind_state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewMindStateStorageDisk(dir + "/mindstates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for i := 0; i < maxMindStatesPerHost; i++ {
+		if _, err := store.Put(fmt.Sprintf("sync%d", i), "10.0.0.1", 1, []byte("state")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a new sync ID from the same host is refused
+	if _, err := store.Put("one too many", "10.0.0.1", 1, []byte("state")); err == nil {
+		t.Fatal("Expected host limit error")
+	}
+
+	// updating an existing sync ID is still allowed, from any host
+	ok, err := store.Put("sync0", "10.0.0.2", 2, []byte("newer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expected update to be stored")
+	}
+	state, updated, err := store.Get("sync0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(state) != "newer" || updated != 2 {
+		t.Fatal("State mismatch after update")
+	}
+
+	// other hosts have their own allowance
+	if _, err := store.Put("one too many", "10.0.0.2", 1, []byte("state")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,7 +2,15 @@ package focalpoint
 
 import (
 	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/ed25519"
 )
@@ -26,3 +34,788 @@ func TestPrivateKeyEncryption(t *testing.T) {
 		t.Fatal("Private key mismatch after decryption")
 	}
 }
+
+func TestMindStateExportImport(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphrase := "the quick brown fox whatever whatever"
+
+	// two devices using the same passphrase
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	desktop, err := NewMind(dir+"/desktop", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer desktop.Shutdown()
+	if _, err := desktop.SetPassphrase(passphrase); err != nil {
+		t.Fatal(err)
+	}
+	laptop, err := NewMind(dir+"/laptop", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer laptop.Shutdown()
+	if _, err := laptop.SetPassphrase(passphrase); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := desktop.SetLabel(pubKey, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := desktop.AddWatchKey(pubKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// move the state over
+	state, _, err := desktop.ExportState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.ImportState(state); err != nil {
+		t.Fatal(err)
+	}
+
+	labels, err := laptop.GetLabels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 1 || labels[0].Label != "alice" || !bytes.Equal(labels[0].PublicKey, pubKey) {
+		t.Fatal("Label mismatch after import")
+	}
+	watchKeys, err := laptop.GetWatchKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watchKeys) != 1 || !bytes.Equal(watchKeys[0], pubKey) {
+		t.Fatal("Watch-only key mismatch after import")
+	}
+
+	// a different passphrase can't read it
+	if _, ok := decryptWithPassphrase(state, "nope"); ok {
+		t.Fatal("Decryption succeeded")
+	}
+
+	// removing the label on one device removes it on the other
+	if err := desktop.SetLabel(pubKey, ""); err != nil {
+		t.Fatal(err)
+	}
+	state, _, err = desktop.ExportState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.ImportState(state); err != nil {
+		t.Fatal(err)
+	}
+	labels, err = laptop.GetLabels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 0 {
+		t.Fatal("Removed label still present after import")
+	}
+}
+
+func TestMindSentConsiderations(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+
+	cn := NewConsideration(pubKey, pubKey, 0, 10, 1, "memo")
+	id, err := cn.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mind.recordSent(id, cn); err != nil {
+		t.Fatal(err)
+	}
+
+	// it's pending until confirmed
+	pending, err := mind.GetPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("Expected 1 pending consideration, found %d", len(pending))
+	}
+	if err := mind.confirmSent(id, ViewID{0x01}, 5); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = mind.GetPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatal("Expected no pending considerations after confirmation")
+	}
+
+	// recording it again, e.g. when merging state from another device, keeps its status
+	if err := mind.recordSent(id, cn); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := mind.GetSent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].ConsiderationID != id {
+		t.Fatal("Sent consideration missing")
+	}
+	if sent[0].Status != SentStatusConfirmed || sent[0].Height != 5 {
+		t.Fatalf("Expected confirmed at height 5, found %s at height %d", sent[0].Status, sent[0].Height)
+	}
+}
+
+func TestMindAuditSignature(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := MindAudit{
+		Height:    10,
+		Keys:      []MindAuditKey{{PublicKey: pubKey, Imbalance: 5, Height: 10}},
+		Total:     5,
+		PublicKey: pubKey,
+	}
+	hash, err := audit.hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit.Signature = ed25519.Sign(privKey, hash[:])
+	if ok, err := audit.Verify(); err != nil || !ok {
+		t.Fatalf("Expected audit to verify, error: %v", err)
+	}
+
+	// tamper with it
+	audit.Keys[0].Imbalance = 50
+	if ok, err := audit.Verify(); err != nil || ok {
+		t.Fatalf("Expected altered audit to fail verification, error: %v", err)
+	}
+}
+
+func TestMindSyncedHeight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+
+	var heights []int64
+	mind.SetFilterViewCallback(func(fb *FilterViewMessage) {
+		heights = append(heights, fb.Header.Height)
+	})
+	mind.onFilterView(&FilterViewMessage{Header: &ViewHeader{Height: 10}})
+	// a replayed older view doesn't move it back
+	mind.onFilterView(&FilterViewMessage{Header: &ViewHeader{Height: 7}})
+
+	height, err := mind.GetSyncedHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 10 {
+		t.Fatalf("Expected synced height 10, found %d", height)
+	}
+	if len(heights) != 2 {
+		t.Fatalf("Expected 2 filter views passed to the callback, found %d", len(heights))
+	}
+}
+
+func TestMindLockedSend(t *testing.T) {
+	// a peer at the given height which accepts every consideration pushed to it
+	var height, pushed int64 = 1000, 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := PeerUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var m Message
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			var reply Message
+			switch m.Type {
+			case "get_tip_header":
+				header := ViewHeader{Height: atomic.LoadInt64(&height)}
+				reply = Message{Type: "tip_header", Body: TipHeaderMessage{ViewID: &ViewID{}, ViewHeader: &header}}
+			case "get_descendant":
+				reply = Message{Type: "descendant", Body: DescendantMessage{}}
+			case "push_consideration":
+				atomic.AddInt64(&pushed, 1)
+				reply = Message{Type: "push_consideration_result", Body: PushConsiderationResultMessage{}}
+			default:
+				return
+			}
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+	if _, err := mind.SetPassphrase("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	pubKeys, err := mind.NewKeys(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mind.Connect(strings.TrimPrefix(server.URL, "https://"), ViewID{}, false); err != nil {
+		t.Fatal(err)
+	}
+	mind.Run()
+
+	// it can't be held past the end of its series
+	if _, err := mind.Send(pubKeys[0], pubKeys[1], 2*VIEWS_UNTIL_NEW_SERIES, 0, ""); err == nil {
+		t.Fatal("Expected a lock past the end of the series to be rejected")
+	}
+	if _, err := mind.Send(pubKeys[0], pubKeys[1], -1, 0, ""); err == nil {
+		t.Fatal("Expected a negative lock to be rejected")
+	}
+
+	// a locked consideration is held rather than pushed
+	id, err := mind.Send(pubKeys[0], pubKeys[1], 5, 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, err := mind.GetSentConsideration(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent == nil || sent.Status != SentStatusLocked || sent.Unlocks != 1005 {
+		t.Fatalf("Expected the consideration to be locked until height 1005, found %+v", sent)
+	}
+	if sent.Consideration.Matures != 0 || sent.Consideration.Expires != 1008 {
+		t.Fatalf("Expected no maturity and expiry at height 1008, found %d and %d",
+			sent.Consideration.Matures, sent.Consideration.Expires)
+	}
+	if atomic.LoadInt64(&pushed) != 0 {
+		t.Fatal("Expected a locked consideration not to be pushed")
+	}
+	pending, err := mind.GetPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatal("Expected a locked consideration not to be pending")
+	}
+
+	// it's pushed once it can be rendered in the next view
+	for _, test := range []struct {
+		height int64
+		pushed int
+	}{
+		{1000, 0},
+		{1003, 0},
+		{1004, 1},
+		{1005, 0},
+	} {
+		atomic.StoreInt64(&height, test.height)
+		ids, err := mind.PushUnlocked()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != test.pushed {
+			t.Fatalf("Expected %d consideration(s) pushed at height %d, found %d",
+				test.pushed, test.height, len(ids))
+		}
+	}
+	if atomic.LoadInt64(&pushed) != 1 {
+		t.Fatalf("Expected 1 push, found %d", atomic.LoadInt64(&pushed))
+	}
+	if sent, err = mind.GetSentConsideration(id); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Status != SentStatusPending {
+		t.Fatalf("Expected the unlocked consideration to be pending, found %s", sent.Status)
+	}
+
+	// one the mind didn't push in time expires
+	id, err = mind.Send(pubKeys[0], pubKeys[1], 5, 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt64(&height, 1020)
+	if ids, err := mind.PushUnlocked(); err != nil || len(ids) != 0 {
+		t.Fatalf("Expected nothing to be pushed, error: %v", err)
+	}
+	if sent, err = mind.GetSentConsideration(id); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Status != SentStatusExpired {
+		t.Fatalf("Expected the consideration to have expired, found %s", sent.Status)
+	}
+}
+
+func TestMindDescendantCheckTimeout(t *testing.T) {
+	if descendantCheckTimeout != 10*time.Second {
+		t.Fatalf("Expected a 10 second timeout, found %s", descendantCheckTimeout)
+	}
+	defer func(timeout time.Duration) { descendantCheckTimeout = timeout }(descendantCheckTimeout)
+	descendantCheckTimeout = 100 * time.Millisecond
+
+	// a peer which answers its first get_descendant too late and the second one straight away
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := PeerUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for requests := 0; ; requests++ {
+			var m Message
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			if requests == 0 {
+				time.Sleep(2 * descendantCheckTimeout)
+			}
+			reply := Message{Type: "descendant", Body: DescendantMessage{IsDescendant: requests == 0}}
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+	if err := mind.Connect(strings.TrimPrefix(server.URL, "https://"), ViewID{}, false); err != nil {
+		t.Fatal(err)
+	}
+	mind.Run()
+
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, answered, err := mind.isDescendant(pubKey, pubKey); err != nil || answered {
+		t.Fatalf("Expected the check to time out, error: %v", err)
+	}
+	if atomic.LoadInt32(&mind.awaitingDescendant) != 0 {
+		t.Fatal("Expected the mind to stop waiting for the reply")
+	}
+
+	// the late reply must not be taken as the answer to the next request
+	time.Sleep(3 * descendantCheckTimeout)
+	descendant, _, answered, err := mind.isDescendant(pubKey, pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !answered || descendant {
+		t.Fatalf("Expected the second reply, answered: %v, descendant: %v", answered, descendant)
+	}
+}
+
+func TestMindScanLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(filepath.Join(dir, "mind"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+	if _, err := mind.SetPassphrase("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	pubKeys, err := mind.NewKeys(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+	viewpoint := NewConsideration(nil, pubKeys[0], 0, 0, 0, "")
+	view, err := NewView(ViewID{}, 0, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := view.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := viewStore.Store(id, view, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.ConnectView(id, view); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mind.ScanLedger(ledger, viewStore, ViewID{}, 0); err == nil {
+		t.Fatal("Expected a ledger for another network to be rejected")
+	}
+
+	var fbs []*FilterViewMessage
+	mind.SetFilterViewCallback(func(fb *FilterViewMessage) {
+		fbs = append(fbs, fb)
+	})
+	result, err := mind.ScanLedger(ledger, viewStore, id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Views != 1 || len(fbs) != 1 || fbs[0].ViewID != id || len(fbs[0].Considerations) != 1 {
+		t.Fatal("Expected the viewpoint to be replayed")
+	}
+	if len(result.Imbalances) != 1 || !bytes.Equal(result.Imbalances[0].PublicKey, pubKeys[0]) {
+		t.Fatal("Expected the key's imbalance")
+	}
+}
+
+func TestMindSignLog(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+	if _, err := mind.SetPassphrase("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		entry := SignLogEntry{
+			Operation: SignLogConsideration,
+			From:      pubKey,
+			To:        pubKey,
+			MemoHash:  hashSignLogMemo("memo"),
+			Height:    int64(i),
+		}
+		if err := mind.appendSignLog(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := mind.GetSignLog(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, found %d", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Sequence != int64(i) || entry.Height != int64(i) || !bytes.Equal(entry.From, pubKey) {
+			t.Fatalf("Entry %d doesn't match what was logged", i)
+		}
+	}
+	if entries, err := mind.GetSignLog(2); err != nil || len(entries) != 2 || entries[0].Sequence != 1 {
+		t.Fatalf("Expected the 2 most recent entries, error: %v", err)
+	}
+
+	// removing an entry breaks the chain
+	key, err := encodeSignLogDbKey(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mind.db.Delete(key, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mind.GetSignLog(0); err == nil {
+		t.Fatal("Expected a removed entry to be detected")
+	}
+}
+
+func TestMindNotificationFiltering(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey2, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noisyKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+
+	var delivered int
+	mind.SetFilterViewCallback(func(fb *FilterViewMessage) {
+		delivered += len(fb.Considerations)
+	})
+	var batches []*ConfirmationBatch
+	mind.SetConfirmationBatchCallback(func(batch *ConfirmationBatch) {
+		batches = append(batches, batch)
+	})
+	if err := mind.MuteKey(noisyKey); err != nil {
+		t.Fatal(err)
+	}
+
+	fb := &FilterViewMessage{
+		Header: &ViewHeader{Height: 10},
+		Considerations: []*Consideration{
+			NewConsideration(pubKey2, pubKey, 0, 0, 1, "memo"),
+			NewConsideration(noisyKey, pubKey, 0, 0, 1, "memo"),
+		},
+	}
+	mind.onFilterView(fb)
+	if delivered != 1 {
+		t.Fatalf("Expected 1 consideration delivered, found %d", delivered)
+	}
+
+	// batch every 3 confirmations
+	if err := mind.SetConfirmationBatchSize(3); err != nil {
+		t.Fatal(err)
+	}
+	delivered = 0
+	for height := int64(11); height < 14; height++ {
+		fb.Header = &ViewHeader{Height: height}
+		mind.onFilterView(fb)
+		if height < 13 && len(batches) != 0 {
+			t.Fatalf("Batch delivered early at height %d", height)
+		}
+	}
+	if delivered != 0 {
+		t.Fatalf("Expected batched considerations to be held back, found %d delivered", delivered)
+	}
+	if len(batches) != 1 || len(batches[0].Confirmations) != 3 {
+		t.Fatal("Expected one batch of 3 confirmations")
+	}
+	if batches[0].StartHeight != 11 || batches[0].EndHeight != 13 {
+		t.Fatalf("Expected batch from height 11 to 13, found %d to %d",
+			batches[0].StartHeight, batches[0].EndHeight)
+	}
+
+	// a partial batch can be flushed
+	fb.Header = &ViewHeader{Height: 14}
+	mind.onFilterView(fb)
+	mind.FlushConfirmations()
+	if len(batches) != 2 || len(batches[1].Confirmations) != 1 {
+		t.Fatal("Expected flushed batch of 1 confirmation")
+	}
+
+	// unmuting takes effect
+	if err := mind.UnmuteKey(noisyKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := mind.SetConfirmationBatchSize(0); err != nil {
+		t.Fatal(err)
+	}
+	fb.Header = &ViewHeader{Height: 15}
+	mind.onFilterView(fb)
+	if delivered != 2 {
+		t.Fatalf("Expected 2 considerations delivered, found %d", delivered)
+	}
+}
+
+func TestMindHeldBatchPersists(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey2, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mind.SetFilterViewCallback(func(fb *FilterViewMessage) {})
+	mind.SetConfirmationBatchCallback(func(batch *ConfirmationBatch) {
+		t.Fatal("Batch delivered early")
+	})
+	if err := mind.SetConfirmationBatchSize(3); err != nil {
+		t.Fatal(err)
+	}
+
+	// hold a partial batch and close the mind
+	fb := &FilterViewMessage{
+		ViewID:         ViewID{0x01},
+		Header:         &ViewHeader{Height: 10},
+		Considerations: []*Consideration{NewConsideration(pubKey2, pubKey, 0, 0, 1, "memo")},
+	}
+	mind.onFilterView(fb)
+	if err := mind.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the held confirmation is delivered after reopening
+	mind, err = NewMind(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+	var batches []*ConfirmationBatch
+	mind.SetConfirmationBatchCallback(func(batch *ConfirmationBatch) {
+		batches = append(batches, batch)
+	})
+	mind.FlushConfirmations()
+	if len(batches) != 1 || len(batches[0].Confirmations) != 1 {
+		t.Fatal("Expected the held confirmation after reopening")
+	}
+	if batches[0].Confirmations[0].ViewID != fb.ViewID || batches[0].StartHeight != 10 {
+		t.Fatal("Held confirmation mismatch after reopening")
+	}
+
+	// once delivered it's gone
+	batches = nil
+	mind.FlushConfirmations()
+	if len(batches) != 0 {
+		t.Fatal("Expected no held confirmations after flushing")
+	}
+}
+
+func TestEstimateConfirmation(t *testing.T) {
+	perView := MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW - 1
+	for _, test := range []struct {
+		queueLength int
+		views       int64
+	}{
+		{0, 1},
+		{perView - 1, 1},
+		{perView, 2},
+		{3*perView + 1, 4},
+		{3 * perView, 4},
+	} {
+		estimate := EstimateConfirmation(0, test.queueLength)
+		if estimate.Views != test.views {
+			t.Fatalf("Expected %d views for a queue of %d, found %d",
+				test.views, test.queueLength, estimate.Views)
+		}
+		if estimate.Duration != time.Duration(test.views*TARGET_SPACING)*time.Second {
+			t.Fatalf("Unexpected duration %s", estimate.Duration)
+		}
+	}
+}
+
+func TestMindBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mind, err := NewMind(filepath.Join(dir, "mind"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mind.Shutdown()
+	if _, err := mind.SetPassphrase("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	pubKeys, err := mind.NewKeys(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an older backup is rotated out
+	backupDir := filepath.Join(dir, "backups")
+	if err := os.Mkdir(backupDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	oldPath := filepath.Join(backupDir, "mind-20000101T000000Z.backup")
+	if err := ioutil.WriteFile(oldPath, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path, err := mind.Backup(backupDir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Fatal("Expected the older backup to be removed")
+	}
+
+	restorePath := filepath.Join(dir, "restored")
+	if _, err := RestoreMindBackup(path, restorePath, "wrong"); err == nil {
+		t.Fatal("Expected the wrong passphrase to be rejected")
+	}
+	if _, err := RestoreMindBackup(path, restorePath, "passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreMindBackup(path, restorePath, "passphrase"); err == nil {
+		t.Fatal("Expected restoring over an existing mind to be rejected")
+	}
+
+	restored, err := NewMind(restorePath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Shutdown()
+	if ok, err := restored.SetPassphrase("passphrase"); err != nil || !ok {
+		t.Fatal("Expected the restored mind to accept the passphrase")
+	}
+	restoredKeys, err := restored.GetKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restoredKeys) != len(pubKeys) {
+		t.Fatalf("Expected %d keys restored, found %d", len(pubKeys), len(restoredKeys))
+	}
+	for _, pubKey := range pubKeys {
+		if _, err := restored.GetPrivateKey(pubKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"time"
+
+	. "github.com/inconsiderable/focal-point"
+	"github.com/inconsiderable/focal-point/internal/config"
+	"golang.org/x/crypto/ed25519"
+)
+
+// netgen configuration file format
+type networkConfig struct {
+	Name           string        `json:"name"`
+	Target         string        `json:"target"`
+	Spacing        int64         `json:"spacing"`
+	Maturity       int64         `json:"maturity"`
+	Port           int           `json:"port"`
+	MemoPolicies   []MemoPolicy  `json:"memo_policies"`
+	DisplayHints   *DisplayHints `json:"display_hints"`
+	BootstrapPeers []string      `json:"bootstrap_peers"`
+	Allocations    []allocation  `json:"allocations"`
+}
+
+// the genesis view's viewpoint
+type allocation struct {
+	PublicKey string `json:"public_key"`
+	Memo      string `json:"memo"`
+}
+
+// Render a genesis view and emit a network parameter bundle for use with -network
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	configPtr := flag.String("config", "", "Path to a JSON file describing the network")
+	outPtr := flag.String("out", "", "Path to a directory to write the network parameter bundle")
+	cfg := config.Register(flag.CommandLine, config.Logging)
+	flag.Parse()
+
+	if err := cfg.Apply(); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(*configPtr) == 0 {
+		log.Fatal("-config argument required")
+	}
+	if len(*outPtr) == 0 {
+		log.Fatal("-out argument required")
+	}
+
+	configJson, err := ioutil.ReadFile(*configPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var conf networkConfig
+	if err := json.Unmarshal(configJson, &conf); err != nil {
+		log.Fatal(err)
+	}
+
+	// fill in defaults from the main network
+	if len(conf.Target) == 0 {
+		conf.Target = INITIAL_TARGET
+	}
+	if conf.Spacing == 0 {
+		conf.Spacing = TARGET_SPACING
+	}
+	if conf.Maturity == 0 {
+		conf.Maturity = VIEWPOINT_MATURITY
+	}
+	if conf.Port == 0 {
+		conf.Port = DEFAULT_FOCALPOINT_PORT
+	}
+
+	// a view has exactly one viewpoint and nothing else can be spent in the genesis view
+	if len(conf.Allocations) != 1 {
+		log.Fatal("Exactly one allocation is required for the genesis view's viewpoint")
+	}
+	pubKeyBytes, err := base64.StdEncoding.DecodeString(conf.Allocations[0].PublicKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(pubKeyBytes) != ed25519.PublicKeySize {
+		log.Fatal("Invalid allocation public key")
+	}
+	if len(conf.Allocations[0].Memo) == 0 {
+		log.Fatal("Memo required for genesis view")
+	}
+
+	// create the viewpoint
+	cn := NewConsideration(nil, ed25519.PublicKey(pubKeyBytes), 0, 0, 0, conf.Allocations[0].Memo)
+
+	// create the view
+	targetBytes, err := hex.DecodeString(conf.Target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var target ViewID
+	copy(target[:], targetBytes)
+	view, err := NewView(ViewID{}, 0, target, ViewID{}, []*Consideration{cn})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// render it
+	log.Printf("Rendering genesis view for network %s...\n", conf.Name)
+	targetInt := view.Header.Target.GetBigInt()
+	ticker := time.NewTicker(30 * time.Second)
+done:
+	for {
+		select {
+		case <-ticker.C:
+			view.Header.Time = time.Now().Unix()
+		default:
+			// keep hashing until proof-of-work is satisfied
+			idInt, _ := view.Header.IDFast(0)
+			if idInt.Cmp(targetInt) <= 0 {
+				break done
+			}
+			view.Header.Nonce += 1
+			if view.Header.Nonce > MAX_NUMBER {
+				view.Header.Nonce = 0
+			}
+		}
+	}
+
+	viewJson, err := json.MarshalIndent(view, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	genesisID, err := view.ID()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	params := &NetworkParams{
+		Name:              conf.Name,
+		InitialTarget:     conf.Target,
+		TargetSpacing:     conf.Spacing,
+		ViewpointMaturity: conf.Maturity,
+		Port:              conf.Port,
+		GenesisID:         genesisID,
+		GenesisViewJson:   string(viewJson),
+		MemoPolicies:      conf.MemoPolicies,
+		DisplayHints:      conf.DisplayHints,
+		BootstrapPeers:    conf.BootstrapPeers,
+	}
+	if err := params.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := WriteNetworkParams(*outPtr, params); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Genesis view ID: %s\n", genesisID)
+	fmt.Printf("Network parameter bundle written to '%s'\n", *outPtr)
+}
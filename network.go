@@ -0,0 +1,173 @@
+package focalpoint
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// NetworkParams describes a focalpoint network other than the default one.
+// A parameter bundle is a directory containing a params file and the network's genesis view.
+type NetworkParams struct {
+	Name              string `json:"name"`
+	InitialTarget     string `json:"initial_target"`
+	TargetSpacing     int64  `json:"target_spacing"`
+	ViewpointMaturity int64  `json:"viewpoint_maturity"`
+	Port              int    `json:"port"`
+	GenesisID         ViewID `json:"genesis_id"`
+	GenesisViewJson   string `json:"-"` // stored separately in the bundle
+
+	// optional. the default network's policy applies if empty
+	MemoPolicies []MemoPolicy `json:"memo_policies,omitempty"`
+
+	// optional. the network name is advertised with the main network's units if nil
+	DisplayHints *DisplayHints `json:"display_hints,omitempty"`
+
+	// optional. host:port addresses seeded into a node's peer storage when it has none
+	BootstrapPeers []string `json:"bootstrap_peers,omitempty"`
+}
+
+const networkParamsFile = "params.json"
+
+const networkGenesisFile = "genesis.json"
+
+// LoadNetworkParams reads a network parameter bundle from the given directory.
+func LoadNetworkParams(dirPath string) (*NetworkParams, error) {
+	paramsJson, err := ioutil.ReadFile(filepath.Join(dirPath, networkParamsFile))
+	if err != nil {
+		return nil, err
+	}
+	params := new(NetworkParams)
+	if err := json.Unmarshal(paramsJson, params); err != nil {
+		return nil, err
+	}
+	genesisJson, err := ioutil.ReadFile(filepath.Join(dirPath, networkGenesisFile))
+	if err != nil {
+		return nil, err
+	}
+	params.GenesisViewJson = string(genesisJson)
+	return params, nil
+}
+
+// WriteNetworkParams writes a network parameter bundle to the given directory.
+func WriteNetworkParams(dirPath string, params *NetworkParams) error {
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return err
+	}
+	paramsJson, err := json.MarshalIndent(params, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirPath, networkParamsFile), paramsJson, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dirPath, networkGenesisFile), []byte(params.GenesisViewJson), 0644)
+}
+
+// Validate checks the parameters for consistency with the genesis view.
+func (n NetworkParams) Validate() error {
+	if len(n.Name) == 0 {
+		return fmt.Errorf("Network name missing")
+	}
+	targetBytes, err := hex.DecodeString(n.InitialTarget)
+	if err != nil {
+		return err
+	}
+	if len(targetBytes) != len(ViewID{}) {
+		return fmt.Errorf("Invalid initial target")
+	}
+	if n.TargetSpacing <= 0 {
+		return fmt.Errorf("Target spacing must be positive")
+	}
+	if n.ViewpointMaturity <= 0 {
+		return fmt.Errorf("Viewpoint maturity must be positive")
+	}
+	if n.Port <= 0 || n.Port > 65535 {
+		return fmt.Errorf("Invalid port %d", n.Port)
+	}
+	memoPolicies := MEMO_POLICIES
+	if len(n.MemoPolicies) != 0 {
+		if err := validateMemoPolicies(n.MemoPolicies); err != nil {
+			return err
+		}
+		memoPolicies = n.MemoPolicies
+	}
+	if n.DisplayHints != nil {
+		if err := n.DisplayHints.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, addr := range n.BootstrapPeers {
+		if err := validateBootstrapPeer(addr); err != nil {
+			return err
+		}
+	}
+
+	// check the genesis view
+	genesisView := new(View)
+	if err := json.Unmarshal([]byte(n.GenesisViewJson), genesisView); err != nil {
+		return err
+	}
+	genesisID, err := genesisView.ID()
+	if err != nil {
+		return err
+	}
+	if genesisID != n.GenesisID {
+		return fmt.Errorf("Genesis view ID %s doesn't match %s", genesisID, n.GenesisID)
+	}
+	if hex.EncodeToString(genesisView.Header.Target[:]) != n.InitialTarget {
+		return fmt.Errorf("Genesis view target doesn't match the initial target")
+	}
+	if !genesisView.CheckPOW(genesisID) {
+		return fmt.Errorf("Genesis view has insufficient proof-of-work")
+	}
+	for _, cn := range genesisView.Considerations {
+		if err := memoPolicies[0].Check(cn.Memo); err != nil {
+			return fmt.Errorf("Genesis view: %s", err)
+		}
+	}
+	return nil
+}
+
+// Bootstrap peers need an explicit port. Their hosts are resolved when they're dialed
+func validateBootstrapPeer(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || len(host) == 0 {
+		return fmt.Errorf("Malformed bootstrap peer address: %s", addr)
+	}
+	if portInt, err := strconv.ParseUint(port, 10, 16); err != nil || portInt == 0 {
+		return fmt.Errorf("Invalid port in bootstrap peer address: %s", addr)
+	}
+	return nil
+}
+
+// SetNetwork makes the given network active. It must be called before anything else in this package is used.
+func SetNetwork(n *NetworkParams) error {
+	if err := n.Validate(); err != nil {
+		return err
+	}
+	INITIAL_TARGET = n.InitialTarget
+	TARGET_SPACING = n.TargetSpacing
+	RETARGET_TIME = RETARGET_INTERVAL * n.TargetSpacing
+	VIEWPOINT_MATURITY = n.ViewpointMaturity
+	DEFAULT_FOCALPOINT_PORT = n.Port
+	if len(n.MemoPolicies) != 0 {
+		MEMO_POLICIES = n.MemoPolicies
+	}
+	hints := DISPLAY_HINTS
+	hints.NetworkName = n.Name
+	if n.DisplayHints != nil {
+		hints = *n.DisplayHints
+		if len(hints.NetworkName) == 0 {
+			hints.NetworkName = n.Name
+		}
+	}
+	DISPLAY_HINTS = hints
+	GenesisViewJson = n.GenesisViewJson
+	return nil
+}
@@ -0,0 +1,117 @@
+package focalpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Returns a valid parameter bundle. The target is easy enough that any view satisfies it
+func makeTestNetworkParams(t *testing.T) *NetworkParams {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initialTarget := strings.Repeat("ff", len(ViewID{}))
+	var target ViewID
+	for i := range target {
+		target[i] = 0xff
+	}
+	viewpoint := NewConsideration(nil, pubKey, 0, 0, 0, "test network")
+	view, err := NewView(ViewID{}, 0, target, ViewID{}, []*Consideration{viewpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewJson, err := json.Marshal(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisID, err := view.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &NetworkParams{
+		Name:              "testnet",
+		InitialTarget:     initialTarget,
+		TargetSpacing:     60,
+		ViewpointMaturity: 10,
+		Port:              18831,
+		GenesisID:         genesisID,
+		GenesisViewJson:   string(viewJson),
+		BootstrapPeers:    []string{"127.0.0.1:18831"},
+	}
+}
+
+func TestNetworkParamsValidate(t *testing.T) {
+	params := makeTestNetworkParams(t)
+	if err := params.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// it survives a round trip through a bundle
+	dir, err := ioutil.TempDir("", "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := WriteNetworkParams(dir, params); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNetworkParams(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mutate := range map[string]func(n *NetworkParams){
+		"no name":           func(n *NetworkParams) { n.Name = "" },
+		"bad target":        func(n *NetworkParams) { n.InitialTarget = "zz" },
+		"short target":      func(n *NetworkParams) { n.InitialTarget = "ffff" },
+		"other target":      func(n *NetworkParams) { n.InitialTarget = strings.Repeat("0f", len(ViewID{})) },
+		"zero spacing":      func(n *NetworkParams) { n.TargetSpacing = 0 },
+		"negative maturity": func(n *NetworkParams) { n.ViewpointMaturity = -1 },
+		"bad port":          func(n *NetworkParams) { n.Port = 70000 },
+		"bad peer":          func(n *NetworkParams) { n.BootstrapPeers = []string{"127.0.0.1"} },
+		"peer port zero":    func(n *NetworkParams) { n.BootstrapPeers = []string{"127.0.0.1:0"} },
+		"wrong genesis ID":  func(n *NetworkParams) { n.GenesisID = ViewID{0x1} },
+		"bad genesis view":  func(n *NetworkParams) { n.GenesisViewJson = "{" },
+		"bad memo policy":   func(n *NetworkParams) { n.MemoPolicies = []MemoPolicy{{MaxLength: -1}} },
+	} {
+		bad := *makeTestNetworkParams(t)
+		mutate(&bad)
+		if err := bad.Validate(); err == nil {
+			t.Fatalf("Expected parameters with %s to be rejected", name)
+		}
+		if err := SetNetwork(&bad); err == nil {
+			t.Fatalf("Expected SetNetwork to reject parameters with %s", name)
+		}
+	}
+}
+
+func TestSetNetwork(t *testing.T) {
+	defer func(target string, spacing, retarget, maturity int64, port int,
+		policies []MemoPolicy, hints DisplayHints, genesis string) {
+		INITIAL_TARGET, TARGET_SPACING, RETARGET_TIME, VIEWPOINT_MATURITY = target, spacing, retarget, maturity
+		DEFAULT_FOCALPOINT_PORT, MEMO_POLICIES, DISPLAY_HINTS, GenesisViewJson = port, policies, hints, genesis
+	}(INITIAL_TARGET, TARGET_SPACING, RETARGET_TIME, VIEWPOINT_MATURITY,
+		DEFAULT_FOCALPOINT_PORT, MEMO_POLICIES, DISPLAY_HINTS, GenesisViewJson)
+
+	params := makeTestNetworkParams(t)
+	if err := SetNetwork(params); err != nil {
+		t.Fatal(err)
+	}
+	if INITIAL_TARGET != params.InitialTarget || TARGET_SPACING != 60 ||
+		RETARGET_TIME != RETARGET_INTERVAL*60 || VIEWPOINT_MATURITY != 10 ||
+		DEFAULT_FOCALPOINT_PORT != 18831 || GenesisViewJson != params.GenesisViewJson {
+		t.Fatal("Expected the consensus parameters to be overridden")
+	}
+	if DISPLAY_HINTS.NetworkName != "testnet" {
+		t.Fatalf("Expected network name testnet, found %s", DISPLAY_HINTS.NetworkName)
+	}
+}
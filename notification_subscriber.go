@@ -0,0 +1,166 @@
+package focalpoint
+
+import (
+	"sync"
+	"time"
+)
+
+// new consideration notifications queued for a subscriber before the oldest are dropped
+const maxPendingNewTxs = 10000
+
+// tip changes queued for a subscriber before it's cut off. tip changes are never dropped, their
+// subscribers depend on seeing every one in order. one which falls this far behind is unregistered
+// and its channel closed instead
+var maxPendingTipChanges = 10000
+
+// NotificationStats describes how far behind the subscribers to one kind of processor notification are.
+type NotificationStats struct {
+	Subscribers int           // registered channels
+	Pending     int           // notifications queued but not yet delivered, across all subscribers
+	MaxLag      time.Duration // how long the oldest undelivered notification has been waiting
+	Dropped     int64         // notifications dropped since startup because a subscriber fell too far behind. Tip changes are never dropped
+}
+
+// notificationSubscriber delivers notifications to a registered channel from its own goroutine so
+// a slow consumer can't stall view and consideration processing. If it falls maxPending
+// notifications behind the oldest are dropped, or if it mustn't miss any it's reported full.
+type notificationSubscriber struct {
+	send       func(n interface{}, stopChan <-chan struct{}) bool // blocks until sent or stopped
+	close      func()                                             // closes the channel
+	maxPending int
+	keepAll    bool // never drop notifications
+	lock       sync.Mutex
+	pending    []pendingNotification
+	sending    time.Time // when the notification being sent was queued, zero if none is
+	dropped    int64
+	readyChan  chan struct{} // signaled when a notification is queued
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+type pendingNotification struct {
+	notification interface{}
+	queued       time.Time
+}
+
+func newTipChangeSubscriber(ch chan<- TipChange, maxPending int) *notificationSubscriber {
+	s := newNotificationSubscriber(maxPending, func(n interface{}, stopChan <-chan struct{}) bool {
+		select {
+		case ch <- n.(TipChange):
+			return true
+		case <-stopChan:
+			return false
+		}
+	}, func() { close(ch) })
+	s.keepAll = true
+	return s
+}
+
+func newNewTxSubscriber(ch chan<- NewTx, maxPending int) *notificationSubscriber {
+	return newNotificationSubscriber(maxPending, func(n interface{}, stopChan <-chan struct{}) bool {
+		select {
+		case ch <- n.(NewTx):
+			return true
+		case <-stopChan:
+			return false
+		}
+	}, func() { close(ch) })
+}
+
+func newNotificationSubscriber(maxPending int,
+	send func(n interface{}, stopChan <-chan struct{}) bool, close func()) *notificationSubscriber {
+	s := &notificationSubscriber{
+		send:       send,
+		close:      close,
+		maxPending: maxPending,
+		readyChan:  make(chan struct{}, 1),
+		stopChan:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+func (s *notificationSubscriber) run() {
+	defer s.wg.Done()
+	for {
+		s.lock.Lock()
+		if len(s.pending) == 0 {
+			s.lock.Unlock()
+			select {
+			case <-s.readyChan:
+				continue
+			case <-s.stopChan:
+				return
+			}
+		}
+		next := s.pending[0]
+		s.pending[0] = pendingNotification{}
+		s.pending = s.pending[1:]
+		s.sending = next.queued
+		s.lock.Unlock()
+
+		sent := s.send(next.notification, s.stopChan)
+
+		s.lock.Lock()
+		s.sending = time.Time{}
+		s.lock.Unlock()
+		if !sent {
+			return
+		}
+	}
+}
+
+// Queue a notification without blocking. If the oldest queued one was dropped to make room
+// this returns how many the subscriber has lost in total, otherwise 0. A subscriber which
+// mustn't miss any queues nothing once it's full and reports it instead
+func (s *notificationSubscriber) notify(n interface{}) (dropped int64, full bool) {
+	s.lock.Lock()
+	if s.maxPending != 0 && len(s.pending) >= s.maxPending {
+		if s.keepAll {
+			s.lock.Unlock()
+			return 0, true
+		}
+		s.pending[0] = pendingNotification{}
+		s.pending = s.pending[1:]
+		s.dropped++
+		dropped = s.dropped
+	}
+	s.pending = append(s.pending, pendingNotification{notification: n, queued: time.Now()})
+	s.lock.Unlock()
+
+	select {
+	case s.readyChan <- struct{}{}:
+	default:
+	}
+	return dropped, false
+}
+
+// Returns the number of undelivered notifications and how long the oldest has been waiting
+func (s *notificationSubscriber) lag() (int, time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pending := len(s.pending)
+	oldest := s.sending
+	if !oldest.IsZero() {
+		pending++
+	} else if len(s.pending) != 0 {
+		oldest = s.pending[0].queued
+	}
+	if oldest.IsZero() {
+		return pending, 0
+	}
+	return pending, time.Since(oldest)
+}
+
+// Stop delivering notifications and wait for the goroutine to exit. Undelivered ones are discarded
+func (s *notificationSubscriber) stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// Stop delivering notifications and close the channel so the consumer knows it was cut off
+func (s *notificationSubscriber) cutOff() {
+	s.stop()
+	s.close()
+}
@@ -0,0 +1,153 @@
+package focalpoint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotificationSubscriberDropsOldest(t *testing.T) {
+	ch := make(chan NewTx)
+	s := newNewTxSubscriber(ch, 2)
+	defer s.stop()
+
+	txAt := func(time int64) NewTx {
+		return NewTx{Consideration: &Consideration{Time: time}}
+	}
+
+	// wait for the first to be in flight
+	s.notify(txAt(1))
+	for inFlight := false; !inFlight; {
+		time.Sleep(time.Millisecond)
+		s.lock.Lock()
+		inFlight = !s.sending.IsZero()
+		s.lock.Unlock()
+	}
+
+	// nobody is reading so queuing must not block
+	for n := int64(2); n <= 4; n++ {
+		dropped, _ := s.notify(txAt(n))
+		if n == 4 && dropped != 1 {
+			t.Fatalf("Expected 1 dropped notification queuing %d, found %d", n, dropped)
+		}
+	}
+	pending, lag := s.lag()
+	if pending != 3 {
+		// one in flight plus two queued
+		t.Fatalf("Expected 3 pending notifications, found %d", pending)
+	}
+	if lag <= 0 {
+		t.Fatalf("Expected a positive lag, found %s", lag)
+	}
+
+	// the first was already in flight, the second was dropped
+	for _, expect := range []int64{1, 3, 4} {
+		select {
+		case newTx := <-ch:
+			if newTx.Consideration.Time != expect {
+				t.Fatalf("Expected %d, found %d", expect, newTx.Consideration.Time)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %d", expect)
+		}
+	}
+}
+
+func TestNotificationSubscriberKeepsTipChanges(t *testing.T) {
+	ch := make(chan TipChange)
+	const count = 1000
+	s := newTipChangeSubscriber(ch, count)
+	defer s.stop()
+
+	tipAt := func(height int64) TipChange {
+		return TipChange{View: &View{Header: &ViewHeader{Height: height}}}
+	}
+
+	// wait for the first to be in flight
+	s.notify(tipAt(1))
+	for inFlight := false; !inFlight; {
+		time.Sleep(time.Millisecond)
+		s.lock.Lock()
+		inFlight = !s.sending.IsZero()
+		s.lock.Unlock()
+	}
+
+	// nobody is reading so queuing must not block, and nothing should be dropped
+	for height := int64(2); height <= count+1; height++ {
+		if dropped, full := s.notify(tipAt(height)); dropped != 0 || full {
+			t.Fatalf("Expected no dropped tip changes queuing height %d, found %d", height, dropped)
+		}
+	}
+
+	// once it's full nothing more is queued
+	if _, full := s.notify(tipAt(count + 2)); !full {
+		t.Fatal("Expected the subscriber to be full")
+	}
+
+	// every one queued should be delivered in order
+	for expect := int64(1); expect <= count+1; expect++ {
+		select {
+		case tip := <-ch:
+			if tip.View.Header.Height != expect {
+				t.Fatalf("Expected height %d, found %d", expect, tip.View.Header.Height)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for height %d", expect)
+		}
+	}
+}
+
+func TestProcessorCutsOffSlowTipChangeSubscriber(t *testing.T) {
+	defer func(max int) { maxPendingTipChanges = max }(maxPendingTipChanges)
+	maxPendingTipChanges = 10
+
+	p := NewProcessor(ViewID{}, nil, nil, nil)
+	p.Run()
+	defer p.Shutdown()
+	slow, fast := make(chan TipChange), make(chan TipChange, 100)
+	p.RegisterForTipChange(slow)
+	p.RegisterForTipChange(fast)
+	for tipChange, _ := p.NotificationStats(); tipChange.Subscribers != 2; tipChange, _ = p.NotificationStats() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the slow subscriber is cut off and its channel closed. wait for the fast one to
+	// catch up after each so only the slow one falls behind
+	for height := int64(1); height <= 20; height++ {
+		p.notifyTipChange(TipChange{View: &View{Header: &ViewHeader{Height: height}}})
+		for p.pendingNotifications(fast, nil) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if tipChange, _ := p.NotificationStats(); tipChange.Subscribers != 1 {
+		t.Fatalf("Expected 1 subscriber, found %d", tipChange.Subscribers)
+	}
+	var received int
+	for {
+		_, ok := <-slow
+		if !ok {
+			break
+		}
+		received++
+	}
+	if received > maxPendingTipChanges+1 {
+		t.Fatalf("Expected at most %d tip changes before the cut off, found %d",
+			maxPendingTipChanges+1, received)
+	}
+
+	// the other one received every tip change in order
+	for expect := int64(1); expect <= 20; expect++ {
+		select {
+		case tip := <-fast:
+			if tip.View.Header.Height != expect {
+				t.Fatalf("Expected height %d, found %d", expect, tip.View.Header.Height)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for height %d", expect)
+		}
+	}
+
+	// its undelivered notifications count towards a peer's backlog
+	if pending := p.pendingNotifications(fast, nil); pending != 0 {
+		t.Fatalf("Expected nothing pending, found %d", pending)
+	}
+}
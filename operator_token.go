@@ -0,0 +1,55 @@
+package focalpoint
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OPERATOR_TOKEN_FILE is the name of the file in the data directory holding the token a mind
+// must present with operator requests such as invalidate_view and indexer_control.
+const OPERATOR_TOKEN_FILE = "operator.token"
+
+// WriteOperatorToken writes a new random operator token to the data directory, readable only by
+// the node's user, and returns it. A new one is written every time the client starts.
+func WriteOperatorToken(dataDir string) (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(tokenBytes)
+	tokenPath := filepath.Join(dataDir, OPERATOR_TOKEN_FILE)
+	if err := os.Remove(tokenPath); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := ioutil.WriteFile(tokenPath, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ReadOperatorToken reads an operator token written by WriteOperatorToken.
+func ReadOperatorToken(tokenPath string) (string, error) {
+	tokenBytes, err := ioutil.ReadFile(tokenPath)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(tokenBytes))
+	if len(token) == 0 {
+		return "", fmt.Errorf("Operator token file %s is empty", tokenPath)
+	}
+	return token, nil
+}
+
+// Returns true if the presented token matches the node's. A node without a token accepts none
+func operatorTokenMatches(expected, presented string) bool {
+	if len(expected) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(presented)) == 1
+}
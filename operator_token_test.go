@@ -0,0 +1,61 @@
+package focalpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOperatorToken(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	token, err := WriteOperatorToken(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readToken, err := ReadOperatorToken(filepath.Join(dataDir, OPERATOR_TOKEN_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readToken != token {
+		t.Fatalf("Expected token %s, found %s", token, readToken)
+	}
+
+	// a new one is written each time
+	token2, err := WriteOperatorToken(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token2 == token {
+		t.Fatal("Expected a new token")
+	}
+
+	p := &Peer{operatorToken: token2}
+	if err := p.checkOperator("127.0.0.1", token2, "invalidate_view"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.checkOperator("127.0.0.1", token, "invalidate_view"); err == nil {
+		t.Fatal("Expected a stale token to be refused")
+	}
+	if err := p.checkOperator("127.0.0.1", "", "invalidate_view"); err == nil {
+		t.Fatal("Expected a missing token to be refused")
+	}
+	if err := p.checkOperator("192.0.2.1", token2, "invalidate_view"); err == nil {
+		t.Fatal("Expected a remote host to be refused")
+	}
+	p.browserOrigin = true
+	if err := p.checkOperator("127.0.0.1", token2, "invalidate_view"); err == nil {
+		t.Fatal("Expected a browser to be refused")
+	}
+
+	// a node without a token accepts none
+	p = &Peer{}
+	if err := p.checkOperator("127.0.0.1", "", "invalidate_view"); err == nil {
+		t.Fatal("Expected a node without a token to refuse")
+	}
+}
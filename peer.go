@@ -1,11 +1,13 @@
 package focalpoint
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"sync"
@@ -13,7 +15,6 @@ import (
 	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
-	cuckoo "github.com/seiflotfy/cuckoofilter"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -23,28 +24,41 @@ type Peer struct {
 	conn                          *websocket.Conn
 	genesisID                     ViewID
 	peerStore                     PeerStorage
+	mindStateStore                MindStateStorage
 	viewStore                     ViewStorage
 	ledger                        Ledger
 	processor                     *Processor
 	indexer                       *Indexer
 	cnQueue                       ConsiderationQueue
 	outbound                      bool
+	nonce                         string     // the peer's session nonce, if it sent one
 	localDownloadQueue            *ViewQueue // peer-local download queue
 	localInflightQueue            *ViewQueue // peer-local inflight queue
 	globalInflightQueue           *ViewQueue // global inflight queue
 	ignoreViewes                  map[ViewID]bool
+	knownViews                    map[ViewID]bool // views the peer announced or we announced to them
+	knownViewsLock                sync.Mutex
 	continuationViewID            ViewID
 	lastPeerAddressesReceivedTime time.Time
 	filterLock                    sync.RWMutex
-	filter                        *cuckoo.Filter
+	filter                        ConsiderationFilter
 	addrChan                      chan<- string
 	workID                        int32
 	workView                      *View
+	outstandingWork               map[int32]*View // recently issued work views by work ID
+	outstandingWorkIDs            []int32         // oldest first
 	medianTimestamp               int64
 	pubKeys                       []ed25519.PublicKey
 	memo                          string
 	readLimitLock                 sync.RWMutex
 	readLimit                     int64
+	queryLimiter                  *QueryLimiter // set for public inbound connections in public mode
+	scanLimiter                   *QueryLimiter // the connection's history scan budget without a query limiter
+	peerManager                   *PeerManager  // set by the manager which created the peer
+	operatorToken                 string        // set for inbound connections, required with operator requests
+	browserOrigin                 bool          // the connection was opened by a web page
+	mind                          bool          // the connection was opened by a mind
+	stats                         peerStats
 	closeHandler                  func()
 	wg                            sync.WaitGroup
 }
@@ -57,12 +71,13 @@ var PeerUpgrader = websocket.Upgrader{
 
 // NewPeer returns a new instance of a peer.
 func NewPeer(conn *websocket.Conn, genesisID ViewID, peerStore PeerStorage,
-	viewStore ViewStorage, ledger Ledger, processor *Processor, indexer *Indexer,
+	mindStateStore MindStateStorage, viewStore ViewStorage, ledger Ledger, processor *Processor, indexer *Indexer,
 	cnQueue ConsiderationQueue, viewQueue *ViewQueue, addrChan chan<- string) *Peer {
 	peer := &Peer{
 		conn:                conn,
 		genesisID:           genesisID,
 		peerStore:           peerStore,
+		mindStateStore:      mindStateStore,
 		viewStore:           viewStore,
 		ledger:              ledger,
 		processor:           processor,
@@ -72,7 +87,10 @@ func NewPeer(conn *websocket.Conn, genesisID ViewID, peerStore PeerStorage,
 		localInflightQueue:  NewViewQueue(),
 		globalInflightQueue: viewQueue,
 		ignoreViewes:        make(map[ViewID]bool),
+		knownViews:          make(map[ViewID]bool),
+		outstandingWork:     make(map[int32]*View),
 		addrChan:            addrChan,
+		scanLimiter:         NewQueryLimiter(historicScanPeriod, historicScanBurst, 1, 1),
 	}
 	peer.updateReadLimit()
 	return peer
@@ -86,13 +104,25 @@ var peerDialer *websocket.Dialer = &websocket.Dialer{
 	TLSClientConfig:  tlsClientConfig,    // set in tls.go
 }
 
-// Connect connects outbound to a peer.
-func (p *Peer) Connect(ctx context.Context, addr, nonce, myAddr string) (int, error) {
+// DialNode connects to a node as a client rather than as a peer, e.g. to render for it. Certificates
+// are only verified if tlsVerify is set since most peers use ephemeral certificates and keys.
+func DialNode(addr string, genesisID ViewID, tlsVerify bool) (*websocket.Conn, error) {
+	u := url.URL{Scheme: "wss", Host: addr, Path: "/" + genesisID.String()}
+	dialer := *peerDialer
+	dialer.TLSClientConfig = tlsClientConfig.Clone()
+	dialer.TLSClientConfig.InsecureSkipVerify = !tlsVerify
+	conn, _, err := dialer.Dial(u.String(), nil)
+	return conn, err
+}
+
+// Connect connects outbound to a peer. It returns the peer's session nonce, if it sent one.
+// The caller is responsible for setting the nonce on the peer where other goroutines can't see it change.
+func (p *Peer) Connect(ctx context.Context, addr, nonce, myAddr string) (int, string, error) {
 	u := url.URL{Scheme: "wss", Host: addr, Path: "/" + p.genesisID.String()}
 	log.Printf("Connecting to %s", u.String())
 
 	if err := p.peerStore.OnConnectAttempt(addr); err != nil {
-		return 0, err
+		return 0, "", err
 	}
 
 	header := http.Header{}
@@ -117,15 +147,47 @@ func (p *Peer) Connect(ctx context.Context, addr, nonce, myAddr string) (int, er
 			// mark it successful so we try it again in the future.
 			p.peerStore.OnConnectSuccess(addr)
 			p.peerStore.OnDisconnect(addr)
+		} else if statusCode == http.StatusLoopDetected {
+			// it's us. don't try this address again
+			p.peerStore.Delete(addr)
 		} else {
 			p.peerStore.OnConnectFailure(addr)
 		}
-		return statusCode, err
+		return statusCode, "", err
+	}
+
+	// the peer echoes back its own session nonce
+	theirNonce := resp.Header.Get("Viewpoint-Peer-Nonce")
+	if theirNonce == nonce {
+		conn.Close()
+		p.peerStore.Delete(addr)
+		return statusCode, "", fmt.Errorf("Connected to ourself at %s", addr)
 	}
 
 	p.conn = conn
 	p.outbound = true
-	return statusCode, p.peerStore.OnConnectSuccess(addr)
+	return statusCode, theirNonce, p.peerStore.OnConnectSuccess(addr)
+}
+
+// Stats returns a snapshot of the peer's protocol statistics.
+func (p *Peer) Stats() PeerStats {
+	stats := p.stats.get()
+	if p.conn != nil {
+		stats.Address = p.conn.RemoteAddr().String()
+	}
+	stats.Outbound = p.outbound
+	return stats
+}
+
+// Write a message to the peer and record how long it took. Only called from the writer context
+func (p *Peer) writeJSON(m interface{}) error {
+	start := time.Now()
+	p.conn.SetWriteDeadline(start.Add(writeWait))
+	if err := p.conn.WriteJSON(m); err != nil {
+		return err
+	}
+	p.stats.onSend(time.Since(start))
+	return nil
 }
 
 // OnClose specifies a handler to call when the peer connection is closed.
@@ -138,6 +200,9 @@ func (p *Peer) Shutdown() {
 	var addr string
 	if p.conn != nil {
 		addr = p.conn.RemoteAddr().String()
+		// let the peer know we're going away. this is safe to call concurrently with the writer
+		closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down")
+		p.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(closeWait))
 		p.conn.Close()
 	}
 	p.wg.Wait()
@@ -153,6 +218,9 @@ const (
 	// Time allowed to write a message to the peer
 	writeWait = 30 * time.Second
 
+	// Time allowed to send the peer a close message on shutdown
+	closeWait = 5 * time.Second
+
 	// Time allowed to read the next pong message from the peer
 	pongWait = 120 * time.Second
 
@@ -176,6 +244,80 @@ const (
 
 	// Maximum local download queue size
 	downloadQueueMax = maxViewesPerInv * 10
+
+	// Maximum views returned per rendered_views message
+	maxRenderedViewsPerMessage = 1000
+
+	// Maximum size of a mind's encrypted state we'll store
+	maxMindStateLength = 1 << 16
+
+	// How far into the future a mind state's update time may be before we clamp it
+	maxMindStateFutureSeconds = 10 * 60
+
+	// How often the view announcement budget is replenished
+	invAnnouncePeriod = 5 * time.Second
+
+	// Maximum inv_view announcements we'll send a peer per invAnnouncePeriod
+	maxInvAnnouncementsPerPeriod = 4
+
+	// Maximum view IDs we'll remember the peer knows about
+	maxKnownViews = maxViewesPerInv * 2
+
+	// A public host earns a mind query every publicQueryRate
+	publicQueryRate = 500 * time.Millisecond
+
+	// Maximum mind queries a public host can make in a burst
+	publicQueryBurst = 20
+
+	// Maximum mind queries served at once to all public hosts
+	publicMaxConcurrentQueries = 32
+
+	// Maximum mind queries served at once to a single public host
+	publicMaxConcurrentQueriesPerHost = 2
+
+	// Maximum rejected queries in a row before we hang up on a public host
+	publicMaxRejectedQueries = 100
+
+	// How often idle public query accounts are forgotten
+	queryAccountSweepPeriod = 10 * time.Minute
+
+	// Notifications waiting to be written at which we consider the peer too slow for relays
+	slowPeerBacklog = MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW / 10
+
+	// Average write latency at which we consider the peer too slow for relays
+	slowPeerWriteLatency = 2 * time.Second
+
+	// Time a slow peer has to catch up before we disconnect it
+	slowPeerDisconnectWait = 2 * time.Minute
+
+	// How often we check if our tip is stale
+	staleTipCheckPeriod = 1 * time.Minute
+
+	// Maximum stored views read to answer a history query over pruned heights
+	historicScanMaxViews = 1000
+
+	// Additional public queries charged for reading stored views to answer a history query
+	historicScanQueryCost = 4
+
+	// History queries reading stored views other connections may make at once and how often
+	// they earn another
+	historicScanBurst  = 4
+	historicScanPeriod = 15 * time.Second
+
+	// Maximum work views issued to a rendering peer we'll accept solutions for
+	maxOutstandingWork = 16
+
+	// How often stale and excess addresses are pruned from peer storage
+	peerStorePrunePeriod = 1 * time.Hour
+
+	// Misbehavior score added when a peer sends a message larger than it may be
+	oversizedMessagePenalty = 50
+
+	// Misbehavior score at which a host is banned
+	misbehaviorBanThreshold = 100
+
+	// How long a host is banned for misbehaving
+	misbehaviorBanDuration = 24 * time.Hour
 )
 
 // Run executes the peer's main loop in its own goroutine.
@@ -252,27 +394,108 @@ func (p *Peer) run() {
 		tickerUpdateWorkCheck := time.NewTicker(30 * time.Second)
 		defer tickerUpdateWorkCheck.Stop()
 
+		// new tip views are announced in batches with a limited number of announcements per period
+		tickerInvAnnounce := time.NewTicker(invAnnouncePeriod)
+		defer tickerInvAnnounce.Stop()
+		var pendingInv []ViewID
+		var invAnnouncements int
+		announce := func() {
+			if len(pendingInv) == 0 || invAnnouncements >= maxInvAnnouncementsPerPeriod {
+				// wait for the next period
+				return
+			}
+			invAnnouncements++
+			if err := p.announceViews(pendingInv); err != nil {
+				log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
+				p.conn.Close()
+			}
+			pendingInv = nil
+		}
+
 		// update the peer store on disconnection
 		if p.outbound {
 			defer p.peerStore.OnDisconnect(peerAddr)
 		}
 
+		defer func() {
+			stats := p.stats.get()
+			log.Printf("Peer stats, sent: %d, received: %d, max backlog: %d, "+
+				"avg write: %s, max write: %s, dropped relays: %d, for: %s\n",
+				stats.MessagesSent, stats.MessagesReceived, stats.MaxBacklog,
+				stats.AvgWriteLatency, stats.MaxWriteLatency, stats.DroppedRelays, peerAddr)
+		}()
+
+		// messages waiting to be written. when writes fall behind the most urgent go first
+		var queue peerWriteQueue
+		writeReady := make(chan struct{})
+		close(writeReady)
+
+		// stop relaying considerations to a peer which can't keep up and disconnect it
+		// if it doesn't catch up. otherwise it would back up into the processor. notifications
+		// the processor has queued for us count too
+		checkBacklog := func() bool {
+			backlog := len(tipChangeChan) + len(newTxChan) + queue.count(writePriorityConsideration) +
+				p.processor.pendingNotifications(tipChangeChan, newTxChan)
+			degraded, changed, since := p.stats.update(backlog, time.Now())
+			if changed && degraded {
+				log.Printf("Peer can't keep up, backlog: %d, relaying views only, to: %s\n",
+					backlog, p.conn.RemoteAddr())
+			} else if changed {
+				log.Printf("Peer caught up, resuming consideration relay, to: %s\n", p.conn.RemoteAddr())
+			}
+			if since > slowPeerDisconnectWait {
+				log.Printf("Peer didn't catch up in %s, disconnecting: %s\n",
+					slowPeerDisconnectWait, p.conn.RemoteAddr())
+				p.conn.Close()
+				return false
+			}
+			return true
+		}
+
 		for {
+			// make the reader wait while too many of its responses are waiting
+			responseChan := outChan
+			if queue.count(writePriorityResponse) >= maxQueuedResponses {
+				responseChan = nil
+			}
+			// write the most urgent waiting message. anything else ready is queued in between
+			var writeChan chan struct{}
+			if queue.len() != 0 {
+				writeChan = writeReady
+			}
+
 			select {
-			case m, ok := <-outChan:
+			case m, ok := <-responseChan:
 				if !ok {
 					// reader loop is exiting
 					return
 				}
+				queue.push(m, writePriorityOf(m.Type), 0)
 
+			case <-writeChan:
 				// send outgoing message to peer
-				p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := p.conn.WriteJSON(m); err != nil {
+				m, _ := queue.pop()
+				if err := p.writeJSON(m); err != nil {
 					log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
 					p.conn.Close()
+					break
+				}
+				// a degraded peer may have just caught up
+				checkBacklog()
+
+			case tip, ok := <-tipChangeChan:
+				if !ok {
+					// the processor cut us off, we'd miss views we must relay
+					log.Printf("Peer fell too far behind on tip changes, disconnecting: %s\n",
+						p.conn.RemoteAddr())
+					p.conn.Close()
+					tipChangeChan = nil
+					break
+				}
+				if !checkBacklog() {
+					break
 				}
 
-			case tip := <-tipChangeChan:
 				// update read limit if necessary
 				p.updateReadLimit()
 
@@ -284,22 +507,29 @@ func (p *Peer) run() {
 
 				if tip.Source == p.conn.RemoteAddr().String() {
 					// this is who sent us the view that caused the change
+					p.addKnownView(tip.ViewID)
 					break
 				}
 
 				if tip.Connect {
-					// new tip announced, notify the peer
-					inv := Message{
-						Type: "inv_view",
-						Body: InvViewMessage{
-							ViewIDs: []ViewID{tip.ViewID},
-						},
+					// new tip. queue it to be announced to the peer
+					if !p.isKnownView(tip.ViewID) {
+						pendingInv = append(pendingInv, tip.ViewID)
+						if len(pendingInv) > maxViewesPerInv {
+							// the peer can find the older ones by asking for a common ancestor
+							pendingInv = pendingInv[len(pendingInv)-maxViewesPerInv:]
+						}
 					}
-					// send it
-					p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-					if err := p.conn.WriteJSON(inv); err != nil {
-						log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
-						p.conn.Close()
+					if !tip.More {
+						announce()
+					}
+				} else {
+					// don't announce views which are no longer on the main point
+					for i, id := range pendingInv {
+						if id == tip.ViewID {
+							pendingInv = append(pendingInv[:i], pendingInv[i+1:]...)
+							break
+						}
 					}
 				}
 
@@ -324,13 +554,16 @@ func (p *Peer) run() {
 
 				log.Printf("Sending %s with %d consideration(s), to: %s\n",
 					m.Type, len(fb.Considerations), p.conn.RemoteAddr())
-				p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := p.conn.WriteJSON(m); err != nil {
+				if err := p.writeJSON(m); err != nil {
 					log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
 					p.conn.Close()
 				}
 
 			case newTx := <-newTxChan:
+				if !checkBacklog() {
+					break
+				}
+
 				if newTx.Source == p.conn.RemoteAddr().String() {
 					// this is who sent it to us
 					break
@@ -344,19 +577,28 @@ func (p *Peer) run() {
 				if !interested {
 					continue
 				}
+				if p.stats.isDegraded() {
+					// they'll get it in a view
+					p.stats.onDroppedRelay()
+					continue
+				}
 
-				// newly verified consideration announced, relay to peer
+				// newly verified consideration announced, relay to peer.
+				// if too many are waiting the lowest ranked senders' are dropped
+				ranking := p.senderRanking(newTx.Consideration)
+				if queue.count(writePriorityConsideration) >= maxQueuedRelays {
+					p.stats.onDroppedRelay()
+					if !queue.dropRelayBelow(ranking) {
+						continue
+					}
+				}
 				pushTx := Message{
 					Type: "push_consideration",
 					Body: PushConsiderationMessage{
 						Consideration: newTx.Consideration,
 					},
 				}
-				p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := p.conn.WriteJSON(pushTx); err != nil {
-					log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
-					p.conn.Close()
-				}
+				queue.push(pushTx, writePriorityConsideration, ranking)
 
 			case <-onConnectChan:
 				// send a new peer a request to find a common ancestor
@@ -365,11 +607,20 @@ func (p *Peer) run() {
 					p.conn.Close()
 				}
 
+				// share our latest signed checkpoint, if any
+				if cp := GetLatestSignedCheckpoint(); cp != nil {
+					log.Printf("Sending checkpoint at height %d to: %s\n", cp.Height, p.conn.RemoteAddr())
+					m := Message{Type: "checkpoint", Body: CheckpointMessage{Checkpoint: cp}}
+					if err := p.writeJSON(m); err != nil {
+						log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
+						p.conn.Close()
+					}
+				}
+
 				// send a get_peer_addresses to request peers
 				log.Printf("Sending get_peer_addresses to: %s\n", p.conn.RemoteAddr())
 				m := Message{Type: "get_peer_addresses"}
-				p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := p.conn.WriteJSON(m); err != nil {
+				if err := p.writeJSON(m); err != nil {
 					log.Printf("Error sending get_peer_addresses: %s, to: %s\n", err, p.conn.RemoteAddr())
 					p.conn.Close()
 				}
@@ -382,11 +633,20 @@ func (p *Peer) run() {
 
 			case <-tickerPing.C:
 				//log.Printf("Sending ping message to: %s\n", p.conn.RemoteAddr())
-				p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				start := time.Now()
+				p.conn.SetWriteDeadline(start.Add(writeWait))
 				if err := p.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 					log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
 					p.conn.Close()
+					break
 				}
+				// re-check the backlog even when nothing is being relayed
+				p.stats.onPing(time.Since(start))
+				checkBacklog()
+
+			case <-tickerInvAnnounce.C:
+				invAnnouncements = 0
+				announce()
 
 			case <-tickerPeerStoreRefresh.C:
 				if p.outbound == false {
@@ -401,8 +661,7 @@ func (p *Peer) run() {
 				// periodically send a get_peer_addresses
 				log.Printf("Sending get_peer_addresses to: %s\n", p.conn.RemoteAddr())
 				m := Message{Type: "get_peer_addresses"}
-				p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := p.conn.WriteJSON(m); err != nil {
+				if err := p.writeJSON(m); err != nil {
 					log.Printf("Error sending get_peer_addresses: %s, to: %s\n", err, p.conn.RemoteAddr())
 					p.conn.Close()
 				}
@@ -463,6 +722,16 @@ func (p *Peer) run() {
 	// set initial read deadline
 	p.conn.SetReadDeadline(time.Now().Add(pongWait))
 
+	// public service query accounting
+	queryHost, _, _ := net.SplitHostPort(peerAddr)
+	var queryCharged bool
+	var queriesRejected int
+	defer func() {
+		if queryCharged {
+			p.queryLimiter.Release(queryHost)
+		}
+	}()
+
 	// reader loop
 	for {
 		// update read limit
@@ -472,8 +741,12 @@ func (p *Peer) run() {
 		messageType, message, err := p.conn.ReadMessage()
 		if err != nil {
 			log.Printf("Read error: %s, from: %s\n", err, p.conn.RemoteAddr())
+			if err == websocket.ErrReadLimit {
+				p.penalize(queryHost, oversizedMessagePenalty, "message over the read limit")
+			}
 			break
 		}
+		p.stats.onReceive()
 
 		switch messageType {
 		case websocket.TextMessage:
@@ -483,6 +756,17 @@ func (p *Peer) run() {
 				return
 			}
 
+			// hangup if the peer is sending oversized messages. only views may be larger than
+			// MAX_PROTOCOL_MESSAGE_LENGTH, up to the read limit, so check before decoding
+			if len(message) > MAX_PROTOCOL_MESSAGE_LENGTH {
+				if t, err := peekMessageType(message); err != nil || t != "view" {
+					log.Printf("Received too large (%d bytes) of a '%s' message, from: %s",
+						len(message), t, p.conn.RemoteAddr())
+					p.penalize(queryHost, oversizedMessagePenalty, "oversized "+t+" message")
+					return
+				}
+			}
+
 			var body json.RawMessage
 			m := Message{Body: &body}
 			if err := json.Unmarshal([]byte(message), &m); err != nil {
@@ -490,11 +774,37 @@ func (p *Peer) run() {
 				return
 			}
 
-			// hangup if the peer is sending oversized messages
-			if m.Type != "view" && len(message) > MAX_PROTOCOL_MESSAGE_LENGTH {
-				log.Printf("Received too large (%d bytes) of a '%s' message, from: %s",
-					len(message), m.Type, p.conn.RemoteAddr())
-				return
+			// don't serve minds stale data. relaying peers still need to exchange considerations
+			if p.peerManager != nil && p.isQueryConnection() && isPointQuery(m.Type) &&
+				p.peerManager.IsTipStale() {
+				log.Printf("Rejected '%s' message, tip is stale, from: %s\n", m.Type, p.conn.RemoteAddr())
+				outChan <- Message{
+					Type: "query_rejected",
+					Body: QueryRejectedMessage{
+						Type:  m.Type,
+						Error: "Tip is stale, this node is catching up with the focal point",
+						Code:  StaleTipCode,
+					},
+				}
+				break
+			}
+
+			// enforce public service quotas
+			if p.queryLimiter != nil && isPublicQuery(m.Type) {
+				if err := p.queryLimiter.Acquire(queryHost); err != nil {
+					log.Printf("Rejected '%s' message: %s, from: %s\n", m.Type, err, p.conn.RemoteAddr())
+					queriesRejected++
+					if queriesRejected > publicMaxRejectedQueries {
+						log.Printf("Too many rejected queries, disconnecting: %s\n", p.conn.RemoteAddr())
+						return
+					}
+					outChan <- Message{
+						Type: "query_rejected",
+						Body: QueryRejectedMessage{Type: m.Type, Error: err.Error()},
+					}
+					break
+				}
+				queryCharged, queriesRejected = true, 0
 			}
 
 			switch m.Type {
@@ -505,7 +815,7 @@ func (p *Peer) run() {
 					return
 				}
 				for i, id := range inv.ViewIDs {
-					if err := p.onInvView(id, i, len(inv.ViewIDs), outChan); err != nil {
+					if err := p.onInvView(id, i, len(inv.ViewIDs), inv.Tips, outChan); err != nil {
 						log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
 						break
 					}
@@ -547,6 +857,12 @@ func (p *Peer) run() {
 					log.Printf("Error: received nil view header, from: %s\n", p.conn.RemoteAddr())
 					return
 				}
+				if limit := maxViewMessageLength(b.View.Header.Height); int64(len(message)) > limit {
+					log.Printf("Received too large (%d bytes) of a view for height %d, limit %d, from: %s\n",
+						len(message), b.View.Header.Height, limit, p.conn.RemoteAddr())
+					p.penalize(queryHost, oversizedMessagePenalty, "oversized view")
+					return
+				}
 				ok, err := p.onView(b.View, ibd, outChan)
 				if err != nil {
 					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
@@ -630,6 +946,17 @@ func (p *Peer) run() {
 					break
 				}
 
+			case "get_descendant":
+				var gd GetDescendantMessage
+				if err := json.Unmarshal(body, &gd); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onGetDescendant(gd.Parent, gd.Descendant, outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
 			case "get_imbalance":
 				var gb GetImbalanceMessage
 				if err := json.Unmarshal(body, &gb); err != nil {
@@ -659,7 +986,31 @@ func (p *Peer) run() {
 					return
 				}
 				if err := p.onGetPublicKeyConsiderations(gpkt.PublicKey,
-					gpkt.StartHeight, gpkt.EndHeight, gpkt.StartIndex, gpkt.Limit, outChan); err != nil {
+					gpkt.StartHeight, gpkt.EndHeight, gpkt.StartIndex, gpkt.Limit, gpkt.IDsOnly, queryHost,
+					outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "get_rendered_views":
+				var grv GetRenderedViewsMessage
+				if err := json.Unmarshal(body, &grv); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onGetRenderedViews(grv.PublicKey,
+					grv.StartHeight, grv.EndHeight, grv.Limit, outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "get_supply":
+				var gs GetSupplyMessage
+				if err := json.Unmarshal(body, &gs); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onGetSupply(gs.Height, outChan); err != nil {
 					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
 					break
 				}
@@ -681,6 +1032,15 @@ func (p *Peer) run() {
 					break
 				}
 
+			case "get_status":
+				if err := p.onGetStatus(outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "get_display_hints":
+				p.onGetDisplayHints(outChan)
+
 			case "push_consideration":
 				var pt PushConsiderationMessage
 				if err := json.Unmarshal(body, &pt); err != nil {
@@ -745,6 +1105,86 @@ func (p *Peer) run() {
 				}
 				p.onPeerAddresses(pa.Addresses)
 
+			case "get_mind_state":
+				var gms GetMindStateMessage
+				if err := json.Unmarshal(body, &gms); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onGetMindState(gms.SyncID, outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "put_mind_state":
+				var ms MindStateMessage
+				if err := json.Unmarshal(body, &ms); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onPutMindState(ms.SyncID, ms.Updated, ms.State, queryHost, outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "checkpoint":
+				var cm CheckpointMessage
+				if err := json.Unmarshal(body, &cm); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if cm.Checkpoint == nil {
+					log.Printf("Error: received nil checkpoint, from: %s\n", p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onCheckpoint(cm.Checkpoint, outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "invalidate_view":
+				var iv InvalidateViewMessage
+				if err := json.Unmarshal(body, &iv); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onInvalidateView(iv.ViewID, iv.Token, queryHost, outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "indexer_control":
+				var ic IndexerControlMessage
+				if err := json.Unmarshal(body, &ic); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onIndexerControl(ic.Action, ic.Height, ic.Token, queryHost, outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "reconsider_view":
+				var rv ReconsiderViewMessage
+				if err := json.Unmarshal(body, &rv); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if err := p.onReconsiderView(rv.ViewID, rv.Token, queryHost, outChan); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					break
+				}
+
+			case "checkpoint_result":
+				var cr CheckpointResultMessage
+				if err := json.Unmarshal(body, &cr); err != nil {
+					log.Printf("Error: %s, from: %s\n", err, p.conn.RemoteAddr())
+					return
+				}
+				if len(cr.Error) != 0 {
+					log.Printf("Error: %s, from: %s\n", cr.Error, p.conn.RemoteAddr())
+				}
+
 			case "get_work":
 				var gw GetWorkMessage
 				if err := json.Unmarshal(body, &gw); err != nil {
@@ -771,6 +1211,11 @@ func (p *Peer) run() {
 				log.Printf("Unknown message: %s, from: %s\n", m.Type, p.conn.RemoteAddr())
 			}
 
+			if queryCharged {
+				p.queryLimiter.Release(queryHost)
+				queryCharged = false
+			}
+
 		case websocket.CloseMessage:
 			log.Printf("Received close message from: %s\n", p.conn.RemoteAddr())
 			break
@@ -779,7 +1224,7 @@ func (p *Peer) run() {
 }
 
 // Handle a message from a peer indicating view inventory available for download
-func (p *Peer) onInvView(id ViewID, index, length int, outChan chan<- Message) error {
+func (p *Peer) onInvView(id ViewID, index, length int, tips bool, outChan chan<- Message) error {
 	log.Printf("Received inv_view: %s, from: %s\n", id, p.conn.RemoteAddr())
 
 	if length > maxViewesPerInv {
@@ -787,6 +1232,9 @@ func (p *Peer) onInvView(id ViewID, index, length int, outChan chan<- Message) e
 			length, maxViewesPerInv)
 	}
 
+	// no need to announce it back to them
+	p.addKnownView(id)
+
 	// is it on the ignore list?
 	if p.ignoreViewes[id] {
 		log.Printf("Ignoring view %s, from: %s\n", id, p.conn.RemoteAddr())
@@ -807,8 +1255,9 @@ func (p *Peer) onInvView(id ViewID, index, length int, outChan chan<- Message) e
 	}
 	if branchType != UNKNOWN {
 		log.Printf("Already processed view %s", id)
-		if length > 1 && index+1 == length {
-			// we might be on a deep side point. this will get us the next 500 views
+		if !tips && length > 1 && index+1 == length {
+			// we might be on a deep side point. this will get us the next 500 views.
+			// announced tips are connected views, not a page of a point we're catching up on
 			return p.sendFindCommonAncestor(&id, false, outChan)
 		}
 		return nil
@@ -828,6 +1277,37 @@ func (p *Peer) onInvView(id ViewID, index, length int, outChan chan<- Message) e
 	return p.processDownloadQueue(outChan)
 }
 
+// Send the peer an inv_view for a batch of new tip views. They request the ones they want with get_view
+func (p *Peer) announceViews(ids []ViewID) error {
+	log.Printf("Sending inv_view with %d new tip ID(s), to: %s\n", len(ids), p.conn.RemoteAddr())
+	m := Message{Type: "inv_view", Body: InvViewMessage{ViewIDs: ids, Tips: true}}
+	if err := p.writeJSON(m); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		p.addKnownView(id)
+	}
+	return nil
+}
+
+// Remember that the peer knows about a view
+func (p *Peer) addKnownView(id ViewID) {
+	p.knownViewsLock.Lock()
+	defer p.knownViewsLock.Unlock()
+	if len(p.knownViews) >= maxKnownViews {
+		// start over. the worst case is a redundant announcement
+		p.knownViews = make(map[ViewID]bool)
+	}
+	p.knownViews[id] = true
+}
+
+// Returns true if the peer is known to have a view
+func (p *Peer) isKnownView(id ViewID) bool {
+	p.knownViewsLock.Lock()
+	defer p.knownViewsLock.Unlock()
+	return p.knownViews[id]
+}
+
 // Handle a request for a view from a peer
 func (p *Peer) onGetView(id ViewID, outChan chan<- Message) error {
 	log.Printf("Received get_view: %s, from: %s\n", id, p.conn.RemoteAddr())
@@ -1072,8 +1552,7 @@ func (p *Peer) sendFindCommonAncestor(startID *ViewID, writeNow bool, outChan ch
 	m := Message{Type: "find_common_ancestor", Body: FindCommonAncestorMessage{ViewIDs: ids}}
 
 	if writeNow {
-		p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-		if err := p.conn.WriteJSON(m); err != nil {
+		if err := p.writeJSON(m); err != nil {
 			log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
 			return err
 		}
@@ -1178,6 +1657,11 @@ func (p *Peer) getViewHeader(id ViewID, outChan chan<- Message) error {
 func (p *Peer) onGetProfile(pubKey ed25519.PublicKey, outChan chan<- Message) error {
 	log.Printf("Received get_profile from: %s\n", p.conn.RemoteAddr())
 
+	if err := p.checkRankingEnabled(); err != nil {
+		outChan <- Message{Type: "profile", Body: ProfileMessage{PublicKey: pubKey, Error: err.Error()}}
+		return err
+	}
+
 	imbalances, _, _, err := p.ledger.GetPublicKeyImbalances([]ed25519.PublicKey{pubKey})
 	if err != nil {
 		outChan <- Message{Type: "imbalance", Body: ProfileMessage{PublicKey: pubKey, Error: err.Error()}}
@@ -1189,26 +1673,18 @@ func (p *Peer) onGetProfile(pubKey ed25519.PublicKey, outChan chan<- Message) er
 		imbalance = b
 	}
 
-	pk := pubKeyToString(pubKey)
-
-	_, locale, _ := localeFromPubKey(pk, p.indexer.Indices.Values())
-
-	graph := p.indexer.cnGraph
-
-	pkIndex, ok := graph.index[pk]
-
-	if ok {
-		node := graph.nodes[pkIndex]
+	snapshot := p.indexer.GraphSnapshot()
 
+	if ranking, ok := snapshot.Ranking(pubKey); ok {
 		outChan <- Message{
 			Type: "profile",
 			Body: ProfileMessage{
 				PublicKey: pubKey,
-				Ranking:   node.ranking,
+				Ranking:   ranking,
 				Imbalance: imbalance,
-				Locale:    locale,
-				ViewID:    p.indexer.latestViewID,
-				Height:    p.indexer.latestHeight,
+				Locale:    snapshot.Locale(pubKey),
+				ViewID:    snapshot.ViewID,
+				Height:    snapshot.Height,
 			},
 		}
 
@@ -1220,8 +1696,8 @@ func (p *Peer) onGetProfile(pubKey ed25519.PublicKey, outChan chan<- Message) er
 				Ranking:   0.00,
 				Imbalance: 0,
 				Locale:    "",
-				ViewID:    p.indexer.latestViewID,
-				Height:    p.indexer.latestHeight,
+				ViewID:    snapshot.ViewID,
+				Height:    snapshot.Height,
 			},
 		}
 	}
@@ -1233,14 +1709,19 @@ func (p *Peer) onGetProfile(pubKey ed25519.PublicKey, outChan chan<- Message) er
 func (p *Peer) onGetGraph(pubKey ed25519.PublicKey, outChan chan<- Message) error {
 	log.Printf("Received get_graph from: %s\n", p.conn.RemoteAddr())
 
-	pk := pubKeyToString(pubKey)
-	viewGraph := p.indexer.cnGraph.ToDOT(pk, p.indexer.Indices.Values(), p.indexer.synonyms)
+	if err := p.checkRankingEnabled(); err != nil {
+		outChan <- Message{Type: "graph", Body: GraphMessage{PublicKey: pubKey, Error: err.Error()}}
+		return err
+	}
+
+	snapshot := p.indexer.GraphSnapshot()
+	viewGraph := snapshot.ToDOT(pubKey)
 
 	outChan <- Message{
 		Type: "graph",
 		Body: GraphMessage{
-			ViewID:    p.indexer.latestViewID,
-			Height:    p.indexer.latestHeight,
+			ViewID:    snapshot.ViewID,
+			Height:    snapshot.Height,
 			PublicKey: pubKey,
 			Graph:     viewGraph,
 		},
@@ -1249,34 +1730,51 @@ func (p *Peer) onGetGraph(pubKey ed25519.PublicKey, outChan chan<- Message) erro
 	return nil
 }
 
+// Handle a request asking whether a public key descends from another in the consideration graph
+func (p *Peer) onGetDescendant(parent, descendant ed25519.PublicKey, outChan chan<- Message) error {
+	log.Printf("Received get_descendant from: %s\n", p.conn.RemoteAddr())
+
+	snapshot := p.indexer.GraphSnapshot()
+	outChan <- Message{
+		Type: "descendant",
+		Body: DescendantMessage{
+			ViewID:       snapshot.ViewID,
+			Height:       snapshot.Height,
+			Parent:       parent,
+			Descendant:   descendant,
+			IsDescendant: snapshot.IsParentDescendant(parent, descendant),
+		},
+	}
+	return nil
+}
+
 // Handle a request for a public key's considerability ranking
 func (p *Peer) onGetRanking(pubKey ed25519.PublicKey, outChan chan<- Message) error {
 	log.Printf("Received get_ranking from: %s\n", p.conn.RemoteAddr())
 
-	pk := pubKeyToString(pubKey)
-
-	graph := p.indexer.cnGraph
-
-	pkIndex, ok := graph.index[pk]
+	if err := p.checkRankingEnabled(); err != nil {
+		outChan <- Message{Type: "ranking", Body: RankingMessage{PublicKey: pubKey, Error: err.Error()}}
+		return err
+	}
 
-	if ok {
-		node := graph.nodes[pkIndex]
+	snapshot := p.indexer.GraphSnapshot()
 
+	if ranking, ok := snapshot.Ranking(pubKey); ok {
 		outChan <- Message{
 			Type: "ranking",
 			Body: RankingMessage{
-				ViewID:    p.indexer.latestViewID,
-				Height:    p.indexer.latestHeight,
+				ViewID:    snapshot.ViewID,
+				Height:    snapshot.Height,
 				PublicKey: pubKey,
-				Ranking:   node.ranking,
+				Ranking:   ranking,
 			},
 		}
 	} else {
 		outChan <- Message{
 			Type: "ranking",
 			Body: RankingMessage{
-				ViewID:    p.indexer.latestViewID,
-				Height:    p.indexer.latestHeight,
+				ViewID:    snapshot.ViewID,
+				Height:    snapshot.Height,
 				PublicKey: pubKey,
 				Ranking:   0.00,
 			},
@@ -1347,7 +1845,8 @@ func (p *Peer) onGetImbalances(pubKeys []ed25519.PublicKey, outChan chan<- Messa
 
 // Handle a request for a public key's considerations over a given height range
 func (p *Peer) onGetPublicKeyConsiderations(pubKey ed25519.PublicKey,
-	startHeight, endHeight int64, startIndex, limit int, outChan chan<- Message) error {
+	startHeight, endHeight int64, startIndex, limit int, idsOnly bool, host string,
+	outChan chan<- Message) error {
 	log.Printf("Received get_public_key_considerations from: %s\n", p.conn.RemoteAddr())
 
 	if limit < 0 {
@@ -1362,13 +1861,29 @@ func (p *Peer) onGetPublicKeyConsiderations(pubKey ed25519.PublicKey,
 
 	// get the indices for all considerations for the given public key
 	// over the given range of view heights
-	bIDs, indices, stopHeight, stopIndex, err := p.ledger.GetPublicKeyConsiderationIndicesRange(
-		pubKey, startHeight, endHeight, startIndex, limit)
+	bIDs, indices, stopHeight, stopIndex, truncated, err := p.getPublicKeyConsiderationIndices(
+		pubKey, startHeight, endHeight, startIndex, limit, host)
 	if err != nil {
 		outChan <- Message{Type: "public_key_considerations", Body: PublicKeyConsiderationsMessage{Error: err.Error()}}
 		return err
 	}
 
+	if idsOnly {
+		cnIDs := p.getPublicKeyConsiderationIDs(pubKey, bIDs, indices)
+		outChan <- Message{
+			Type: "public_key_considerations",
+			Body: PublicKeyConsiderationsMessage{
+				PublicKey:        pubKey,
+				StartHeight:      startHeight,
+				StopHeight:       stopHeight,
+				StopIndex:        stopIndex,
+				ConsiderationIDs: cnIDs,
+				Truncated:        truncated,
+			},
+		}
+		return nil
+	}
+
 	// build filter views from the indices
 	var fbs []*FilterViewMessage
 	for i, viewID := range bIDs {
@@ -1401,11 +1916,198 @@ func (p *Peer) onGetPublicKeyConsiderations(pubKey ed25519.PublicKey,
 	outChan <- Message{
 		Type: "public_key_considerations",
 		Body: PublicKeyConsiderationsMessage{
-			PublicKey:    pubKey,
-			StartHeight:  startHeight,
-			StopHeight:   stopHeight,
-			StopIndex:    stopIndex,
-			FilterViewes: fbs,
+			PublicKey:        pubKey,
+			StartHeight:      startHeight,
+			StopHeight:       stopHeight,
+			StopIndex:        stopIndex,
+			FilterViewes:     fbs,
+			Truncated:        truncated,
+		},
+	}
+	return nil
+}
+
+// Look up the IDs of the considerations at the given indices. They're read from the ledger's index
+// where it has them so the views needn't be read. Otherwise the consideration is read and hashed
+func (p *Peer) getPublicKeyConsiderationIDs(pubKey ed25519.PublicKey, viewIDs []ViewID, indices []int) []PublicKeyConsiderationID {
+	var cnIDs []PublicKeyConsiderationID
+	var viewHeader *ViewHeader
+	for i, viewID := range viewIDs {
+		if i == 0 || viewIDs[i-1] != viewID {
+			var err error
+			viewHeader, _, err = p.viewStore.GetViewHeader(viewID)
+			if err == nil && viewHeader == nil {
+				err = fmt.Errorf("View not found")
+			}
+			if err != nil {
+				// odd case. just log it and continue
+				log.Printf("Error retrieving consideration history, view: %s, error: %s\n", viewID, err)
+				viewHeader = nil
+				continue
+			}
+		} else if viewHeader == nil {
+			continue
+		}
+
+		cnID, err := p.ledger.GetPublicKeyConsiderationID(pubKey, viewHeader.Height, indices[i])
+		if err == nil && cnID == nil {
+			// indexed by an older version or pruned from the index
+			var cn *Consideration
+			if cn, _, err = p.viewStore.GetConsideration(viewID, indices[i]); err == nil {
+				var id ConsiderationID
+				id, err = cn.ID()
+				cnID = &id
+			}
+		}
+		if err != nil {
+			log.Printf("Error retrieving consideration ID, view: %s, index: %d, error: %s\n",
+				viewID, indices[i], err)
+			continue
+		}
+		cnIDs = append(cnIDs, PublicKeyConsiderationID{
+			ConsiderationID: *cnID,
+			ViewID:          viewID,
+			Height:          viewHeader.Height,
+		})
+	}
+	return cnIDs
+}
+
+// Charge a history query which reads stored views to the connection
+func (p *Peer) chargeHistoricScan(host string) error {
+	if p.queryLimiter != nil {
+		return p.queryLimiter.Charge(host, historicScanQueryCost)
+	}
+	return p.scanLimiter.Charge(host, 1)
+}
+
+// Get the indices for considerations involving the public key. Heights the ledger has pruned
+// are answered by reading the stored views, a bounded number at a time
+func (p *Peer) getPublicKeyConsiderationIndices(pubKey ed25519.PublicKey,
+	startHeight, endHeight int64, startIndex, limit int, host string) (
+	ids []ViewID, indices []int, stopHeight int64, stopIndex int, truncated bool, err error) {
+	prunedHeight, err := p.ledger.GetPrunedHeight()
+	if err != nil {
+		return nil, nil, 0, 0, false, err
+	}
+	forward := endHeight >= startHeight
+	if (forward && startHeight >= prunedHeight) || (!forward && endHeight >= prunedHeight) {
+		// fully indexed
+		ids, indices, stopHeight, stopIndex, err = p.ledger.GetPublicKeyConsiderationIndicesRange(
+			pubKey, startHeight, endHeight, startIndex, limit)
+		return ids, indices, stopHeight, stopIndex, false, err
+	}
+
+	if !forward && startHeight >= prunedHeight {
+		// the indexed part comes first going in reverse
+		ids, indices, stopHeight, stopIndex, err = p.ledger.GetPublicKeyConsiderationIndicesRange(
+			pubKey, startHeight, prunedHeight, startIndex, limit)
+		if err != nil || len(indices) == limit {
+			return ids, indices, stopHeight, stopIndex, false, err
+		}
+		startHeight, startIndex = prunedHeight-1, MAX_CONSIDERATIONS_PER_VIEW-1
+	}
+	scanEndHeight := endHeight
+	if forward && scanEndHeight >= prunedHeight {
+		scanEndHeight = prunedHeight - 1
+	}
+
+	// scans cost public hosts more of their quota. everyone else has a budget for them
+	if err := p.chargeHistoricScan(host); err != nil {
+		return nil, nil, 0, 0, false, err
+	}
+	scanIDs, scanIndices, lastHeight, lastIndex, scannedHeight, truncated, err := ScanPublicKeyConsiderations(
+		p.ledger, p.viewStore, pubKey, startHeight, scanEndHeight, startIndex, limit-len(indices),
+		historicScanMaxViews)
+	if err != nil {
+		return nil, nil, 0, 0, false, err
+	}
+	ids, indices = append(ids, scanIDs...), append(indices, scanIndices...)
+	if len(scanIndices) != 0 {
+		stopHeight, stopIndex = lastHeight, lastIndex
+	}
+	if truncated {
+		return ids, indices, scannedHeight, stopIndex, true, nil
+	}
+	if !forward || scanEndHeight == endHeight || len(indices) == limit {
+		return ids, indices, stopHeight, stopIndex, false, nil
+	}
+
+	// the indexed part comes last going forward
+	indexIDs, indexIndices, lastHeight, lastIndex, err := p.ledger.GetPublicKeyConsiderationIndicesRange(
+		pubKey, prunedHeight, endHeight, 0, limit-len(indices))
+	if err != nil {
+		return nil, nil, 0, 0, false, err
+	}
+	ids, indices = append(ids, indexIDs...), append(indices, indexIndices...)
+	if len(indexIndices) != 0 {
+		stopHeight, stopIndex = lastHeight, lastIndex
+	}
+	return ids, indices, stopHeight, stopIndex, false, nil
+}
+
+// Handle a request for the views rendered to a public key
+func (p *Peer) onGetRenderedViews(pubKey ed25519.PublicKey,
+	startHeight, endHeight int64, limit int, outChan chan<- Message) error {
+	log.Printf("Received get_rendered_views from: %s\n", p.conn.RemoteAddr())
+
+	// enforce our limit
+	if limit > maxRenderedViewsPerMessage || limit <= 0 {
+		limit = maxRenderedViewsPerMessage
+	}
+
+	ids, heights, err := p.ledger.GetRenderedViews(pubKey, startHeight, endHeight, limit)
+	if err != nil {
+		outChan <- Message{Type: "rendered_views", Body: RenderedViewsMessage{Error: err.Error()}}
+		return err
+	}
+
+	rv := RenderedViewsMessage{PublicKey: pubKey}
+	for i, id := range ids {
+		rv.Views = append(rv.Views, RenderedView{ViewID: id, Height: heights[i]})
+	}
+	outChan <- Message{Type: "rendered_views", Body: rv}
+	return nil
+}
+
+// Handle a request for network-wide totals
+func (p *Peer) onGetSupply(height int64, outChan chan<- Message) error {
+	log.Printf("Received get_supply, from: %s\n", p.conn.RemoteAddr())
+
+	if height == 0 {
+		_, tipHeight, err := p.ledger.GetPointTip()
+		if err != nil {
+			outChan <- Message{Type: "supply", Body: SupplyMessage{Error: err.Error()}}
+			return err
+		}
+		height = tipHeight
+	}
+	viewID, err := p.ledger.GetViewIDForHeight(height)
+	if err != nil {
+		outChan <- Message{Type: "supply", Body: SupplyMessage{Height: height, Error: err.Error()}}
+		return err
+	}
+	stats, err := p.ledger.GetSupplyStats(height)
+	if err != nil {
+		outChan <- Message{Type: "supply", Body: SupplyMessage{Height: height, Error: err.Error()}}
+		return err
+	}
+	if viewID == nil || stats == nil {
+		outChan <- Message{
+			Type: "supply",
+			Body: SupplyMessage{Height: height, Error: fmt.Sprintf("No view at height %d", height)},
+		}
+		return nil
+	}
+	outChan <- Message{
+		Type: "supply",
+		Body: SupplyMessage{
+			ViewID:            viewID,
+			Height:            height,
+			Supply:            stats.ViewpointsMatured,
+			Considerations:    stats.Considerations,
+			ViewpointsMatured: stats.ViewpointsMatured,
+			ActiveKeys:        stats.ActiveKeys,
 		},
 	}
 	return nil
@@ -1480,6 +2182,62 @@ func (p *Peer) onGetTipHeader(outChan chan<- Message) error {
 	return nil
 }
 
+// Handle a request for our network's display hints
+func (p *Peer) onGetDisplayHints(outChan chan<- Message) {
+	log.Printf("Received get_display_hints, from: %s\n", p.conn.RemoteAddr())
+	outChan <- Message{
+		Type: "display_hints",
+		Body: DisplayHintsMessage{GenesisID: p.genesisID, Hints: DISPLAY_HINTS},
+	}
+}
+
+// Handle a request for a summary of our health
+func (p *Peer) onGetStatus(outChan chan<- Message) error {
+	log.Printf("Received get_status, from: %s\n", p.conn.RemoteAddr())
+	tipID, tipHeader, tipWhen, err := getPointTipHeader(p.ledger, p.viewStore)
+	if err != nil {
+		outChan <- Message{Type: "status", Body: StatusMessage{Error: err.Error()}}
+		return err
+	}
+	ibd, _, err := IsInitialViewDownload(p.ledger, p.viewStore)
+	if err != nil {
+		outChan <- Message{Type: "status", Body: StatusMessage{Error: err.Error()}}
+		return err
+	}
+
+	indexerViewID, indexerHeight := p.indexer.Latest()
+	status := StatusMessage{
+		ViewID:              *tipID,
+		Height:              tipHeader.Height,
+		TimeSeen:            tipWhen,
+		InitialViewDownload: ibd,
+		QueueLength:         p.cnQueue.Len(),
+		IndexerViewID:       indexerViewID,
+		IndexerHeight:       indexerHeight,
+		IndexerState:        p.indexer.State(),
+	}
+	tipChange, newTx := p.processor.NotificationStats()
+	status.NotificationBacklog = tipChange.Pending + newTx.Pending
+	status.DroppedNotifications = tipChange.Dropped + newTx.Dropped
+	lag := tipChange.MaxLag
+	if newTx.MaxLag > lag {
+		lag = newTx.MaxLag
+	}
+	status.NotificationLag = int64(lag / time.Millisecond)
+	if p.peerManager != nil {
+		status.StaleTip = p.peerManager.IsTipStale()
+		status.InboundPeers = p.peerManager.inboundPeerCount()
+		status.OutboundPeers = p.peerManager.outboundPeerCount()
+		if p.peerManager.hashrateMonitor != nil {
+			hashrate := p.peerManager.hashrateMonitor.Hashrate()
+			status.Hashrate = &hashrate
+			status.RendererKeys = p.peerManager.hashrateMonitor.KeyStats()
+		}
+	}
+	outChan <- Message{Type: "status", Body: status}
+	return nil
+}
+
 // Handle receiving a consideration from a peer
 func (p *Peer) onPushConsideration(cn *Consideration, outChan chan<- Message) error {
 	id, err := cn.ID()
@@ -1491,11 +2249,14 @@ func (p *Peer) onPushConsideration(cn *Consideration, outChan chan<- Message) er
 	log.Printf("Received push_consideration: %s, from: %s\n", id, p.conn.RemoteAddr())
 
 	// process the consideration if this is the first time we've seen it
-	var errStr string
+	var errStr, code string
 	if !p.cnQueue.Exists(id) {
 		err = p.processor.ProcessConsideration(id, cn, p.conn.RemoteAddr().String())
 		if err != nil {
 			errStr = err.Error()
+			if r, ok := err.(considerationRejection); ok {
+				code = r.code
+			}
 		}
 	}
 
@@ -1503,6 +2264,7 @@ func (p *Peer) onPushConsideration(cn *Consideration, outChan chan<- Message) er
 		Body: PushConsiderationResultMessage{
 			ConsiderationID: id,
 			Error:           errStr,
+			Code:            code,
 		},
 	}
 	return err
@@ -1512,27 +2274,11 @@ func (p *Peer) onPushConsideration(cn *Consideration, outChan chan<- Message) er
 func (p *Peer) onFilterLoad(filterType string, filterBytes []byte, outChan chan<- Message) error {
 	log.Printf("Received filter_load (size: %d), from: %s\n", len(filterBytes), p.conn.RemoteAddr())
 
-	// check filter type
-	if filterType != "cuckoo" {
-		err := fmt.Errorf("Unsupported filter type: %s", filterType)
-		result := FilterResultMessage{Error: err.Error()}
-		outChan <- Message{Type: "filter_result", Body: result}
-		return err
-	}
-
-	// check limit
-	maxSize := 1 << 16
-	if len(filterBytes) > maxSize {
-		err := fmt.Errorf("Filter too large, max: %d\n", maxSize)
-		result := FilterResultMessage{Error: err.Error()}
-		outChan <- Message{Type: "filter_result", Body: result}
-		return err
-	}
-
-	// decode it
-	filter, err := cuckoo.Decode(filterBytes)
+	// decode it. this checks the type and its size limit
+	filter, err := DecodeConsiderationFilter(filterType, filterBytes)
 	if err != nil {
-		result := FilterResultMessage{Error: err.Error()}
+		// let them know what we do support
+		result := FilterResultMessage{Error: err.Error(), Types: FilterTypes}
 		outChan <- Message{Type: "filter_result", Body: result}
 		return err
 	}
@@ -1563,12 +2309,14 @@ func (p *Peer) onFilterAdd(pubKeys []ed25519.PublicKey, outChan chan<- Message)
 		return err
 	}
 
+	var code string
 	err := func() error {
 		p.filterLock.Lock()
 		defer p.filterLock.Unlock()
-		// set the filter if it's not set
+		// adding to a filter we don't have would leave the peer with a partial one
 		if p.filter == nil {
-			p.filter = cuckoo.NewFilter(1 << 16)
+			code = FilterNotLoadedCode
+			return fmt.Errorf("No filter loaded")
 		}
 		// perform the inserts
 		for _, pubKey := range pubKeys {
@@ -1582,7 +2330,7 @@ func (p *Peer) onFilterAdd(pubKeys []ed25519.PublicKey, outChan chan<- Message)
 	// send the result
 	var m Message
 	if err != nil {
-		m = Message{Type: "filter_result", Body: FilterResultMessage{Error: err.Error()}}
+		m = Message{Type: "filter_result", Body: FilterResultMessage{Error: err.Error(), Code: code}}
 	} else {
 		m = Message{Type: "filter_result"}
 	}
@@ -1599,7 +2347,7 @@ func (p *Peer) onGetFilterConsiderationQueue(outChan chan<- Message) {
 	p.filterLock.RLock()
 	defer p.filterLock.RUnlock()
 	if p.filter == nil {
-		ftq.Error = "No filter set"
+		ftq.Error, ftq.Code = "No filter loaded", FilterNotLoadedCode
 	} else {
 		considerations := p.cnQueue.Get(0)
 		for _, cn := range considerations {
@@ -1664,6 +2412,181 @@ func (p *Peer) onGetPeerAddresses(outChan chan<- Message) error {
 	return nil
 }
 
+// Handle a request for a mind's stored state
+func (p *Peer) onGetMindState(syncID string, outChan chan<- Message) error {
+	log.Printf("Received get_mind_state from: %s\n", p.conn.RemoteAddr())
+
+	if p.mindStateStore == nil {
+		err := fmt.Errorf("Mind state sync is disabled on this peer")
+		outChan <- Message{Type: "mind_state", Body: MindStateMessage{SyncID: syncID, Error: err.Error()}}
+		return err
+	}
+
+	state, updated, err := p.mindStateStore.Get(syncID)
+	if err != nil {
+		outChan <- Message{Type: "mind_state", Body: MindStateMessage{SyncID: syncID, Error: err.Error()}}
+		return err
+	}
+
+	outChan <- Message{
+		Type: "mind_state",
+		Body: MindStateMessage{
+			SyncID:  syncID,
+			Updated: updated,
+			State:   state,
+		},
+	}
+	return nil
+}
+
+// Handle a request to store a mind's state
+func (p *Peer) onPutMindState(syncID string, updated int64, state []byte, host string,
+	outChan chan<- Message) error {
+	log.Printf("Received put_mind_state from: %s\n", p.conn.RemoteAddr())
+
+	var err error
+	if p.mindStateStore == nil {
+		err = fmt.Errorf("Mind state sync is disabled on this peer")
+	} else if len(syncID) == 0 || len(syncID) > 64 {
+		err = fmt.Errorf("Invalid sync ID")
+	} else if len(state) > maxMindStateLength {
+		err = fmt.Errorf("Mind state of %d bytes exceeds maximum of %d bytes",
+			len(state), maxMindStateLength)
+	} else {
+		// don't let a state from the future pin itself in place
+		if max := time.Now().Unix() + maxMindStateFutureSeconds; updated > max {
+			updated = max
+		}
+		var ok bool
+		ok, err = p.mindStateStore.Put(syncID, host, updated, state)
+		if err == nil && !ok {
+			err = fmt.Errorf("A newer mind state is already stored")
+		}
+	}
+
+	result := PutMindStateResultMessage{SyncID: syncID}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	outChan <- Message{Type: "put_mind_state_result", Body: result}
+	return err
+}
+
+// Handle a checkpoint announcement
+func (p *Peer) onCheckpoint(cp *SignedCheckpoint, outChan chan<- Message) error {
+	log.Printf("Received checkpoint at height %d, from: %s\n", cp.Height, p.conn.RemoteAddr())
+
+	added, err := AddSignedCheckpoint(cp)
+	if err != nil {
+		outChan <- Message{Type: "checkpoint_result", Body: CheckpointResultMessage{Height: cp.Height, Error: err.Error()}}
+		return err
+	}
+	outChan <- Message{Type: "checkpoint_result", Body: CheckpointResultMessage{Height: cp.Height}}
+	if !added {
+		return nil
+	}
+	log.Printf("Accepted signed checkpoint %s at height %d\n", cp.ViewID, cp.Height)
+
+	// warn the operator if our main point already disagrees
+	id, err := p.ledger.GetViewIDForHeight(cp.Height)
+	if err != nil {
+		return err
+	}
+	if id != nil && *id != cp.ViewID {
+		log.Printf("WARNING: main point view %s at height %d conflicts with signed checkpoint %s\n",
+			*id, cp.Height, cp.ViewID)
+	}
+	return nil
+}
+
+// Handle an operator request to invalidate a view
+func (p *Peer) onInvalidateView(id ViewID, token, host string, outChan chan<- Message) error {
+	log.Printf("Received invalidate_view for %s, from: %s\n", id, p.conn.RemoteAddr())
+
+	err := p.checkOperator(host, token, "invalidate_view")
+	if err == nil {
+		err = p.processor.InvalidateView(id, p.conn.RemoteAddr().String())
+	}
+	result := InvalidateViewResultMessage{ViewID: id}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	outChan <- Message{Type: "invalidate_view_result", Body: result}
+	return err
+}
+
+// Handle an operator request to reconsider an invalidated view
+func (p *Peer) onReconsiderView(id ViewID, token, host string, outChan chan<- Message) error {
+	log.Printf("Received reconsider_view for %s, from: %s\n", id, p.conn.RemoteAddr())
+
+	err := p.checkOperator(host, token, "reconsider_view")
+	if err == nil {
+		err = p.processor.ReconsiderView(id, p.conn.RemoteAddr().String())
+	}
+	result := ReconsiderViewResultMessage{ViewID: id}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	outChan <- Message{Type: "reconsider_view_result", Body: result}
+	return err
+}
+
+// Handle an operator request to pause, disable, resume or rebuild the indexer
+func (p *Peer) onIndexerControl(action string, height int64, token, host string, outChan chan<- Message) error {
+	log.Printf("Received indexer_control %s, from: %s\n", action, p.conn.RemoteAddr())
+
+	err := p.checkOperator(host, token, "indexer_control")
+	if err == nil {
+		switch action {
+		case "pause":
+			err = p.indexer.PauseRanking()
+		case "disable":
+			err = p.indexer.DisableRanking()
+		case "resume":
+			err = p.indexer.ResumeRanking()
+		case "rebuild":
+			err = p.indexer.Rebuild(height)
+		default:
+			err = fmt.Errorf("Unknown indexer action: %s", action)
+		}
+	}
+	result := IndexerControlResultMessage{Action: action, State: p.indexer.State()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	outChan <- Message{Type: "indexer_control_result", Body: result}
+	return err
+}
+
+// Ranking queries are refused while the operator has disabled ranking
+func (p *Peer) checkRankingEnabled() error {
+	if p.indexer.State() == IndexerDisabled {
+		return fmt.Errorf("Ranking is disabled on this node")
+	}
+	return nil
+}
+
+// Returns true if the connection is from a mind or a public host making queries rather than a
+// relaying peer. Minds identify themselves with the mind header when they connect
+func (p *Peer) isQueryConnection() bool {
+	return p.queryLimiter != nil || p.mind
+}
+
+// Operator requests are only accepted from the node's own host with the operator token and
+// never from a web page, which could otherwise reach a node on the same host
+func (p *Peer) checkOperator(host, token, messageType string) error {
+	if p.browserOrigin {
+		return fmt.Errorf("%s is not accepted from a browser", messageType)
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%s is only accepted over loopback", messageType)
+	}
+	if !operatorTokenMatches(p.operatorToken, token) {
+		return fmt.Errorf("%s requires the node's operator token", messageType)
+	}
+	return nil
+}
+
 // Received a list of addresses
 func (p *Peer) onPeerAddresses(addresses []string) {
 	log.Printf("Received peer_addresses message with %d address(es), from: %s\n",
@@ -1694,15 +2617,13 @@ func (p *Peer) onGetWork(gw GetWorkMessage) {
 		err = fmt.Errorf("Peer already has work")
 	} else if len(gw.PublicKeys) == 0 {
 		err = fmt.Errorf("No public keys specified")
-	} else if len(gw.Memo) > MAX_MEMO_LENGTH {
-		err = fmt.Errorf("Max memo length (%d) exceeded: %d", MAX_MEMO_LENGTH, len(gw.Memo))
 	} else {
 		var tipID *ViewID
 		var tipHeader *ViewHeader
 		tipID, tipHeader, _, err = getPointTipHeader(p.ledger, p.viewStore)
 		if err != nil {
 			log.Printf("Error getting tip header: %s, for: %s\n", err, p.conn.RemoteAddr())
-		} else {
+		} else if err = MemoPolicyAt(tipHeader.Height + 1).Check(gw.Memo); err == nil {
 			// create and send out new work
 			p.pubKeys = gw.PublicKeys
 			p.memo = gw.Memo
@@ -1712,8 +2633,7 @@ func (p *Peer) onGetWork(gw GetWorkMessage) {
 
 	if err != nil {
 		m := Message{Type: "work", Body: WorkMessage{Error: err.Error()}}
-		p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-		if err := p.conn.WriteJSON(m); err != nil {
+		if err := p.writeJSON(m); err != nil {
 			log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
 			p.conn.Close()
 		}
@@ -1727,7 +2647,7 @@ func (p *Peer) createNewWorkView(tipID ViewID, tipHeader *ViewHeader) error {
 		return nil
 	}
 
-	medianTimestamp, err := computeMedianTimestamp(tipHeader, p.viewStore)
+	medianTimestamp, err := ComputeMedianTimestamp(tipHeader, p.viewStore)
 	if err != nil {
 		log.Printf("Error computing median timestamp: %s, for: %s\n", err, p.conn.RemoteAddr())
 	} else {
@@ -1738,6 +2658,14 @@ func (p *Peer) createNewWorkView(tipID ViewID, tipHeader *ViewHeader) error {
 		p.workView, err = createNextView(tipID, tipHeader, p.cnQueue, p.viewStore, p.ledger, p.pubKeys[keyIndex], p.memo)
 		if err != nil {
 			log.Printf("Error creating next view: %s, for: %s\n", err, p.conn.RemoteAddr())
+		} else {
+			// earlier work on the same tip is still worth submitting
+			p.outstandingWork[p.workID] = p.workView
+			p.outstandingWorkIDs = append(p.outstandingWorkIDs, p.workID)
+			if len(p.outstandingWorkIDs) > maxOutstandingWork {
+				delete(p.outstandingWork, p.outstandingWorkIDs[0])
+				p.outstandingWorkIDs = p.outstandingWorkIDs[1:]
+			}
 		}
 	}
 
@@ -1748,8 +2676,7 @@ func (p *Peer) createNewWorkView(tipID ViewID, tipHeader *ViewHeader) error {
 		m.Body = WorkMessage{WorkID: p.workID, Header: p.workView.Header, MinTime: p.medianTimestamp + 1}
 	}
 
-	p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err := p.conn.WriteJSON(m); err != nil {
+	if err := p.writeJSON(m); err != nil {
 		log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
 		p.conn.Close()
 		return err
@@ -1761,31 +2688,42 @@ func (p *Peer) createNewWorkView(tipID ViewID, tipHeader *ViewHeader) error {
 func (p *Peer) onSubmitWork(sw SubmitWorkMessage) {
 	m := Message{Type: "submit_work_result"}
 	id, err := sw.Header.ID()
+	var code string
 
 	if err != nil {
 		log.Printf("Error computing view ID: %s, from: %s\n", err, p.conn.RemoteAddr())
 	} else if sw.WorkID == 0 {
 		err = fmt.Errorf("No work ID set")
 		log.Printf("%s, from: %s\n", err.Error(), p.conn.RemoteAddr())
-	} else if sw.WorkID != p.workID {
-		err = fmt.Errorf("Expected work ID %d, found %d", p.workID, sw.WorkID)
+	} else if workView, ok := p.outstandingWork[sw.WorkID]; !ok {
+		err, code = fmt.Errorf("Unknown work ID %d", sw.WorkID), UnknownWorkCode
 		log.Printf("%s, from: %s\n", err.Error(), p.conn.RemoteAddr())
 	} else {
-		p.workView.Header = sw.Header
-		err = p.processor.ProcessView(id, p.workView, p.conn.RemoteAddr().String())
+		var tipID *ViewID
+		tipID, _, err = p.ledger.GetPointTip()
 		if err != nil {
-			log.Printf("Error processing work view: %s, from: %s\n", err, p.conn.RemoteAddr())
+			log.Printf("Error getting point tip: %s\n", err)
+		} else if workView.Header.Previous != *tipID {
+			err = fmt.Errorf("Work ID %d builds on view %s which is no longer the tip",
+				sw.WorkID, workView.Header.Previous)
+			code = StaleWorkCode
+			log.Printf("%s, from: %s\n", err.Error(), p.conn.RemoteAddr())
+		} else {
+			view := &View{Header: sw.Header, Considerations: workView.Considerations}
+			err = p.processor.ProcessView(id, view, p.conn.RemoteAddr().String())
+			if err != nil {
+				log.Printf("Error processing work view: %s, from: %s\n", err, p.conn.RemoteAddr())
+			}
 		}
 	}
 
 	if err != nil {
-		m.Body = SubmitWorkResultMessage{WorkID: sw.WorkID, Error: err.Error()}
+		m.Body = SubmitWorkResultMessage{WorkID: sw.WorkID, Error: err.Error(), Code: code}
 	} else {
 		m.Body = SubmitWorkResultMessage{WorkID: sw.WorkID}
 	}
 
-	p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err := p.conn.WriteJSON(m); err != nil {
+	if err := p.writeJSON(m); err != nil {
 		log.Printf("Write error: %s, to: %s\n", err, p.conn.RemoteAddr())
 		p.conn.Close()
 	}
@@ -1793,22 +2731,62 @@ func (p *Peer) onSubmitWork(sw SubmitWorkMessage) {
 
 // Update the read limit if necessary
 func (p *Peer) updateReadLimit() {
-	ok, height, err := IsInitialViewDownload(p.ledger, p.viewStore)
+	_, height, err := IsInitialViewDownload(p.ledger, p.viewStore)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	p.readLimitLock.Lock()
 	defer p.readLimitLock.Unlock()
-	if ok {
-		// TODO: do something smarter about this
-		p.readLimit = 0
-		return
+
+	// views we're sent may be up to an inv ahead of our tip while syncing. allow for that
+	// whether we think we're syncing or not since tip notifications can lag
+	p.readLimit = maxViewMessageLength(height + maxViewesPerInv)
+	if p.readLimit < MAX_PROTOCOL_MESSAGE_LENGTH {
+		p.readLimit = MAX_PROTOCOL_MESSAGE_LENGTH
 	}
+}
 
-	// considerations are <500 bytes so this gives us significant wiggle room
-	maxConsiderations := computeMaxConsiderationsPerView(height + 1)
-	p.readLimit = int64(maxConsiderations) * 1024
+// Returns the largest a view message for a view at the given height may be. Views are exempt from
+// MAX_PROTOCOL_MESSAGE_LENGTH since they grow with the consideration limit
+func maxViewMessageLength(height int64) int64 {
+	// everything but the memo fits in well under 1KB. escaping at most sextuples the memo
+	perConsideration := int64(1024 + 6*MemoPolicyAt(height).MaxLength)
+	// one more consideration's worth covers the header and the message envelope
+	return int64(computeMaxConsiderationsPerView(height)+1) * perConsideration
+}
+
+// Returns a message's type without decoding the rest of it
+func peekMessageType(message []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(message))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return "", fmt.Errorf("Message isn't an object")
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if key == "type" {
+			var messageType string
+			if err := dec.Decode(&messageType); err != nil {
+				return "", err
+			}
+			return messageType, nil
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("Message has no type")
+}
+
+// Add to the peer's misbehavior score
+func (p *Peer) penalize(host string, points int, reason string) {
+	if p.peerManager != nil {
+		p.peerManager.penalize(host, points, reason)
+	}
 }
 
 // Returns the maximum allowed size of a network message
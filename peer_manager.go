@@ -19,6 +19,7 @@ import (
 type PeerManager struct {
 	genesisID         ViewID
 	peerStore         PeerStorage
+	mindStateStore    MindStateStorage
 	viewStore        ViewStorage
 	ledger            Ledger
 	processor         *Processor
@@ -36,7 +37,17 @@ type PeerManager struct {
 	accepting         bool
 	irc               bool
 	dnsseed           bool
+	queryLimiter      *QueryLimiter // set in public mode
+	operatorToken     string        // minds present this with operator requests
 	banMap            map[string]bool
+	misbehavior       map[string]int       // misbehavior scores by host
+	misbehaviorBans   map[string]time.Time // hosts banned for misbehaving and until when
+	misbehaviorLock   sync.Mutex
+	eventFeed         *EventFeed
+	hashrateMonitor   *HashrateMonitor // set if we're rendering
+	tipStale          bool             // our tip is older than MAX_TIP_AGE
+	tipStaleHeight    int64            // tip height at the last stale tip check
+	tipStaleLock      sync.RWMutex
 	inPeers           map[string]*Peer
 	inPeerCountByHost map[string]int
 	outPeers          map[string]*Peer
@@ -54,10 +65,11 @@ type PeerManager struct {
 
 // NewPeerManager returns a new PeerManager instance.
 func NewPeerManager(
-	genesisID ViewID, peerStore PeerStorage, viewStore ViewStorage,
+	genesisID ViewID, peerStore PeerStorage, mindStateStore MindStateStorage, viewStore ViewStorage,
 	ledger Ledger, processor *Processor, indexer *Indexer, cnQueue ConsiderationQueue,
 	dataDir, myExternalIP, peer, certPath, keyPath string,
-	port, inboundLimit int, accept, irc, dnsseed bool, banMap map[string]bool) *PeerManager {
+	port, inboundLimit int, accept, irc, dnsseed, public bool, banMap map[string]bool,
+	eventFeed *EventFeed, hashrateMonitor *HashrateMonitor) *PeerManager {
 
 	// compute and save these
 	var privateIPBlocks []*net.IPNet
@@ -82,9 +94,17 @@ func NewPeerManager(
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// public mode serves mind queries to anyone subject to quotas
+	var queryLimiter *QueryLimiter
+	if public {
+		queryLimiter = NewQueryLimiter(publicQueryRate, publicQueryBurst,
+			publicMaxConcurrentQueries, publicMaxConcurrentQueriesPerHost)
+	}
+
 	return &PeerManager{
 		genesisID:         genesisID,
 		peerStore:         peerStore,
+		mindStateStore:    mindStateStore,
 		viewStore:        viewStore,
 		ledger:            ledger,
 		processor:         processor,
@@ -101,7 +121,12 @@ func NewPeerManager(
 		accept:            accept,
 		irc:               irc,
 		dnsseed:           dnsseed,
+		queryLimiter:      queryLimiter,
 		banMap:            banMap,
+		misbehavior:       make(map[string]int),
+		misbehaviorBans:   make(map[string]time.Time),
+		eventFeed:         eventFeed,
+		hashrateMonitor:   hashrateMonitor,
 		inPeers:           make(map[string]*Peer),
 		inPeerCountByHost: make(map[string]int),
 		outPeers:          make(map[string]*Peer),
@@ -117,6 +142,14 @@ func NewPeerManager(
 // It determines our connectivity and manages sourcing peer addresses from seed sources
 // as well as maintaining full outbound connections and accepting inbound connections.
 func (p *PeerManager) Run() {
+	if p.accept {
+		// minds on this host read it from the data directory
+		token, err := WriteOperatorToken(p.dataDir)
+		if err != nil {
+			log.Printf("Error writing operator token, operator requests will be refused: %s\n", err)
+		}
+		p.operatorToken = token
+	}
 	p.wg.Add(1)
 	go p.run()
 }
@@ -181,6 +214,9 @@ func (p *PeerManager) run() {
 		}
 	}
 
+	// find out if we're starting with a stale tip
+	p.checkStaleTip(ctx)
+
 	// handle listening for inbound peers
 	p.listenForPeers(ctx)
 
@@ -191,6 +227,15 @@ func (p *PeerManager) run() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
+	// watch for our tip going stale
+	tickerStaleTip := time.NewTicker(staleTipCheckPeriod)
+	defer tickerStaleTip.Stop()
+
+	// keep peer storage from growing without bound
+	p.prunePeerStore()
+	tickerPrunePeers := time.NewTicker(peerStorePrunePeriod)
+	defer tickerPrunePeers.Stop()
+
 	// main loop
 	for {
 		select {
@@ -202,7 +247,7 @@ func (p *PeerManager) run() {
 			}
 
 			// is it banned?
-			if p.banMap[host] {
+			if p.isBanned(host) {
 				log.Printf("Ignoring banned host: %s\n", host)
 				continue
 			}
@@ -239,6 +284,12 @@ func (p *PeerManager) run() {
 			// periodically try connecting to some saved peers
 			p.connectToPeers(ctx)
 
+		case <-tickerStaleTip.C:
+			p.checkStaleTip(ctx)
+
+		case <-tickerPrunePeers.C:
+			p.prunePeerStore()
+
 		case _, ok := <-p.shutdownChan:
 			if !ok {
 				log.Println("Peer manager shutting down...")
@@ -301,6 +352,130 @@ func (p *PeerManager) outboundPeerCount() int {
 	return len(p.outPeers)
 }
 
+// IsTipStale returns true if our main point tip is older than MAX_TIP_AGE.
+// Mind queries are refused while it is.
+func (p *PeerManager) IsTipStale() bool {
+	p.tipStaleLock.RLock()
+	defer p.tipStaleLock.RUnlock()
+	return p.tipStale
+}
+
+// Check if our tip has gone stale or recovered. If it's stale and we aren't making
+// progress look harder for peers who can catch us up
+func (p *PeerManager) checkStaleTip(ctx context.Context) {
+	tipID, tipHeader, _, err := getPointTipHeader(p.ledger, p.viewStore)
+	if err != nil {
+		log.Printf("Error getting tip header: %s\n", err)
+		return
+	}
+	stale := tipHeader.Time < time.Now().Unix()-MAX_TIP_AGE
+
+	p.tipStaleLock.Lock()
+	changed := stale != p.tipStale
+	progressing := tipHeader.Height > p.tipStaleHeight
+	p.tipStale, p.tipStaleHeight = stale, tipHeader.Height
+	p.tipStaleLock.Unlock()
+
+	if changed {
+		eventType := "tip_fresh"
+		if stale {
+			eventType = "tip_stale"
+			log.Printf("Tip %s at height %d is stale, last view time: %s, refusing mind queries\n",
+				*tipID, tipHeader.Height, time.Unix(tipHeader.Time, 0))
+		} else {
+			log.Printf("Tip %s at height %d is no longer stale\n", *tipID, tipHeader.Height)
+		}
+		if p.eventFeed != nil {
+			p.eventFeed.Publish(eventType, StaleTipEvent{
+				ViewID: *tipID,
+				Height: tipHeader.Height,
+				Time:   tipHeader.Time,
+			})
+		}
+	}
+	if !stale || progressing {
+		return
+	}
+
+	// we're stuck. ask the seeds for more peers and swap out one of ours
+	log.Println("Tip is stale and not advancing, looking for more peers")
+	if len(p.peer) == 0 {
+		addresses, err := dnsQueryForPeers()
+		if err != nil {
+			log.Printf("Error from DNS query: %s\n", err)
+		}
+		for _, addr := range addresses {
+			select {
+			case p.addrChan <- addr:
+			default:
+			}
+		}
+	}
+	if p.outboundPeerCount() >= MAX_OUTBOUND_PEER_CONNECTIONS {
+		p.dropRandomPeer()
+	}
+	p.connectToPeers(ctx)
+}
+
+// Returns true if the host is on the ban list or was banned for misbehaving
+func (p *PeerManager) isBanned(host string) bool {
+	if p.banMap[host] {
+		return true
+	}
+	p.misbehaviorLock.Lock()
+	defer p.misbehaviorLock.Unlock()
+	until, ok := p.misbehaviorBans[host]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(p.misbehaviorBans, host)
+		return false
+	}
+	return true
+}
+
+// Add to a host's misbehavior score. Once it reaches misbehaviorBanThreshold the host is
+// banned for misbehaviorBanDuration. Loopback and private hosts are never banned, they may be
+// our own renderers and minds or a proxy every connection arrives through, so they're only disconnected
+func (p *PeerManager) penalize(host string, points int, reason string) {
+	if p.isPrivateHost(host) {
+		log.Printf("Not penalizing private host %s: %s\n", host, reason)
+		return
+	}
+	p.misbehaviorLock.Lock()
+	defer p.misbehaviorLock.Unlock()
+	p.misbehavior[host] += points
+	score := p.misbehavior[host]
+	log.Printf("Misbehavior score of %s is now %d: %s\n", host, score, reason)
+	if score < misbehaviorBanThreshold {
+		return
+	}
+	delete(p.misbehavior, host)
+	p.misbehaviorBans[host] = time.Now().Add(misbehaviorBanDuration)
+	log.Printf("Banning %s for %s for misbehaving\n", host, misbehaviorBanDuration)
+}
+
+// PeerStats returns protocol statistics for every connected peer.
+func (p *PeerManager) PeerStats() []PeerStats {
+	var stats []PeerStats
+	p.outPeersLock.RLock()
+	for addr, peer := range p.outPeers {
+		s := peer.Stats()
+		s.Address = addr
+		stats = append(stats, s)
+	}
+	p.outPeersLock.RUnlock()
+	p.inPeersLock.RLock()
+	for addr, peer := range p.inPeers {
+		s := peer.Stats()
+		s.Address = addr
+		stats = append(stats, s)
+	}
+	p.inPeersLock.RUnlock()
+	return stats
+}
+
 // Try connecting to some recent peers
 func (p *PeerManager) connectToPeers(ctx context.Context) error {
 	if len(p.peer) != 0 {
@@ -371,8 +546,11 @@ func (p *PeerManager) connectToPeers(ctx context.Context) error {
 
 			// is it banned?
 			host, _, _ := net.SplitHostPort(addr)
-			if p.banMap[host] {
+			if p.isBanned(host) {
 				log.Printf("Skipping and removing banned host: %s\n", host)
+				if p.eventFeed != nil {
+					p.eventFeed.Publish("peer_banned", PeerEvent{Address: addr})
+				}
 				if err := p.peerStore.Delete(addr); err != nil {
 					log.Printf("Error removing peer from storage: %s\n", err)
 				}
@@ -400,7 +578,8 @@ func (p *PeerManager) connectToPeers(ctx context.Context) error {
 
 // Connect to a peer
 func (p *PeerManager) connect(ctx context.Context, addr string) (int, *Peer, error) {
-	peer := NewPeer(nil, p.genesisID, p.peerStore, p.viewStore, p.ledger, p.processor, p.indexer, p.cnQueue, p.viewQueue, p.addrChan)
+	peer := NewPeer(nil, p.genesisID, p.peerStore, p.mindStateStore, p.viewStore, p.ledger, p.processor, p.indexer, p.cnQueue, p.viewQueue, p.addrChan)
+	peer.peerManager = p
 
 	if ok := p.addToOutboundSet(addr, peer); !ok {
 		return 0, nil, fmt.Errorf("Too many peer connections")
@@ -413,12 +592,25 @@ func (p *PeerManager) connect(ctx context.Context, addr string) (int, *Peer, err
 	}
 
 	// connect to the peer
-	statusCode, err := peer.Connect(ctx, addr, p.peerNonce, myAddress)
+	statusCode, theirNonce, err := peer.Connect(ctx, addr, p.peerNonce, myAddress)
 	if err != nil {
 		p.removeFromOutboundSet(addr)
 		return statusCode, nil, err
 	}
 
+	// the peer is already in the outbound set where findPeerByNonce reads it
+	p.outPeersLock.Lock()
+	peer.nonce = theirNonce
+	p.outPeersLock.Unlock()
+
+	// we may already be connected to this node under a different address or inbound
+	if !p.resolveDuplicateConnection(peer.nonce, peer, true) {
+		peer.conn.Close()
+		p.removeFromOutboundSet(addr)
+		p.peerStore.OnDisconnect(addr)
+		return statusCode, nil, fmt.Errorf("Already connected to the node at %s", addr)
+	}
+
 	peer.OnClose(func() {
 		p.removeFromOutboundSet(addr)
 	})
@@ -491,8 +683,11 @@ func (p *PeerManager) acceptConnections() {
 	peerHandler := func(w http.ResponseWriter, r *http.Request) {
 		// is it banned?
 		host, _, _ := net.SplitHostPort(r.RemoteAddr)
-		if p.banMap[host] {
+		if p.isBanned(host) {
 			log.Printf("Rejecting connection from banned host: %s\n", r.RemoteAddr)
+			if p.eventFeed != nil {
+				p.eventFeed.Publish("peer_banned", PeerEvent{Address: r.RemoteAddr})
+			}
 			w.WriteHeader(http.StatusForbidden)
 			return
 		}
@@ -513,6 +708,15 @@ func (p *PeerManager) acceptConnections() {
 			return
 		}
 
+		// see if we're already connected to this node
+		if !p.resolveDuplicateConnection(theirNonce, nil, false) {
+			log.Printf("Already connected to the node at %s, dropping inbound connection",
+				r.RemoteAddr)
+			// write back error reply
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
 		// if they set their address it means they think they are open
 		theirAddress := r.Header.Get("Viewpoint-Peer-Address")
 		if len(theirAddress) != 0 {
@@ -543,14 +747,27 @@ func (p *PeerManager) acceptConnections() {
 			}
 		}
 
-		// accept the new websocket
-		conn, err := PeerUpgrader.Upgrade(w, r, nil)
+		// accept the new websocket. let them know who we are so they can detect duplicates too
+		header := http.Header{}
+		header.Add("Viewpoint-Peer-Nonce", p.peerNonce)
+		conn, err := PeerUpgrader.Upgrade(w, r, header)
 		if err != nil {
 			log.Print("Upgrade:", err)
 			return
 		}
 
-		peer := NewPeer(conn, p.genesisID, p.peerStore, p.viewStore, p.ledger, p.processor, p.indexer, p.cnQueue, p.viewQueue, p.addrChan)
+		peer := NewPeer(conn, p.genesisID, p.peerStore, p.mindStateStore, p.viewStore, p.ledger, p.processor, p.indexer, p.cnQueue, p.viewQueue, p.addrChan)
+		peer.nonce = theirNonce
+		peer.peerManager = p
+		peer.operatorToken = p.operatorToken
+		// browsers always send an origin. a web page must never be able to make operator requests
+		peer.browserOrigin = len(r.Header.Get("Origin")) != 0
+		peer.mind = len(r.Header.Get("Viewpoint-Mind")) != 0
+		if p.queryLimiter != nil && !p.isPrivateHost(host) {
+			// public connections are subject to query quotas. the peer address header isn't
+			// verified so announcing one doesn't exempt them
+			peer.queryLimiter = p.queryLimiter
+		}
 
 		if ok := p.addToInboundSet(r.RemoteAddr, peer); !ok {
 			// TODO: tell the peer why
@@ -578,8 +795,11 @@ func (p *PeerManager) acceptConnections() {
 		}
 	}
 
-	// listen for websocket requests using the genesis view ID as the handler pattern
-	http.HandleFunc("/"+p.genesisID.String(), peerHandler)
+	// listen for websocket requests using the genesis view ID as the handler pattern.
+	// each peer manager has its own mux so more than one can run in a process
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+p.genesisID.String(), peerHandler)
+	p.server.Handler = mux
 
 	log.Println("Listening for new peer connections")
 	if err := p.server.ListenAndServeTLS(certPath, keyPath); err != nil {
@@ -668,6 +888,77 @@ func (p *PeerManager) checkHostConnectionLimit(addr string) bool {
 	return count < MAX_INBOUND_PEER_CONNECTIONS_FROM_SAME_HOST
 }
 
+// Remove stale and excess addresses from peer storage
+func (p *PeerManager) prunePeerStore() {
+	count, err := p.peerStore.Prune()
+	if err != nil {
+		log.Printf("Error pruning peer storage: %s\n", err)
+		return
+	}
+	if count != 0 {
+		log.Printf("Pruned %d peer address(es) from storage\n", count)
+	}
+}
+
+// Returns true if the host is on a loopback or private network.
+func (p *PeerManager) isPrivateHost(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, block := range p.privateIPBlocks {
+		if block.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a connected peer with the given session nonce other than the one passed and whether it's outbound.
+func (p *PeerManager) findPeerByNonce(nonce string, except *Peer) (*Peer, bool) {
+	p.outPeersLock.RLock()
+	for _, peer := range p.outPeers {
+		if peer != except && peer.nonce == nonce {
+			p.outPeersLock.RUnlock()
+			return peer, true
+		}
+	}
+	p.outPeersLock.RUnlock()
+
+	p.inPeersLock.RLock()
+	defer p.inPeersLock.RUnlock()
+	for _, peer := range p.inPeers {
+		if peer != except && peer.nonce == nonce {
+			return peer, false
+		}
+	}
+	return nil, false
+}
+
+// Decide whether to keep a new connection to the node with the given session nonce.
+// If we already have a connection in the same direction the new one is redundant.
+// If the nodes connected to each other the connection initiated by the node with the
+// lower nonce is kept, so that both ends agree, and the other is dropped.
+func (p *PeerManager) resolveDuplicateConnection(nonce string, newPeer *Peer, outbound bool) bool {
+	if len(nonce) == 0 {
+		// older peers don't tell us
+		return true
+	}
+	existing, existingOutbound := p.findPeerByNonce(nonce, newPeer)
+	if existing == nil {
+		return true
+	}
+	if existingOutbound == outbound {
+		return false
+	}
+	if outbound != (p.peerNonce < nonce) {
+		return false
+	}
+	log.Printf("Dropping redundant connection with: %s\n", existing.conn.RemoteAddr())
+	go existing.Shutdown()
+	return true
+}
+
 // Helper to check if a peer address exists in the outbound set
 func (p *PeerManager) existsInOutboundSet(addr string) bool {
 	p.outPeersLock.RLock()
@@ -0,0 +1,159 @@
+package focalpoint
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Returns the client end of a websocket connection to a test server and a channel of the
+// messages the server receives
+func dialTestPeer(t *testing.T) (*websocket.Conn, <-chan Message, func()) {
+	upgrader := websocket.Upgrader{}
+	received := make(chan Message, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var m Message
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			select {
+			case received <- m:
+			default:
+			}
+		}
+	}))
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return conn, received, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestResolveDuplicateConnection(t *testing.T) {
+	newPeerManager := func(nonce string) *PeerManager {
+		return &PeerManager{
+			peerNonce: nonce,
+			inPeers:   make(map[string]*Peer),
+			outPeers:  make(map[string]*Peer),
+		}
+	}
+
+	// older peers don't send a nonce
+	pm := newPeerManager("5")
+	pm.outPeers["127.0.0.1:8831"] = &Peer{}
+	if !pm.resolveDuplicateConnection("", &Peer{}, true) {
+		t.Fatal("Expected a connection without a nonce to be kept")
+	}
+
+	// no other connection with the same nonce
+	pm.outPeers["127.0.0.1:8831"].nonce = "7"
+	if !pm.resolveDuplicateConnection("8", &Peer{}, false) {
+		t.Fatal("Expected a connection with an unknown nonce to be kept")
+	}
+
+	// the existing connection doesn't count against itself
+	existing := pm.outPeers["127.0.0.1:8831"]
+	if !pm.resolveDuplicateConnection("7", existing, true) {
+		t.Fatal("Expected a connection not to be a duplicate of itself")
+	}
+
+	// a second connection in the same direction is redundant
+	if pm.resolveDuplicateConnection("7", &Peer{}, true) {
+		t.Fatal("Expected a second outbound connection to be dropped")
+	}
+	pm.inPeers["127.0.0.1:50000"] = &Peer{nonce: "9"}
+	if pm.resolveDuplicateConnection("9", &Peer{}, false) {
+		t.Fatal("Expected a second inbound connection to be dropped")
+	}
+
+	// when both nodes connected to each other both ends keep the connection
+	// initiated by the node with the lower nonce
+	for _, test := range []struct {
+		ours, theirs string
+		outbound     bool
+		keep         bool
+	}{
+		{"5", "7", true, true},
+		{"5", "7", false, false},
+		{"7", "5", true, false},
+		{"7", "5", false, true},
+	} {
+		// the losing connection is shut down
+		conn, _, closeConn := dialTestPeer(t)
+		defer closeConn()
+		pm := newPeerManager(test.ours)
+		if test.outbound {
+			pm.inPeers["127.0.0.1:50000"] = &Peer{conn: conn, nonce: test.theirs}
+		} else {
+			pm.outPeers["127.0.0.1:8831"] = &Peer{conn: conn, nonce: test.theirs, outbound: true}
+		}
+		if keep := pm.resolveDuplicateConnection(test.theirs, &Peer{}, test.outbound); keep != test.keep {
+			t.Fatalf("Our nonce %s, their nonce %s, outbound %v: expected keep %v, found %v",
+				test.ours, test.theirs, test.outbound, test.keep, keep)
+		}
+
+		// the other end makes the same choice about the same connection
+		otherConn, _, closeOtherConn := dialTestPeer(t)
+		defer closeOtherConn()
+		other := newPeerManager(test.theirs)
+		if test.outbound {
+			other.outPeers["127.0.0.1:8831"] = &Peer{conn: otherConn, nonce: test.ours, outbound: true}
+		} else {
+			other.inPeers["127.0.0.1:50000"] = &Peer{conn: otherConn, nonce: test.ours}
+		}
+		if keep := other.resolveDuplicateConnection(test.ours, &Peer{}, !test.outbound); keep != test.keep {
+			t.Fatalf("Our nonce %s, their nonce %s, outbound %v: expected both ends to agree",
+				test.ours, test.theirs, test.outbound)
+		}
+	}
+}
+
+func TestPenalize(t *testing.T) {
+	pm := &PeerManager{
+		misbehavior:     make(map[string]int),
+		misbehaviorBans: make(map[string]time.Time),
+	}
+	for _, cidr := range []string{"127.0.0.0/8", "10.0.0.0/8", "::1/128"} {
+		_, block, _ := net.ParseCIDR(cidr)
+		pm.privateIPBlocks = append(pm.privateIPBlocks, block)
+	}
+
+	// a public host is banned once it reaches the threshold
+	for score := 0; score < misbehaviorBanThreshold; score += oversizedMessagePenalty {
+		if pm.isBanned("203.0.113.5") {
+			t.Fatalf("Expected the host not to be banned with score %d", score)
+		}
+		pm.penalize("203.0.113.5", oversizedMessagePenalty, "test")
+	}
+	if !pm.isBanned("203.0.113.5") {
+		t.Fatal("Expected the host to be banned")
+	}
+
+	// loopback and private hosts are only disconnected
+	for _, host := range []string{"127.0.0.1", "10.1.2.3", "::1"} {
+		for score := 0; score < 2*misbehaviorBanThreshold; score += oversizedMessagePenalty {
+			pm.penalize(host, oversizedMessagePenalty, "test")
+		}
+		if pm.isBanned(host) {
+			t.Fatalf("Expected %s not to be banned", host)
+		}
+		if score := pm.misbehavior[host]; score != 0 {
+			t.Fatalf("Expected %s to have no misbehavior score, found %d", host, score)
+		}
+	}
+}
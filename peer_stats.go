@@ -0,0 +1,106 @@
+package focalpoint
+
+import (
+	"sync"
+	"time"
+)
+
+// PeerStats are protocol statistics for a peer connection.
+type PeerStats struct {
+	Address          string
+	Outbound         bool
+	MessagesSent     int64
+	MessagesReceived int64
+	Backlog          int // notifications waiting to be written to the peer
+	MaxBacklog       int
+	AvgWriteLatency  time.Duration // moving average
+	MaxWriteLatency  time.Duration
+	Degraded         bool // true if we've stopped relaying considerations because the peer can't keep up
+	DegradedSince    time.Time
+	DroppedRelays    int64 // considerations not relayed while degraded
+}
+
+// Tracks a peer's statistics. It's updated by the peer's reader and writer and read by others
+type peerStats struct {
+	stats PeerStats
+	lock  sync.Mutex
+}
+
+// Record a message written to the peer and how long it took
+func (s *peerStats) onSend(latency time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats.MessagesSent++
+	s.addWriteLatency(latency)
+}
+
+// Record how long a ping took to write. Pings keep sampling the write latency while
+// a degraded peer is sent little else
+func (s *peerStats) onPing(latency time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.addWriteLatency(latency)
+}
+
+// Update the write latency average. Caller must hold the lock
+func (s *peerStats) addWriteLatency(latency time.Duration) {
+	if s.stats.AvgWriteLatency == 0 {
+		s.stats.AvgWriteLatency = latency
+	} else {
+		s.stats.AvgWriteLatency += (latency - s.stats.AvgWriteLatency) / 8
+	}
+	if latency > s.stats.MaxWriteLatency {
+		s.stats.MaxWriteLatency = latency
+	}
+}
+
+// Record a message read from the peer
+func (s *peerStats) onReceive() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats.MessagesReceived++
+}
+
+// Record a consideration we didn't relay because the peer is degraded
+func (s *peerStats) onDroppedRelay() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats.DroppedRelays++
+}
+
+// Returns true if the peer is degraded
+func (s *peerStats) isDegraded() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.stats.Degraded
+}
+
+// Update the backlog and whether or not the peer is keeping up. It returns the degraded state,
+// whether it changed and for how long the peer has been degraded
+func (s *peerStats) update(backlog int, now time.Time) (degraded, changed bool, since time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats.Backlog = backlog
+	if backlog > s.stats.MaxBacklog {
+		s.stats.MaxBacklog = backlog
+	}
+
+	slow := backlog >= slowPeerBacklog || s.stats.AvgWriteLatency >= slowPeerWriteLatency
+	if slow && !s.stats.Degraded {
+		s.stats.Degraded, s.stats.DegradedSince, changed = true, now, true
+	} else if !slow && s.stats.Degraded && backlog == 0 {
+		// it caught up
+		s.stats.Degraded, s.stats.DegradedSince, changed = false, time.Time{}, true
+	}
+	if s.stats.Degraded {
+		since = now.Sub(s.stats.DegradedSince)
+	}
+	return s.stats.Degraded, changed, since
+}
+
+// Returns a snapshot of the statistics
+func (s *peerStats) get() PeerStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.stats
+}
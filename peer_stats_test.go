@@ -0,0 +1,53 @@
+package focalpoint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeerStatsSlowConsumer(t *testing.T) {
+	var s peerStats
+	now := time.Now()
+
+	// keeping up
+	s.onSend(10 * time.Millisecond)
+	if degraded, changed, _ := s.update(1, now); degraded || changed {
+		t.Fatal("Expected peer to be keeping up")
+	}
+
+	// backlog builds up
+	degraded, changed, _ := s.update(slowPeerBacklog, now)
+	if !degraded || !changed {
+		t.Fatal("Expected peer to be degraded")
+	}
+	_, _, since := s.update(slowPeerBacklog/2, now.Add(time.Minute))
+	if since != time.Minute {
+		t.Fatalf("Expected degraded for a minute, found %s", since)
+	}
+
+	// it has to fully catch up to recover
+	if degraded, changed, _ := s.update(0, now.Add(2*time.Minute)); degraded || !changed {
+		t.Fatal("Expected peer to have recovered")
+	}
+
+	// slow writes degrade it too
+	for i := 0; i < 32; i++ {
+		s.onSend(2 * slowPeerWriteLatency)
+	}
+	if degraded, _, _ := s.update(0, now); !degraded {
+		t.Fatal("Expected slow writes to degrade the peer")
+	}
+
+	// fast pings bring the average back down so it recovers without relaying anything
+	for i := 0; i < 32; i++ {
+		s.onPing(10 * time.Millisecond)
+	}
+	if degraded, changed, _ := s.update(0, now); degraded || !changed {
+		t.Fatal("Expected fast pings to let the peer recover")
+	}
+
+	stats := s.get()
+	if stats.MessagesSent != 33 || stats.MaxBacklog != slowPeerBacklog || stats.MaxWriteLatency != 2*slowPeerWriteLatency {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+}
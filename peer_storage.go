@@ -1,5 +1,7 @@
 package focalpoint
 
+import "time"
+
 // PeerStorage is an interface for storing peer addresses and information about their connectivity.
 type PeerStorage interface {
 	// Store stores a peer address. Returns true if the peer was newly added to storage.
@@ -25,4 +27,25 @@ type PeerStorage interface {
 
 	// OnDisconnect is called upon disconnection.
 	OnDisconnect(addr string) error
+
+	// Prune removes addresses not seen within the retention period and the lowest scoring
+	// addresses over the storage limit. It returns the number removed.
+	Prune() (int, error)
+}
+
+// PeerRecord describes a stored peer address.
+type PeerRecord struct {
+	Addr        string  `json:"addr"`
+	FirstSeen   int64   `json:"first_seen"`
+	LastAttempt int64   `json:"last_attempt,omitempty"` // zero if never attempted
+	LastSuccess int64   `json:"last_success,omitempty"` // zero if never connected
+	Score       float64 `json:"score"`                  // from 1 for connected just now down to 0 for never connected
+}
+
+// LastSeen returns when we last connected to the peer or first heard of it if we never have.
+func (r PeerRecord) LastSeen() time.Time {
+	if r.LastSuccess != 0 {
+		return time.Unix(r.LastSuccess, 0)
+	}
+	return time.Unix(r.FirstSeen, 0)
 }
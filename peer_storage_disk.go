@@ -7,29 +7,41 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 // PeerStorageDisk is an on-disk implementation of the PeerStorage interface using LevelDB.
 type PeerStorageDisk struct {
 	db                 *leveldb.DB
+	retention          time.Duration // addresses not seen for this long are pruned, 0 to keep them
+	maxPeers           int           // most addresses kept when pruning, 0 for no limit
 	connectedPeers     map[string]bool
 	connectedPeersLock sync.Mutex
 }
 
-// NewPeerStorageDisk returns a new PeerStorageDisk instance.
-func NewPeerStorageDisk(dbPath string) (*PeerStorageDisk, error) {
+// NewPeerStorageDisk returns a new PeerStorageDisk instance. Prune drops addresses not seen
+// within retention and keeps at most maxPeers; pass 0 for either to disable it.
+func NewPeerStorageDisk(dbPath string, readOnly bool, retention time.Duration, maxPeers int) (
+	*PeerStorageDisk, error) {
 	// open peer database
-	db, err := leveldb.OpenFile(dbPath, nil)
+	opts := opt.Options{ReadOnly: readOnly}
+	db, err := leveldb.OpenFile(dbPath, &opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PeerStorageDisk{db: db, connectedPeers: make(map[string]bool)}, nil
+	return &PeerStorageDisk{
+		db:             db,
+		retention:      retention,
+		maxPeers:       maxPeers,
+		connectedPeers: make(map[string]bool),
+	}, nil
 }
 
 // Store stores a peer address. Returns true if the peer was newly added to storage.
@@ -284,6 +296,93 @@ func (p *PeerStorageDisk) OnDisconnect(addr string) error {
 	return nil
 }
 
+// GetAll returns every stored peer address, highest score first.
+func (p *PeerStorageDisk) GetAll() ([]PeerRecord, error) {
+	peers, err := p.getAllPeerInfo()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	records := make([]PeerRecord, 0, len(peers))
+	for addr, info := range peers {
+		records = append(records, info.toRecord(addr, now))
+	}
+	sortPeerRecords(records)
+	return records, nil
+}
+
+// Seed stores the given peer addresses if storage is empty, e.g. on a node's first run.
+// Returns the number stored.
+func (p *PeerStorageDisk) Seed(addrs []string) (int, error) {
+	iter := p.db.NewIterator(util.BytesPrefix([]byte{peerPrefix}), nil)
+	empty := !iter.Next()
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+	if !empty {
+		return 0, nil
+	}
+	var count int
+	for _, addr := range addrs {
+		ok, err := p.Store(addr)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Prune removes addresses not seen within the retention period and the lowest scoring
+// addresses over the storage limit. It returns the number removed.
+func (p *PeerStorageDisk) Prune() (int, error) {
+	if p.retention == 0 && p.maxPeers == 0 {
+		return 0, nil
+	}
+	peers, err := p.getAllPeerInfo()
+	if err != nil {
+		return 0, err
+	}
+	connectedPeers := p.getConnectedPeers()
+
+	now := time.Now()
+	var keep, remove []PeerRecord
+	for addr, info := range peers {
+		record := info.toRecord(addr, now.Unix())
+		if connectedPeers[addr] {
+			continue
+		}
+		if p.retention != 0 && now.Sub(record.LastSeen()) > p.retention {
+			remove = append(remove, record)
+		} else {
+			keep = append(keep, record)
+		}
+	}
+	if p.maxPeers != 0 && len(keep) > p.maxPeers-len(connectedPeers) {
+		sortPeerRecords(keep)
+		limit := p.maxPeers - len(connectedPeers)
+		if limit < 0 {
+			limit = 0
+		}
+		remove = append(remove, keep[limit:]...)
+	}
+
+	batch := new(leveldb.Batch)
+	for _, record := range remove {
+		info := peers[record.Addr]
+		if err := info.deleteFromBatch(record.Addr, batch); err != nil {
+			return 0, err
+		}
+	}
+	if err := p.db.Write(batch, nil); err != nil {
+		return 0, err
+	}
+	return len(remove), nil
+}
+
 // Close is called to close any underlying storage.
 func (p *PeerStorageDisk) Close() error {
 	return p.db.Close()
@@ -313,23 +412,44 @@ func getPeerInfo(addr string, db leveldb.Reader) (*peerInfo, error) {
 
 // Helper to delete a peer
 func (p *PeerStorageDisk) deletePeer(addr string, lastAttempt, lastSuccess int64) error {
-	peerKey, err := computePeerKey(addr)
-	if err != nil {
+	batch := new(leveldb.Batch)
+	info := peerInfo{LastAttempt: lastAttempt, LastSuccess: lastSuccess}
+	if err := info.deleteFromBatch(addr, batch); err != nil {
 		return err
 	}
-	attemptKey, err := computeLastAttemptTimeKey(lastAttempt, addr)
-	if err != nil {
-		return err
+	return p.db.Write(batch, nil)
+}
+
+// Helper to read every stored peer's info
+func (p *PeerStorageDisk) getAllPeerInfo() (map[string]peerInfo, error) {
+	peers := make(map[string]peerInfo)
+	iter := p.db.NewIterator(util.BytesPrefix([]byte{peerPrefix}), nil)
+	for iter.Next() {
+		var info peerInfo
+		if err := decodePeerInfo(iter.Value(), &info); err != nil {
+			iter.Release()
+			return nil, err
+		}
+		peers[string(iter.Key()[1:])] = info
 	}
-	successKey, err := computeLastSuccessTimeKey(lastSuccess, addr)
-	if err != nil {
-		return err
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
 	}
-	batch := new(leveldb.Batch)
-	batch.Delete(peerKey)
-	batch.Delete(attemptKey)
-	batch.Delete(successKey)
-	return p.db.Write(batch, nil)
+	return peers, nil
+}
+
+// Best first. Ties go to the address we heard of most recently
+func sortPeerRecords(records []PeerRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Score != records[j].Score {
+			return records[i].Score > records[j].Score
+		}
+		if records[i].FirstSeen != records[j].FirstSeen {
+			return records[i].FirstSeen > records[j].FirstSeen
+		}
+		return records[i].Addr < records[j].Addr
+	})
 }
 
 // Helper to return a copy of the connected set
@@ -398,6 +518,53 @@ func (p peerInfo) shouldDelete() bool {
 	return time.Since(lastSuccess) > time.Duration(24*7*time.Hour)
 }
 
+// How much we'd like to keep this peer. 1 for a peer we connected to just now falling
+// with the days since, 0 if we never have
+func (p peerInfo) score(now int64) float64 {
+	if p.LastSuccess == 0 {
+		return 0
+	}
+	days := float64(now-p.LastSuccess) / (24 * 60 * 60)
+	if days < 0 {
+		days = 0
+	}
+	return 1 / (1 + days)
+}
+
+func (p peerInfo) toRecord(addr string, now int64) PeerRecord {
+	record := PeerRecord{
+		Addr:        addr,
+		FirstSeen:   p.FirstSeen,
+		LastSuccess: p.LastSuccess,
+		Score:       p.score(now),
+	}
+	if p.LastAttempt >= 1<<30 {
+		// otherwise it's a random ordering for new peers. see Store
+		record.LastAttempt = p.LastAttempt
+	}
+	return record
+}
+
+// Helper to delete the peer and its indices in a batch
+func (p peerInfo) deleteFromBatch(addr string, batch *leveldb.Batch) error {
+	peerKey, err := computePeerKey(addr)
+	if err != nil {
+		return err
+	}
+	attemptKey, err := computeLastAttemptTimeKey(p.LastAttempt, addr)
+	if err != nil {
+		return err
+	}
+	successKey, err := computeLastSuccessTimeKey(p.LastSuccess, addr)
+	if err != nil {
+		return err
+	}
+	batch.Delete(peerKey)
+	batch.Delete(attemptKey)
+	batch.Delete(successKey)
+	return nil
+}
+
 // Helper to write the peer info to a batch
 func (p peerInfo) writeToBatch(addr string, batch *leveldb.Batch) error {
 	key, err := computePeerKey(addr)
@@ -0,0 +1,107 @@
+package focalpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestPeerStorageDiskPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	peerStore, err := NewPeerStorageDisk(filepath.Join(dir, "peers.db"), false, 30*24*time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerStore.Close()
+
+	// backdate a peer's info
+	set := func(addr string, firstSeen, lastSuccess time.Duration) {
+		if _, err := peerStore.Store(addr); err != nil {
+			t.Fatal(err)
+		}
+		info, err := getPeerInfo(addr, peerStore.db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		info.FirstSeen = now.Add(-firstSeen).Unix()
+		if lastSuccess != 0 {
+			info.LastSuccess = now.Add(-lastSuccess).Unix()
+		}
+		batch := new(leveldb.Batch)
+		if err := info.writeToBatch(addr, batch); err != nil {
+			t.Fatal(err)
+		}
+		if err := peerStore.db.Write(batch, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("1.1.1.1:8832", 100*24*time.Hour, 1*time.Hour)     // recently connected
+	set("2.2.2.2:8832", 100*24*time.Hour, 10*24*time.Hour) // connected a while ago
+	set("3.3.3.3:8832", 1*time.Hour, 0)                    // never connected
+	set("4.4.4.4:8832", 40*24*time.Hour, 0)                // never connected and past retention
+
+	// the old one goes for retention and the never connected one for the limit
+	count, err := peerStore.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 peers pruned, found %d", count)
+	}
+	peers, err := peerStore.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 || peers[0].Addr != "1.1.1.1:8832" || peers[1].Addr != "2.2.2.2:8832" {
+		t.Fatalf("Unexpected peers remaining: %+v", peers)
+	}
+	if peers[0].Score <= peers[1].Score {
+		t.Fatal("Expected the most recently connected peer to score highest")
+	}
+}
+
+func TestPeerStorageDiskSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	peerStore, err := NewPeerStorageDisk(filepath.Join(dir, "peers.db"), false, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerStore.Close()
+
+	count, err := peerStore.Seed([]string{"1.1.1.1:8832", "2.2.2.2:8832", "1.1.1.1:8832"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 peers seeded, found %d", count)
+	}
+
+	// it's only seeded when empty
+	count, err = peerStore.Seed([]string{"3.3.3.3:8832"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("Expected no peers seeded, found %d", count)
+	}
+	peers, err := peerStore.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("Expected 2 peers stored, found %d", len(peers))
+	}
+}
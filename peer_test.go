@@ -0,0 +1,375 @@
+package focalpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestMaxViewMessageLength(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a view of signed considerations with the largest memos, all of which need escaping
+	memo := strings.Repeat("<", MemoPolicyAt(0).MaxLength)
+	var cns []*Consideration
+	for i := 0; i < 100; i++ {
+		cn := NewConsideration(pubKey, pubKey, MAX_NUMBER, MAX_NUMBER, MAX_NUMBER, memo)
+		if err := cn.Sign(privKey); err != nil {
+			t.Fatal(err)
+		}
+		cns = append(cns, cn)
+	}
+	view, err := NewView(ViewID{}, MAX_NUMBER, ViewID{}, ViewID{}, cns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := view.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := json.Marshal(Message{Type: "view", Body: ViewMessage{ViewID: &id, View: view}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	perConsideration := maxViewMessageLength(0) / int64(computeMaxConsiderationsPerView(0)+1)
+	if limit := int64(len(cns)+1) * perConsideration; int64(len(message)) > limit {
+		t.Fatalf("View message of %d bytes exceeds the limit of %d", len(message), limit)
+	}
+}
+
+func TestIsQueryConnection(t *testing.T) {
+	limiter := NewQueryLimiter(time.Second, 1, 1, 1)
+	for i, test := range []struct {
+		peer     *Peer
+		expected bool
+	}{
+		{&Peer{mind: true}, true},                          // a mind
+		{&Peer{}, false},                                   // an older inbound peer without a nonce
+		{&Peer{nonce: "abc"}, false},                       // an inbound relaying peer
+		{&Peer{outbound: true, nonce: "abc"}, false},       // an outbound relaying peer
+		{&Peer{outbound: true}, false},                     // an outbound peer without a nonce
+		{&Peer{nonce: "abc", queryLimiter: limiter}, true}, // a public host
+	} {
+		if test.peer.isQueryConnection() != test.expected {
+			t.Fatalf("Expected %v for peer %d", test.expected, i)
+		}
+	}
+}
+
+func TestChargeHistoricScan(t *testing.T) {
+	// connections without a query limiter have their own budget
+	p := &Peer{scanLimiter: NewQueryLimiter(historicScanPeriod, historicScanBurst, 1, 1)}
+	for i := 0; i < historicScanBurst; i++ {
+		if err := p.chargeHistoricScan("127.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.chargeHistoricScan("127.0.0.1"); err == nil {
+		t.Fatal("Expected the scan budget to run out")
+	}
+	other := &Peer{scanLimiter: NewQueryLimiter(historicScanPeriod, historicScanBurst, 1, 1)}
+	if err := other.chargeHistoricScan("127.0.0.1"); err != nil {
+		t.Fatal("Expected another connection to have its own budget")
+	}
+
+	// public hosts pay from their query quota
+	limiter := NewQueryLimiter(time.Hour, 2*historicScanQueryCost, 1, 1)
+	p = &Peer{queryLimiter: limiter, scanLimiter: NewQueryLimiter(historicScanPeriod, historicScanBurst, 1, 1)}
+	for i := 0; i < 2; i++ {
+		if err := p.chargeHistoricScan("203.0.113.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.chargeHistoricScan("203.0.113.1"); err == nil {
+		t.Fatal("Expected the query quota to run out")
+	}
+	if account := limiter.Account("203.0.113.1"); account.Tokens != 0 {
+		t.Fatalf("Expected no queries left, found %d", account.Tokens)
+	}
+}
+
+func TestOnGetDescendant(t *testing.T) {
+	var pubKeys []ed25519.PublicKey
+	for i := 0; i < 4; i++ {
+		pubKey, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	g := NewGraph()
+	g.Link("0", pubKeyToString(pubKeys[0]), 1) // the root is never considered
+	g.Link(pubKeyToString(pubKeys[0]), pubKeyToString(pubKeys[1]), 1)
+	g.Link(pubKeyToString(pubKeys[1]), pubKeyToString(pubKeys[2]), 1)
+	g.Rank(1.0, 1e-6)
+	idx := NewIndexer(g, nil, nil, nil, ViewID{})
+	conn, _, closeConn := dialTestPeer(t)
+	defer closeConn()
+	p := &Peer{conn: conn, indexer: idx}
+
+	var found bool
+	for _, pair := range [][2]int{{0, 2}, {2, 0}, {0, 3}, {3, 0}} {
+		parent, descendant := pubKeys[pair[0]], pubKeys[pair[1]]
+		outChan := make(chan Message, 1)
+		if err := p.onGetDescendant(parent, descendant, outChan); err != nil {
+			t.Fatal(err)
+		}
+		m := <-outChan
+		if m.Type != "descendant" {
+			t.Fatalf("Expected a descendant message, found %s", m.Type)
+		}
+		d := m.Body.(DescendantMessage)
+		if !bytes.Equal(d.Parent, parent) || !bytes.Equal(d.Descendant, descendant) {
+			t.Fatal("Expected the reply to name the public keys asked about")
+		}
+		if expected := g.IsParentDescendant(pubKeyToString(parent), pubKeyToString(descendant)); d.IsDescendant != expected {
+			t.Fatalf("Expected %v for pair %v, found %v", expected, pair, d.IsDescendant)
+		}
+		found = found || d.IsDescendant
+		if pair[0] == 3 || pair[1] == 3 {
+			if d.IsDescendant {
+				t.Fatal("Expected a key outside the graph not to be a descendant")
+			}
+		}
+	}
+	if !found {
+		t.Fatal("Expected the linked keys to be related")
+	}
+}
+
+func TestPeekMessageType(t *testing.T) {
+	for message, expected := range map[string]string{
+		`{"type":"view","body":{"view":{}}}`:          "view",
+		`{"body":{"type":"other"},"type":"inv_view"}`: "inv_view",
+	} {
+		messageType, err := peekMessageType([]byte(message))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if messageType != expected {
+			t.Fatalf("Expected type %s, found %s", expected, messageType)
+		}
+	}
+	for _, message := range []string{`[]`, `{"body":{}}`, `{"type":`} {
+		if _, err := peekMessageType([]byte(message)); err == nil {
+			t.Fatalf("Expected an error peeking at %s", message)
+		}
+	}
+}
+
+func TestOutstandingWork(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	connect := func(previous ViewID, height int64) (ViewID, *View) {
+		viewpoint := NewConsideration(nil, pubKey, 0, 0, height, "")
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.ConnectView(id, view); err != nil {
+			t.Fatal(err)
+		}
+		return id, view
+	}
+	genesisID, genesis := connect(ViewID{}, 0)
+
+	conn, received, closeConn := dialTestPeer(t)
+	defer closeConn()
+	p := &Peer{
+		conn:            conn,
+		viewStore:       viewStore,
+		ledger:          ledger,
+		cnQueue:         NewConsiderationQueueMemory(ledger, NewGraph()),
+		pubKeys:         []ed25519.PublicKey{pubKey},
+		outstandingWork: make(map[int32]*View),
+	}
+	next := func(messageType string, body interface{}) {
+		select {
+		case m := <-received:
+			if m.Type != messageType {
+				t.Fatalf("Expected %s message, found %s", messageType, m.Type)
+			}
+			b, err := json.Marshal(m.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(b, body); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %s message", messageType)
+		}
+	}
+
+	// only the most recently issued work is remembered
+	var workIDs []int32
+	for i := 0; i < maxOutstandingWork+1; i++ {
+		if err := p.createNewWorkView(genesisID, genesis.Header); err != nil {
+			t.Fatal(err)
+		}
+		var work WorkMessage
+		next("work", &work)
+		if work.WorkID != p.workID || len(work.Error) != 0 {
+			t.Fatalf("Unexpected work message: %+v", work)
+		}
+		workIDs = append(workIDs, work.WorkID)
+	}
+	if len(p.outstandingWork) != maxOutstandingWork || len(p.outstandingWorkIDs) != maxOutstandingWork {
+		t.Fatalf("Expected %d outstanding work views, found %d", maxOutstandingWork, len(p.outstandingWork))
+	}
+	if _, ok := p.outstandingWork[workIDs[0]]; ok {
+		t.Fatal("Expected the oldest work to be forgotten")
+	}
+	for _, workID := range workIDs[1:] {
+		if _, ok := p.outstandingWork[workID]; !ok {
+			t.Fatalf("Expected work ID %d to be outstanding", workID)
+		}
+	}
+
+	// forgotten work is unknown
+	submit := func(workID int32) SubmitWorkResultMessage {
+		p.onSubmitWork(SubmitWorkMessage{WorkID: workID, Header: p.outstandingWork[workIDs[1]].Header})
+		var result SubmitWorkResultMessage
+		next("submit_work_result", &result)
+		if result.WorkID != workID {
+			t.Fatalf("Expected result for work ID %d, found %d", workID, result.WorkID)
+		}
+		return result
+	}
+	if result := submit(workIDs[0]); result.Code != UnknownWorkCode {
+		t.Fatalf("Expected code %s, found %s: %s", UnknownWorkCode, result.Code, result.Error)
+	}
+
+	// outstanding work which no longer builds on the tip is stale
+	connect(genesisID, 1)
+	for _, workID := range []int32{workIDs[1], workIDs[maxOutstandingWork]} {
+		if result := submit(workID); result.Code != StaleWorkCode {
+			t.Fatalf("Expected code %s, found %s: %s", StaleWorkCode, result.Code, result.Error)
+		}
+	}
+}
+
+func TestOnGetPublicKeyConsiderationIDs(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	// every view is rendered to the key
+	var previous ViewID
+	var expect []PublicKeyConsiderationID
+	for height := int64(0); height < 4; height++ {
+		viewpoint := NewConsideration(nil, pubKey, 0, 0, height, "")
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.ConnectView(id, view); err != nil {
+			t.Fatal(err)
+		}
+		cnID, err := viewpoint.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect = append(expect, PublicKeyConsiderationID{ConsiderationID: cnID, ViewID: id, Height: height})
+		previous = id
+	}
+
+	conn, _, closeConn := dialTestPeer(t)
+	defer closeConn()
+	p := &Peer{conn: conn, ledger: ledger, viewStore: viewStore}
+	checkIDs := func(what string) {
+		outChan := make(chan Message, 1)
+		if err := p.onGetPublicKeyConsiderations(pubKey, 0, 3, 0, 0, true, "127.0.0.1", outChan); err != nil {
+			t.Fatal(err)
+		}
+		pkc := (<-outChan).Body.(PublicKeyConsiderationsMessage)
+		if len(pkc.FilterViewes) != 0 {
+			t.Fatalf("%s: expected no considerations, found %d view(s)", what, len(pkc.FilterViewes))
+		}
+		if !reflect.DeepEqual(pkc.ConsiderationIDs, expect) {
+			t.Fatalf("%s: expected %v, found %v", what, expect, pkc.ConsiderationIDs)
+		}
+	}
+	checkIDs("indexed")
+
+	// entries indexed before IDs were recorded are read from the view
+	key, err := computePubKeyConsiderationIndexKey(pubKey, &expect[1].Height, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.db.Put(key, []byte{0x1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if cnID, err := ledger.GetPublicKeyConsiderationID(pubKey, expect[1].Height, 0); err != nil || cnID != nil {
+		t.Fatalf("Expected no ID for an old index entry, found %v, error: %v", cnID, err)
+	}
+	checkIDs("old index entry")
+
+	// otherwise the views aren't read at all
+	if err := ledger.db.Put(key, expect[1].ConsiderationID[:], nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "views")); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs("views removed")
+}
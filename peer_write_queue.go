@@ -0,0 +1,150 @@
+package focalpoint
+
+import "container/heap"
+
+// Priorities of messages waiting to be written to a peer, most urgent first
+const (
+	// views, headers, work and the requests which sync them. delays slow the point down
+	writePriorityView = iota
+	// relayed considerations, ordered by their sender's ranking
+	writePriorityConsideration
+	// everything else, including bulk history responses
+	writePriorityResponse
+	numWritePriorities
+)
+
+const (
+	// Relayed considerations allowed to wait for a peer. Past it the lowest ranked are dropped
+	maxQueuedRelays = slowPeerBacklog
+
+	// Responses allowed to wait for a peer. Past it the reader waits for the writer to catch up
+	maxQueuedResponses = 8
+)
+
+// A peerWriteQueue orders the messages waiting to be written to a peer when writes fall behind,
+// so views and highly ranked senders' considerations aren't stuck behind bulk history responses.
+// It's only used from the peer's writer goroutine.
+type peerWriteQueue struct {
+	items  peerWriteItems
+	seq    int64
+	counts [numWritePriorities]int
+}
+
+type peerWriteItem struct {
+	message  Message
+	priority int
+	ranking  float64 // of a relayed consideration's sender
+	seq      int64   // messages which are otherwise equal are written in the order queued
+	index    int
+}
+
+// Returns the priority of a message the reader sends to the peer
+func writePriorityOf(messageType string) int {
+	switch messageType {
+	case "view", "inv_view", "view_header", "tip_header", "get_view", "find_common_ancestor",
+		"work", "submit_work_result", "checkpoint":
+		return writePriorityView
+	case "push_consideration":
+		return writePriorityConsideration
+	default:
+		return writePriorityResponse
+	}
+}
+
+// Queue a message to be written
+func (q *peerWriteQueue) push(m Message, priority int, ranking float64) {
+	q.seq++
+	heap.Push(&q.items, &peerWriteItem{message: m, priority: priority, ranking: ranking, seq: q.seq})
+	q.counts[priority]++
+}
+
+// Remove and return the most urgent message
+func (q *peerWriteQueue) pop() (Message, bool) {
+	if len(q.items) == 0 {
+		return Message{}, false
+	}
+	item := heap.Pop(&q.items).(*peerWriteItem)
+	q.counts[item.priority]--
+	return item.message, true
+}
+
+// Returns the number of waiting messages
+func (q *peerWriteQueue) len() int {
+	return len(q.items)
+}
+
+// Returns the number of waiting messages with the given priority
+func (q *peerWriteQueue) count(priority int) int {
+	return q.counts[priority]
+}
+
+// Make room for a relayed consideration from a sender with the given ranking by dropping the
+// lowest ranked relay waiting, the newest of them if there's a tie. Returns false if none rank
+// below it, in which case it's the one to drop
+func (q *peerWriteQueue) dropRelayBelow(ranking float64) bool {
+	var lowest *peerWriteItem
+	for _, item := range q.items {
+		if item.priority != writePriorityConsideration {
+			continue
+		}
+		if lowest == nil || item.ranking < lowest.ranking ||
+			(item.ranking == lowest.ranking && item.seq > lowest.seq) {
+			lowest = item
+		}
+	}
+	if lowest == nil || lowest.ranking >= ranking {
+		return false
+	}
+	heap.Remove(&q.items, lowest.index)
+	q.counts[lowest.priority]--
+	return true
+}
+
+// peerWriteItems implements heap.Interface
+type peerWriteItems []*peerWriteItem
+
+func (items peerWriteItems) Len() int { return len(items) }
+
+func (items peerWriteItems) Less(i, j int) bool {
+	if items[i].priority != items[j].priority {
+		return items[i].priority < items[j].priority
+	}
+	if items[i].ranking != items[j].ranking {
+		return items[i].ranking > items[j].ranking
+	}
+	return items[i].seq < items[j].seq
+}
+
+func (items peerWriteItems) Swap(i, j int) {
+	items[i], items[j] = items[j], items[i]
+	items[i].index = i
+	items[j].index = j
+}
+
+func (items *peerWriteItems) Push(x interface{}) {
+	item := x.(*peerWriteItem)
+	item.index = len(*items)
+	*items = append(*items, item)
+}
+
+func (items *peerWriteItems) Pop() interface{} {
+	old := *items
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*items = old[:n-1]
+	return item
+}
+
+// Returns the sender's ranking used to order relayed considerations, 0 if it isn't known
+func (p *Peer) senderRanking(cn *Consideration) float64 {
+	if p.indexer == nil {
+		return 0
+	}
+	snapshot := p.indexer.GraphSnapshot()
+	if snapshot == nil {
+		return 0
+	}
+	ranking, _ := snapshot.Ranking(cn.By)
+	return ranking
+}
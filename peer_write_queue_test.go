@@ -0,0 +1,46 @@
+package focalpoint
+
+import "testing"
+
+func TestPeerWriteQueue(t *testing.T) {
+	var q peerWriteQueue
+	q.push(Message{Type: "public_key_considerations"}, writePriorityOf("public_key_considerations"), 0)
+	q.push(Message{Type: "low"}, writePriorityConsideration, 0.1)
+	q.push(Message{Type: "imbalance"}, writePriorityOf("imbalance"), 0)
+	q.push(Message{Type: "high"}, writePriorityConsideration, 0.9)
+	q.push(Message{Type: "view"}, writePriorityOf("view"), 0)
+	q.push(Message{Type: "also low"}, writePriorityConsideration, 0.1)
+	if q.count(writePriorityConsideration) != 3 || q.count(writePriorityResponse) != 2 {
+		t.Fatal("Unexpected counts")
+	}
+
+	// the newest of the lowest ranked is dropped first
+	if q.dropRelayBelow(0.1) {
+		t.Fatal("Expected nothing to rank below the lowest")
+	}
+	if !q.dropRelayBelow(0.5) {
+		t.Fatal("Expected a lower ranked relay to be dropped")
+	}
+	if q.count(writePriorityConsideration) != 2 {
+		t.Fatal("Expected the dropped relay to be uncounted")
+	}
+
+	expected := []string{"view", "high", "low", "public_key_considerations", "imbalance"}
+	for _, messageType := range expected {
+		m, ok := q.pop()
+		if !ok {
+			t.Fatalf("Expected %s, queue is empty", messageType)
+		}
+		if m.Type != messageType {
+			t.Fatalf("Expected %s, got %s", messageType, m.Type)
+		}
+	}
+	if _, ok := q.pop(); ok || q.len() != 0 {
+		t.Fatal("Expected the queue to be empty")
+	}
+	for priority := 0; priority < numWritePriorities; priority++ {
+		if q.count(priority) != 0 {
+			t.Fatalf("Expected no messages with priority %d", priority)
+		}
+	}
+}
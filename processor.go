@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
@@ -17,19 +18,24 @@ import (
 // Processor processes views and considerations in order to construct the ledger.
 // It also manages the storage of all focal point data as well as inclusion of new considerations into the consideration queue.
 type Processor struct {
+	droppedNewTxs           int64 // new consideration notifications dropped for slow subscribers, first for atomic alignment
 	genesisID               ViewID
-	viewStore               ViewStorage                   // storage of raw view data
-	cnQueue                 ConsiderationQueue           // queue of considerations to confirm
-	ledger                  Ledger                        // ledger built from processing views
-	cnChan                  chan cnToProcess              // receive new considerations to process on this channel
-	viewChan                chan viewToProcess            // receive new views to process on this channel
-	registerNewTxChan       chan chan<- NewTx             // receive registration requests for new consideration notifications
-	unregisterNewTxChan     chan chan<- NewTx             // receive unregistration requests for new consideration notifications
-	registerTipChangeChan   chan chan<- TipChange         // receive registration requests for tip change notifications
-	unregisterTipChangeChan chan chan<- TipChange         // receive unregistration requests for tip change notifications
-	newTxChannels           map[chan<- NewTx]struct{}     // channels needing notification of newly processed considerations
-	tipChangeChannels       map[chan<- TipChange]struct{} // channels needing notification of changes to main point tip views
+	viewStore               ViewStorage                                  // storage of raw view data
+	headerIndex             *HeaderIndex                                 // recent main point headers
+	cnQueue                 ConsiderationQueue                           // queue of considerations to confirm
+	ledger                  Ledger                                       // ledger built from processing views
+	cnChan                  chan cnToProcess                             // receive new considerations to process on this channel
+	viewChan                chan viewToProcess                           // receive new views to process on this channel
+	invalidateChan          chan viewToInvalidate                        // receive operator requests to invalidate or reconsider views on this channel
+	registerNewTxChan       chan chan<- NewTx                            // receive registration requests for new consideration notifications
+	unregisterNewTxChan     chan chan<- NewTx                            // receive unregistration requests for new consideration notifications
+	registerTipChangeChan   chan chan<- TipChange                        // receive registration requests for tip change notifications
+	unregisterTipChangeChan chan chan<- TipChange                        // receive unregistration requests for tip change notifications
+	newTxChannels           map[chan<- NewTx]*notificationSubscriber     // channels needing notification of newly processed considerations
+	tipChangeChannels       map[chan<- TipChange]*notificationSubscriber // channels needing notification of changes to main point tip views
+	subscriberLock          sync.RWMutex                                 // guards the channel maps for NotificationStats
 	shutdownChan            chan struct{}
+	doneChan                chan struct{} // closed once the main loop has exited
 	wg                      sync.WaitGroup
 }
 
@@ -49,6 +55,13 @@ type TipChange struct {
 	More    bool    // true if the tip has been connected and more connections are expected
 }
 
+type viewToInvalidate struct {
+	id         ViewID       // view ID
+	reconsider bool         // true to clear the invalid mark instead
+	source     string       // who asked
+	resultChan chan<- error // channel to receive the result
+}
+
 type cnToProcess struct {
 	id         ConsiderationID // consideration ID
 	cn         *Consideration  // consideration to process
@@ -68,17 +81,20 @@ func NewProcessor(genesisID ViewID, viewStore ViewStorage, cnQueue Consideration
 	return &Processor{
 		genesisID:               genesisID,
 		viewStore:               viewStore,
+		headerIndex:             NewHeaderIndex(viewStore, ledger),
 		cnQueue:                 cnQueue,
 		ledger:                  ledger,
 		cnChan:                  make(chan cnToProcess, 100),
 		viewChan:                make(chan viewToProcess, 10),
+		invalidateChan:          make(chan viewToInvalidate),
 		registerNewTxChan:       make(chan chan<- NewTx),
 		unregisterNewTxChan:     make(chan chan<- NewTx),
 		registerTipChangeChan:   make(chan chan<- TipChange),
 		unregisterTipChangeChan: make(chan chan<- TipChange),
-		newTxChannels:           make(map[chan<- NewTx]struct{}),
-		tipChangeChannels:       make(map[chan<- TipChange]struct{}),
+		newTxChannels:           make(map[chan<- NewTx]*notificationSubscriber),
+		tipChangeChannels:       make(map[chan<- TipChange]*notificationSubscriber),
 		shutdownChan:            make(chan struct{}),
+		doneChan:                make(chan struct{}),
 	}
 }
 
@@ -91,6 +107,7 @@ func (p *Processor) Run() {
 
 func (p *Processor) run() {
 	defer p.wg.Done()
+	defer close(p.doneChan)
 
 	for {
 		select {
@@ -121,59 +138,268 @@ func (p *Processor) run() {
 			// send back the result
 			viewToProcess.resultChan <- err
 
+		case toInvalidate := <-p.invalidateChan:
+			var err error
+			if toInvalidate.reconsider {
+				err = p.reconsiderView(toInvalidate.id, toInvalidate.source)
+			} else {
+				err = p.invalidateView(toInvalidate.id, toInvalidate.source)
+			}
+			if err != nil {
+				log.Println(err)
+			}
+			toInvalidate.resultChan <- err
+
 		case ch := <-p.registerNewTxChan:
-			p.newTxChannels[ch] = struct{}{}
+			p.subscriberLock.Lock()
+			if _, ok := p.newTxChannels[ch]; !ok {
+				p.newTxChannels[ch] = newNewTxSubscriber(ch, maxPendingNewTxs)
+			}
+			p.subscriberLock.Unlock()
 
 		case ch := <-p.unregisterNewTxChan:
-			delete(p.newTxChannels, ch)
+			p.subscriberLock.Lock()
+			if s, ok := p.newTxChannels[ch]; ok {
+				s.stop()
+				delete(p.newTxChannels, ch)
+			}
+			p.subscriberLock.Unlock()
 
 		case ch := <-p.registerTipChangeChan:
-			p.tipChangeChannels[ch] = struct{}{}
+			p.subscriberLock.Lock()
+			if _, ok := p.tipChangeChannels[ch]; !ok {
+				p.tipChangeChannels[ch] = newTipChangeSubscriber(ch, maxPendingTipChanges)
+			}
+			p.subscriberLock.Unlock()
 
 		case ch := <-p.unregisterTipChangeChan:
-			delete(p.tipChangeChannels, ch)
+			p.subscriberLock.Lock()
+			if s, ok := p.tipChangeChannels[ch]; ok {
+				s.stop()
+				delete(p.tipChangeChannels, ch)
+			}
+			p.subscriberLock.Unlock()
 
 		case _, ok := <-p.shutdownChan:
 			if !ok {
 				log.Println("Processor shutting down...")
+				p.drain()
+				p.stopSubscribers()
 				return
 			}
 		}
 	}
 }
 
+// Abort any requests which were queued behind the one in progress when shutdown began
+func (p *Processor) drain() {
+	err := fmt.Errorf("Processor is shutting down")
+	for {
+		select {
+		case cnToProcess := <-p.cnChan:
+			log.Printf("Aborted processing consideration %s\n", cnToProcess.id)
+			cnToProcess.resultChan <- err
+		case viewToProcess := <-p.viewChan:
+			log.Printf("Aborted processing view %s\n", viewToProcess.id)
+			viewToProcess.resultChan <- err
+		case toInvalidate := <-p.invalidateChan:
+			toInvalidate.resultChan <- err
+		default:
+			return
+		}
+	}
+}
+
+// Queue a tip change for every registered channel. Each is delivered from the subscriber's own
+// goroutine so a slow one only falls behind itself. Tip changes are never dropped, subscribers
+// apply them in order and would diverge from the point if one went missing. A subscriber which
+// falls too far behind is unregistered and its channel closed instead
+func (p *Processor) notifyTipChange(tip TipChange) {
+	var behind []chan<- TipChange
+	p.subscriberLock.RLock()
+	for ch, s := range p.tipChangeChannels {
+		if _, full := s.notify(tip); full {
+			behind = append(behind, ch)
+		}
+	}
+	p.subscriberLock.RUnlock()
+	if len(behind) == 0 {
+		return
+	}
+
+	p.subscriberLock.Lock()
+	defer p.subscriberLock.Unlock()
+	for _, ch := range behind {
+		log.Printf("Tip change subscriber is %d behind, cutting it off\n", maxPendingTipChanges)
+		p.tipChangeChannels[ch].cutOff()
+		delete(p.tipChangeChannels, ch)
+	}
+}
+
+// Queue a new consideration for every registered channel. Unlike tip changes the oldest are
+// dropped if a subscriber falls too far behind
+func (p *Processor) notifyNewTx(newTx NewTx) {
+	p.subscriberLock.RLock()
+	defer p.subscriberLock.RUnlock()
+	for _, s := range p.newTxChannels {
+		if dropped, _ := s.notify(newTx); dropped != 0 {
+			atomic.AddInt64(&p.droppedNewTxs, 1)
+			if dropped%1000 == 1 {
+				log.Printf("New consideration subscriber is more than %d behind, dropped %d notification(s)\n",
+					maxPendingNewTxs, dropped)
+			}
+		}
+	}
+}
+
+// Stop delivering to any channels still registered at shutdown
+func (p *Processor) stopSubscribers() {
+	p.subscriberLock.Lock()
+	defer p.subscriberLock.Unlock()
+	for ch, s := range p.newTxChannels {
+		s.stop()
+		delete(p.newTxChannels, ch)
+	}
+	for ch, s := range p.tipChangeChannels {
+		s.stop()
+		delete(p.tipChangeChannels, ch)
+	}
+}
+
+// Returns the number of notifications queued for the channels which haven't been delivered yet.
+// It's safe to call from any goroutine
+func (p *Processor) pendingNotifications(tipChangeChan chan<- TipChange, newTxChan chan<- NewTx) int {
+	p.subscriberLock.RLock()
+	defer p.subscriberLock.RUnlock()
+	var pending int
+	if s, ok := p.tipChangeChannels[tipChangeChan]; ok {
+		n, _ := s.lag()
+		pending += n
+	}
+	if s, ok := p.newTxChannels[newTxChan]; ok {
+		n, _ := s.lag()
+		pending += n
+	}
+	return pending
+}
+
+// NotificationStats returns how far behind the subscribers to tip change and new consideration
+// notifications are. It's safe to call from any goroutine.
+func (p *Processor) NotificationStats() (tipChange, newTx NotificationStats) {
+	p.subscriberLock.RLock()
+	defer p.subscriberLock.RUnlock()
+	tipChange.Subscribers = len(p.tipChangeChannels)
+	for _, s := range p.tipChangeChannels {
+		pending, lag := s.lag()
+		tipChange.Pending += pending
+		if lag > tipChange.MaxLag {
+			tipChange.MaxLag = lag
+		}
+	}
+	newTx.Subscribers = len(p.newTxChannels)
+	for _, s := range p.newTxChannels {
+		pending, lag := s.lag()
+		newTx.Pending += pending
+		if lag > newTx.MaxLag {
+			newTx.MaxLag = lag
+		}
+	}
+	newTx.Dropped = atomic.LoadInt64(&p.droppedNewTxs)
+	return
+}
+
 // ProcessConsideration is called to process a new candidate consideration for the consideration queue.
 func (p *Processor) ProcessConsideration(id ConsiderationID, cn *Consideration, from string) error {
-	resultChan := make(chan error)
-	p.cnChan <- cnToProcess{id: id, cn: cn, source: from, resultChan: resultChan}
-	return <-resultChan
+	resultChan := make(chan error, 1)
+	select {
+	case p.cnChan <- cnToProcess{id: id, cn: cn, source: from, resultChan: resultChan}:
+	case <-p.shutdownChan:
+		return fmt.Errorf("Processor is shutting down")
+	}
+	return p.waitForResult(resultChan)
 }
 
 // ProcessView is called to process a new candidate focal point tip.
 func (p *Processor) ProcessView(id ViewID, view *View, from string) error {
-	resultChan := make(chan error)
-	p.viewChan <- viewToProcess{id: id, view: view, source: from, resultChan: resultChan}
-	return <-resultChan
+	resultChan := make(chan error, 1)
+	select {
+	case p.viewChan <- viewToProcess{id: id, view: view, source: from, resultChan: resultChan}:
+	case <-p.shutdownChan:
+		return fmt.Errorf("Processor is shutting down")
+	}
+	return p.waitForResult(resultChan)
+}
+
+// InvalidateView is called by the operator to treat a view and every view built on it as invalid.
+// If the view is on the main point the node reorganizes to the best point without it.
+func (p *Processor) InvalidateView(id ViewID, from string) error {
+	return p.requestInvalidate(viewToInvalidate{id: id, source: from})
+}
+
+// ReconsiderView is called by the operator to clear the invalid mark set by InvalidateView on a view,
+// its ancestors and its descendants. The node reorganizes to the best point if it's changed.
+func (p *Processor) ReconsiderView(id ViewID, from string) error {
+	return p.requestInvalidate(viewToInvalidate{id: id, reconsider: true, source: from})
+}
+
+func (p *Processor) requestInvalidate(request viewToInvalidate) error {
+	resultChan := make(chan error, 1)
+	request.resultChan = resultChan
+	select {
+	case p.invalidateChan <- request:
+	case <-p.shutdownChan:
+		return fmt.Errorf("Processor is shutting down")
+	}
+	return p.waitForResult(resultChan)
+}
+
+// Wait for the result of a queued request. Requests queued too late to be drained are aborted
+func (p *Processor) waitForResult(resultChan <-chan error) error {
+	select {
+	case err := <-resultChan:
+		return err
+	case <-p.doneChan:
+		select {
+		case err := <-resultChan:
+			return err
+		default:
+			return fmt.Errorf("Processor is shutting down")
+		}
+	}
 }
 
 // RegisterForNewConsiderations is called to register to receive notifications of newly queued considerations.
+// If the channel's reader falls too far behind the oldest undelivered notifications are dropped.
 func (p *Processor) RegisterForNewConsiderations(ch chan<- NewTx) {
-	p.registerNewTxChan <- ch
+	select {
+	case p.registerNewTxChan <- ch:
+	case <-p.shutdownChan:
+	}
 }
 
 // UnregisterForNewConsiderations is called to unregister to receive notifications of newly queued considerations
 func (p *Processor) UnregisterForNewConsiderations(ch chan<- NewTx) {
-	p.unregisterNewTxChan <- ch
+	select {
+	case p.unregisterNewTxChan <- ch:
+	case <-p.shutdownChan:
+	}
 }
 
 // RegisterForTipChange is called to register to receive notifications of tip view changes.
+// Every notification is delivered in order however far behind the channel's reader falls.
 func (p *Processor) RegisterForTipChange(ch chan<- TipChange) {
-	p.registerTipChangeChan <- ch
+	select {
+	case p.registerTipChangeChan <- ch:
+	case <-p.shutdownChan:
+	}
 }
 
 // UnregisterForTipChange is called to unregister to receive notifications of tip view changes.
 func (p *Processor) UnregisterForTipChange(ch chan<- TipChange) {
-	p.unregisterTipChangeChan <- ch
+	select {
+	case p.unregisterTipChangeChan <- ch:
+	case <-p.shutdownChan:
+	}
 }
 
 // Shutdown stops the processor synchronously.
@@ -199,7 +425,8 @@ func (p *Processor) processConsideration(id ConsiderationID, cn *Consideration,
 
 	// is the queue full?
 	if p.cnQueue.Len() >= MAX_CONSIDERATION_QUEUE_LENGTH {
-		return fmt.Errorf("No room for consideration %s, queue is full", id)
+		return considerationRejection{QueueFullCode,
+			fmt.Errorf("No room for consideration %s, queue is full", id)}
 	}
 
 	// is it confirmed already?
@@ -219,34 +446,12 @@ func (p *Processor) processConsideration(id ConsiderationID, cn *Consideration,
 	if tipID == nil {
 		return fmt.Errorf("No main point tip id found")
 	}
-
-	// is the series current for inclusion in the next view?
-	if !checkConsiderationSeries(cn, tipHeight+1) {
-		return fmt.Errorf("Consideration %s would have invalid series", id)
-	}
-
-	// would it be mature if included in the next view?
-	if !cn.IsMature(tipHeight + 1) {
-		return fmt.Errorf("Consideration %s would not be mature", id)
-	}
-
-	// is it expired if included in the next view?
-	if cn.IsExpired(tipHeight + 1) {
-		return fmt.Errorf("Consideration %s is expired, height: %d, expires: %d",
-			id, tipHeight, cn.Expires)
-	}
-
-	// verify signature
-	ok, err := cn.Verify()
-	if err != nil {
+	if err := checkConsiderationForNextView(id, cn, tipHeight); err != nil {
 		return err
 	}
-	if !ok {
-		return fmt.Errorf("Signature verification failed for %s", id)
-	}
 
 	// rejects a consideration if sender would have insufficient imbalance
-	ok, err = p.cnQueue.Add(id, cn)
+	ok, err := p.cnQueue.Add(id, cn)
 	if err != nil {
 		return err
 	}
@@ -256,9 +461,7 @@ func (p *Processor) processConsideration(id ConsiderationID, cn *Consideration,
 	}
 
 	// notify channels
-	for ch := range p.newTxChannels {
-		ch <- NewTx{ConsiderationID: id, Consideration: cn, Source: source}
-	}
+	p.notifyNewTx(NewTx{ConsiderationID: id, Consideration: cn, Source: source})
 	return nil
 }
 
@@ -318,11 +521,6 @@ func checkConsideration(id ConsiderationID, cn *Consideration) error {
 		return fmt.Errorf("Consideration %s memo contains invalid utf8 characters", id)
 	}
 
-	// check memo length
-	if len(cn.Memo) > MAX_MEMO_LENGTH {
-		return fmt.Errorf("Consideration %s memo length exceeded", id)
-	}
-
 	// sanity check maturity, expiration and series
 	if cn.Matures < 0 || cn.Matures > MAX_NUMBER {
 		return fmt.Errorf("Invalid maturity, consideration: %s", id)
@@ -337,6 +535,52 @@ func checkConsideration(id ConsiderationID, cn *Consideration) error {
 	return nil
 }
 
+// Check a consideration could be included in the view following the tip at the given height
+func checkConsiderationForNextView(id ConsiderationID, cn *Consideration, tipHeight int64) error {
+	// is the series current for inclusion in the next view?
+	if !checkConsiderationSeries(cn, tipHeight+1) {
+		return fmt.Errorf("Consideration %s would have invalid series", id)
+	}
+
+	// would the memo be allowed in the next view?
+	if err := MemoPolicyAt(tipHeight + 1).Check(cn.Memo); err != nil {
+		return fmt.Errorf("Consideration %s has an invalid memo: %s", id, err)
+	}
+
+	// would it be mature if included in the next view?
+	if !cn.IsMature(tipHeight + 1) {
+		return considerationRejection{ImmatureCode,
+			fmt.Errorf("Consideration %s would not be mature", id)}
+	}
+
+	// is it expired if included in the next view?
+	if cn.IsExpired(tipHeight + 1) {
+		return considerationRejection{ExpiredCode,
+			fmt.Errorf("Consideration %s is expired, height: %d, expires: %d", id, tipHeight, cn.Expires)}
+	}
+
+	// verify signature
+	ok, err := cn.Verify()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Signature verification failed for %s", id)
+	}
+	return nil
+}
+
+// A considerationRejection is an error rejecting a consideration for a reason the sender can act on.
+// The code is one of the PushConsiderationResultMessage reason codes
+type considerationRejection struct {
+	code string
+	err  error
+}
+
+func (r considerationRejection) Error() string {
+	return r.err.Error()
+}
+
 // The series must be within the acceptable range given the current height
 func checkConsiderationSeries(cn *Consideration, height int64) bool {	 
 	if cn.IsViewpoint() {
@@ -344,13 +588,7 @@ func checkConsiderationSeries(cn *Consideration, height int64) bool {
 		return cn.Series == height/VIEWS_UNTIL_NEW_SERIES+1
 	}
 
-	// user considerations have a grace period (1 full series) to mitigate effects
-	// of any potential queueing delay and/or reorgs near series switchover time
-	high := height/VIEWS_UNTIL_NEW_SERIES + 1
-	low := high - 1
-	if low == 0 {
-		low = 1
-	}
+	low, high := ValidSeries(height)
 	return cn.Series >= low && cn.Series <= high
 }
 
@@ -380,6 +618,16 @@ func (p *Processor) processView(id ViewID, view *View, source string) error {
 	if err != nil {
 		return err
 	}
+	if branchType == INVALID {
+		// keep it in case the operator reconsiders its parent
+		if err := p.viewStore.Store(id, view, now); err != nil {
+			return err
+		}
+		if err := p.ledger.SetBranchType(id, INVALID); err != nil {
+			return err
+		}
+		return fmt.Errorf("View %s builds on invalid view %s", id, view.Header.Previous)
+	}
 	if branchType != MAIN && branchType != SIDE {
 		if id == p.genesisID {
 			// store it
@@ -524,7 +772,7 @@ func computeMaxConsiderationsPerView(height int64) int {
 
 // Attempt to extend the point with the new view
 func (p *Processor) acceptView(id ViewID, view *View, now int64, source string) error {
-	prevHeader, _, err := p.viewStore.GetViewHeader(view.Header.Previous)
+	prevHeader, _, err := p.headerIndex.GetViewHeader(view.Header.Previous)
 	if err != nil {
 		return err
 	}
@@ -547,7 +795,7 @@ func (p *Processor) acceptView(id ViewID, view *View, now int64, source string)
 	}
 
 	// check declared proof of work is correct
-	target, err := computeTarget(prevHeader, p.viewStore, p.ledger)
+	target, err := ComputeTarget(prevHeader, p.headerIndex, p.headerIndex)
 	if err != nil {
 		return err
 	}
@@ -564,7 +812,7 @@ func (p *Processor) acceptView(id ViewID, view *View, now int64, source string)
 	}
 
 	// check that the timestamp isn't too far in the past
-	medianTimestamp, err := computeMedianTimestamp(prevHeader, p.viewStore)
+	medianTimestamp, err := ComputeMedianTimestamp(prevHeader, p.headerIndex)
 	if err != nil {
 		return err
 	}
@@ -572,7 +820,40 @@ func (p *Processor) acceptView(id ViewID, view *View, now int64, source string)
 		return fmt.Errorf("Timestamp is too early for view %s", id)
 	}
 
-	// check series, maturity, expiration then verify signatures
+	// check series, maturity, expiration then verify signatures.
+	// if it's in the queue with the same signature we've verified it already
+	if err := checkViewConsiderations(view, p.cnQueue.ExistsSigned); err != nil {
+		return err
+	}
+
+	// store the view if we think we're going to accept it
+	if err := p.viewStore.Store(id, view, now); err != nil {
+		return err
+	}
+
+	// get the current tip before we try adjusting the point
+	tipID, _, err := p.ledger.GetPointTip()
+	if err != nil {
+		return err
+	}
+
+	// finish accepting the view if possible
+	if err := p.acceptViewContinue(id, view, now, prevHeader, source); err != nil {
+		// we may have disconnected the old best point and partially
+		// connected the new one before encountering a problem. re-activate it now
+		if err2 := p.reconnectTip(*tipID, source); err2 != nil {
+			log.Printf("Error reconnecting tip: %s, view: %s\n", err2, *tipID)
+		}
+		// return the original error
+		return err
+	}
+
+	return nil
+}
+
+// Check the view's considerations are valid at its height. Signatures are verified unless
+// the verified function reports they have been already
+func checkViewConsiderations(view *View, verified func(ConsiderationID, Signature) bool) error {
 	for _, cn := range view.Considerations {
 		cnID, err := cn.ID()
 		if err != nil {
@@ -581,6 +862,9 @@ func (p *Processor) acceptView(id ViewID, view *View, now int64, source string)
 		if !checkConsiderationSeries(cn, view.Header.Height) {
 			return fmt.Errorf("Consideration %s would have invalid series", cnID)
 		}
+		if err := MemoPolicyAt(view.Header.Height).Check(cn.Memo); err != nil {
+			return fmt.Errorf("Consideration %s has an invalid memo: %s", cnID, err)
+		}
 		if !cn.IsViewpoint() {
 			if !cn.IsMature(view.Header.Height) {
 				return fmt.Errorf("Consideration %s is immature", cnID)
@@ -588,8 +872,7 @@ func (p *Processor) acceptView(id ViewID, view *View, now int64, source string)
 			if cn.IsExpired(view.Header.Height) {
 				return fmt.Errorf("Consideration %s is expired", cnID)
 			}
-			// if it's in the queue with the same signature we've verified it already
-			if !p.cnQueue.ExistsSigned(cnID, cn.Signature) {
+			if verified == nil || !verified(cnID, cn.Signature) {
 				ok, err := cn.Verify()
 				if err != nil {
 					return err
@@ -600,34 +883,11 @@ func (p *Processor) acceptView(id ViewID, view *View, now int64, source string)
 			}
 		}
 	}
-
-	// store the view if we think we're going to accept it
-	if err := p.viewStore.Store(id, view, now); err != nil {
-		return err
-	}
-
-	// get the current tip before we try adjusting the point
-	tipID, _, err := p.ledger.GetPointTip()
-	if err != nil {
-		return err
-	}
-
-	// finish accepting the view if possible
-	if err := p.acceptViewContinue(id, view, now, prevHeader, source); err != nil {
-		// we may have disconnected the old best point and partially
-		// connected the new one before encountering a problem. re-activate it now
-		if err2 := p.reconnectTip(*tipID, source); err2 != nil {
-			log.Printf("Error reconnecting tip: %s, view: %s\n", err2, *tipID)
-		}
-		// return the original error
-		return err
-	}
-
 	return nil
 }
 
-// Compute expected target of the current view
-func computeTarget(prevHeader *ViewHeader, viewStore ViewStorage, ledger Ledger) (ViewID, error) {
+// ComputeTarget returns the target a view following prevHeader must have.
+func ComputeTarget(prevHeader *ViewHeader, viewStore viewHeaderSource, ledger viewIDForHeightSource) (ViewID, error) {
 	if prevHeader.Height >= BITCOIN_CASH_RETARGET_ALGORITHM_HEIGHT {
 		return computeTargetBitcoinCash(prevHeader, viewStore, ledger)
 	}
@@ -635,7 +895,7 @@ func computeTarget(prevHeader *ViewHeader, viewStore ViewStorage, ledger Ledger)
 }
 
 // Original target computation
-func computeTargetBitcoin(prevHeader *ViewHeader, viewStore ViewStorage) (ViewID, error) {
+func computeTargetBitcoin(prevHeader *ViewHeader, viewStore viewHeaderSource) (ViewID, error) {
 	if (prevHeader.Height+1)%RETARGET_INTERVAL != 0 {
 		// not 2016th view, use previous view's value
 		return prevHeader.Target, nil
@@ -693,7 +953,7 @@ func computeTargetBitcoin(prevHeader *ViewHeader, viewStore ViewStorage) (ViewID
 }
 
 // Revised target computation
-func computeTargetBitcoinCash(prevHeader *ViewHeader, viewStore ViewStorage, ledger Ledger) (
+func computeTargetBitcoinCash(prevHeader *ViewHeader, viewStore viewHeaderSource, ledger viewIDForHeightSource) (
 	targetID ViewID, err error) {
 
 	firstID, err := ledger.GetViewIDForHeight(prevHeader.Height - RETARGET_SMA_WINDOW)
@@ -739,8 +999,9 @@ func computeTargetBitcoinCash(prevHeader *ViewHeader, viewStore ViewStorage, led
 	return
 }
 
-// Compute the median timestamp of the last NUM_VIEWS_FOR_MEDIAN_TIMESTAMP views
-func computeMedianTimestamp(prevHeader *ViewHeader, viewStore ViewStorage) (int64, error) {
+// ComputeMedianTimestamp returns the median timestamp of the last NUM_VIEWS_FOR_MEDIAN_TIMESTAMP
+// views. A view following prevHeader must have a later timestamp.
+func ComputeMedianTimestamp(prevHeader *ViewHeader, viewStore viewHeaderSource) (int64, error) {
 	var timestamps []int64
 	var err error
 	for i := 0; i < NUM_VIEWS_FOR_MEDIAN_TMESTAMP; i++ {
@@ -764,7 +1025,7 @@ func (p *Processor) acceptViewContinue(
 	id ViewID, view *View, viewWhen int64, prevHeader *ViewHeader, source string) error {
 
 	// get the current tip
-	tipID, tipHeader, tipWhen, err := getPointTipHeader(p.ledger, p.viewStore)
+	tipID, tipHeader, tipWhen, err := getPointTipHeader(p.ledger, p.headerIndex)
 	if err != nil {
 		return err
 	}
@@ -796,7 +1057,7 @@ func (p *Processor) acceptViewContinue(
 	for tipAncestor.Height > minHeight {
 		viewsToDisconnect = append(viewsToDisconnect, tipAncestorID)
 		tipAncestorID = tipAncestor.Previous
-		tipAncestor, _, err = p.viewStore.GetViewHeader(tipAncestorID)
+		tipAncestor, _, err = p.headerIndex.GetViewHeader(tipAncestorID)
 		if err != nil {
 			return err
 		}
@@ -806,7 +1067,7 @@ func (p *Processor) acceptViewContinue(
 	for newAncestor.Height > minHeight {
 		viewsToConnect = append([]ViewID{newAncestorID}, viewsToConnect...)
 		newAncestorID = newAncestor.Previous
-		newAncestor, _, err = p.viewStore.GetViewHeader(newAncestorID)
+		newAncestor, _, err = p.headerIndex.GetViewHeader(newAncestorID)
 		if err != nil {
 			return err
 		}
@@ -817,12 +1078,12 @@ func (p *Processor) acceptViewContinue(
 		viewsToDisconnect = append(viewsToDisconnect, tipAncestorID)
 		viewsToConnect = append([]ViewID{newAncestorID}, viewsToConnect...)
 		tipAncestorID = tipAncestor.Previous
-		tipAncestor, _, err = p.viewStore.GetViewHeader(tipAncestorID)
+		tipAncestor, _, err = p.headerIndex.GetViewHeader(tipAncestorID)
 		if err != nil {
 			return err
 		}
 		newAncestorID = newAncestor.Previous
-		newAncestor, _, err = p.viewStore.GetViewHeader(newAncestorID)
+		newAncestor, _, err = p.headerIndex.GetViewHeader(newAncestorID)
 		if err != nil {
 			return err
 		}
@@ -864,15 +1125,15 @@ func (p *Processor) disconnectView(id ViewID, view *View, source string) error {
 
 	log.Printf("View %s has been disconnected, height: %d\n", id, view.Header.Height)
 
+	p.headerIndex.Disconnect(id, view.Header)
+
 	// Add newly disconnected non-viewpoint considerations back to the queue
 	if err := p.cnQueue.AddBatch(cnIDs[1:], view.Considerations[1:], view.Header.Height-1); err != nil {
 		return err
 	}
 
 	// Notify tip change channels
-	for ch := range p.tipChangeChannels {
-		ch <- TipChange{ViewID: id, View: view, Source: source}
-	}
+	p.notifyTipChange(TipChange{ViewID: id, View: view, Source: source})
 	return nil
 }
 
@@ -886,15 +1147,20 @@ func (p *Processor) connectView(id ViewID, view *View, source string, more bool)
 
 	log.Printf("View %s is the new tip, height: %d\n", id, view.Header.Height)
 
+	// the storage time is needed to compare the tip with competing views
+	_, when, err := p.viewStore.GetViewHeader(id)
+	if err != nil {
+		return err
+	}
+	p.headerIndex.Connect(id, view.Header, when)
+
 	// Remove newly confirmed non-viewpoint considerations from the queue
 	if err := p.cnQueue.RemoveBatch(cnIDs[1:], view.Header.Height, more); err != nil {
 		return err
 	}
 
 	// Notify tip change channels
-	for ch := range p.tipChangeChannels {
-		ch <- TipChange{ViewID: id, View: view, Source: source, Connect: true, More: more}
-	}
+	p.notifyTipChange(TipChange{ViewID: id, View: view, Source: source, Connect: true, More: more})
 	return nil
 }
 
@@ -907,19 +1173,206 @@ func (p *Processor) reconnectTip(id ViewID, source string) error {
 	if view == nil {
 		return fmt.Errorf("View %s not found", id)
 	}
-	_, when, err := p.viewStore.GetViewHeader(id)
+	_, when, err := p.headerIndex.GetViewHeader(id)
 	if err != nil {
 		return err
 	}
-	prevHeader, _, err := p.viewStore.GetViewHeader(view.Header.Previous)
+	prevHeader, _, err := p.headerIndex.GetViewHeader(view.Header.Previous)
 	if err != nil {
 		return err
 	}
 	return p.acceptViewContinue(id, view, when, prevHeader, source)
 }
 
+// Mark a view and its descendants invalid, disconnecting any on the main point, and switch to the best remaining point
+func (p *Processor) invalidateView(id ViewID, source string) error {
+	if id == p.genesisID {
+		return fmt.Errorf("The genesis view can't be invalidated")
+	}
+	branchType, err := p.ledger.GetBranchType(id)
+	if err != nil {
+		return err
+	}
+	if branchType == UNKNOWN {
+		return fmt.Errorf("View %s not found", id)
+	}
+	header, _, err := p.headerIndex.GetViewHeader(id)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return fmt.Errorf("Header for view %s not found", id)
+	}
+
+	if branchType == MAIN {
+		// disconnect the main point back to the view's parent. disconnected views become side views
+		for {
+			tipID, tipHeight, err := p.ledger.GetPointTip()
+			if err != nil {
+				return err
+			}
+			if tipHeight < header.Height {
+				break
+			}
+			tipView, err := p.viewStore.GetView(*tipID)
+			if err != nil {
+				return err
+			}
+			if tipView == nil {
+				return fmt.Errorf("View %s not found", *tipID)
+			}
+			if err := p.disconnectView(*tipID, tipView, source); err != nil {
+				return err
+			}
+		}
+	}
+
+	descendants, err := p.getDescendants(id, header.Height, SIDE)
+	if err != nil {
+		return err
+	}
+	for _, invalidID := range append(descendants, id) {
+		if err := p.ledger.SetBranchType(invalidID, INVALID); err != nil {
+			return err
+		}
+	}
+	log.Printf("View %s and %d descendant(s) marked invalid\n", id, len(descendants))
+
+	return p.activateBestPoint(source)
+}
+
+// Clear the invalid mark from a view, its ancestors and its descendants and switch to the best point
+func (p *Processor) reconsiderView(id ViewID, source string) error {
+	branchType, err := p.ledger.GetBranchType(id)
+	if err != nil {
+		return err
+	}
+	if branchType != INVALID {
+		return fmt.Errorf("View %s isn't marked invalid", id)
+	}
+	header, _, err := p.headerIndex.GetViewHeader(id)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return fmt.Errorf("Header for view %s not found", id)
+	}
+
+	toClear, err := p.getDescendants(id, header.Height, INVALID)
+	if err != nil {
+		return err
+	}
+	toClear = append(toClear, id)
+	for ancestorID := header.Previous; ; {
+		branchType, err := p.ledger.GetBranchType(ancestorID)
+		if err != nil {
+			return err
+		}
+		if branchType != INVALID {
+			break
+		}
+		toClear = append(toClear, ancestorID)
+		ancestor, _, err := p.headerIndex.GetViewHeader(ancestorID)
+		if err != nil {
+			return err
+		}
+		if ancestor == nil {
+			return fmt.Errorf("Header for view %s not found", ancestorID)
+		}
+		ancestorID = ancestor.Previous
+	}
+	for _, clearID := range toClear {
+		if err := p.ledger.SetBranchType(clearID, SIDE); err != nil {
+			return err
+		}
+	}
+	log.Printf("Cleared the invalid mark from %d view(s) including %s\n", len(toClear), id)
+
+	return p.activateBestPoint(source)
+}
+
+// Returns the views of the given branch type built on the view with the given ID and height
+func (p *Processor) getDescendants(id ViewID, height int64, branchType BranchType) ([]ViewID, error) {
+	candidates, err := p.ledger.GetBranchViews(branchType)
+	if err != nil {
+		return nil, err
+	}
+
+	// walk each candidate back to the view's height. remember what we learn along the way
+	descends := map[ViewID]bool{id: true}
+	var descendants []ViewID
+	for _, candidate := range candidates {
+		var path []ViewID
+		walkID := candidate
+		result := false
+		for {
+			if known, ok := descends[walkID]; ok {
+				result = known
+				break
+			}
+			walkHeader, _, err := p.headerIndex.GetViewHeader(walkID)
+			if err != nil {
+				return nil, err
+			}
+			if walkHeader == nil || walkHeader.Height <= height {
+				break
+			}
+			path = append(path, walkID)
+			walkID = walkHeader.Previous
+		}
+		for _, pathID := range path {
+			descends[pathID] = result
+		}
+		if result && candidate != id {
+			descendants = append(descendants, candidate)
+		}
+	}
+	return descendants, nil
+}
+
+// Switch to the most-work side branch if it's better than the current main point
+func (p *Processor) activateBestPoint(source string) error {
+	branches, err := GetSideBranches(p.ledger, p.viewStore)
+	if err != nil {
+		return err
+	}
+	for _, branch := range branches {
+		if branch.ForkHeight < 0 {
+			// not connected to the main point
+			continue
+		}
+		view, err := p.viewStore.GetView(branch.TipID)
+		if err != nil {
+			return err
+		}
+		if view == nil {
+			return fmt.Errorf("View %s not found", branch.TipID)
+		}
+		_, when, err := p.headerIndex.GetViewHeader(branch.TipID)
+		if err != nil {
+			return err
+		}
+		prevHeader, _, err := p.headerIndex.GetViewHeader(view.Header.Previous)
+		if err != nil {
+			return err
+		}
+		tipID, _, err := p.ledger.GetPointTip()
+		if err != nil {
+			return err
+		}
+		if err := p.acceptViewContinue(branch.TipID, view, when, prevHeader, source); err != nil {
+			if err2 := p.reconnectTip(*tipID, source); err2 != nil {
+				log.Printf("Error reconnecting tip: %s, view: %s\n", err2, *tipID)
+			}
+			return err
+		}
+		return nil
+	}
+	return nil
+}
+
 // Convenience method to get the current main point's tip ID, header, and storage time.
-func getPointTipHeader(ledger Ledger, viewStore ViewStorage) (*ViewID, *ViewHeader, int64, error) {
+func getPointTipHeader(ledger Ledger, viewStore viewHeaderSource) (*ViewID, *ViewHeader, int64, error) {
 	// get the current tip
 	tipID, _, err := ledger.GetPointTip()
 	if err != nil {
@@ -1,6 +1,15 @@
 package focalpoint
 
-import "testing"
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
 
 func TestComputeMaxConsiderationsPerView(t *testing.T) {
 	var maxDoublings int64 = 64
@@ -57,3 +66,240 @@ func TestComputeMaxConsiderationsPerView(t *testing.T) {
 			MAX_CONSIDERATIONS_PER_VIEW_EXCEEDED_AT_HEIGHT-1, max)
 	}
 }
+
+func TestProcessorShutdown(t *testing.T) {
+	p := NewProcessor(ViewID{}, nil, nil, nil)
+	p.Run()
+	tipChangeChan := make(chan TipChange)
+	p.RegisterForTipChange(tipChangeChan)
+	p.Shutdown()
+
+	// requests made after shutdown are refused rather than blocking forever
+	if err := p.ProcessView(ViewID{}, &View{}, ""); err == nil {
+		t.Fatal("Expected an error processing a view after shutdown")
+	}
+	p.UnregisterForTipChange(tipChangeChan)
+}
+
+func TestConsiderationRejectionCodes(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey2, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tipHeight int64 = 1000
+
+	immature := NewConsideration(pubKey, pubKey2, tipHeight, 0, tipHeight, "")
+	expired := NewConsideration(pubKey, pubKey2, 0, tipHeight, tipHeight, "")
+	for code, cn := range map[string]*Consideration{ImmatureCode: immature, ExpiredCode: expired} {
+		err := checkConsiderationForNextView(ConsiderationID{}, cn, tipHeight)
+		r, ok := err.(considerationRejection)
+		if !ok {
+			t.Fatalf("Expected a rejection with code %s, found: %v", code, err)
+		}
+		if r.code != code {
+			t.Fatalf("Expected code %s, found %s", code, r.code)
+		}
+	}
+
+	// the mind has something to say about each code
+	for _, code := range []string{QueueFullCode, ExpiredCode, ImmatureCode, DescendantRuleCode,
+		InsufficientImbalanceCode} {
+		if _, ok := pushRejectionHints[code]; !ok {
+			t.Fatalf("No hint for code %s", code)
+		}
+	}
+}
+
+func TestProcessorInvalidateView(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "invalidate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	store := func(previous ViewID, height, work int64) (ViewID, *View) {
+		viewpoint := NewConsideration(nil, pubKey, 0, 0, height, "")
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		view.Header.Nonce = work
+		view.Header.PointWork.SetBigInt(big.NewInt(work))
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+		return id, view
+	}
+
+	// a main point of genesis, a1 and a2 and a side branch of b1 off of genesis
+	genesisID, genesis := store(ViewID{}, 0, 0)
+	a1ID, a1 := store(genesisID, 1, 10)
+	a2ID, a2 := store(a1ID, 2, 20)
+	for i, view := range []*View{genesis, a1, a2} {
+		if _, err := ledger.ConnectView([]ViewID{genesisID, a1ID, a2ID}[i], view); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b1ID, _ := store(genesisID, 1, 5)
+	if err := ledger.SetBranchType(b1ID, SIDE); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProcessor(genesisID, viewStore, NewConsiderationQueueMemory(ledger, NewGraph()), ledger)
+	p.Run()
+	defer p.Shutdown()
+
+	expect := func(tipID ViewID, branchTypes map[ViewID]BranchType) {
+		id, _, err := ledger.GetPointTip()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *id != tipID {
+			t.Fatalf("Expected tip %s, found %s", tipID, *id)
+		}
+		for id, expected := range branchTypes {
+			branchType, err := ledger.GetBranchType(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if branchType != expected {
+				t.Fatalf("Expected view %s to have branch type %d, found %d", id, expected, branchType)
+			}
+		}
+	}
+
+	// invalidating a1 takes a2 with it and leaves b1 as the best point
+	if err := p.InvalidateView(a1ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+	expect(b1ID, map[ViewID]BranchType{a1ID: INVALID, a2ID: INVALID, b1ID: MAIN})
+
+	if err := p.ReconsiderView(b1ID, "test"); err == nil {
+		t.Fatal("Expected an error reconsidering a view which isn't invalid")
+	}
+
+	// reconsidering a2 clears a1 too and the original point wins again
+	if err := p.ReconsiderView(a2ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+	expect(a2ID, map[ViewID]BranchType{a1ID: MAIN, a2ID: MAIN, b1ID: SIDE})
+
+	if err := p.InvalidateView(genesisID, "test"); err == nil {
+		t.Fatal("Expected an error invalidating the genesis view")
+	}
+}
+
+func TestProcessorDuplicateNonce(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "duplicate_nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	// mature viewpoints in the next view so there's something to spend
+	defer func(maturity int64) { VIEWPOINT_MATURITY = maturity }(VIEWPOINT_MATURITY)
+	VIEWPOINT_MATURITY = 1
+
+	var genesisID, previous ViewID
+	for height := int64(0); height < 3; height++ {
+		viewpoint := NewConsideration(nil, pubKey, 0, 0, height, "")
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.ConnectView(id, view); err != nil {
+			t.Fatal(err)
+		}
+		if height == 0 {
+			genesisID = id
+		}
+		previous = id
+	}
+
+	cnQueue := NewConsiderationQueueMemory(ledger, NewGraph())
+	p := NewProcessor(genesisID, viewStore, cnQueue, ledger)
+	p.Run()
+	defer p.Shutdown()
+
+	process := func(cn *Consideration) ConsiderationID {
+		if err := cn.Sign(privKey); err != nil {
+			t.Fatal(err)
+		}
+		id, err := cn.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.ProcessConsideration(id, cn, "test"); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	// two considerations with the same nonce but different content are distinct
+	cn1 := NewConsideration(pubKey, otherKey, 0, 0, 3, "first")
+	cn2 := NewConsideration(pubKey, otherKey, 0, 0, 3, "second")
+	cn2.Time, cn2.Nonce = cn1.Time, cn1.Nonce
+	id1, id2 := process(cn1), process(cn2)
+	if id1 == id2 {
+		t.Fatal("Expected considerations with different content to have different IDs")
+	}
+	if cnQueue.Len() != 2 || !cnQueue.Exists(id1) || !cnQueue.Exists(id2) {
+		t.Fatalf("Expected both considerations to be queued, found %d", cnQueue.Len())
+	}
+
+	// a copy with the same nonce and content is the same consideration and only queued once
+	cn3 := *cn1
+	if id3 := process(&cn3); id3 != id1 {
+		t.Fatal("Expected an identical consideration to have the same ID")
+	}
+	if cnQueue.Len() != 2 {
+		t.Fatalf("Expected the duplicate not to be queued again, found %d", cnQueue.Len())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	. "github.com/inconsiderable/focal-point"
+	"github.com/inconsiderable/focal-point/internal/config"
+)
+
+// Connect to a node and check it answers each peer protocol message the way the reference
+// implementation does
+func main() {
+	DefaultPeer := "127.0.0.1:" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
+	peerPtr := flag.String("peer", DefaultPeer, "Address of the node to check")
+	cfg := config.Register(flag.CommandLine, config.Network|config.TLSClient|config.Logging)
+	flag.Parse()
+
+	// switch networks before doing anything else
+	if err := cfg.Apply(); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.NetworkParams != nil && *peerPtr == DefaultPeer {
+		*peerPtr = "127.0.0.1:" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
+	}
+	if len(*peerPtr) == 0 {
+		log.Fatal("Peer address required")
+	}
+	*peerPtr = config.WithDefaultPort(*peerPtr)
+
+	// load genesis view
+	var genesisView View
+	if err := json.Unmarshal([]byte(GenesisViewJson), &genesisView); err != nil {
+		log.Fatal(err)
+	}
+	genesisID, err := genesisView.ID()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var skipped int
+	failed, err := runProtocolCheck(*peerPtr, genesisID, cfg.TLSVerify, func(check string, err error) {
+		switch err.(type) {
+		case nil:
+			fmt.Printf("ok   %s\n", check)
+		case protocolCheckSkip:
+			skipped++
+			fmt.Printf("skip %s: %s\n", check, err)
+		default:
+			fmt.Printf("FAIL %s: %s\n", check, err)
+		}
+	})
+	if err != nil {
+		fmt.Printf("FAIL %s\n", err)
+		os.Exit(1)
+	}
+	if failed != 0 {
+		fmt.Printf("FAIL %d check(s) failed, %d skipped\n", failed, skipped)
+		os.Exit(1)
+	}
+	if skipped != 0 {
+		fmt.Printf("PASS %d check(s) skipped\n", skipped)
+		return
+	}
+	fmt.Println("PASS")
+}
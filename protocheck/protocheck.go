@@ -0,0 +1,1100 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/inconsiderable/focal-point"
+	"golang.org/x/crypto/ed25519"
+)
+
+// how long to wait for the node to answer each request
+const protocolCheckTimeout = 30 * time.Second
+
+// protocolCheckSkip is reported for a check which couldn't be run against the node, e.g. because
+// its tip is stale or it hasn't rendered past the genesis view.
+type protocolCheckSkip struct {
+	Reason string
+}
+
+func (s protocolCheckSkip) Error() string {
+	return s.Reason
+}
+
+// runProtocolCheck connects to the node at addr as a peer and exercises each message type of the
+// peer protocol with valid and boundary inputs. Responses are decoded strictly against the message
+// types in protocol.go, so unknown or misspelled fields fail, and are checked against what the
+// reference implementation answers, including its reason codes. It's meant for validating other
+// implementations and upgrades. Nothing it sends changes the node's ledger or queue. Operator
+// messages are only checked to be refused or rejected, so the results are the same from any host.
+// report is called with the name of each check and nil if it passed, a protocolCheckSkip if it was
+// skipped or why it failed. It returns the number of failed checks.
+func runProtocolCheck(addr string, genesisID ViewID, tlsVerify bool,
+	report func(check string, err error)) (int, error) {
+	c := &protocolCheck{genesisID: genesisID}
+	if err := c.connect(addr, tlsVerify); err != nil {
+		return 0, err
+	}
+	defer c.conn.Close()
+
+	// the rest of the checks depend on knowing the tip
+	if err := c.checkTipHeader(); err != nil {
+		return 1, fmt.Errorf("get_tip_header: %s", err)
+	}
+	report("get_tip_header", nil)
+
+	checks := []struct {
+		name string
+		fn   func() error
+	}{
+		{"get_view_header_by_height", c.checkViewHeaderByHeight},
+		{"get_view_header_by_height beyond tip", c.checkViewHeaderByHeightBeyondTip},
+		{"get_view_header", c.checkViewHeader},
+		{"get_view_header unknown view", c.checkViewHeaderUnknown},
+		{"get_view_by_height", c.checkViewByHeight},
+		{"get_view_by_height beyond tip", c.checkViewByHeightBeyondTip},
+		{"get_view unknown view", c.checkViewUnknown},
+		{"find_common_ancestor", c.checkFindCommonAncestor},
+		{"get_status", c.checkStatus},
+		{"get_display_hints", c.checkDisplayHints},
+		{"get_imbalance", c.checkImbalance},
+		{"get_imbalances", c.checkImbalances},
+		{"get_imbalances over limit", c.checkImbalancesOverLimit},
+		{"get_public_key_considerations", c.checkPublicKeyConsiderations},
+		{"get_public_key_considerations ids only", c.checkPublicKeyConsiderationIDs},
+		{"get_public_key_considerations negative limit", c.checkPublicKeyConsiderationsNegativeLimit},
+		{"get_rendered_views", c.checkRenderedViews},
+		{"get_supply", c.checkSupply},
+		{"get_supply beyond tip", c.checkSupplyBeyondTip},
+		{"get_consideration unknown consideration", c.checkConsiderationUnknown},
+		{"get_profile", c.checkProfile},
+		{"get_graph", c.checkGraph},
+		{"get_ranking", c.checkRanking},
+		{"get_descendant", c.checkDescendant},
+		{"push_consideration unsigned", c.checkPushUnsigned},
+		{"push_consideration immature", c.checkPushImmature},
+		{"push_consideration expired", c.checkPushExpired},
+		{"push_consideration insufficient imbalance", c.checkPushInsufficientImbalance},
+		{"filter_add without filter", c.checkFilterAddNotLoaded},
+		{"get_filter_consideration_queue without filter", c.checkFilterQueueNotLoaded},
+		{"filter_load unsupported type", c.checkFilterLoadUnsupported},
+		{"filter_load", c.checkFilterLoad},
+		{"filter_add", c.checkFilterAdd},
+		{"filter_add over limit", c.checkFilterAddOverLimit},
+		{"get_filter_consideration_queue", c.checkFilterQueue},
+		{"get_peer_addresses", c.checkPeerAddresses},
+		{"get_mind_state", c.checkGetMindState},
+		{"put_mind_state invalid sync ID", c.checkPutMindStateInvalid},
+		{"checkpoint untrusted key", c.checkCheckpointUntrusted},
+		{"invalidate_view genesis", c.checkInvalidateGenesis},
+		{"reconsider_view genesis", c.checkReconsiderGenesis},
+		{"indexer_control unknown action", c.checkIndexerControlUnknown},
+		{"unanswered messages", c.checkUnanswered},
+		{"get_work without keys", c.checkWorkWithoutKeys},
+		{"submit_work unknown work", c.checkSubmitUnknownWork},
+		{"get_work", c.checkWork},
+	}
+	failed := 0
+	for _, check := range checks {
+		err := check.fn()
+		if _, ok := err.(protocolCheckSkip); err != nil && !ok {
+			failed++
+		}
+		report(check.name, err)
+		if c.closed {
+			return failed, fmt.Errorf("Connection lost after %s", check.name)
+		}
+	}
+	return failed, nil
+}
+
+type protocolCheck struct {
+	genesisID ViewID
+	conn      *websocket.Conn
+	closed    bool // the connection can't be read from anymore
+	tipID     ViewID
+	tipHeader *ViewHeader
+}
+
+// Connect the same way a peer or mind does
+func (c *protocolCheck) connect(addr string, tlsVerify bool) error {
+	conn, err := DialNode(addr, c.genesisID, tlsVerify)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+// Send a message without expecting a response
+func (c *protocolCheck) send(messageType string, body interface{}) error {
+	c.conn.SetWriteDeadline(time.Now().Add(protocolCheckTimeout))
+	if err := c.conn.WriteJSON(Message{Type: messageType, Body: body}); err != nil {
+		c.closed = true
+		return err
+	}
+	return nil
+}
+
+// Send a request and decode the response of the given type into response. The node may send its
+// own requests and announcements at any time so other message types are skipped
+func (c *protocolCheck) request(messageType string, body interface{},
+	responseType string, response interface{}) error {
+	if err := c.send(messageType, body); err != nil {
+		return err
+	}
+	_, err := c.receive(messageType, map[string]interface{}{responseType: response})
+	return err
+}
+
+// Wait for a response of one of the given types and decode it into the matching value. Returns the
+// type received
+func (c *protocolCheck) receive(requestType string, responses map[string]interface{}) (string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(protocolCheckTimeout))
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			// a failed read leaves the connection unusable
+			c.closed = true
+			return "", fmt.Errorf("No response to %s received: %s", requestType, err)
+		}
+		var rawBody json.RawMessage
+		m := Message{Body: &rawBody}
+		if err := json.Unmarshal(message, &m); err != nil {
+			return "", fmt.Errorf("Invalid message frame: %s", err)
+		}
+		if m.Type == "query_rejected" {
+			var qr QueryRejectedMessage
+			if err := decodeProtocolCheckBody(rawBody, &qr); err != nil {
+				return "", err
+			}
+			if qr.Type != requestType {
+				continue
+			}
+			if qr.Code == StaleTipCode {
+				return "", protocolCheckSkip{fmt.Sprintf("Query rejected, the node's tip is stale: %s", qr.Error)}
+			}
+			return "", fmt.Errorf("Query rejected: %s", qr.Error)
+		}
+		response, ok := responses[m.Type]
+		if !ok {
+			continue
+		}
+		return m.Type, decodeProtocolCheckBody(rawBody, response)
+	}
+}
+
+// Decode a message body rejecting fields the message type doesn't have. A missing body decodes
+// to the zero value
+func decodeProtocolCheckBody(body json.RawMessage, v interface{}) error {
+	if len(body) == 0 || string(body) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("Response doesn't match the message schema: %s", err)
+	}
+	return nil
+}
+
+// Check a header hashes to the ID it was sent with
+func checkProtocolHeaderID(id *ViewID, header *ViewHeader) error {
+	if id == nil || header == nil {
+		return fmt.Errorf("Missing view ID or header")
+	}
+	headerID, err := header.ID()
+	if err != nil {
+		return err
+	}
+	if headerID != *id {
+		return fmt.Errorf("Header hashes to %s, not the view ID %s", headerID, *id)
+	}
+	return nil
+}
+
+// Returns a public key nobody has used
+func newProtocolCheckKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(nil)
+}
+
+func (c *protocolCheck) checkTipHeader() error {
+	var th TipHeaderMessage
+	if err := c.request("get_tip_header", nil, "tip_header", &th); err != nil {
+		return err
+	}
+	if err := checkProtocolHeaderID(th.ViewID, th.ViewHeader); err != nil {
+		return err
+	}
+	if th.TimeSeen <= 0 {
+		return fmt.Errorf("Invalid time seen %d", th.TimeSeen)
+	}
+	c.tipID, c.tipHeader = *th.ViewID, th.ViewHeader
+	return nil
+}
+
+func (c *protocolCheck) checkViewHeaderByHeight() error {
+	var vh ViewHeaderMessage
+	if err := c.request("get_view_header_by_height", GetViewHeaderByHeightMessage{Height: 0},
+		"view_header", &vh); err != nil {
+		return err
+	}
+	if err := checkProtocolHeaderID(vh.ViewID, vh.ViewHeader); err != nil {
+		return err
+	}
+	if *vh.ViewID != c.genesisID {
+		return fmt.Errorf("View at height 0 is %s, not the genesis view", *vh.ViewID)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkViewHeaderByHeightBeyondTip() error {
+	var vh ViewHeaderMessage
+	if err := c.request("get_view_header_by_height",
+		GetViewHeaderByHeightMessage{Height: c.tipHeader.Height + 1000},
+		"view_header", &vh); err != nil {
+		return err
+	}
+	if vh.ViewID != nil || vh.ViewHeader != nil {
+		return fmt.Errorf("Expected an empty response")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkViewHeader() error {
+	var vh ViewHeaderMessage
+	if err := c.request("get_view_header", GetViewHeaderMessage{ViewID: c.tipID},
+		"view_header", &vh); err != nil {
+		return err
+	}
+	if err := checkProtocolHeaderID(vh.ViewID, vh.ViewHeader); err != nil {
+		return err
+	}
+	if *vh.ViewID != c.tipID {
+		return fmt.Errorf("Asked for view %s, received %s", c.tipID, *vh.ViewID)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkViewHeaderUnknown() error {
+	id := ViewID(randomProtocolCheckHash())
+	var vh ViewHeaderMessage
+	if err := c.request("get_view_header", GetViewHeaderMessage{ViewID: id},
+		"view_header", &vh); err != nil {
+		return err
+	}
+	if vh.ViewID == nil || *vh.ViewID != id {
+		return fmt.Errorf("Expected the requested view ID to be echoed")
+	}
+	if vh.ViewHeader != nil {
+		return fmt.Errorf("Received a header for an unknown view")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkViewByHeight() error {
+	var vm ViewMessage
+	if err := c.request("get_view_by_height", GetViewByHeightMessage{Height: 0}, "view", &vm); err != nil {
+		return err
+	}
+	if vm.ViewID == nil || vm.View == nil {
+		return fmt.Errorf("Missing view ID or view")
+	}
+	id, err := vm.View.ID()
+	if err != nil {
+		return err
+	}
+	if id != *vm.ViewID || id != c.genesisID {
+		return fmt.Errorf("View at height 0 is %s, not the genesis view", id)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkViewByHeightBeyondTip() error {
+	var vm ViewMessage
+	if err := c.request("get_view_by_height", GetViewByHeightMessage{Height: c.tipHeader.Height + 1000},
+		"view", &vm); err != nil {
+		return err
+	}
+	if vm.ViewID != nil || vm.View != nil {
+		return fmt.Errorf("Expected an empty response")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkViewUnknown() error {
+	id := ViewID(randomProtocolCheckHash())
+	var vm ViewMessage
+	if err := c.request("get_view", GetViewMessage{ViewID: id}, "view", &vm); err != nil {
+		return err
+	}
+	if vm.ViewID == nil || *vm.ViewID != id {
+		return fmt.Errorf("Expected the requested view ID to be echoed")
+	}
+	if vm.View != nil {
+		return fmt.Errorf("Received an unknown view")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkFindCommonAncestor() error {
+	if c.tipHeader.Height == 0 {
+		return protocolCheckSkip{"The node has no views past the genesis view"}
+	}
+	var vh ViewHeaderMessage
+	if err := c.request("get_view_header_by_height", GetViewHeaderByHeightMessage{Height: 1},
+		"view_header", &vh); err != nil {
+		return err
+	}
+	if err := checkProtocolHeaderID(vh.ViewID, vh.ViewHeader); err != nil {
+		return err
+	}
+
+	// sharing only the genesis view the node should offer the views which follow it
+	var inv InvViewMessage
+	if err := c.request("find_common_ancestor", FindCommonAncestorMessage{ViewIDs: []ViewID{c.genesisID}},
+		"inv_view", &inv); err != nil {
+		return err
+	}
+	if len(inv.ViewIDs) == 0 {
+		return fmt.Errorf("No views offered")
+	}
+	if inv.ViewIDs[0] != *vh.ViewID {
+		return fmt.Errorf("First view offered is %s, not %s at height 1", inv.ViewIDs[0], *vh.ViewID)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkStatus() error {
+	var sm StatusMessage
+	if err := c.request("get_status", nil, "status", &sm); err != nil {
+		return err
+	}
+	if len(sm.Error) != 0 {
+		return fmt.Errorf("Status error: %s", sm.Error)
+	}
+	if sm.Height < c.tipHeader.Height {
+		return fmt.Errorf("Status height %d is behind the tip height %d", sm.Height, c.tipHeader.Height)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkDisplayHints() error {
+	var dh DisplayHintsMessage
+	if err := c.request("get_display_hints", nil, "display_hints", &dh); err != nil {
+		return err
+	}
+	if dh.GenesisID != c.genesisID {
+		return fmt.Errorf("Genesis ID %s doesn't match %s", dh.GenesisID, c.genesisID)
+	}
+	return dh.Hints.Validate()
+}
+
+func (c *protocolCheck) checkImbalance() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var im ImbalanceMessage
+	if err := c.request("get_imbalance", GetImbalanceMessage{PublicKey: pubKey}, "imbalance", &im); err != nil {
+		return err
+	}
+	if len(im.Error) != 0 {
+		return fmt.Errorf("Imbalance error: %s", im.Error)
+	}
+	if !bytes.Equal(im.PublicKey, pubKey) {
+		return fmt.Errorf("Expected the requested public key to be echoed")
+	}
+	if im.ViewID == nil || im.Height < c.tipHeader.Height {
+		return fmt.Errorf("Missing or stale view ID and height")
+	}
+	if im.Imbalance != 0 {
+		return fmt.Errorf("Unused public key has imbalance %d", im.Imbalance)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkImbalances() error {
+	var pubKeys []ed25519.PublicKey
+	for i := 0; i < 2; i++ {
+		pubKey, _, err := newProtocolCheckKey()
+		if err != nil {
+			return err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	var im ImbalancesMessage
+	if err := c.request("get_imbalances", GetImbalancesMessage{PublicKeys: pubKeys},
+		"imbalances", &im); err != nil {
+		return err
+	}
+	if len(im.Error) != 0 {
+		return fmt.Errorf("Imbalances error: %s", im.Error)
+	}
+	if len(im.Imbalances) != len(pubKeys) {
+		return fmt.Errorf("Expected %d imbalances, received %d", len(pubKeys), len(im.Imbalances))
+	}
+	for i, pkb := range im.Imbalances {
+		if !bytes.Equal(pkb.PublicKey, pubKeys[i]) {
+			return fmt.Errorf("Imbalance %d is for a different public key", i)
+		}
+		if pkb.Imbalance != 0 {
+			return fmt.Errorf("Unused public key has imbalance %d", pkb.Imbalance)
+		}
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkImbalancesOverLimit() error {
+	pubKeys := make([]ed25519.PublicKey, 65)
+	for i := range pubKeys {
+		pubKey, _, err := newProtocolCheckKey()
+		if err != nil {
+			return err
+		}
+		pubKeys[i] = pubKey
+	}
+	var im ImbalancesMessage
+	if err := c.request("get_imbalances", GetImbalancesMessage{PublicKeys: pubKeys},
+		"imbalances", &im); err != nil {
+		return err
+	}
+	if len(im.Error) == 0 || len(im.Imbalances) != 0 {
+		return fmt.Errorf("Expected an error for %d public keys", len(pubKeys))
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkPublicKeyConsiderations() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var pkc PublicKeyConsiderationsMessage
+	if err := c.request("get_public_key_considerations",
+		GetPublicKeyConsiderationsMessage{
+			PublicKey:   pubKey,
+			StartHeight: c.tipHeader.Height,
+			EndHeight:   c.tipHeader.Height + 1,
+			Limit:       10,
+		}, "public_key_considerations", &pkc); err != nil {
+		return err
+	}
+	if len(pkc.Error) != 0 {
+		return fmt.Errorf("Public key considerations error: %s", pkc.Error)
+	}
+	if !bytes.Equal(pkc.PublicKey, pubKey) {
+		return fmt.Errorf("Expected the requested public key to be echoed")
+	}
+	if len(pkc.FilterViewes) != 0 {
+		return fmt.Errorf("Unused public key has %d filter view(s)", len(pkc.FilterViewes))
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkPublicKeyConsiderationIDs() error {
+	// the tip's viewpoint is known to be in the recipient's history
+	var vm ViewMessage
+	if err := c.request("get_view", GetViewMessage{ViewID: c.tipID}, "view", &vm); err != nil {
+		return err
+	}
+	if vm.View == nil || len(vm.View.Considerations) == 0 {
+		return fmt.Errorf("Missing tip view")
+	}
+	viewpoint := vm.View.Considerations[0]
+	viewpointID, err := viewpoint.ID()
+	if err != nil {
+		return err
+	}
+	var pkc PublicKeyConsiderationsMessage
+	if err := c.request("get_public_key_considerations",
+		GetPublicKeyConsiderationsMessage{
+			PublicKey:   viewpoint.For,
+			StartHeight: c.tipHeader.Height,
+			EndHeight:   c.tipHeader.Height + 1,
+			IDsOnly:     true,
+		}, "public_key_considerations", &pkc); err != nil {
+		return err
+	}
+	if len(pkc.Error) != 0 {
+		return fmt.Errorf("Public key considerations error: %s", pkc.Error)
+	}
+	if len(pkc.FilterViewes) != 0 {
+		return fmt.Errorf("Expected no filter views when only IDs are requested")
+	}
+	for _, id := range pkc.ConsiderationIDs {
+		if id.ConsiderationID == viewpointID {
+			if id.ViewID != c.tipID || id.Height != c.tipHeader.Height {
+				return fmt.Errorf("Viewpoint %s reported in view %s at height %d",
+					viewpointID, id.ViewID, id.Height)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("Tip viewpoint %s missing from the recipient's consideration IDs", viewpointID)
+}
+
+func (c *protocolCheck) checkPublicKeyConsiderationsNegativeLimit() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var pkc PublicKeyConsiderationsMessage
+	if err := c.request("get_public_key_considerations",
+		GetPublicKeyConsiderationsMessage{PublicKey: pubKey, Limit: -1},
+		"public_key_considerations", &pkc); err != nil {
+		return err
+	}
+	if len(pkc.PublicKey) != 0 || len(pkc.FilterViewes) != 0 || len(pkc.Error) != 0 {
+		return fmt.Errorf("Expected an empty response")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkRenderedViews() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var rv RenderedViewsMessage
+	if err := c.request("get_rendered_views",
+		GetRenderedViewsMessage{
+			PublicKey:   pubKey,
+			StartHeight: c.tipHeader.Height,
+			EndHeight:   c.tipHeader.Height + 1,
+			Limit:       10,
+		},
+		"rendered_views", &rv); err != nil {
+		return err
+	}
+	if len(rv.Error) != 0 {
+		return fmt.Errorf("Rendered views error: %s", rv.Error)
+	}
+	if len(rv.Views) != 0 {
+		return fmt.Errorf("Unused public key rendered %d view(s)", len(rv.Views))
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkSupply() error {
+	var sm SupplyMessage
+	if err := c.request("get_supply", GetSupplyMessage{}, "supply", &sm); err != nil {
+		return err
+	}
+	if len(sm.Error) != 0 {
+		return fmt.Errorf("Supply error: %s", sm.Error)
+	}
+	if sm.ViewID == nil || sm.Height < c.tipHeader.Height {
+		return fmt.Errorf("Missing or stale view ID and height")
+	}
+	if sm.Supply < 0 || sm.Considerations < 0 || sm.ViewpointsMatured < 0 || sm.ActiveKeys < 0 {
+		return fmt.Errorf("Negative totals")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkSupplyBeyondTip() error {
+	var sm SupplyMessage
+	if err := c.request("get_supply", GetSupplyMessage{Height: c.tipHeader.Height + 1000},
+		"supply", &sm); err != nil {
+		return err
+	}
+	if len(sm.Error) == 0 {
+		return fmt.Errorf("Expected an error for a height beyond the tip")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkConsiderationUnknown() error {
+	id := ConsiderationID(randomProtocolCheckHash())
+	var cm ConsiderationMessage
+	if err := c.request("get_consideration", GetConsiderationMessage{ConsiderationID: id},
+		"consideration", &cm); err != nil {
+		return err
+	}
+	if cm.ConsiderationID != id {
+		return fmt.Errorf("Expected the requested consideration ID to be echoed")
+	}
+	if cm.Consideration != nil || cm.ViewID != nil {
+		return fmt.Errorf("Received an unknown consideration")
+	}
+	return nil
+}
+
+// The indexer can be disabled, in which case ranking queries answer with an error
+func (c *protocolCheck) rankingDisabled(errStr string) error {
+	if len(errStr) == 0 {
+		return nil
+	}
+	return protocolCheckSkip{fmt.Sprintf("Ranking unavailable: %s", errStr)}
+}
+
+func (c *protocolCheck) checkProfile() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var pm ProfileMessage
+	if err := c.request("get_profile", GetProfileMessage{PublicKey: pubKey}, "profile", &pm); err != nil {
+		return err
+	}
+	if err := c.rankingDisabled(pm.Error); err != nil {
+		return err
+	}
+	if !bytes.Equal(pm.PublicKey, pubKey) {
+		return fmt.Errorf("Expected the requested public key to be echoed")
+	}
+	if pm.Ranking != 0 || pm.Imbalance != 0 {
+		return fmt.Errorf("Unused public key has ranking %f and imbalance %d", pm.Ranking, pm.Imbalance)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkGraph() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var gm GraphMessage
+	if err := c.request("get_graph", GetGraphMessage{PublicKey: pubKey}, "graph", &gm); err != nil {
+		return err
+	}
+	if err := c.rankingDisabled(gm.Error); err != nil {
+		return err
+	}
+	if !bytes.Equal(gm.PublicKey, pubKey) {
+		return fmt.Errorf("Expected the requested public key to be echoed")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkRanking() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var rm RankingMessage
+	if err := c.request("get_ranking", GetRankingMessage{PublicKey: pubKey}, "ranking", &rm); err != nil {
+		return err
+	}
+	if err := c.rankingDisabled(rm.Error); err != nil {
+		return err
+	}
+	if !bytes.Equal(rm.PublicKey, pubKey) {
+		return fmt.Errorf("Expected the requested public key to be echoed")
+	}
+	if rm.Ranking != 0 {
+		return fmt.Errorf("Unused public key has ranking %f", rm.Ranking)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkDescendant() error {
+	parent, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	descendant, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var dm DescendantMessage
+	if err := c.request("get_descendant", GetDescendantMessage{Parent: parent, Descendant: descendant},
+		"descendant", &dm); err != nil {
+		return err
+	}
+	if len(dm.Error) != 0 {
+		return fmt.Errorf("Descendant error: %s", dm.Error)
+	}
+	if !bytes.Equal(dm.Parent, parent) || !bytes.Equal(dm.Descendant, descendant) {
+		return fmt.Errorf("Expected the requested public keys to be echoed")
+	}
+	if dm.IsDescendant {
+		return fmt.Errorf("Unused public keys are related")
+	}
+	return nil
+}
+
+// Push a consideration between unused keys and check the result's error and code. No consideration
+// it sends can be queued since the sender never has an imbalance
+func (c *protocolCheck) pushConsideration(matures, expires int64, sign bool, code string) error {
+	pubKey, privKey, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	forKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	cn := NewConsideration(pubKey, forKey, matures, expires, c.tipHeader.Height, "")
+	if sign {
+		if err := cn.Sign(privKey); err != nil {
+			return err
+		}
+	}
+	id, err := cn.ID()
+	if err != nil {
+		return err
+	}
+	var pr PushConsiderationResultMessage
+	if err := c.request("push_consideration", PushConsiderationMessage{Consideration: cn},
+		"push_consideration_result", &pr); err != nil {
+		return err
+	}
+	if pr.ConsiderationID != id {
+		return fmt.Errorf("Result is for consideration %s, not %s", pr.ConsiderationID, id)
+	}
+	if len(pr.Error) == 0 {
+		return fmt.Errorf("Consideration was accepted")
+	}
+	if pr.Code != code {
+		return fmt.Errorf("Expected code %q, received %q: %s", code, pr.Code, pr.Error)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkPushUnsigned() error {
+	return c.pushConsideration(0, 0, false, "")
+}
+
+func (c *protocolCheck) checkPushImmature() error {
+	if c.tipHeader.Height == 0 {
+		return protocolCheckSkip{"The node has no views past the genesis view"}
+	}
+	// a consideration is only mature up to its maturity height
+	return c.pushConsideration(c.tipHeader.Height, 0, true, ImmatureCode)
+}
+
+func (c *protocolCheck) checkPushExpired() error {
+	if c.tipHeader.Height == 0 {
+		return protocolCheckSkip{"The node has no views past the genesis view"}
+	}
+	return c.pushConsideration(0, c.tipHeader.Height, true, ExpiredCode)
+}
+
+func (c *protocolCheck) checkPushInsufficientImbalance() error {
+	return c.pushConsideration(0, 0, true, InsufficientImbalanceCode)
+}
+
+func (c *protocolCheck) checkFilterAddNotLoaded() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var fr FilterResultMessage
+	if err := c.request("filter_add", FilterAddMessage{PublicKeys: []ed25519.PublicKey{pubKey}},
+		"filter_result", &fr); err != nil {
+		return err
+	}
+	if fr.Code != FilterNotLoadedCode {
+		return fmt.Errorf("Expected code %q, received %q", FilterNotLoadedCode, fr.Code)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkFilterQueueNotLoaded() error {
+	var fq FilterConsiderationQueueMessage
+	if err := c.request("get_filter_consideration_queue", nil,
+		"filter_consideration_queue", &fq); err != nil {
+		return err
+	}
+	if fq.Code != FilterNotLoadedCode {
+		return fmt.Errorf("Expected code %q, received %q", FilterNotLoadedCode, fq.Code)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkFilterLoadUnsupported() error {
+	var fr FilterResultMessage
+	if err := c.request("filter_load", FilterLoadMessage{Type: "protocheck", Filter: []byte{0}},
+		"filter_result", &fr); err != nil {
+		return err
+	}
+	if len(fr.Error) == 0 {
+		return fmt.Errorf("Unsupported filter type was loaded")
+	}
+	for _, filterType := range []string{FilterTypeCuckoo, FilterTypeKeySet} {
+		found := false
+		for _, t := range fr.Types {
+			found = found || t == filterType
+		}
+		if !found {
+			return fmt.Errorf("Supported filter types %v don't include %s", fr.Types, filterType)
+		}
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkFilterLoad() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var fr FilterResultMessage
+	if err := c.request("filter_load", FilterLoadMessage{Type: FilterTypeKeySet, Filter: pubKey},
+		"filter_result", &fr); err != nil {
+		return err
+	}
+	if len(fr.Error) != 0 {
+		return fmt.Errorf("Filter error: %s", fr.Error)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkFilterAdd() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	var fr FilterResultMessage
+	if err := c.request("filter_add", FilterAddMessage{PublicKeys: []ed25519.PublicKey{pubKey}},
+		"filter_result", &fr); err != nil {
+		return err
+	}
+	if len(fr.Error) != 0 {
+		return fmt.Errorf("Filter error: %s", fr.Error)
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkFilterAddOverLimit() error {
+	pubKeys := make([]ed25519.PublicKey, 257)
+	for i := range pubKeys {
+		pubKey, _, err := newProtocolCheckKey()
+		if err != nil {
+			return err
+		}
+		pubKeys[i] = pubKey
+	}
+	var fr FilterResultMessage
+	if err := c.request("filter_add", FilterAddMessage{PublicKeys: pubKeys}, "filter_result", &fr); err != nil {
+		return err
+	}
+	if len(fr.Error) == 0 {
+		return fmt.Errorf("Expected an error for %d public keys", len(pubKeys))
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkFilterQueue() error {
+	var fq FilterConsiderationQueueMessage
+	if err := c.request("get_filter_consideration_queue", nil,
+		"filter_consideration_queue", &fq); err != nil {
+		return err
+	}
+	if len(fq.Error) != 0 {
+		return fmt.Errorf("Filter queue error: %s", fq.Error)
+	}
+	if len(fq.Considerations) != 0 {
+		return fmt.Errorf("Queue has %d consideration(s) for unused public keys", len(fq.Considerations))
+	}
+	return nil
+}
+
+// A node without addresses to share doesn't respond so a tip header request follows it
+func (c *protocolCheck) checkPeerAddresses() error {
+	if err := c.send("get_peer_addresses", nil); err != nil {
+		return err
+	}
+	if err := c.send("get_tip_header", nil); err != nil {
+		return err
+	}
+	var pa PeerAddressesMessage
+	var th TipHeaderMessage
+	responseType, err := c.receive("get_peer_addresses",
+		map[string]interface{}{"peer_addresses": &pa, "tip_header": &th})
+	if err != nil {
+		return err
+	}
+	if responseType == "tip_header" {
+		return nil
+	}
+	if len(pa.Addresses) == 0 {
+		return fmt.Errorf("Received an empty list of addresses")
+	}
+	// the tip header is still coming
+	_, err = c.receive("get_tip_header", map[string]interface{}{"tip_header": &th})
+	return err
+}
+
+func (c *protocolCheck) checkGetMindState() error {
+	syncID := ViewID(randomProtocolCheckHash()).String()
+	var ms MindStateMessage
+	if err := c.request("get_mind_state", GetMindStateMessage{SyncID: syncID}, "mind_state", &ms); err != nil {
+		return err
+	}
+	if len(ms.Error) != 0 {
+		// mind state storage is optional
+		return nil
+	}
+	if ms.SyncID != syncID {
+		return fmt.Errorf("Expected the requested sync ID to be echoed")
+	}
+	if len(ms.State) != 0 {
+		return fmt.Errorf("Received state for an unused sync ID")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkPutMindStateInvalid() error {
+	var pr PutMindStateResultMessage
+	if err := c.request("put_mind_state", MindStateMessage{SyncID: "", State: []byte{0}},
+		"put_mind_state_result", &pr); err != nil {
+		return err
+	}
+	if len(pr.Error) == 0 {
+		return fmt.Errorf("Stored state for an empty sync ID")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkCheckpointUntrusted() error {
+	_, privKey, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	checkpoint, err := NewSignedCheckpoint(c.genesisID, 0, privKey)
+	if err != nil {
+		return err
+	}
+	var cr CheckpointResultMessage
+	if err := c.request("checkpoint", CheckpointMessage{Checkpoint: checkpoint},
+		"checkpoint_result", &cr); err != nil {
+		return err
+	}
+	if len(cr.Error) == 0 {
+		return fmt.Errorf("Accepted a checkpoint signed by an untrusted key")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkInvalidateGenesis() error {
+	var ir InvalidateViewResultMessage
+	if err := c.request("invalidate_view", InvalidateViewMessage{ViewID: c.genesisID},
+		"invalidate_view_result", &ir); err != nil {
+		return err
+	}
+	if ir.ViewID != c.genesisID {
+		return fmt.Errorf("Expected the requested view ID to be echoed")
+	}
+	if len(ir.Error) == 0 {
+		return fmt.Errorf("Invalidated the genesis view")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkReconsiderGenesis() error {
+	var rr ReconsiderViewResultMessage
+	if err := c.request("reconsider_view", ReconsiderViewMessage{ViewID: c.genesisID},
+		"reconsider_view_result", &rr); err != nil {
+		return err
+	}
+	if rr.ViewID != c.genesisID {
+		return fmt.Errorf("Expected the requested view ID to be echoed")
+	}
+	if len(rr.Error) == 0 {
+		return fmt.Errorf("Reconsidered the genesis view which isn't marked invalid")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkIndexerControlUnknown() error {
+	var ir IndexerControlResultMessage
+	if err := c.request("indexer_control", IndexerControlMessage{Action: "protocheck"},
+		"indexer_control_result", &ir); err != nil {
+		return err
+	}
+	if len(ir.Error) == 0 {
+		return fmt.Errorf("Accepted an unknown action")
+	}
+	return nil
+}
+
+// Messages which aren't requests get no response, including unknown types. The connection must
+// still work afterward
+func (c *protocolCheck) checkUnanswered() error {
+	messages := []Message{
+		{Type: "inv_view", Body: InvViewMessage{ViewIDs: []ViewID{c.genesisID}}},
+		{Type: "peer_addresses", Body: PeerAddressesMessage{}},
+		{Type: "push_consideration_result", Body: PushConsiderationResultMessage{}},
+		{Type: "checkpoint_result", Body: CheckpointResultMessage{}},
+		{Type: "protocheck_unknown"},
+	}
+	for _, m := range messages {
+		if err := c.send(m.Type, m.Body); err != nil {
+			return err
+		}
+	}
+	var th TipHeaderMessage
+	if err := c.request("get_tip_header", nil, "tip_header", &th); err != nil {
+		return err
+	}
+	return checkProtocolHeaderID(th.ViewID, th.ViewHeader)
+}
+
+func (c *protocolCheck) checkWorkWithoutKeys() error {
+	var wm WorkMessage
+	if err := c.request("get_work", GetWorkMessage{}, "work", &wm); err != nil {
+		return err
+	}
+	if len(wm.Error) == 0 {
+		return fmt.Errorf("Issued work without public keys")
+	}
+	return nil
+}
+
+func (c *protocolCheck) checkSubmitUnknownWork() error {
+	header := *c.tipHeader
+	var sr SubmitWorkResultMessage
+	if err := c.request("submit_work", SubmitWorkMessage{WorkID: 1, Header: &header},
+		"submit_work_result", &sr); err != nil {
+		return err
+	}
+	if sr.WorkID != 1 {
+		return fmt.Errorf("Result is for work %d, not 1", sr.WorkID)
+	}
+	if sr.Code != UnknownWorkCode {
+		return fmt.Errorf("Expected code %q, received %q: %s", UnknownWorkCode, sr.Code, sr.Error)
+	}
+	return nil
+}
+
+// This is the last check since the node keeps sending work once it's asked for it
+func (c *protocolCheck) checkWork() error {
+	pubKey, _, err := newProtocolCheckKey()
+	if err != nil {
+		return err
+	}
+	if err := c.checkTipHeader(); err != nil {
+		return err
+	}
+	var wm WorkMessage
+	if err := c.request("get_work", GetWorkMessage{PublicKeys: []ed25519.PublicKey{pubKey}},
+		"work", &wm); err != nil {
+		return err
+	}
+	if len(wm.Error) != 0 {
+		return fmt.Errorf("Work error: %s", wm.Error)
+	}
+	if wm.Header == nil {
+		return fmt.Errorf("Missing header")
+	}
+	if wm.Header.Previous != c.tipID || wm.Header.Height != c.tipHeader.Height+1 {
+		return fmt.Errorf("Work at height %d doesn't build on the tip", wm.Header.Height)
+	}
+	if wm.MinTime <= 0 {
+		return fmt.Errorf("Invalid minimum time %d", wm.MinTime)
+	}
+	return nil
+}
+
+// Returns a random hash for IDs which won't be found
+func randomProtocolCheckHash() [32]byte {
+	var hash [32]byte
+	rand.Read(hash[:])
+	return hash
+}
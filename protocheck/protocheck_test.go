@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/inconsiderable/focal-point"
+)
+
+func TestDecodeProtocolCheckBody(t *testing.T) {
+	var fr FilterResultMessage
+	if err := decodeProtocolCheckBody(nil, &fr); err != nil {
+		t.Fatal(err)
+	}
+	if err := decodeProtocolCheckBody(json.RawMessage(`{"error":"x","types":["keys"]}`), &fr); err != nil {
+		t.Fatal(err)
+	}
+	if fr.Error != "x" || len(fr.Types) != 1 {
+		t.Fatalf("Unexpected result: %+v", fr)
+	}
+	if err := decodeProtocolCheckBody(json.RawMessage(`{"eror":"x"}`), &fr); err == nil {
+		t.Fatal("Expected an error for an unknown field")
+	}
+	if err := decodeProtocolCheckBody(json.RawMessage(`{"error":1}`), &fr); err == nil {
+		t.Fatal("Expected an error for a field of the wrong type")
+	}
+}
@@ -11,10 +11,13 @@ type Message struct {
 	Body interface{} `json:"body,omitempty"`
 }
 
-// InvViewMessage is used to communicate views available for download.
+// InvViewMessage is used to communicate views available for download. Tips is set when the
+// views are newly connected tips being announced rather than a page of the point for a peer
+// catching up.
 // Type: "inv_view".
 type InvViewMessage struct {
 	ViewIDs []ViewID `json:"view_ids"`
+	Tips    bool     `json:"tips,omitempty"`
 }
 
 // GetViewMessage is used to request a view for download.
@@ -92,6 +95,7 @@ type GraphMessage struct {
 	Height    int64             `json:"height,omitempty"`
 	PublicKey ed25519.PublicKey `json:"public_key"`
 	Graph     string            `json:"graph"`
+	Error     string            `json:"error,omitempty"`
 }
 
 // GetRankingMessage requests a public key's considerability ranking.
@@ -131,6 +135,25 @@ type PublicKeyRanking struct {
 	Ranking   float64 `json:"ranking"`
 }
 
+// GetDescendantMessage asks whether a public key descends from another in the consideration graph.
+// A consideration from a descendant to one of its ancestors isn't admitted to the queue.
+// Type: "get_descendant".
+type GetDescendantMessage struct {
+	Parent     ed25519.PublicKey `json:"parent"`
+	Descendant ed25519.PublicKey `json:"descendant"`
+}
+
+// DescendantMessage is used to answer a GetDescendantMessage.
+// Type: "descendant".
+type DescendantMessage struct {
+	ViewID       ViewID            `json:"view_id,omitempty"`
+	Height       int64             `json:"height,omitempty"`
+	Parent       ed25519.PublicKey `json:"parent"`
+	Descendant   ed25519.PublicKey `json:"descendant"`
+	IsDescendant bool              `json:"is_descendant"`
+	Error        string            `json:"error,omitempty"`
+}
+
 // GetImbalanceMessage requests a public key's imbalance.
 // Type: "get_imbalance".
 type GetImbalanceMessage struct {
@@ -191,6 +214,38 @@ type TipHeaderMessage struct {
 	TimeSeen   int64       `json:"time_seen,omitempty"`
 }
 
+// DisplayHintsMessage advertises how the node's network suggests it be presented. It's sent in
+// response to the empty "get_display_hints" message type. Clients can tell deployments apart by
+// the genesis view ID and show the network's name and units.
+// Type: "display_hints".
+type DisplayHintsMessage struct {
+	GenesisID ViewID       `json:"genesis_id"`
+	Hints     DisplayHints `json:"hints"`
+}
+
+// StatusMessage summarizes the health of a node. It's sent in response to the empty
+// "get_status" message type. Hashrate is omitted if the node isn't rendering.
+// Type: "status".
+type StatusMessage struct {
+	ViewID               ViewID             `json:"view_id"`
+	Height               int64              `json:"height"`
+	TimeSeen             int64              `json:"time_seen"`
+	InitialViewDownload  bool               `json:"initial_view_download"`
+	InboundPeers         int                `json:"inbound_peers"`
+	OutboundPeers        int                `json:"outbound_peers"`
+	QueueLength          int                `json:"queue_length"`
+	Hashrate             *float64           `json:"hashrate,omitempty"`      // hashes per second
+	RendererKeys         []RendererKeyStats `json:"renderer_keys,omitempty"` // rendering work by public key
+	IndexerViewID        ViewID             `json:"indexer_view_id"`
+	IndexerHeight        int64              `json:"indexer_height"`
+	IndexerState         string             `json:"indexer_state,omitempty"`         // running, paused or disabled
+	StaleTip             bool               `json:"stale_tip"`                       // mind queries are refused while true
+	NotificationLag      int64              `json:"notification_lag,omitempty"`      // milliseconds the slowest internal subscriber is behind
+	NotificationBacklog  int                `json:"notification_backlog,omitempty"`  // notifications queued for internal subscribers
+	DroppedNotifications int64              `json:"dropped_notifications,omitempty"` // dropped since startup for subscribers too far behind
+	Error                string             `json:"error,omitempty"`
+}
+
 // PushConsiderationMessage is used to push a newly processed unconfirmed consideration to peers.
 // Type: "push_consideration".
 type PushConsiderationMessage struct {
@@ -198,14 +253,26 @@ type PushConsiderationMessage struct {
 }
 
 // PushConsiderationResultMessage is sent in response to a PushConsiderationMessage.
+// If the consideration wasn't admitted to the queue for a reason the sender can act on Code says which.
 // Type: "push_consideration_result".
 type PushConsiderationResultMessage struct {
 	ConsiderationID ConsiderationID `json:"consideration_id"`
 	Error           string          `json:"error,omitempty"`
+	Code            string          `json:"code,omitempty"`
 }
 
+// Reason codes included with a PushConsiderationResultMessage error.
+const (
+	QueueFullCode             = "queue_full"             // try again later
+	ExpiredCode               = "expired"                // it can no longer be rendered
+	ImmatureCode              = "immature"               // it isn't mature at the next height
+	DescendantRuleCode        = "descendant_rule"        // the sender descends from the recipient
+	InsufficientImbalanceCode = "insufficient_imbalance" // the sender has no imbalance
+)
+
 // FilterLoadMessage is used to request that we load a filter which is used to
-// filter considerations returned to the peer based on interest.
+// filter considerations returned to the peer based on interest. The filter type is
+// "cuckoo" or "keys", a plain concatenation of public keys.
 // Type: "filter_load"
 type FilterLoadMessage struct {
 	Type   string `json:"type"`
@@ -213,18 +280,26 @@ type FilterLoadMessage struct {
 }
 
 // FilterAddMessage is used to request the addition of the given public keys to the current filter.
-// The filter is created if it's not set.
+// A filter must have been loaded first.
 // Type: "filter_add".
 type FilterAddMessage struct {
 	PublicKeys []ed25519.PublicKey `json:"public_keys"`
 }
 
 // FilterResultMessage indicates whether or not the filter request was successful.
+// If a filter couldn't be loaded the supported filter types are included.
 // Type: "filter_result".
 type FilterResultMessage struct {
-	Error string `json:"error,omitempty"`
+	Error string   `json:"error,omitempty"`
+	Code  string   `json:"code,omitempty"`
+	Types []string `json:"types,omitempty"`
 }
 
+// FilterNotLoadedCode is the error code included with a response to a request which depends on the
+// connection's filter when no filter is loaded, e.g. the peer restarted. The requester should send
+// filter_load again.
+const FilterNotLoadedCode = "filter_not_loaded"
+
 // FilterViewMessage represents a pared down view containing only considerations relevant to the peer given their filter.
 // Type: "filter_view".
 type FilterViewMessage struct {
@@ -239,10 +314,12 @@ type FilterViewMessage struct {
 type FilterConsiderationQueueMessage struct {
 	Considerations []*Consideration `json:"considerations"`
 	Error          string           `json:"error,omitempty"`
+	Code           string           `json:"code,omitempty"`
 }
 
 // GetPublicKeyConsiderationsMessage requests considerations associated with a given public key over a given
-// height range of the focal point.
+// height range of the focal point. IDsOnly requests only the consideration IDs and where they were confirmed
+// instead of filter views. The considerations can then be fetched as needed with "get_consideration".
 // Type: "get_public_key_considerations".
 type GetPublicKeyConsiderationsMessage struct {
 	PublicKey   ed25519.PublicKey `json:"public_key"`
@@ -250,18 +327,74 @@ type GetPublicKeyConsiderationsMessage struct {
 	StartIndex  int               `json:"start_index"`
 	EndHeight   int64             `json:"end_height"`
 	Limit       int               `json:"limit"`
+	IDsOnly     bool              `json:"ids_only,omitempty"`
 }
 
 // PublicKeyConsiderationsMessage is used to return a list of view headers and the considerations relevant to
 // the public key over a given height range of the focal point.
+// Truncated is set when a pruned node stopped reading stored views before filling the response.
+// StopHeight is then the last height it read and the request can be continued from the next one.
+// ConsiderationIDs is set instead of FilterViewes if only IDs were requested.
 // Type: "public_key_considerations".
 type PublicKeyConsiderationsMessage struct {
-	PublicKey    ed25519.PublicKey    `json:"public_key"`
-	StartHeight  int64                `json:"start_height"`
-	StopHeight   int64                `json:"stop_height"`
-	StopIndex    int                  `json:"stop_index"`
-	FilterViewes []*FilterViewMessage `json:"filter_views"`
-	Error        string               `json:"error,omitempty"`
+	PublicKey        ed25519.PublicKey          `json:"public_key"`
+	StartHeight      int64                      `json:"start_height"`
+	StopHeight       int64                      `json:"stop_height"`
+	StopIndex        int                        `json:"stop_index"`
+	FilterViewes     []*FilterViewMessage       `json:"filter_views"`
+	ConsiderationIDs []PublicKeyConsiderationID `json:"consideration_ids,omitempty"`
+	Truncated        bool                       `json:"truncated,omitempty"`
+	Error            string                     `json:"error,omitempty"`
+}
+
+// PublicKeyConsiderationID is an entry in the PublicKeyConsiderationsMessage's ConsiderationIDs field.
+type PublicKeyConsiderationID struct {
+	ConsiderationID ConsiderationID `json:"consideration_id"`
+	ViewID          ViewID          `json:"view_id"`
+	Height          int64           `json:"height"`
+}
+
+// GetRenderedViewsMessage requests the main point views whose viewpoint went to the given public key
+// over a given height range of the focal point.
+// Type: "get_rendered_views".
+type GetRenderedViewsMessage struct {
+	PublicKey   ed25519.PublicKey `json:"public_key"`
+	StartHeight int64             `json:"start_height"`
+	EndHeight   int64             `json:"end_height"`
+	Limit       int               `json:"limit"`
+}
+
+// RenderedViewsMessage is used to return the views rendered to a public key in ascending height order.
+// Type: "rendered_views".
+type RenderedViewsMessage struct {
+	PublicKey ed25519.PublicKey `json:"public_key"`
+	Views     []RenderedView    `json:"views,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// RenderedView is an entry in the RenderedViewsMessage's Views field.
+type RenderedView struct {
+	ViewID ViewID `json:"view_id"`
+	Height int64  `json:"height"`
+}
+
+// GetSupplyMessage requests network-wide totals as of a main point height. A height of 0 requests
+// them as of the current tip. The genesis view's totals are all zero.
+// Type: "get_supply".
+type GetSupplyMessage struct {
+	Height int64 `json:"height,omitempty"`
+}
+
+// SupplyMessage is used to send network-wide totals as of a main point height.
+// Type: "supply".
+type SupplyMessage struct {
+	ViewID            *ViewID `json:"view_id,omitempty"`
+	Height            int64   `json:"height"`
+	Supply            int64   `json:"supply"`             // total imbalance of all public keys
+	Considerations    int64   `json:"considerations"`     // confirmed, not counting viewpoints
+	ViewpointsMatured int64   `json:"viewpoints_matured"` // applied to imbalances
+	ActiveKeys        int64   `json:"active_keys"`        // public keys with a non-zero imbalance
+	Error             string  `json:"error,omitempty"`
 }
 
 // PeerAddressesMessage is used to communicate a list of potential peer addresses known by a peer.
@@ -297,8 +430,116 @@ type SubmitWorkMessage struct {
 }
 
 // SubmitWorkResultMessage is used to inform a rendering peer of the result of its work.
+// If the work was rejected because it's no longer useful Code says why.
 // Type: "submit_work_result"
 type SubmitWorkResultMessage struct {
 	WorkID int32  `json:"work_id"`
 	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+// Reason codes included with a SubmitWorkResultMessage error.
+const (
+	StaleWorkCode   = "stale_work"   // the work builds on a view which is no longer the tip
+	UnknownWorkCode = "unknown_work" // the work ID was never issued or has been forgotten
+)
+
+// GetMindStateMessage is used by a mind to request its encrypted state previously stored with a peer.
+// Type: "get_mind_state"
+type GetMindStateMessage struct {
+	SyncID string `json:"sync_id"`
+}
+
+// MindStateMessage is used to send a mind's encrypted state. It's sent in response to
+// a GetMindStateMessage or used by a mind to store its state with a peer.
+// Type: "mind_state" or "put_mind_state"
+type MindStateMessage struct {
+	SyncID  string `json:"sync_id"`
+	Updated int64  `json:"updated,omitempty"`
+	State   []byte `json:"state,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PutMindStateResultMessage is sent in response to a "put_mind_state" message.
+// Type: "put_mind_state_result"
+type PutMindStateResultMessage struct {
+	SyncID string `json:"sync_id"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CheckpointMessage is used to announce a checkpoint signed by a trusted key.
+// Type: "checkpoint"
+type CheckpointMessage struct {
+	Checkpoint *SignedCheckpoint `json:"checkpoint"`
+}
+
+// CheckpointResultMessage is sent in response to a CheckpointMessage.
+// Type: "checkpoint_result"
+type CheckpointResultMessage struct {
+	Height int64  `json:"height"`
+	Error  string `json:"error,omitempty"`
+}
+
+// InvalidateViewMessage asks a node to treat a view and every view built on it as invalid,
+// reorganizing away from it if it's on the main point. Nodes only accept it over loopback with
+// the operator token from their data directory.
+// Type: "invalidate_view"
+type InvalidateViewMessage struct {
+	ViewID ViewID `json:"view_id"`
+	Token  string `json:"token"`
+}
+
+// InvalidateViewResultMessage is sent in response to an InvalidateViewMessage.
+// Type: "invalidate_view_result"
+type InvalidateViewResultMessage struct {
+	ViewID ViewID `json:"view_id"`
+	Error  string `json:"error,omitempty"`
 }
+
+// ReconsiderViewMessage asks a node to clear the invalid mark from a view, its ancestors and its
+// descendants and switch to the best point. Nodes only accept it over loopback with the
+// operator token from their data directory.
+// Type: "reconsider_view"
+type ReconsiderViewMessage struct {
+	ViewID ViewID `json:"view_id"`
+	Token  string `json:"token"`
+}
+
+// ReconsiderViewResultMessage is sent in response to a ReconsiderViewMessage.
+// Type: "reconsider_view_result"
+type ReconsiderViewResultMessage struct {
+	ViewID ViewID `json:"view_id"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IndexerControlMessage asks a node to pause, disable, resume or rebuild its indexer without
+// restarting. Rebuilding re-derives the consideration graph from the given height. Nodes only
+// accept it over loopback with the operator token from their data directory.
+// Type: "indexer_control"
+type IndexerControlMessage struct {
+	Action string `json:"action"` // "pause", "disable", "resume" or "rebuild"
+	Height int64  `json:"height,omitempty"`
+	Token  string `json:"token"`
+}
+
+// IndexerControlResultMessage is sent in response to an IndexerControlMessage.
+// Type: "indexer_control_result"
+type IndexerControlResultMessage struct {
+	Action string `json:"action"`
+	State  string `json:"state"`
+	Error  string `json:"error,omitempty"`
+}
+
+// QueryRejectedMessage is sent in response to a query a node declined to serve. A node in public
+// mode declines queries when the requesting host is over its quota or the node is busy. Any node
+// declines mind queries while its tip is stale.
+// Type: "query_rejected"
+type QueryRejectedMessage struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+// StaleTipCode is the QueryRejectedMessage code used when the node's main point tip is older
+// than MAX_TIP_AGE and any answer would likely be out of date.
+const StaleTipCode = "stale_tip"
@@ -0,0 +1,197 @@
+package focalpoint
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// QueryLimiter enforces quotas on mind queries from public hosts when a node is run as a
+// public service. Each host has a token bucket which refills at a steady rate and the number
+// of queries being served at once is capped both per host and across all hosts.
+type QueryLimiter struct {
+	rate              time.Duration // a host earns a query every rate
+	burst             int
+	maxConcurrent     int
+	maxConcurrentHost int
+	accounts          map[string]*queryAccount
+	inflight          int
+	lastSweep         time.Time
+	now               func() time.Time
+	lock              sync.Mutex
+}
+
+// QueryAccount is a snapshot of a host's query accounting.
+type QueryAccount struct {
+	Host     string
+	Tokens   int
+	Inflight int
+	Served   int64
+	Rejected int64
+}
+
+type queryAccount struct {
+	tokens   float64
+	updated  time.Time
+	inflight int
+	served   int64
+	rejected int64
+}
+
+// NewQueryLimiter returns a new QueryLimiter instance. Each host may issue burst queries at once
+// and then one query every rate. At most maxConcurrent queries are served at once across all
+// hosts and at most maxConcurrentHost for any single host.
+func NewQueryLimiter(rate time.Duration, burst, maxConcurrent, maxConcurrentHost int) *QueryLimiter {
+	return &QueryLimiter{
+		rate:              rate,
+		burst:             burst,
+		maxConcurrent:     maxConcurrent,
+		maxConcurrentHost: maxConcurrentHost,
+		accounts:          make(map[string]*queryAccount),
+		lastSweep:         time.Now(),
+		now:               time.Now,
+	}
+}
+
+// Acquire charges a query to the host. If it returns nil the caller must call Release
+// once the query has been answered.
+func (q *QueryLimiter) Acquire(host string) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	now := q.now()
+	q.sweep(now)
+
+	account, ok := q.accounts[host]
+	if !ok {
+		account = &queryAccount{tokens: float64(q.burst), updated: now}
+		q.accounts[host] = account
+	} else {
+		account.refill(now, q.rate, q.burst)
+	}
+
+	if q.inflight >= q.maxConcurrent {
+		account.rejected++
+		return fmt.Errorf("Server busy, try again later")
+	}
+	if account.inflight >= q.maxConcurrentHost {
+		account.rejected++
+		return fmt.Errorf("Too many concurrent queries from host %s", host)
+	}
+	if account.tokens < 1 {
+		account.rejected++
+		return fmt.Errorf("Query quota exceeded for host %s", host)
+	}
+
+	account.tokens--
+	account.inflight++
+	account.served++
+	q.inflight++
+	return nil
+}
+
+// Charge takes additional queries from the host's quota for a query which costs more to answer.
+func (q *QueryLimiter) Charge(host string, queries int) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	account, ok := q.accounts[host]
+	if !ok {
+		account = &queryAccount{tokens: float64(q.burst), updated: q.now()}
+		q.accounts[host] = account
+	} else {
+		account.refill(q.now(), q.rate, q.burst)
+	}
+	if account.tokens < float64(queries) {
+		account.rejected++
+		return fmt.Errorf("Query quota exceeded for host %s", host)
+	}
+	account.tokens -= float64(queries)
+	return nil
+}
+
+// Release is called when a query charged with Acquire has been answered.
+func (q *QueryLimiter) Release(host string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if account, ok := q.accounts[host]; ok && account.inflight > 0 {
+		account.inflight--
+		q.inflight--
+	}
+}
+
+// Account returns a snapshot of the host's query accounting.
+func (q *QueryLimiter) Account(host string) QueryAccount {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	qa := QueryAccount{Host: host, Tokens: q.burst}
+	if account, ok := q.accounts[host]; ok {
+		account.refill(q.now(), q.rate, q.burst)
+		qa.Tokens = int(account.tokens)
+		qa.Inflight = account.inflight
+		qa.Served = account.served
+		qa.Rejected = account.rejected
+	}
+	return qa
+}
+
+// Forget accounts for hosts which are idle with a full bucket. They'd start over from the same
+// place anyway and it keeps the number of accounts bounded by recent activity
+func (q *QueryLimiter) sweep(now time.Time) {
+	if now.Sub(q.lastSweep) < queryAccountSweepPeriod {
+		return
+	}
+	q.lastSweep = now
+	for host, account := range q.accounts {
+		account.refill(now, q.rate, q.burst)
+		if account.inflight != 0 || account.tokens < float64(q.burst) {
+			continue
+		}
+		if account.rejected != 0 {
+			log.Printf("Public query account for %s closed, served: %d, rejected: %d\n",
+				host, account.served, account.rejected)
+		}
+		delete(q.accounts, host)
+	}
+}
+
+// Add the tokens earned since the last update
+func (a *queryAccount) refill(now time.Time, rate time.Duration, burst int) {
+	elapsed := now.Sub(a.updated)
+	if elapsed <= 0 {
+		return
+	}
+	a.tokens += float64(elapsed) / float64(rate)
+	if a.tokens > float64(burst) {
+		a.tokens = float64(burst)
+	}
+	a.updated = now
+}
+
+// isPointQuery returns true if the message type is a mind query answered from the focal point.
+// They're refused on mind and public query connections while our tip is stale.
+func isPointQuery(messageType string) bool {
+	switch messageType {
+	case "get_profile", "get_graph", "get_ranking", "get_descendant",
+		"get_imbalance", "get_imbalances",
+		"get_public_key_considerations", "get_consideration", "get_rendered_views", "get_supply",
+		"push_consideration", "get_filter_consideration_queue":
+		return true
+	}
+	return false
+}
+
+// isPublicQuery returns true if the message type is a mind query subject to public service quotas.
+func isPublicQuery(messageType string) bool {
+	switch messageType {
+	case "get_profile", "get_graph", "get_ranking", "get_descendant",
+		"get_imbalance", "get_imbalances",
+		"get_public_key_considerations", "get_consideration", "get_rendered_views", "get_supply",
+		"push_consideration",
+		"filter_load", "filter_add", "get_filter_consideration_queue",
+		"get_mind_state", "put_mind_state":
+		return true
+	}
+	return false
+}
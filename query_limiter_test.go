@@ -0,0 +1,74 @@
+package focalpoint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	q := NewQueryLimiter(time.Second, 3, 4, 2)
+	q.now = func() time.Time { return now }
+
+	// burst
+	for i := 0; i < 3; i++ {
+		if err := q.Acquire("a"); err != nil {
+			t.Fatalf("Expected query %d to be allowed: %s", i, err)
+		}
+		q.Release("a")
+	}
+	if err := q.Acquire("a"); err == nil {
+		t.Fatal("Expected query over quota to be rejected")
+	}
+
+	// other hosts have their own quota
+	if err := q.Acquire("b"); err != nil {
+		t.Fatal(err)
+	}
+	q.Release("b")
+
+	// refill
+	now = now.Add(2 * time.Second)
+	for i := 0; i < 2; i++ {
+		if err := q.Acquire("a"); err != nil {
+			t.Fatalf("Expected refilled query %d to be allowed: %s", i, err)
+		}
+	}
+
+	// per host concurrency
+	now = now.Add(time.Minute)
+	if err := q.Acquire("a"); err == nil {
+		t.Fatal("Expected query over host concurrency limit to be rejected")
+	}
+	q.Release("a")
+	q.Release("a")
+
+	// costly queries are charged extra
+	now = now.Add(time.Minute)
+	if err := q.Charge("a", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Charge("a", 2); err == nil {
+		t.Fatal("Expected charge over quota to be rejected")
+	}
+	now = now.Add(time.Minute)
+
+	account := q.Account("a")
+	if account.Served != 5 || account.Rejected != 3 || account.Inflight != 0 {
+		t.Fatalf("Unexpected account: %+v", account)
+	}
+
+	// total concurrency
+	for _, host := range []string{"c", "c", "d", "d"} {
+		if err := q.Acquire(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Acquire("e"); err == nil {
+		t.Fatal("Expected query over total concurrency limit to be rejected")
+	}
+	q.Release("d")
+	if err := q.Acquire("e"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,12 @@
 package focalpoint
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,23 +23,46 @@ type Renderer struct {
 	processor      *Processor
 	num            int
 	keyIndex       int
-	hashUpdateChan chan int64
+	hashUpdateChan chan HashUpdate
 	shutdownChan   chan struct{}
 	wg             sync.WaitGroup
 }
 
+// Rebuild the view being rendered if the queue has at least this many more considerations than it
+const renderRefreshThreshold = 100
+
 // HashrateMonitor collects hash counts from all renderers in order to monitor and display the aggregate hashrate.
+// Hashes and rendered views are also attributed to the public key they were rendered for.
 type HashrateMonitor struct {
-	hashUpdateChan chan int64
+	hashUpdateChan chan HashUpdate
+	hashrate       float64                      // hashes per second over the last update interval
+	keyStats       map[string]*RendererKeyStats // by public key
+	hashrateLock   sync.RWMutex
 	shutdownChan   chan struct{}
 	wg             sync.WaitGroup
 }
 
+// HashUpdate reports a renderer's work for one of its public keys to the HashrateMonitor.
+type HashUpdate struct {
+	PublicKey ed25519.PublicKey
+	Hashes    int64 // computed since the renderer's last update
+	Views     int64 // rendered since the renderer's last update
+}
+
+// RendererKeyStats is the rendering work attributed to one of the public keys views are rendered for.
+type RendererKeyStats struct {
+	PublicKey     ed25519.PublicKey `json:"public_key"`
+	Hashrate      float64           `json:"hashrate"`                // hashes per second over the last update interval
+	Hashes        int64             `json:"hashes"`                  // since startup
+	ViewsRendered int64             `json:"views_rendered"`          // since startup
+	LastRendered  int64             `json:"last_rendered,omitempty"` // when the most recent view was rendered
+}
+
 // NewRenderer returns a new Renderer instance.
 func NewRenderer(pubKeys []ed25519.PublicKey, memo string,
 	viewStore ViewStorage, cnQueue ConsiderationQueue,
 	ledger Ledger, processor *Processor,
-	hashUpdateChan chan int64, num int) *Renderer {
+	hashUpdateChan chan HashUpdate, num int) *Renderer {
 	return &Renderer{
 		pubKeys:        pubKeys,
 		memo:           memo,
@@ -52,9 +78,10 @@ func NewRenderer(pubKeys []ed25519.PublicKey, memo string,
 }
 
 // NewHashrateMonitor returns a new HashrateMonitor instance.
-func NewHashrateMonitor(hashUpdateChan chan int64) *HashrateMonitor {
+func NewHashrateMonitor(hashUpdateChan chan HashUpdate) *HashrateMonitor {
 	return &HashrateMonitor{
 		hashUpdateChan: hashUpdateChan,
+		keyStats:       make(map[string]*RendererKeyStats),
 		shutdownChan:   make(chan struct{}),
 	}
 }
@@ -104,7 +131,7 @@ func (m *Renderer) run() {
 	// register for tip changes
 	tipChangeChan := make(chan TipChange, 1)
 	m.processor.RegisterForTipChange(tipChangeChan)
-	defer m.processor.UnregisterForTipChange(tipChangeChan)
+	defer func() { m.processor.UnregisterForTipChange(tipChangeChan) }()
 
 	// register for new considerations
 	newTxChan := make(chan NewTx, 1)
@@ -117,7 +144,14 @@ func (m *Renderer) run() {
 	var targetInt *big.Int
 	for {
 		select {
-		case tip := <-tipChangeChan:
+		case tip, ok := <-tipChangeChan:
+			if !ok {
+				// cut off for falling behind. the next connected tip starts new work
+				log.Println("Renderer missed tip changes")
+				tipChangeChan = make(chan TipChange, 1)
+				m.processor.RegisterForTipChange(tipChangeChan)
+				continue
+			}
 			if !tip.Connect || tip.More {
 				// only build off newly connected tip views
 				continue
@@ -134,7 +168,7 @@ func (m *Renderer) run() {
 				panic(err)
 			}
 			// make sure we're at least +1 the median timestamp
-			medianTimestamp, err = computeMedianTimestamp(tip.View.Header, m.viewStore)
+			medianTimestamp, err = ComputeMedianTimestamp(tip.View.Header, m.viewStore)
 			if err != nil {
 				panic(err)
 			}
@@ -173,10 +207,20 @@ func (m *Renderer) run() {
 
 		case <-ticker.C:
 			// update hashcount for hashrate monitor
-			m.hashUpdateChan <- hashes
+			m.hashUpdateChan <- HashUpdate{PublicKey: m.pubKeys[m.keyIndex], Hashes: hashes}
 			hashes = 0
 
 			if view != nil {
+				// rebuild the view if the queue has grown a lot since we created it.
+				// otherwise a long search could render a view missing most of what's waiting
+				if shouldRefreshView(view, m.cnQueue.Len()) {
+					log.Printf("Renderer %d refreshing view, queue length: %d, considerations in view: %d\n",
+						m.num, m.cnQueue.Len(), len(view.Considerations)-1)
+					// a new one is created from the current tip below
+					view = nil
+					continue
+				}
+
 				// update view time every so often
 				now := time.Now().Unix()
 				if now > medianTimestamp {
@@ -197,7 +241,7 @@ func (m *Renderer) run() {
 					panic(err)
 				}
 				// make sure we're at least +1 the median timestamp
-				medianTimestamp, err = computeMedianTimestamp(tipHeader, m.viewStore)
+				medianTimestamp, err = ComputeMedianTimestamp(tipHeader, m.viewStore)
 				if err != nil {
 					panic(err)
 				}
@@ -217,10 +261,17 @@ func (m *Renderer) run() {
 				log.Printf("Renderer %d rendered new view %s\n", m.num, *id)
 
 				// process the view
+				update := HashUpdate{PublicKey: m.pubKeys[m.keyIndex], Hashes: hashes}
 				if err := m.processor.ProcessView(*id, view, "localhost"); err != nil {
 					log.Printf("Error processing rendered view: %s\n", err)
+				} else {
+					update.Views = 1
 				}
 
+				// credit the key before it changes
+				m.hashUpdateChan <- update
+				hashes = 0
+
 				view = nil
 				m.keyIndex = rand.Intn(len(m.pubKeys))
 			} else {
@@ -241,6 +292,17 @@ func (m *Renderer) Shutdown() {
 	log.Printf("Renderer %d shutdown\n", m.num)
 }
 
+// Returns true if the view being rendered should be rebuilt because the queue has grown by at least
+// renderRefreshThreshold considerations it doesn't include. A full view can't take any more
+func shouldRefreshView(view *View, queueLength int) bool {
+	if MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW != 0 &&
+		len(view.Considerations) >= MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW {
+		return false
+	}
+	cnCount := len(view.Considerations) - 1
+	return queueLength-cnCount >= renderRefreshThreshold
+}
+
 // Create a new view off of the given tip view.
 func (m *Renderer) createNextView(tipID ViewID, tipHeader *ViewHeader) (*View, error) {
 	log.Printf("Renderer %d rendering new view from current tip %s\n", m.num, tipID)
@@ -265,7 +327,7 @@ func createNextView(tipID ViewID, tipHeader *ViewHeader, cnQueue ConsiderationQu
 	cns = append([]*Consideration{cn}, cns...)
 
 	// compute the next target
-	newTarget, err := computeTarget(tipHeader, viewStore, ledger)
+	newTarget, err := ComputeTarget(tipHeader, viewStore, ledger)
 	if err != nil {
 		return nil, err
 	}
@@ -288,6 +350,7 @@ func (h *HashrateMonitor) run() {
 	defer h.wg.Done()
 
 	var totalHashes int64
+	keyHashes := make(map[string]int64)
 	updateInterval := 1 * time.Minute
 	ticker := time.NewTicker(updateInterval)
 	defer ticker.Stop()
@@ -299,16 +362,92 @@ func (h *HashrateMonitor) run() {
 				log.Println("Hashrate monitor shutting down...")
 				return
 			}
-		case hashes := <-h.hashUpdateChan:
-			totalHashes += hashes
+		case update := <-h.hashUpdateChan:
+			totalHashes += update.Hashes
+			key := pubKeyToString(update.PublicKey)
+			keyHashes[key] += update.Hashes
+			h.hashrateLock.Lock()
+			stats, ok := h.keyStats[key]
+			if !ok {
+				stats = &RendererKeyStats{PublicKey: update.PublicKey}
+				h.keyStats[key] = stats
+			}
+			stats.Hashes += update.Hashes
+			if update.Views != 0 {
+				stats.ViewsRendered += update.Views
+				stats.LastRendered = time.Now().Unix()
+			}
+			h.hashrateLock.Unlock()
 		case <-ticker.C:
 			hps := float64(totalHashes) / updateInterval.Seconds()
 			totalHashes = 0
+			h.hashrateLock.Lock()
+			h.hashrate = hps
+			for key, stats := range h.keyStats {
+				stats.Hashrate = float64(keyHashes[key]) / updateInterval.Seconds()
+			}
+			h.hashrateLock.Unlock()
+			keyHashes = make(map[string]int64)
 			log.Printf("Hashrate: %.2f MH/s", hps/1000/1000)
 		}
 	}
 }
 
+// Hashrate returns the aggregate hashes per second measured over the last update interval.
+func (h *HashrateMonitor) Hashrate() float64 {
+	h.hashrateLock.RLock()
+	defer h.hashrateLock.RUnlock()
+	return h.hashrate
+}
+
+// KeyStats returns the rendering work attributed to each public key, ordered by public key.
+func (h *HashrateMonitor) KeyStats() []RendererKeyStats {
+	h.hashrateLock.RLock()
+	defer h.hashrateLock.RUnlock()
+	stats := make([]RendererKeyStats, 0, len(h.keyStats))
+	for _, s := range h.keyStats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return pubKeyToString(stats[i].PublicKey) < pubKeyToString(stats[j].PublicKey)
+	})
+	return stats
+}
+
+// WriteMetrics writes the aggregate and per key stats in the Prometheus text exposition format.
+func (h *HashrateMonitor) WriteMetrics(w io.Writer) error {
+	stats := h.KeyStats()
+	metrics := []struct {
+		name, help, kind string
+		value            func(RendererKeyStats) float64
+	}{
+		{"focalpoint_renderer_hashrate", "Hashes per second over the last minute by public key", "gauge",
+			func(s RendererKeyStats) float64 { return s.Hashrate }},
+		{"focalpoint_renderer_hashes_total", "Hashes computed since startup by public key", "counter",
+			func(s RendererKeyStats) float64 { return float64(s.Hashes) }},
+		{"focalpoint_renderer_views_total", "Views rendered since startup by public key", "counter",
+			func(s RendererKeyStats) float64 { return float64(s.ViewsRendered) }},
+	}
+	_, err := fmt.Fprintf(w, "# HELP focalpoint_hashrate Hashes per second over the last minute\n"+
+		"# TYPE focalpoint_hashrate gauge\nfocalpoint_hashrate %g\n", h.Hashrate())
+	if err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+			return err
+		}
+		for _, s := range stats {
+			// base64 needs no escaping in a label value
+			_, err := fmt.Fprintf(w, "%s{public_key=\"%s\"} %g\n", m.name, pubKeyToString(s.PublicKey), m.value(s))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Shutdown stops the hashrate monitor synchronously.
 func (h *HashrateMonitor) Shutdown() {
 	close(h.shutdownChan)
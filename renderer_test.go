@@ -0,0 +1,82 @@
+package focalpoint
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestHashrateMonitorKeyStats(t *testing.T) {
+	pubKey1, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey2, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashUpdateChan := make(chan HashUpdate)
+	monitor := NewHashrateMonitor(hashUpdateChan)
+	monitor.Run()
+	hashUpdateChan <- HashUpdate{PublicKey: pubKey1, Hashes: 100}
+	hashUpdateChan <- HashUpdate{PublicKey: pubKey2, Hashes: 50, Views: 1}
+	hashUpdateChan <- HashUpdate{PublicKey: pubKey1, Hashes: 25, Views: 1}
+	hashUpdateChan <- HashUpdate{PublicKey: pubKey1, Hashes: 5}
+	monitor.Shutdown()
+
+	stats := monitor.KeyStats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats for 2 keys, found %d", len(stats))
+	}
+	for _, s := range stats {
+		var hashes, views int64
+		switch {
+		case bytes.Equal(s.PublicKey, pubKey1):
+			hashes, views = 130, 1
+		case bytes.Equal(s.PublicKey, pubKey2):
+			hashes, views = 50, 1
+		default:
+			t.Fatalf("Unexpected public key %s", pubKeyToString(s.PublicKey))
+		}
+		if s.Hashes != hashes {
+			t.Fatalf("Expected %d hashes, found %d", hashes, s.Hashes)
+		}
+		if s.ViewsRendered != views {
+			t.Fatalf("Expected %d views, found %d", views, s.ViewsRendered)
+		}
+		if s.LastRendered == 0 {
+			t.Fatal("Expected last rendered time to be set")
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := monitor.WriteMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	metric := "focalpoint_renderer_hashes_total{public_key=\"" + pubKeyToString(pubKey1) + "\"} 130\n"
+	if !strings.Contains(buf.String(), metric) {
+		t.Fatalf("Expected metrics to contain %q, found:\n%s", metric, buf.String())
+	}
+}
+
+func TestShouldRefreshView(t *testing.T) {
+	viewWith := func(count int) *View {
+		return &View{Considerations: make([]*Consideration, count)}
+	}
+
+	// just the viewpoint
+	if shouldRefreshView(viewWith(1), renderRefreshThreshold-1) {
+		t.Fatal("Expected no refresh below the threshold")
+	}
+	if !shouldRefreshView(viewWith(1), renderRefreshThreshold) {
+		t.Fatal("Expected a refresh at the threshold")
+	}
+
+	// a full view can't take any more however long the queue is
+	if shouldRefreshView(viewWith(MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW), 10*MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW) {
+		t.Fatal("Expected a full view never to be refreshed")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	. "github.com/inconsiderable/focal-point"
+	"github.com/inconsiderable/focal-point/internal/config"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Render views for one of a list of nodes over the work protocol, failing over between them
+func main() {
+	DefaultNode := "127.0.0.1:" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
+	nodesPtr := flag.String("nodes", DefaultNode,
+		"Comma-separated addresses of nodes to render for, in order of preference")
+	pubKeyPtr := flag.String("pubkey", "", "A public key which receives newly rendered view points")
+	keyFilePtr := flag.String("keyfile", "", "Path to a file containing public keys to use when rendering")
+	memoPtr := flag.String("memo", "", "A memo to include in newly rendered views")
+	numRenderersPtr := flag.Int("numrenderers", 1, "Number of renderers to run")
+	cfg := config.Register(flag.CommandLine, config.Network|config.TLSClient|config.Logging)
+	flag.Parse()
+
+	// switch networks before doing anything else
+	if err := cfg.Apply(); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.NetworkParams != nil && *nodesPtr == DefaultNode {
+		*nodesPtr = "127.0.0.1:" + strconv.Itoa(DEFAULT_FOCALPOINT_PORT)
+	}
+
+	var nodes []string
+	for _, node := range strings.Split(*nodesPtr, ",") {
+		if node = strings.TrimSpace(node); len(node) != 0 {
+			nodes = append(nodes, config.WithDefaultPort(node))
+		}
+	}
+	if len(nodes) == 0 {
+		log.Fatal("At least one node address required")
+	}
+	if *numRenderersPtr <= 0 {
+		log.Fatal("At least one renderer required")
+	}
+	if len(*pubKeyPtr) == 0 && len(*keyFilePtr) == 0 {
+		log.Fatal("-pubkey or -keyfile argument required to receive newly rendered view points")
+	}
+	if len(*pubKeyPtr) != 0 && len(*keyFilePtr) != 0 {
+		log.Fatal("Specify only one of -pubkey or -keyfile but not both")
+	}
+	pubKeys, err := loadPublicKeys(*pubKeyPtr, *keyFilePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// load genesis view
+	var genesisView View
+	if err := json.Unmarshal([]byte(GenesisViewJson), &genesisView); err != nil {
+		log.Fatal(err)
+	}
+	genesisID, err := genesisView.ID()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Rendering for %d node(s) with %d renderer(s)\n", len(nodes), *numRenderersPtr)
+
+	hashUpdateChan := make(chan HashUpdate, *numRenderersPtr)
+	hashrateMonitor := NewHashrateMonitor(hashUpdateChan)
+	hashrateMonitor.Run()
+	worker := newRenderWorker(nodes, genesisID, cfg.TLSVerify, pubKeys, *memoPtr, *numRenderersPtr,
+		hashUpdateChan)
+	worker.Run()
+
+	// shutdown on ctrl-c
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	<-c
+
+	log.Println("Shutting down...")
+	worker.Shutdown()
+	hashrateMonitor.Shutdown()
+	log.Println("Exiting")
+}
+
+func loadPublicKeys(pubKeyEncoded, keyFile string) ([]ed25519.PublicKey, error) {
+	var pubKeysEncoded []string
+	var pubKeys []ed25519.PublicKey
+
+	if len(pubKeyEncoded) != 0 {
+		pubKeysEncoded = append(pubKeysEncoded, pubKeyEncoded)
+	} else {
+		file, err := os.Open(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			pubKeysEncoded = append(pubKeysEncoded, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if len(pubKeysEncoded) == 0 {
+			return nil, fmt.Errorf("No public keys found in '%s'", keyFile)
+		}
+	}
+
+	for _, pubKeyEncoded = range pubKeysEncoded {
+		pubKeyBytes, err := base64.StdEncoding.DecodeString(pubKeyEncoded)
+		if err != nil {
+			return nil, err
+		}
+		if len(pubKeyBytes) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Invalid public key: %s", pubKeyEncoded)
+		}
+		pubKeys = append(pubKeys, ed25519.PublicKey(pubKeyBytes))
+	}
+	return pubKeys, nil
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/inconsiderable/focal-point"
+	"golang.org/x/crypto/ed25519"
+)
+
+// renderWorkerMaxLag is how many views the node a render worker is rendering for may fall behind
+// the best of its other nodes before the worker fails over.
+const renderWorkerMaxLag = 2
+
+// how often a render worker checks on its nodes
+const renderWorkerCheckInterval = 30 * time.Second
+
+// a render worker fails over if its node sends nothing for this long
+const renderWorkerTimeout = 90 * time.Second
+
+// how long to wait for a node which isn't being rendered for to report its tip
+const renderWorkerProbeTimeout = 10 * time.Second
+
+// how long to wait for a message to be written to a node
+const renderWorkerWriteWait = 30 * time.Second
+
+// renderWorker renders views for a node over the work protocol without running a node itself. It's
+// given a list of nodes in order of preference and renders for one at a time. If that node stops
+// responding or falls more than renderWorkerMaxLag views behind another of the nodes the worker
+// fails over to the first node which is caught up, and it returns to a node earlier in the list once
+// that node is at least as far along, so hash power stays productive while a node is down for
+// maintenance. Each connection renders for one of the public keys chosen at random.
+type renderWorker struct {
+	nodes          []string
+	genesisID      ViewID
+	tlsVerify      bool
+	pubKeys        []ed25519.PublicKey
+	memo           string
+	hashUpdateChan chan HashUpdate
+	workChans      []chan *WorkMessage // one per renderer. nil stops rendering until there's new work
+	solutionChan   chan renderSolution
+	current        int               // index of the node being rendered for
+	pubKey         ed25519.PublicKey // the key the current node is rendering for
+	currentLock    sync.RWMutex
+	shutdownChan   chan struct{}
+	wg             sync.WaitGroup
+}
+
+// A header which satisfies the target of the given work
+type renderSolution struct {
+	workID int32
+	header *ViewHeader
+}
+
+// A renderer's copy of the work it's searching
+type renderWorkerJob struct {
+	workID  int32
+	header  *ViewHeader
+	minTime int64
+	target  *big.Int
+}
+
+// newRenderWorker returns a new renderWorker instance which runs numRenderers renderers.
+func newRenderWorker(nodes []string, genesisID ViewID, tlsVerify bool, pubKeys []ed25519.PublicKey,
+	memo string, numRenderers int, hashUpdateChan chan HashUpdate) *renderWorker {
+	w := &renderWorker{
+		nodes:          nodes,
+		genesisID:      genesisID,
+		tlsVerify:      tlsVerify,
+		pubKeys:        pubKeys,
+		memo:           memo,
+		hashUpdateChan: hashUpdateChan,
+		solutionChan:   make(chan renderSolution, numRenderers),
+		shutdownChan:   make(chan struct{}),
+	}
+	for i := 0; i < numRenderers; i++ {
+		w.workChans = append(w.workChans, make(chan *WorkMessage, 1))
+	}
+	return w
+}
+
+// Run executes the render worker's main loop and its renderers in their own goroutines.
+func (w *renderWorker) Run() {
+	w.wg.Add(1 + len(w.workChans))
+	go w.run()
+	for i := range w.workChans {
+		go w.render(i)
+	}
+}
+
+// Node returns the address of the node currently being rendered for.
+func (w *renderWorker) Node() string {
+	w.currentLock.RLock()
+	defer w.currentLock.RUnlock()
+	return w.nodes[w.current]
+}
+
+// Shutdown stops the render worker synchronously.
+func (w *renderWorker) Shutdown() {
+	close(w.shutdownChan)
+	w.wg.Wait()
+	log.Println("Render worker shutdown")
+}
+
+func (w *renderWorker) run() {
+	defer w.wg.Done()
+
+	index, failures := 0, 0
+	for {
+		next, err := w.renderFor(index)
+		select {
+		case <-w.shutdownChan:
+			log.Println("Render worker shutting down...")
+			return
+		default:
+		}
+		if err != nil {
+			log.Printf("Error rendering for %s: %s\n", w.nodes[index], err)
+			failures++
+			next = (index + 1) % len(w.nodes)
+		} else {
+			failures = 0
+		}
+		if failures != 0 && failures%len(w.nodes) == 0 {
+			// none of them are available. don't spin
+			log.Printf("No nodes available, trying again in %s\n", renderWorkerCheckInterval)
+			select {
+			case <-w.shutdownChan:
+				log.Println("Render worker shutting down...")
+				return
+			case <-time.After(renderWorkerCheckInterval):
+			}
+		}
+		index = next
+	}
+}
+
+// Render for the node at the given index until it fails or a better node is found. Returns the
+// index of the node to render for next
+func (w *renderWorker) renderFor(index int) (int, error) {
+	addr := w.nodes[index]
+	conn, err := DialNode(addr, w.genesisID, w.tlsVerify)
+	if err != nil {
+		return index, err
+	}
+	defer conn.Close()
+
+	pubKey := w.pubKeys[rand.Intn(len(w.pubKeys))]
+	w.currentLock.Lock()
+	w.current, w.pubKey = index, pubKey
+	w.currentLock.Unlock()
+	// idle the renderers until there's work from the next node
+	defer w.setWork(nil)
+
+	log.Printf("Requesting work from %s\n", addr)
+	err = writeRenderWorkerMessage(conn, "get_work",
+		GetWorkMessage{PublicKeys: []ed25519.PublicKey{pubKey}, Memo: w.memo})
+	if err != nil {
+		return index, err
+	}
+
+	// read from the node in the background
+	messageChan := make(chan []byte)
+	errChan := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				errChan <- err
+				return
+			}
+			select {
+			case messageChan <- message:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(renderWorkerCheckInterval)
+	defer ticker.Stop()
+
+	// the height the node's work builds on. unknown until the first work arrives
+	height := int64(-1)
+	lastMessage := time.Now()
+	var probeChan chan int
+	var submitted int32 // the work last submitted
+
+	for {
+		select {
+		case message := <-messageChan:
+			lastMessage = time.Now()
+			var body json.RawMessage
+			m := Message{Body: &body}
+			if err := json.Unmarshal(message, &m); err != nil {
+				return index, err
+			}
+			switch m.Type {
+			case "work":
+				var wm WorkMessage
+				if err := json.Unmarshal(body, &wm); err != nil {
+					return index, err
+				}
+				if len(wm.Error) != 0 {
+					return index, fmt.Errorf("Work refused: %s", wm.Error)
+				}
+				if wm.Header == nil {
+					return index, fmt.Errorf("Received work without a header")
+				}
+				log.Printf("Received work %d at height %d from %s\n", wm.WorkID, wm.Header.Height, addr)
+				height = wm.Header.Height - 1
+				w.setWork(&wm)
+
+			case "submit_work_result":
+				var swr SubmitWorkResultMessage
+				if err := json.Unmarshal(body, &swr); err != nil {
+					return index, err
+				}
+				if len(swr.Error) != 0 {
+					log.Printf("Work %d rejected by %s: %s\n", swr.WorkID, addr, swr.Error)
+				} else {
+					log.Printf("Work %d accepted by %s\n", swr.WorkID, addr)
+					w.hashUpdateChan <- HashUpdate{PublicKey: pubKey, Views: 1}
+				}
+			}
+
+		case solution := <-w.solutionChan:
+			if solution.workID == submitted {
+				// another renderer solved it first
+				continue
+			}
+			submitted = solution.workID
+			log.Printf("Submitting work %d to %s\n", solution.workID, addr)
+			err := writeRenderWorkerMessage(conn, "submit_work",
+				SubmitWorkMessage{WorkID: solution.workID, Header: solution.header})
+			if err != nil {
+				return index, err
+			}
+
+		case err := <-errChan:
+			return index, err
+
+		case next := <-probeChan:
+			probeChan = nil
+			if next != index {
+				log.Printf("Failing over from %s to %s\n", addr, w.nodes[next])
+				return next, nil
+			}
+
+		case <-ticker.C:
+			if time.Since(lastMessage) > renderWorkerTimeout {
+				return index, fmt.Errorf("No response for %s", time.Since(lastMessage).Round(time.Second))
+			}
+			// make sure the node is still answering
+			if err := writeRenderWorkerMessage(conn, "get_tip_header", nil); err != nil {
+				return index, err
+			}
+			if probeChan == nil && height >= 0 && len(w.nodes) > 1 {
+				probeChan = make(chan int, 1)
+				go func(probeChan chan<- int, height int64) {
+					probeChan <- w.chooseNode(index, height)
+				}(probeChan, height)
+			}
+
+		case <-w.shutdownChan:
+			return index, nil
+		}
+	}
+}
+
+// Asks the other nodes for their tips and returns the index of the node to render for given the
+// current node and the height it's building on
+func (w *renderWorker) chooseNode(current int, currentHeight int64) int {
+	heights := make([]int64, len(w.nodes))
+	var wg sync.WaitGroup
+	for i := range w.nodes {
+		if i == current {
+			heights[i] = currentHeight
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			height, err := probeNodeHeight(w.nodes[i], w.genesisID, w.tlsVerify)
+			if err != nil {
+				log.Printf("Unable to reach %s: %s\n", w.nodes[i], err)
+				height = -1
+			}
+			heights[i] = height
+		}(i)
+	}
+	wg.Wait()
+
+	next := chooseRenderNode(current, heights)
+	if next != current {
+		log.Printf("%s is at height %d, %s is at height %d\n",
+			w.nodes[current], currentHeight, w.nodes[next], heights[next])
+	}
+	return next
+}
+
+// Given each node's height, -1 if it's unreachable, returns the index of the node to render for.
+// If the current node is more than renderWorkerMaxLag behind the best the first node within that
+// lag is chosen. Otherwise a node earlier in the list is preferred if it's at least as far along
+func chooseRenderNode(current int, heights []int64) int {
+	best := heights[current]
+	for _, height := range heights {
+		if height > best {
+			best = height
+		}
+	}
+	if heights[current] < best-renderWorkerMaxLag {
+		for i, height := range heights {
+			if height >= best-renderWorkerMaxLag {
+				return i
+			}
+		}
+	}
+	for i := 0; i < current; i++ {
+		if heights[i] >= heights[current] {
+			return i
+		}
+	}
+	return current
+}
+
+// Hand each renderer its own copy of the work
+func (w *renderWorker) setWork(work *WorkMessage) {
+	for _, workChan := range w.workChans {
+		// replace any work the renderer hasn't picked up yet
+		select {
+		case <-workChan:
+		default:
+		}
+		workChan <- work
+	}
+}
+
+// Returns the public key the current node is rendering for
+func (w *renderWorker) currentPubKey() ed25519.PublicKey {
+	w.currentLock.RLock()
+	defer w.currentLock.RUnlock()
+	return w.pubKey
+}
+
+// A renderer searches its share of the nonce space for the latest work
+func (w *renderWorker) render(num int) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	var hashes int64
+	var job *renderWorkerJob
+	for {
+		if job == nil {
+			// wait for work
+			select {
+			case work := <-w.workChans[num]:
+				job = w.newJob(work, num)
+			case <-ticker.C:
+				w.hashUpdateChan <- HashUpdate{PublicKey: w.currentPubKey(), Hashes: hashes}
+				hashes = 0
+			case <-w.shutdownChan:
+				log.Printf("Renderer %d shutting down...\n", num)
+				return
+			}
+			continue
+		}
+
+		select {
+		case work := <-w.workChans[num]:
+			job = w.newJob(work, num)
+
+		case <-ticker.C:
+			// update hashcount for hashrate monitor
+			w.hashUpdateChan <- HashUpdate{PublicKey: w.currentPubKey(), Hashes: hashes}
+			hashes = 0
+
+			// update view time every so often
+			if now := time.Now().Unix(); now >= job.minTime {
+				job.header.Time = now
+			}
+
+		case <-w.shutdownChan:
+			log.Printf("Renderer %d shutting down...\n", num)
+			return
+
+		default:
+			// hash the view and check the proof-of-work
+			idInt, attempts := job.header.IDFast(num)
+			hashes += attempts
+			if idInt.Cmp(job.target) <= 0 {
+				// found a solution
+				id := new(ViewID).SetBigInt(idInt)
+				log.Printf("Renderer %d rendered new view %s\n", num, *id)
+				// the job is dropped so the copy is the only user of its hasher
+				header := *job.header
+				select {
+				case w.solutionChan <- renderSolution{workID: job.workID, header: &header}:
+				case <-w.shutdownChan:
+				}
+				// the node sends new work once it has a new tip
+				job = nil
+			} else {
+				// no solution yet
+				job.header.Nonce += attempts
+				if job.header.Nonce > MAX_NUMBER {
+					job.header.Nonce = 0
+				}
+			}
+		}
+	}
+}
+
+// Returns a renderer's copy of the work. Each renderer starts at a different nonce so they don't
+// search the same ones
+func (w *renderWorker) newJob(work *WorkMessage, num int) *renderWorkerJob {
+	if work == nil {
+		return nil
+	}
+	// work is decoded from the node so the copy starts without hash state
+	header := *work.Header
+	header.Nonce = int64(num) * (MAX_NUMBER / int64(len(w.workChans)))
+	if header.Time < work.MinTime {
+		header.Time = work.MinTime
+	}
+	return &renderWorkerJob{
+		workID:  work.WorkID,
+		header:  &header,
+		minTime: work.MinTime,
+		target:  header.Target.GetBigInt(),
+	}
+}
+
+// Returns the height of a node's tip
+func probeNodeHeight(addr string, genesisID ViewID, tlsVerify bool) (int64, error) {
+	conn, err := DialNode(addr, genesisID, tlsVerify)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(renderWorkerProbeTimeout))
+	if err := writeRenderWorkerMessage(conn, "get_tip_header", nil); err != nil {
+		return 0, err
+	}
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		var body json.RawMessage
+		m := Message{Body: &body}
+		if err := json.Unmarshal(message, &m); err != nil {
+			return 0, err
+		}
+		if m.Type != "tip_header" {
+			continue
+		}
+		var th TipHeaderMessage
+		if err := json.Unmarshal(body, &th); err != nil {
+			return 0, err
+		}
+		if th.ViewHeader == nil {
+			return 0, fmt.Errorf("Received a tip without a header")
+		}
+		return th.ViewHeader.Height, nil
+	}
+}
+
+func writeRenderWorkerMessage(conn *websocket.Conn, messageType string, body interface{}) error {
+	conn.SetWriteDeadline(time.Now().Add(renderWorkerWriteWait))
+	return conn.WriteJSON(Message{Type: messageType, Body: body})
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestChooseRenderNode(t *testing.T) {
+	tests := []struct {
+		current int
+		heights []int64
+		want    int
+	}{
+		// caught up and preferred
+		{0, []int64{100, 100, 100}, 0},
+		// a backup a little ahead isn't worth switching for
+		{0, []int64{100, 100 + renderWorkerMaxLag, 90}, 0},
+		// fail over to the first node which is caught up
+		{0, []int64{100, 101, 100 + renderWorkerMaxLag + 1}, 1},
+		{0, []int64{100, 99, 100 + renderWorkerMaxLag + 1}, 2},
+		{0, []int64{100, -1, 100 + renderWorkerMaxLag + 1}, 2},
+		// return to a preferred node once it's as far along
+		{2, []int64{-1, 100, 100}, 1},
+		{2, []int64{99, 99, 100}, 2},
+		{1, []int64{101, 100}, 0},
+	}
+	for i, test := range tests {
+		if got := chooseRenderNode(test.current, test.heights); got != test.want {
+			t.Fatalf("Test %d: expected node %d, got %d", i, test.want, got)
+		}
+	}
+}
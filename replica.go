@@ -0,0 +1,182 @@
+package focalpoint
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// how many times to retry a snapshot when the node changes the database while it's being taken
+const maxSnapshotAttempts = 10
+
+// the databases a replica copies
+var replicaDatabases = []string{"ledger.db", "headers.db", "peers.db"}
+
+// Replica is a point-in-time copy of a node's databases which can be opened read-only while the
+// node is still running, for explorers, analytics and the inspector. LevelDB only allows one
+// process to open a database so the copy is taken instead. Table files are immutable and are hard
+// linked where possible, so taking a replica is cheap. Views are read from the node's own views
+// directory since view files are never modified once written.
+type Replica struct {
+	dataDir string
+	dir     string
+}
+
+// NewReplica snapshots the databases in the node's data directory. The ledger is copied before
+// the view headers so every view it refers to is present in the copy. Close removes the copy.
+// Copies are kept under the data directory's "replicas" directory, which is safe to empty
+// whenever nothing is reading from a replica.
+func NewReplica(dataDir string) (*Replica, error) {
+	// keep it on the same filesystem so the tables can be linked instead of copied
+	replicasDir := filepath.Join(dataDir, "replicas")
+	if err := os.MkdirAll(replicasDir, 0700); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(replicasDir, "")
+	if err != nil {
+		return nil, err
+	}
+	r := &Replica{dataDir: dataDir, dir: dir}
+	for _, name := range replicaDatabases {
+		srcPath := filepath.Join(dataDir, name)
+		if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+			continue
+		}
+		if err := snapshotDatabase(srcPath, filepath.Join(dir, name)); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// DatabasePath returns the path of the named database's copy, e.g. "ledger.db".
+func (r *Replica) DatabasePath(name string) string {
+	return filepath.Join(r.dir, name)
+}
+
+// ViewsPath returns the path of the node's views directory.
+func (r *Replica) ViewsPath() string {
+	return filepath.Join(r.dataDir, "views")
+}
+
+// OpenViewStorage opens the replica's view storage read-only.
+func (r *Replica) OpenViewStorage() (*ViewStorageDisk, error) {
+	return NewViewStorageDisk(r.ViewsPath(), r.DatabasePath("headers.db"), true, false)
+}
+
+// OpenLedger opens the replica's ledger read-only.
+func (r *Replica) OpenLedger(viewStore ViewStorage, conGraph *Graph) (*LedgerDisk, error) {
+	return NewLedgerDisk(r.DatabasePath("ledger.db"), true, false, viewStore, conGraph)
+}
+
+// Close removes the copy. Anything opened from the replica must be closed first.
+func (r *Replica) Close() error {
+	return os.RemoveAll(r.dir)
+}
+
+// Copy a LevelDB database which may be open by another process. Once CURRENT names a manifest
+// the files it lists are only deleted after a newer manifest record is written, so the copy is
+// consistent if the manifest didn't change while copying. A journal may be copied mid-write but
+// LevelDB drops a torn final record when it's opened
+func snapshotDatabase(srcPath, dstPath string) error {
+	for attempt := 0; attempt < maxSnapshotAttempts; attempt++ {
+		if err := os.RemoveAll(dstPath); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dstPath, 0700); err != nil {
+			return err
+		}
+		ok, err := copyDatabaseFiles(srcPath, dstPath)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("Database %s kept changing while taking a snapshot", srcPath)
+}
+
+// Returns false if the database's manifest changed during the copy
+func copyDatabaseFiles(srcPath, dstPath string) (bool, error) {
+	current, err := ioutil.ReadFile(filepath.Join(srcPath, "CURRENT"))
+	if err != nil {
+		return false, err
+	}
+	manifest := strings.TrimSpace(string(current))
+	manifestSize, err := copyFile(filepath.Join(srcPath, manifest), filepath.Join(dstPath, manifest))
+	if os.IsNotExist(err) {
+		// replaced since reading CURRENT
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	files, err := ioutil.ReadDir(srcPath)
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		name := file.Name()
+		src, dst := filepath.Join(srcPath, name), filepath.Join(dstPath, name)
+		var err error
+		switch {
+		case name == manifest, name == "LOCK", strings.HasPrefix(name, "LOG"):
+			continue
+		case strings.HasSuffix(name, ".ldb"), strings.HasSuffix(name, ".sst"):
+			if err = os.Link(src, dst); err != nil && !os.IsNotExist(err) {
+				// possibly on another filesystem
+				_, err = copyFile(src, dst)
+			}
+		case strings.HasSuffix(name, ".log"), strings.HasPrefix(name, "MANIFEST-"):
+			_, err = copyFile(src, dst)
+		default:
+			continue
+		}
+		if err != nil && !os.IsNotExist(err) {
+			// files removed since listing them were obsolete if the manifest hasn't changed
+			return false, err
+		}
+	}
+
+	// CURRENT is written last so the copy only opens once it's complete
+	if err := ioutil.WriteFile(filepath.Join(dstPath, "CURRENT"), current, 0600); err != nil {
+		return false, err
+	}
+	current2, err := ioutil.ReadFile(filepath.Join(srcPath, "CURRENT"))
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(filepath.Join(srcPath, manifest))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return string(current) == string(current2) && info.Size() == manifestSize, nil
+}
+
+// Copy a file and return the number of bytes copied
+func copyFile(srcPath, dstPath string) (int64, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return n, err
+	}
+	return n, dst.Close()
+}
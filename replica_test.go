@@ -0,0 +1,64 @@
+package focalpoint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+func TestSnapshotOpenDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replica")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// keep the source open as a running node would
+	srcPath := filepath.Join(dir, "ledger.db")
+	db, err := leveldb.OpenFile(srcPath, &opt.Options{WriteBuffer: 64 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// enough to flush some tables with the rest left in the journal
+	for i := 0; i < 5000; i++ {
+		if err := db.Put([]byte(fmt.Sprintf("key%d", i)), make([]byte, 100), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replica, err := NewReplica(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicaDB, err := leveldb.OpenFile(replica.DatabasePath("ledger.db"), &opt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5000; i++ {
+		if ok, err := replicaDB.Has([]byte(fmt.Sprintf("key%d", i)), nil); err != nil || !ok {
+			t.Fatalf("Key %d missing from the replica, error: %v", i, err)
+		}
+	}
+
+	// later writes to the source aren't seen
+	if err := db.Put([]byte("later"), []byte{0x1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := replicaDB.Has([]byte("later"), nil); err != nil || ok {
+		t.Fatalf("Expected the replica to be unchanged, error: %v", err)
+	}
+
+	replicaDB.Close()
+	if err := replica.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(replica.DatabasePath("ledger.db")); !os.IsNotExist(err) {
+		t.Fatal("Expected the replica to be removed")
+	}
+}
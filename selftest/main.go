@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/inconsiderable/focal-point/internal/config"
+)
+
+// Run two nodes in-process on a throwaway network and check they agree after rendering views,
+// sending considerations and reorganizing
+func main() {
+	dataDirPtr := flag.String("datadir", "",
+		"Path to a directory to keep the test nodes' data in instead of a temporary one")
+	cfg := config.Register(flag.CommandLine, config.Logging)
+	flag.Parse()
+
+	if err := cfg.Apply(); err != nil {
+		log.Fatal(err)
+	}
+
+	dataDir := *dataDirPtr
+	if len(dataDir) == 0 {
+		var err error
+		if dataDir, err = ioutil.TempDir("", "focalpoint-selftest"); err != nil {
+			log.Fatal(err)
+		}
+	} else if err := os.MkdirAll(dataDir, 0700); err != nil {
+		log.Fatal(err)
+	}
+
+	// the nodes are chatty. keep their output with their data unless -logfile says otherwise
+	if len(cfg.LogFile) == 0 {
+		logPath := filepath.Join(dataDir, "selftest.log")
+		logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer logFile.Close()
+		log.SetOutput(logFile)
+	}
+
+	err := runSelfTest(dataDir, func(step string) {
+		fmt.Printf("ok   %s\n", step)
+	})
+	if err != nil {
+		fmt.Printf("FAIL %s\n", err)
+		fmt.Printf("Logs and data are in '%s'\n", dataDir)
+		os.Exit(1)
+	}
+	if len(*dataDirPtr) == 0 {
+		os.RemoveAll(dataDir)
+	}
+	fmt.Println("PASS")
+}
@@ -0,0 +1,694 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/inconsiderable/focal-point"
+	"golang.org/x/crypto/ed25519"
+)
+
+// the self test network's viewpoint maturity. It's short so rendered views can be spent soon after
+const selfTestMaturity = 2
+
+// how long to wait for the nodes to catch up with each other before giving up
+const selfTestWait = 2 * time.Minute
+
+// runSelfTest is a smoke test for operators to run after upgrading. It starts two nodes in-process on
+// a throwaway network in dataDir, renders views, sends considerations between generated minds, forces
+// a reorganization and checks both nodes end up with the same ledger and graph. report is called with
+// the name of each step once it passes. It switches the active network so it needs its own process.
+func runSelfTest(dataDir string, report func(step string)) error {
+	t := &selfTest{dataDir: dataDir}
+	defer t.shutdown()
+
+	steps := []struct {
+		name string
+		fn   func() error
+	}{
+		{"create network", t.createNetwork},
+		{"start nodes", t.startNodes},
+		{"render views", t.renderViews},
+		{"send considerations", t.sendConsiderations},
+		{"reorganize", t.reorganize},
+		{"check ledgers", t.checkLedgers},
+		{"check indexers", t.checkIndexers},
+	}
+	for _, step := range steps {
+		if err := step.fn(); err != nil {
+			return fmt.Errorf("%s: %s", step.name, err)
+		}
+		report(step.name)
+	}
+	return nil
+}
+
+type selfTest struct {
+	dataDir    string
+	genesisID  ViewID
+	renderKeys []ed25519.PrivateKey // one per node
+	pubKeys    []ed25519.PublicKey  // render keys followed by mind keys
+	nodes      []*selfTestNode
+	minds      []*Mind // one per node
+	cnIDs      []ConsiderationID
+}
+
+type selfTestNode struct {
+	port        int
+	viewStore   *ViewStorageDisk
+	ledger      *LedgerDisk
+	peerStore   *PeerStorageDisk
+	cnQueue     *ConsiderationQueueMemory
+	processor   *Processor
+	indexer     *Indexer
+	peerManager *PeerManager
+}
+
+// Render a genesis view with an easy target and switch to the network
+func (t *selfTest) createNetwork() error {
+	for i := 0; i < 2; i++ {
+		pubKey, privKey, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return err
+		}
+		t.renderKeys = append(t.renderKeys, privKey)
+		t.pubKeys = append(t.pubKeys, pubKey)
+	}
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+
+	target := "00ffff" + strings.Repeat("0", 58)
+	targetBytes, err := hex.DecodeString(target)
+	if err != nil {
+		return err
+	}
+	var targetID ViewID
+	copy(targetID[:], targetBytes)
+
+	viewpoint := NewConsideration(nil, t.pubKeys[0], 0, 0, 0, "focal point self test")
+	view, err := NewView(ViewID{}, 0, targetID, ViewID{}, []*Consideration{viewpoint})
+	if err != nil {
+		return err
+	}
+	if t.genesisID, err = solveView(view); err != nil {
+		return err
+	}
+	viewJson, err := json.Marshal(view)
+	if err != nil {
+		return err
+	}
+	return SetNetwork(&NetworkParams{
+		Name:              "selftest",
+		InitialTarget:     target,
+		TargetSpacing:     TARGET_SPACING,
+		ViewpointMaturity: selfTestMaturity,
+		Port:              port,
+		GenesisID:         t.genesisID,
+		GenesisViewJson:   string(viewJson),
+	})
+}
+
+// Start both nodes with the second connecting to the first and a mind connected to each
+func (t *selfTest) startNodes() error {
+	first, err := t.startNode(DEFAULT_FOCALPOINT_PORT, "")
+	if err != nil {
+		return err
+	}
+	if err := waitForListener(first.port); err != nil {
+		return err
+	}
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	second, err := t.startNode(port, "127.0.0.1:"+strconv.Itoa(first.port))
+	if err != nil {
+		return err
+	}
+	if err := waitForListener(second.port); err != nil {
+		return err
+	}
+	err = waitFor("the nodes to connect", func() (bool, error) {
+		_, firstIn := peerCounts(first.peerManager)
+		secondOut, _ := peerCounts(second.peerManager)
+		return firstIn != 0 && secondOut != 0, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, n := range t.nodes {
+		mind, err := NewMind(filepath.Join(t.dataDir, "mind"+strconv.Itoa(i+1)), false)
+		if err != nil {
+			return err
+		}
+		t.minds = append(t.minds, mind)
+		if _, err := mind.SetPassphrase("selftest"); err != nil {
+			return err
+		}
+		if err := mind.Connect("127.0.0.1:"+strconv.Itoa(n.port), t.genesisID, false); err != nil {
+			return err
+		}
+		mind.Run()
+	}
+	return nil
+}
+
+// Start a node the way the client does. It's added to the test before it's fully started so
+// whatever was started is shut down if something fails
+func (t *selfTest) startNode(port int, peer string) (*selfTestNode, error) {
+	n := &selfTestNode{port: port}
+	t.nodes = append(t.nodes, n)
+	dataDir := filepath.Join(t.dataDir, "node"+strconv.Itoa(len(t.nodes)))
+	if err := MigrateDatadir(dataDir); err != nil {
+		return nil, err
+	}
+
+	genesisView := new(View)
+	if err := json.Unmarshal([]byte(GenesisViewJson), genesisView); err != nil {
+		return nil, err
+	}
+
+	conGraph := NewGraph()
+	var err error
+	n.viewStore, err = NewViewStorageDisk(filepath.Join(dataDir, "views"), filepath.Join(dataDir, "headers.db"),
+		false, false)
+	if err != nil {
+		return nil, err
+	}
+	n.ledger, err = NewLedgerDisk(filepath.Join(dataDir, "ledger.db"), false, false, n.viewStore, conGraph)
+	if err != nil {
+		return nil, err
+	}
+	n.peerStore, err = NewPeerStorageDisk(filepath.Join(dataDir, "peers.db"), false, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	n.cnQueue = NewConsiderationQueueMemory(n.ledger, conGraph)
+	n.processor = NewProcessor(t.genesisID, n.viewStore, n.cnQueue, n.ledger)
+	n.processor.Run()
+	if err := n.processor.ProcessView(t.genesisID, genesisView, ""); err != nil {
+		return nil, err
+	}
+	n.indexer = NewIndexer(conGraph, n.viewStore, n.ledger, n.processor, t.genesisID)
+	n.indexer.Run()
+	n.peerManager = NewPeerManager(t.genesisID, n.peerStore, nil, n.viewStore, n.ledger, n.processor, n.indexer,
+		n.cnQueue, dataDir, "", peer, "", "", port, MAX_INBOUND_PEER_CONNECTIONS, true, false, false, false,
+		make(map[string]bool), nil, nil)
+	n.peerManager.Run()
+	return n, nil
+}
+
+// Render enough views on the first node for its first viewpoints to mature
+func (t *selfTest) renderViews() error {
+	for i := 0; i <= selfTestMaturity; i++ {
+		if _, err := t.nodes[0].renderTip(t.pubKeys[0]); err != nil {
+			return err
+		}
+	}
+	return t.waitForSync()
+}
+
+// Send a consideration from a matured render key to a new key through the second node and have the
+// first node confirm it, then pass it on to another new key through the first node and have the second
+// node confirm that
+func (t *selfTest) sendConsiderations() error {
+	if err := t.minds[1].AddKey(t.pubKeys[0], t.renderKeys[0]); err != nil {
+		return err
+	}
+	for _, mind := range t.minds {
+		pubKeys, err := mind.NewKeys(1)
+		if err != nil {
+			return err
+		}
+		t.pubKeys = append(t.pubKeys, pubKeys[0])
+	}
+
+	sends := []struct {
+		mind     *Mind
+		from, to ed25519.PublicKey
+		renderer *selfTestNode
+		pubKey   ed25519.PublicKey
+	}{
+		{t.minds[1], t.pubKeys[0], t.pubKeys[2], t.nodes[0], t.pubKeys[0]},
+		{t.minds[0], t.pubKeys[2], t.pubKeys[3], t.nodes[1], t.pubKeys[1]},
+	}
+	for _, send := range sends {
+		id, err := send.mind.Send(send.from, send.to, 0, 0, "self test")
+		if err != nil {
+			return err
+		}
+		t.cnIDs = append(t.cnIDs, id)
+		err = waitFor("the consideration to be relayed", func() (bool, error) {
+			return send.renderer.cnQueue.Exists(id), nil
+		})
+		if err != nil {
+			return err
+		}
+		viewID, err := send.renderer.renderTip(send.pubKey)
+		if err != nil {
+			return err
+		}
+		if err := t.waitForSync(); err != nil {
+			return err
+		}
+		if err := t.checkConfirmed(id, viewID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render a branch on the first node which replaces the tip and make sure both nodes switch to it and
+// confirm the consideration from the old tip again
+func (t *selfTest) reorganize() error {
+	n := t.nodes[0]
+	_, tipHeader, _, err := n.tipHeader()
+	if err != nil {
+		return err
+	}
+	forkHeader, _, err := n.viewStore.GetViewHeader(tipHeader.Previous)
+	if err != nil {
+		return err
+	}
+	if forkHeader == nil {
+		return fmt.Errorf("Missing view %s", tipHeader.Previous)
+	}
+
+	// two views off the tip's parent outweigh the tip
+	id, view, err := n.renderView(tipHeader.Previous, forkHeader, t.pubKeys[0], nil)
+	if err != nil {
+		return err
+	}
+	id, _, err = n.renderView(id, view.Header, t.pubKeys[0], nil)
+	if err != nil {
+		return err
+	}
+	if err := t.waitForSync(); err != nil {
+		return err
+	}
+	tipID, _, err := n.ledger.GetPointTip()
+	if err != nil {
+		return err
+	}
+	if *tipID != id {
+		return fmt.Errorf("Expected the branch tip %s to be the tip, found %s", id, *tipID)
+	}
+
+	cnID := t.cnIDs[len(t.cnIDs)-1]
+	for i, n := range t.nodes {
+		err := waitFor(fmt.Sprintf("node %d to queue the disconnected consideration", i+1), func() (bool, error) {
+			return n.cnQueue.Exists(cnID), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	viewID, err := n.renderTip(t.pubKeys[0])
+	if err != nil {
+		return err
+	}
+	if err := t.waitForSync(); err != nil {
+		return err
+	}
+	return t.checkConfirmed(cnID, viewID)
+}
+
+// Replay the main point to work out every key's imbalance and the totals and compare them with each
+// node's ledger
+func (t *selfTest) checkLedgers() error {
+	_, tipHeight, err := t.nodes[0].ledger.GetPointTip()
+	if err != nil {
+		return err
+	}
+
+	expect := make(map[string]int64)
+	var viewpoints []*Consideration
+	var expectStats SupplyStats
+	for height := int64(0); height <= tipHeight; height++ {
+		id, err := t.nodes[0].ledger.GetViewIDForHeight(height)
+		if err != nil {
+			return err
+		}
+		for i, n := range t.nodes[1:] {
+			id2, err := n.ledger.GetViewIDForHeight(height)
+			if err != nil {
+				return err
+			}
+			if id == nil || id2 == nil || *id != *id2 {
+				return fmt.Errorf("Node %d disagrees with node 1 at height %d", i+2, height)
+			}
+		}
+		view, err := t.nodes[0].viewStore.GetView(*id)
+		if err != nil {
+			return err
+		}
+		if view == nil {
+			return fmt.Errorf("Missing view %s", *id)
+		}
+		for _, cn := range view.Considerations {
+			if cn.IsViewpoint() {
+				viewpoints = append(viewpoints, cn)
+				continue
+			}
+			expect[pubKeyString(cn.By)]--
+			expect[pubKeyString(cn.For)]++
+			expectStats.Considerations++
+		}
+		if height >= selfTestMaturity {
+			expect[pubKeyString(viewpoints[height-selfTestMaturity].For)]++
+			expectStats.ViewpointsMatured++
+		}
+	}
+	for pk, imbalance := range expect {
+		if imbalance == 0 {
+			delete(expect, pk)
+		}
+	}
+	expectStats.ActiveKeys = int64(len(expect))
+
+	for i, n := range t.nodes {
+		found := make(map[string]int64)
+		_, _, err := n.ledger.ForEachPublicKeyImbalance(func(pubKey ed25519.PublicKey, imbalance int64) error {
+			if imbalance != 0 {
+				found[pubKeyString(pubKey)] = imbalance
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(found) != len(expect) {
+			return fmt.Errorf("Node %d has %d public key imbalances, expected %d", i+1, len(found), len(expect))
+		}
+		for pk, imbalance := range expect {
+			if found[pk] != imbalance {
+				return fmt.Errorf("Node %d has imbalance %d for %s, expected %d",
+					i+1, found[pk], pk, imbalance)
+			}
+		}
+		stats, err := n.ledger.GetSupplyStats(tipHeight)
+		if err != nil {
+			return err
+		}
+		if stats == nil || *stats != expectStats {
+			return fmt.Errorf("Node %d has totals %+v, expected %+v", i+1, stats, expectStats)
+		}
+	}
+	return nil
+}
+
+// Make sure each node's graph reflects the confirmed considerations and agrees with a graph built from
+// scratch and with the other node's. Keys only seen in disconnected views stay in the graph without
+// weight, so after the reorganization rankings are only expected to match between the nodes
+func (t *selfTest) checkIndexers() error {
+	tipID, _, err := t.nodes[0].ledger.GetPointTip()
+	if err != nil {
+		return err
+	}
+	for i, n := range t.nodes {
+		indexer := n.indexer
+		err := waitFor(fmt.Sprintf("node %d's indexer to rank the tip", i+1), func() (bool, error) {
+			return indexer.GraphSnapshot().ViewID == *tipID, nil
+		})
+		if err != nil {
+			return err
+		}
+		snapshot := indexer.GraphSnapshot()
+		for _, cnID := range t.cnIDs {
+			viewID, index, err := n.ledger.GetConsiderationIndex(cnID)
+			if err != nil {
+				return err
+			}
+			if viewID == nil {
+				return fmt.Errorf("Consideration %s isn't confirmed on node %d", cnID, i+1)
+			}
+			cn, _, err := n.viewStore.GetConsideration(*viewID, index)
+			if err != nil {
+				return err
+			}
+			if cn == nil || !snapshot.IsParentDescendant(cn.By, cn.For) {
+				return fmt.Errorf("Node %d's graph is missing consideration %s", i+1, cnID)
+			}
+		}
+
+		fresh := NewIndexer(NewGraph(), n.viewStore, n.ledger, n.processor, t.genesisID)
+		fresh.Run()
+		err = waitFor(fmt.Sprintf("a new indexer for node %d to rank the tip", i+1), func() (bool, error) {
+			return fresh.GraphSnapshot().ViewID == *tipID, nil
+		})
+		if err == nil {
+			err = compareGraphSnapshots(snapshot, fresh.GraphSnapshot(), t.pubKeys, false)
+		}
+		fresh.Shutdown()
+		if err != nil {
+			return fmt.Errorf("Node %d's indexer doesn't match a new one: %s", i+1, err)
+		}
+	}
+	return compareGraphSnapshots(t.nodes[0].indexer.GraphSnapshot(), t.nodes[1].indexer.GraphSnapshot(),
+		t.pubKeys, true)
+}
+
+// Returns an error if the consideration isn't confirmed in the view on every node
+func (t *selfTest) checkConfirmed(cnID ConsiderationID, viewID ViewID) error {
+	for i, n := range t.nodes {
+		id, _, err := n.ledger.GetConsiderationIndex(cnID)
+		if err != nil {
+			return err
+		}
+		if id == nil || *id != viewID {
+			return fmt.Errorf("Consideration %s isn't confirmed in view %s on node %d", cnID, viewID, i+1)
+		}
+	}
+	return nil
+}
+
+// Wait for every node to have the same tip
+func (t *selfTest) waitForSync() error {
+	return waitFor("the nodes to agree on the tip", func() (bool, error) {
+		var tipIDs []ViewID
+		for _, n := range t.nodes {
+			tipID, _, err := n.ledger.GetPointTip()
+			if err != nil {
+				return false, err
+			}
+			if tipID == nil {
+				return false, nil
+			}
+			tipIDs = append(tipIDs, *tipID)
+		}
+		for _, tipID := range tipIDs[1:] {
+			if tipID != tipIDs[0] {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+func (t *selfTest) shutdown() {
+	for _, mind := range t.minds {
+		if err := mind.Shutdown(); err != nil {
+			log.Println(err)
+		}
+	}
+	for _, n := range t.nodes {
+		n.shutdown()
+	}
+}
+
+// Render a view on the node's tip with whatever's queued
+func (n *selfTestNode) renderTip(pubKey ed25519.PublicKey) (ViewID, error) {
+	tipID, tipHeader, _, err := n.tipHeader()
+	if err != nil {
+		return ViewID{}, err
+	}
+	cns := n.cnQueue.Get(MAX_CONSIDERATIONS_TO_INCLUDE_PER_VIEW - 1)
+	id, _, err := n.renderView(*tipID, tipHeader, pubKey, cns)
+	return id, err
+}
+
+// Return the node's main point tip
+func (n *selfTestNode) tipHeader() (*ViewID, *ViewHeader, int64, error) {
+	tipID, _, err := n.ledger.GetPointTip()
+	if err != nil || tipID == nil {
+		return nil, nil, 0, err
+	}
+	tipHeader, tipWhen, err := n.viewStore.GetViewHeader(*tipID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return tipID, tipHeader, tipWhen, nil
+}
+
+// Render a view following the given one and process it
+func (n *selfTestNode) renderView(previousID ViewID, previous *ViewHeader, pubKey ed25519.PublicKey,
+	cns []*Consideration) (ViewID, *View, error) {
+	viewpoint := NewConsideration(nil, pubKey, 0, 0, previous.Height+1, "")
+	target, err := ComputeTarget(previous, n.viewStore, n.ledger)
+	if err != nil {
+		return ViewID{}, nil, err
+	}
+	view, err := NewView(previousID, previous.Height+1, target, previous.PointWork,
+		append([]*Consideration{viewpoint}, cns...))
+	if err != nil {
+		return ViewID{}, nil, err
+	}
+	medianTimestamp, err := ComputeMedianTimestamp(previous, n.viewStore)
+	if err != nil {
+		return ViewID{}, nil, err
+	}
+	if view.Header.Time <= medianTimestamp {
+		view.Header.Time = medianTimestamp + 1
+	}
+	id, err := solveView(view)
+	if err != nil {
+		return ViewID{}, nil, err
+	}
+	if err := n.processor.ProcessView(id, view, "localhost"); err != nil {
+		return ViewID{}, nil, err
+	}
+	return id, view, nil
+}
+
+func (n *selfTestNode) shutdown() {
+	if n.peerManager != nil {
+		n.peerManager.Shutdown()
+	}
+	if n.processor != nil {
+		n.processor.Shutdown()
+	}
+	if n.indexer != nil {
+		n.indexer.Shutdown()
+	}
+	if n.peerStore != nil {
+		if err := n.peerStore.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+	if n.ledger != nil {
+		if err := n.ledger.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+	if n.viewStore != nil {
+		if err := n.viewStore.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Returns an error if the snapshots are of different views or disagree about how the keys in both
+// descend from each other or, if rankings is true, about the keys' rankings
+func compareGraphSnapshots(a, b *GraphSnapshot, pubKeys []ed25519.PublicKey, rankings bool) error {
+	if a.ViewID != b.ViewID {
+		return fmt.Errorf("Graphs are of different views %s and %s", a.ViewID, b.ViewID)
+	}
+	var inBoth []ed25519.PublicKey
+	for _, pubKey := range pubKeys {
+		rankingA, okA := a.Ranking(pubKey)
+		rankingB, okB := b.Ranking(pubKey)
+		if rankings && (okA != okB || math.Abs(rankingA-rankingB) > 1e-9) {
+			return fmt.Errorf("Rankings of %s differ: %f and %f",
+				base64.StdEncoding.EncodeToString(pubKey), rankingA, rankingB)
+		}
+		if okA && okB {
+			inBoth = append(inBoth, pubKey)
+		}
+	}
+	for _, parent := range inBoth {
+		for _, descendant := range inBoth {
+			if a.IsParentDescendant(parent, descendant) != b.IsParentDescendant(parent, descendant) {
+				return fmt.Errorf("Graphs disagree whether %s descends from %s",
+					base64.StdEncoding.EncodeToString(descendant), base64.StdEncoding.EncodeToString(parent))
+			}
+		}
+	}
+	return nil
+}
+
+// Search for a nonce which satisfies the view's target. The self test network's target is easy
+func solveView(view *View) (ViewID, error) {
+	for {
+		id, err := view.ID()
+		if err != nil {
+			return ViewID{}, err
+		}
+		if view.CheckPOW(id) {
+			return id, nil
+		}
+		view.Header.Nonce++
+		if view.Header.Nonce > MAX_NUMBER {
+			view.Header.Nonce = 0
+		}
+	}
+}
+
+// Poll until done returns true, giving up after selfTestWait
+func waitFor(what string, done func() (bool, error)) error {
+	deadline := time.Now().Add(selfTestWait)
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out waiting for %s", what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Return the number of outbound and inbound peers the node has
+func peerCounts(peerManager *PeerManager) (outbound, inbound int) {
+	for _, stats := range peerManager.PeerStats() {
+		if stats.Outbound {
+			outbound++
+		} else {
+			inbound++
+		}
+	}
+	return
+}
+
+// Encode a public key to compare imbalances by
+func pubKeyString(pubKey ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pubKey)
+}
+
+// Wait for a node to accept connections. It only starts listening once it's generated a certificate
+func waitForListener(port int) error {
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+	return waitFor("a node to listen on "+addr, func() (bool, error) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	})
+}
+
+// Returns a port nothing is listening on
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,103 @@
+package focalpoint
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SideBranch describes a branch of views off of the main point ending at a side tip.
+type SideBranch struct {
+	TipID      ViewID      `json:"tip_id"`
+	TipHeader  *ViewHeader `json:"tip_header"`
+	ForkHeight int64       `json:"fork_height"` // height of the main point view it branches from, -1 if unknown
+	Length     int64       `json:"length"`      // number of views on the branch
+}
+
+// GetSideBranches returns every side branch, most work first. A side tip is a side view
+// which no other side view builds on.
+func GetSideBranches(ledger Ledger, viewStore ViewStorage) ([]SideBranch, error) {
+	ids, err := ledger.GetBranchViews(SIDE)
+	if err != nil {
+		return nil, err
+	}
+
+	// find the side views something else on a side branch builds on
+	headers := make(map[ViewID]*ViewHeader)
+	hasChild := make(map[ViewID]bool)
+	for _, id := range ids {
+		header, _, err := viewStore.GetViewHeader(id)
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			return nil, fmt.Errorf("Missing header for side view %s", id)
+		}
+		headers[id] = header
+		hasChild[header.Previous] = true
+	}
+
+	var branches []SideBranch
+	for _, id := range ids {
+		if hasChild[id] {
+			continue
+		}
+		branchIDs, _, err := GetBranchHeaders(id, ledger, viewStore)
+		if err != nil {
+			return nil, err
+		}
+		tipHeader := headers[id]
+		forkHeight := tipHeader.Height - int64(len(branchIDs))
+		if forkHeight >= 0 {
+			// make sure we actually reached the main point
+			branchType, err := ledger.GetBranchType(headers[branchIDs[len(branchIDs)-1]].Previous)
+			if err != nil {
+				return nil, err
+			}
+			if branchType != MAIN {
+				forkHeight = -1
+			}
+		}
+		branches = append(branches, SideBranch{
+			TipID:      id,
+			TipHeader:  tipHeader,
+			ForkHeight: forkHeight,
+			Length:     int64(len(branchIDs)),
+		})
+	}
+
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].TipHeader.PointWork.GetBigInt().Cmp(
+			branches[j].TipHeader.PointWork.GetBigInt()) > 0
+	})
+	return branches, nil
+}
+
+// GetBranchHeaders returns the views of the branch ending at the given view, starting with
+// that view and walking back until reaching the main point or a view we don't have.
+func GetBranchHeaders(id ViewID, ledger Ledger, viewStore ViewStorage) ([]ViewID, []*ViewHeader, error) {
+	var ids []ViewID
+	var headers []*ViewHeader
+	for {
+		branchType, err := ledger.GetBranchType(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if branchType == MAIN {
+			break
+		}
+		header, _, err := viewStore.GetViewHeader(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if header == nil {
+			break
+		}
+		ids = append(ids, id)
+		headers = append(headers, header)
+		if header.Height == 0 {
+			break
+		}
+		id = header.Previous
+	}
+	return ids, headers, nil
+}
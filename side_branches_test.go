@@ -0,0 +1,88 @@
+package focalpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestGetSideBranches(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "branches")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	ledger, err := NewLedgerDisk(filepath.Join(dir, "ledger.db"), false, false, viewStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	store := func(previous ViewID, height, nonce int64) (ViewID, *View) {
+		viewpoint := NewConsideration(nil, pubKey, 0, 0, height, "")
+		view, err := NewView(previous, height, ViewID{}, ViewID{}, []*Consideration{viewpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		view.Header.Nonce = nonce
+		id, err := view.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := viewStore.Store(id, view, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+		return id, view
+	}
+
+	// genesis and a main point view on top of it
+	genesisID, genesis := store(ViewID{}, 0, 0)
+	if _, err := ledger.ConnectView(genesisID, genesis); err != nil {
+		t.Fatal(err)
+	}
+	mainID, main := store(genesisID, 1, 1)
+	if _, err := ledger.ConnectView(mainID, main); err != nil {
+		t.Fatal(err)
+	}
+
+	// a two view side branch off of genesis
+	side1ID, _ := store(genesisID, 1, 2)
+	side2ID, _ := store(side1ID, 2, 3)
+	for _, id := range []ViewID{side1ID, side2ID} {
+		if err := ledger.SetBranchType(id, SIDE); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	branches, err := GetSideBranches(ledger, viewStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 1 {
+		t.Fatalf("Expected 1 side branch, found %d", len(branches))
+	}
+	if branches[0].TipID != side2ID || branches[0].Length != 2 || branches[0].ForkHeight != 0 {
+		t.Fatalf("Unexpected side branch: %+v", branches[0])
+	}
+
+	ids, headers, err := GetBranchHeaders(side2ID, ledger, viewStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != side2ID || ids[1] != side1ID || headers[1].Height != 1 {
+		t.Fatal("Unexpected branch headers")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	. "github.com/inconsiderable/focal-point"
+	"github.com/inconsiderable/focal-point/internal/config"
+)
+
+// Generate consensus test vectors or re-validate previously generated ones
+func main() {
+	outPtr := flag.String("out", "", "Path to a file to write generated consensus test vectors")
+	checkPtr := flag.String("check", "", "Path to a file of consensus test vectors to re-validate")
+	cfg := config.Register(flag.CommandLine, config.Network|config.Logging)
+	flag.Parse()
+
+	if err := cfg.Apply(); err != nil {
+		log.Fatal(err)
+	}
+	if (len(*outPtr) == 0) == (len(*checkPtr) == 0) {
+		log.Fatal("Specify one of -out or -check")
+	}
+
+	network := "main"
+	if cfg.NetworkParams != nil {
+		network = cfg.NetworkParams.Name
+	}
+
+	if len(*outPtr) != 0 {
+		vectors, err := GenerateConsensusVectors(network)
+		if err != nil {
+			log.Fatal(err)
+		}
+		vectorsJson, err := json.MarshalIndent(vectors, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(*outPtr, append(vectorsJson, '\n'), 0644); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d consensus test vectors for network %s written to '%s'\n",
+			len(vectors.Vectors), network, *outPtr)
+		return
+	}
+
+	vectorsJson, err := ioutil.ReadFile(*checkPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var vectors ConsensusVectors
+	if err := json.Unmarshal(vectorsJson, &vectors); err != nil {
+		log.Fatal(err)
+	}
+	if vectors.Network != network {
+		log.Fatalf("Vectors are for network %s but %s is active, pass -network\n", vectors.Network, network)
+	}
+	var failed int
+	for _, v := range vectors.Vectors {
+		if err := v.Check(); err != nil {
+			fmt.Println(err)
+			failed++
+		}
+	}
+	fmt.Printf("%d of %d consensus test vectors passed\n", len(vectors.Vectors)-failed, len(vectors.Vectors))
+	if failed != 0 {
+		os.Exit(1)
+	}
+}
@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/buger/jsonparser"
 	"github.com/pierrec/lz4"
@@ -69,16 +72,10 @@ func (b ViewStorageDisk) Store(id ViewID, view *View, now int64) error {
 	var ext string
 	if b.compress {
 		// compress with lz4
-		in := bytes.NewReader(viewBytes)
-		zout := new(bytes.Buffer)
-		zw := lz4.NewWriter(zout)
-		if _, err := io.Copy(zw, in); err != nil {
+		viewBytes, err = compressViewBytes(viewBytes)
+		if err != nil {
 			return err
 		}
-		if err := zw.Close(); err != nil {
-			return err
-		}
-		viewBytes = zout.Bytes()
 		ext = ".lz4"
 	} else {
 		ext = ".json"
@@ -86,21 +83,7 @@ func (b ViewStorageDisk) Store(id ViewID, view *View, now int64) error {
 
 	// write the view and sync
 	viewPath := filepath.Join(b.dirPath, id.String()+ext)
-	f, err := os.OpenFile(viewPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
-	}
-	n, err := f.Write(viewBytes)
-	if err != nil {
-		return err
-	}
-	if err == nil && n < len(viewBytes) {
-		return io.ErrShortWrite
-	}
-	if err := f.Sync(); err != nil {
-		return err
-	}
-	if err := f.Close(); err != nil {
+	if err := writeViewFile(viewPath, viewBytes); err != nil {
 		return err
 	}
 
@@ -159,13 +142,7 @@ func (b ViewStorageDisk) GetViewBytes(id ViewID) ([]byte, error) {
 
 	if compressed {
 		// uncompress
-		zin := bytes.NewBuffer(viewBytes)
-		out := new(bytes.Buffer)
-		zr := lz4.NewReader(zin)
-		if _, err := io.Copy(out, zr); err != nil {
-			return nil, err
-		}
-		viewBytes = out.Bytes()
+		return decompressViewBytes(viewBytes)
 	}
 
 	return viewBytes, nil
@@ -222,6 +199,166 @@ func (b *ViewStorageDisk) Close() error {
 	return b.db.Close()
 }
 
+// RecompressResult summarizes the outcome of a call to Recompress.
+type RecompressResult struct {
+	Converted   int   // views converted to the new format
+	Failed      int   // views left as they were because they couldn't be verified
+	BytesBefore int64 // size of the converted views before conversion
+	BytesAfter  int64 // size of the converted views after conversion
+}
+
+// Recompress converts every view on disk to the storage's compression setting. Each converted
+// view is decoded and its ID checked against its file name before the original is removed.
+// The client must not be running.
+func (b ViewStorageDisk) Recompress() (*RecompressResult, error) {
+	if b.readOnly {
+		return nil, fmt.Errorf("View storage is in read-only mode")
+	}
+
+	fromExt, toExt := ".lz4", ".json"
+	if b.compress {
+		fromExt, toExt = ".json", ".lz4"
+	}
+
+	files, err := ioutil.ReadDir(b.dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(RecompressResult)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || filepath.Ext(name) != fromExt {
+			continue
+		}
+		idStr := strings.TrimSuffix(name, fromExt)
+		idBytes, err := hex.DecodeString(idStr)
+		if err != nil || len(idBytes) != len(ViewID{}) {
+			// not a view
+			continue
+		}
+		var id ViewID
+		copy(id[:], idBytes)
+
+		fromPath := filepath.Join(b.dirPath, name)
+		toPath := filepath.Join(b.dirPath, idStr+toExt)
+		size, err := b.recompressView(id, fromPath, toPath)
+		if err != nil {
+			log.Printf("Unable to convert view %s: %s\n", id, err)
+			result.Failed++
+			continue
+		}
+		result.Converted++
+		result.BytesBefore += file.Size()
+		result.BytesAfter += size
+	}
+	return result, nil
+}
+
+// Convert a single view and return its new size on disk
+func (b ViewStorageDisk) recompressView(id ViewID, fromPath, toPath string) (int64, error) {
+	viewBytes, err := ioutil.ReadFile(fromPath)
+	if err != nil {
+		return 0, err
+	}
+	if !b.compress {
+		// we're converting from lz4
+		if viewBytes, err = decompressViewBytes(viewBytes); err != nil {
+			return 0, err
+		}
+	}
+	if err := checkViewBytes(id, viewBytes); err != nil {
+		return 0, err
+	}
+	if b.compress {
+		if viewBytes, err = compressViewBytes(viewBytes); err != nil {
+			return 0, err
+		}
+	}
+
+	// write it out and make sure it reads back correctly before removing the original
+	if err := writeViewFile(toPath, viewBytes); err != nil {
+		os.Remove(toPath)
+		return 0, err
+	}
+	checkBytes, err := ioutil.ReadFile(toPath)
+	if err == nil && b.compress {
+		checkBytes, err = decompressViewBytes(checkBytes)
+	}
+	if err == nil {
+		err = checkViewBytes(id, checkBytes)
+	}
+	if err != nil {
+		os.Remove(toPath)
+		return 0, err
+	}
+	if err := os.Remove(fromPath); err != nil {
+		return 0, err
+	}
+	return int64(len(viewBytes)), nil
+}
+
+// Check that view JSON decodes to a view with the given ID
+func checkViewBytes(id ViewID, viewJson []byte) error {
+	view := new(View)
+	if err := json.Unmarshal(viewJson, view); err != nil {
+		return err
+	}
+	viewID, err := view.ID()
+	if err != nil {
+		return err
+	}
+	if viewID != id {
+		return fmt.Errorf("View ID mismatch, found %s", viewID)
+	}
+	return nil
+}
+
+// Compress view JSON with lz4
+func compressViewBytes(viewBytes []byte) ([]byte, error) {
+	in := bytes.NewReader(viewBytes)
+	zout := new(bytes.Buffer)
+	zw := lz4.NewWriter(zout)
+	if _, err := io.Copy(zw, in); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return zout.Bytes(), nil
+}
+
+// Uncompress lz4 compressed view JSON
+func decompressViewBytes(viewBytes []byte) ([]byte, error) {
+	zin := bytes.NewBuffer(viewBytes)
+	out := new(bytes.Buffer)
+	zr := lz4.NewReader(zin)
+	if _, err := io.Copy(out, zr); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Write a view file and sync it
+func writeViewFile(viewPath string, viewBytes []byte) error {
+	f, err := os.OpenFile(viewPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := f.Write(viewBytes)
+	if err != nil {
+		return err
+	}
+	if err == nil && n < len(viewBytes) {
+		return io.ErrShortWrite
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
 // leveldb schema: {bid} -> {timestamp}{gob encoded header}
 
 func encodeViewHeader(header *ViewHeader, when int64) ([]byte, error) {
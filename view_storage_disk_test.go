@@ -2,6 +2,10 @@ package focalpoint
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"golang.org/x/crypto/ed25519"
@@ -49,3 +53,54 @@ func TestEncodeViewHeader(t *testing.T) {
 		t.Fatal("Decoded timestamp doesn't match original")
 	}
 }
+
+func TestViewStorageDiskRecompress(t *testing.T) {
+	view := new(View)
+	if err := json.Unmarshal([]byte(GenesisViewJson), view); err != nil {
+		t.Fatal(err)
+	}
+	id, err := view.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// store it uncompressed
+	viewStore, err := NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := viewStore.Store(id, view, 0); err != nil {
+		t.Fatal(err)
+	}
+	viewStore.Close()
+
+	// convert it to lz4
+	viewStore, err = NewViewStorageDisk(filepath.Join(dir, "views"), filepath.Join(dir, "headers.db"), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewStore.Close()
+	result, err := viewStore.Recompress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Converted != 1 || result.Failed != 0 {
+		t.Fatalf("Expected 1 view converted, found %d converted and %d failed", result.Converted, result.Failed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "views", id.String()+".json")); !os.IsNotExist(err) {
+		t.Fatal("Expected original view to be removed")
+	}
+	view2, err := viewStore.GetView(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view2 == nil {
+		t.Fatal("View missing after conversion")
+	}
+}
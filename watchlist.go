@@ -0,0 +1,232 @@
+package focalpoint
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Watchlist reports activity involving public keys the node operator is interested in, regardless
+// of any mind's filter. Newly queued and confirmed considerations and ranking changes are logged,
+// published to the event feed if there is one and posted to a webhook if one is configured.
+type Watchlist struct {
+	pubKeys      []ed25519.PublicKey
+	processor    *Processor
+	indexer      *Indexer   // rankings are watched if set
+	eventFeed    *EventFeed // optional
+	webhookURL   string     // optional
+	rankings     map[string]float64
+	snapshot     *GraphSnapshot // the graph snapshot rankings were last compared at
+	webhookChan  chan Message
+	shutdownChan chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewWatchlist returns a new Watchlist instance. Pass an indexer to watch the keys' rankings, an
+// event feed to publish to and a webhook URL to post each event to as JSON.
+func NewWatchlist(pubKeys []ed25519.PublicKey, processor *Processor, indexer *Indexer, eventFeed *EventFeed,
+	webhookURL string) *Watchlist {
+	return &Watchlist{
+		pubKeys:      pubKeys,
+		processor:    processor,
+		indexer:      indexer,
+		eventFeed:    eventFeed,
+		webhookURL:   webhookURL,
+		rankings:     make(map[string]float64),
+		webhookChan:  make(chan Message, 100),
+		shutdownChan: make(chan struct{}),
+	}
+}
+
+// Run executes the Watchlist's main loop in its own goroutine.
+func (w *Watchlist) Run() {
+	w.wg.Add(1)
+	go w.run()
+	if len(w.webhookURL) != 0 {
+		w.wg.Add(1)
+		go w.post()
+	}
+}
+
+func (w *Watchlist) run() {
+	defer w.wg.Done()
+
+	// register for new considerations
+	newTxChan := make(chan NewTx, 100)
+	w.processor.RegisterForNewConsiderations(newTxChan)
+	defer w.processor.UnregisterForNewConsiderations(newTxChan)
+
+	// register for tip changes
+	tipChangeChan := make(chan TipChange, 10)
+	w.processor.RegisterForTipChange(tipChangeChan)
+	defer func() { w.processor.UnregisterForTipChange(tipChangeChan) }()
+
+	for {
+		select {
+		case newTx := <-newTxChan:
+			for _, pubKey := range w.involved(newTx.Consideration) {
+				w.notify("watch_consideration_queued", WatchConsiderationEvent{
+					PublicKey:       pubKey,
+					ConsiderationID: newTx.ConsiderationID,
+					Consideration:   newTx.Consideration,
+				})
+			}
+
+		case tip, ok := <-tipChangeChan:
+			if !ok {
+				// cut off for falling behind. watched activity in between goes unreported
+				log.Println("Watchlist missed tip changes")
+				tipChangeChan = make(chan TipChange, 10)
+				w.processor.RegisterForTipChange(tipChangeChan)
+				break
+			}
+			w.onTipChange(tip)
+
+		case _, ok := <-w.shutdownChan:
+			if !ok {
+				log.Println("Watchlist shutting down...")
+				return
+			}
+		}
+	}
+}
+
+// Report watched considerations in the view and any ranking changes
+func (w *Watchlist) onTipChange(tip TipChange) {
+	eventType := "watch_consideration_confirmed"
+	if !tip.Connect {
+		eventType = "watch_consideration_unconfirmed"
+	}
+	for _, cn := range tip.View.Considerations {
+		pubKeys := w.involved(cn)
+		if len(pubKeys) == 0 {
+			continue
+		}
+		cnID, err := cn.ID()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, pubKey := range pubKeys {
+			w.notify(eventType, WatchConsiderationEvent{
+				PublicKey:       pubKey,
+				ConsiderationID: cnID,
+				Consideration:   cn,
+				ViewID:          &tip.ViewID,
+				Height:          tip.View.Header.Height,
+			})
+		}
+	}
+
+	if w.indexer == nil || !tip.Connect || tip.More {
+		return
+	}
+
+	// the indexer ranks the graph on its own time. compare every ranking once per rank pass from
+	// the same snapshot and report changes as of the view it was taken at
+	snapshot := w.indexer.GraphSnapshot()
+	if snapshot == nil || snapshot == w.snapshot {
+		return
+	}
+	w.snapshot = snapshot
+	for _, pubKey := range w.pubKeys {
+		pk := pubKeyToString(pubKey)
+		ranking, _ := snapshot.Ranking(pubKey)
+		previous, ok := w.rankings[pk]
+		w.rankings[pk] = ranking
+		if !ok || ranking == previous {
+			// first look or no change
+			continue
+		}
+		w.notify("watch_ranking_changed", WatchRankingEvent{
+			PublicKey: pubKey,
+			Ranking:   ranking,
+			Previous:  previous,
+			ViewID:    snapshot.ViewID,
+			Height:    snapshot.Height,
+		})
+	}
+}
+
+// Returns the watched public keys the consideration involves
+func (w *Watchlist) involved(cn *Consideration) []ed25519.PublicKey {
+	var pubKeys []ed25519.PublicKey
+	for _, pubKey := range w.pubKeys {
+		if cn.Contains(pubKey) {
+			pubKeys = append(pubKeys, pubKey)
+		}
+	}
+	return pubKeys
+}
+
+// Log the event, publish it and queue it for the webhook
+func (w *Watchlist) notify(eventType string, body interface{}) {
+	switch event := body.(type) {
+	case WatchConsiderationEvent:
+		log.Printf("Watchlist: %s, public key: %s, consideration: %s\n", eventType,
+			base64.StdEncoding.EncodeToString(event.PublicKey), event.ConsiderationID)
+	case WatchRankingEvent:
+		log.Printf("Watchlist: %s, public key: %s, ranking: %f -> %f\n", eventType,
+			base64.StdEncoding.EncodeToString(event.PublicKey), event.Previous, event.Ranking)
+	}
+
+	if w.eventFeed != nil {
+		w.eventFeed.Publish(eventType, body)
+	}
+	if len(w.webhookURL) != 0 {
+		select {
+		case w.webhookChan <- Message{Type: eventType, Body: body}:
+		default:
+			log.Printf("Watchlist webhook queue is full, dropping %s event\n", eventType)
+		}
+	}
+}
+
+// Post queued events to the webhook
+func (w *Watchlist) post() {
+	defer w.wg.Done()
+	client := &http.Client{Timeout: 10 * time.Second}
+	for {
+		select {
+		case m := <-w.webhookChan:
+			if err := w.postMessage(client, m); err != nil {
+				log.Printf("Error posting %s event to watchlist webhook: %s\n", m.Type, err)
+			}
+
+		case _, ok := <-w.shutdownChan:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+func (w *Watchlist) postMessage(client *http.Client, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(w.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown stops the watchlist synchronously.
+func (w *Watchlist) Shutdown() {
+	close(w.shutdownChan)
+	w.wg.Wait()
+	log.Println("Watchlist shutdown")
+}
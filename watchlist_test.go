@@ -0,0 +1,57 @@
+package focalpoint
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestWatchlistRankingFromSnapshot(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := pubKeyToString(pubKey)
+	g := NewGraph()
+	g.Link("0", pk, 1)
+	g.Rank(1.0, 1e-6)
+	idx := NewIndexer(g, nil, nil, nil, ViewID{})
+	w := NewWatchlist([]ed25519.PublicKey{pubKey}, nil, idx, nil, "")
+
+	tip := TipChange{View: &View{Header: &ViewHeader{Height: 1}}, Connect: true}
+	w.onTipChange(tip)
+	snapshot := idx.GraphSnapshot()
+	ranking, _ := snapshot.Ranking(pubKey)
+	if w.rankings[pk] != ranking {
+		t.Fatalf("Expected ranking %f, found %f", ranking, w.rankings[pk])
+	}
+	if w.snapshot != snapshot {
+		t.Fatal("Expected the rankings to be compared at the latest snapshot")
+	}
+
+	// nothing is compared again until there's a new snapshot
+	w.rankings[pk] = -1
+	w.onTipChange(tip)
+	if w.rankings[pk] != -1 {
+		t.Fatal("Expected the rankings not to be compared twice at the same snapshot")
+	}
+	w.rankings[pk] = ranking
+
+	// the indexer links and ranks on its own goroutine. rankings must only be read from the
+	// snapshot, this races under -race otherwise
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			g.Link(pk, padTo44Characters("a"), 1)
+			g.Rank(1.0, 1e-6)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		w.onTipChange(tip)
+	}
+	<-done
+	if w.rankings[pk] != ranking {
+		t.Fatal("Expected the ranking as of the last snapshot")
+	}
+}